@@ -2,7 +2,10 @@ package client
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,7 +21,10 @@ import (
 	"strings"
 
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/autogen/dockerversion"
+	"github.com/docker/docker/builder/session"
 	"github.com/docker/docker/graph/tags"
+	"github.com/docker/docker/opts"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/jsonmessage"
@@ -59,6 +65,11 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	flCPUSetCpus := cmd.String([]string{"-cpuset-cpus"}, "", "CPUs in which to allow execution (0-3, 0,1)")
 	flCPUSetMems := cmd.String([]string{"-cpuset-mems"}, "", "MEMs in which to allow execution (0-3, 0,1)")
 	flCgroupParent := cmd.String([]string{"-cgroup-parent"}, "", "Optional parent cgroup for the container")
+	flBuildArg := opts.NewListOpts(validateBuildArg)
+	cmd.Var(&flBuildArg, []string{"-build-arg"}, "Set build-time variables")
+	stream := cmd.Bool([]string{"-stream"}, false, "Send the files of the context as the build needs them")
+	squash := cmd.Bool([]string{"-squash"}, false, "Squash the layers created by the build into one layer")
+	addTrustedFlags(cmd, true)
 
 	cmd.Require(flag.Exact, 1)
 	cmd.ParseFlags(args, true)
@@ -67,10 +78,20 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		context  archive.Archive
 		isRemote bool
 		err      error
+
+		// the local context directory served to the daemon with --stream
+		streamRoot     string
+		streamExcludes []string
+
+		// the Dockerfile whose base images are resolved with content trust
+		trustedDockerfile []byte
 	)
 
 	_, err = exec.LookPath("git")
 	hasGit := err == nil
+	if *stream && (cmd.Arg(0) == "-" || (urlutil.IsURL(cmd.Arg(0)) && (!urlutil.IsGitURL(cmd.Arg(0)) || !hasGit))) {
+		return fmt.Errorf("--stream requires a local build context directory")
+	}
 	if cmd.Arg(0) == "-" {
 		// As a special case, 'docker build -' will build from either an empty context with the
 		// contents of stdin as a Dockerfile, or a tar-ed context from stdin.
@@ -89,6 +110,7 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 			// so just use our default Dockerfile name
 			*dockerfileName = api.DefaultDockerfileName
 			context, err = archive.Generate(*dockerfileName, string(dockerfile))
+			trustedDockerfile = dockerfile
 		} else {
 			context = ioutil.NopCloser(buf)
 		}
@@ -155,6 +177,11 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		if _, err = os.Lstat(filename); os.IsNotExist(err) {
 			return fmt.Errorf("Cannot locate Dockerfile: %s", origDockerfile)
 		}
+		if isTrusted() {
+			if trustedDockerfile, err = ioutil.ReadFile(filename); err != nil {
+				return err
+			}
+		}
 		var includes = []string{"."}
 
 		excludes, err := utils.ReadDockerIgnore(path.Join(root, ".dockerignore"))
@@ -174,17 +201,23 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 			includes = append(includes, ".dockerignore", *dockerfileName)
 		}
 
-		if err := utils.ValidateContextDirectory(root, excludes); err != nil {
-			return fmt.Errorf("Error checking context is accessible: '%s'. Please check permissions and try again.", err)
-		}
-		options := &archive.TarOptions{
-			Compression:     archive.Uncompressed,
-			ExcludePatterns: excludes,
-			IncludeFiles:    includes,
-		}
-		context, err = archive.TarWithOptions(root, options)
-		if err != nil {
-			return err
+		if *stream {
+			// the daemon requests the files it needs during the build
+			streamRoot = absRoot
+			streamExcludes = excludes
+		} else {
+			if err := utils.ValidateContextDirectory(root, excludes); err != nil {
+				return fmt.Errorf("Error checking context is accessible: '%s'. Please check permissions and try again.", err)
+			}
+			options := &archive.TarOptions{
+				Compression:     archive.Uncompressed,
+				ExcludePatterns: excludes,
+				IncludeFiles:    includes,
+			}
+			context, err = archive.TarWithOptions(root, options)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
@@ -271,6 +304,10 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		v.Set("pull", "1")
 	}
 
+	if *squash {
+		v.Set("squash", "1")
+	}
+
 	v.Set("cpusetcpus", *flCPUSetCpus)
 	v.Set("cpusetmems", *flCPUSetMems)
 	v.Set("cpushares", strconv.FormatInt(*flCPUShares, 10))
@@ -282,23 +319,60 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 
 	v.Set("dockerfile", *dockerfileName)
 
+	buildArgs := map[string]string{}
+	for _, arg := range flBuildArg.GetAll() {
+		parts := strings.SplitN(arg, "=", 2)
+		buildArgs[parts[0]] = parts[1]
+	}
+	buildArgsJSON, err := json.Marshal(buildArgs)
+	if err != nil {
+		return err
+	}
+	v.Set("buildargs", string(buildArgsJSON))
+
+	if isTrusted() {
+		// the daemon refuses the base images which are not resolved,
+		// when the Dockerfile is not available to the client
+		trustedRefs := map[string]string{}
+		if trustedDockerfile != nil {
+			if trustedRefs, err = cli.trustedBuildRefs(bytes.NewReader(trustedDockerfile)); err != nil {
+				return err
+			}
+		}
+		trustedRefsJSON, err := json.Marshal(trustedRefs)
+		if err != nil {
+			return err
+		}
+		v.Set("trustedrefs", string(trustedRefsJSON))
+	}
+
 	headers := http.Header(make(map[string][]string))
-	buf, err := json.Marshal(cli.configFile.AuthConfigs)
+	authConfigs, err := cli.getAllCredentials()
+	if err != nil {
+		return err
+	}
+	buf, err := json.Marshal(authConfigs)
 	if err != nil {
 		return err
 	}
 	headers.Add("X-Registry-Config", base64.URLEncoding.EncodeToString(buf))
 
-	if context != nil {
-		headers.Set("Content-Type", "application/tar")
-	}
-	sopts := &streamOpts{
-		rawTerminal: true,
-		in:          body,
-		out:         cli.out,
-		headers:     headers,
+	if streamRoot != "" {
+		v.Set("stream", "1")
+		v.Set("contextid", contextID(streamRoot))
+		err = cli.streamBuild(v, headers, streamRoot, streamExcludes, *dockerfileName)
+	} else {
+		if context != nil {
+			headers.Set("Content-Type", "application/tar")
+		}
+		sopts := &streamOpts{
+			rawTerminal: true,
+			in:          body,
+			out:         cli.out,
+			headers:     headers,
+		}
+		err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), sopts)
 	}
-	err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), sopts)
 	if jerr, ok := err.(*jsonmessage.JSONError); ok {
 		// If no error code is set, default to 1
 		if jerr.Code == 0 {
@@ -308,3 +382,53 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	}
 	return err
 }
+
+// validateBuildArg gives the build-time variables set without a value the
+// value of the variable in the client's environment, empty if it is not set.
+func validateBuildArg(val string) (string, error) {
+	if !strings.Contains(val, "=") {
+		return val + "=" + os.Getenv(val), nil
+	}
+	return val, nil
+}
+
+// streamBuild runs a build whose context is streamed: the daemon requests the
+// files of the context at root on the hijacked connection when it needs them.
+func (cli *DockerCli) streamBuild(v *url.Values, headers http.Header, root string, excludes []string, dockerfileName string) error {
+	req, err := http.NewRequest("POST", fmt.Sprintf("/v%s/build?%s", api.APIVERSION, v.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	for k, v := range cli.configFile.HttpHeaders {
+		req.Header.Set(k, v)
+	}
+	for k, v := range headers {
+		req.Header[k] = v
+	}
+	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
+
+	conn, br, err := cli.dialHijack(req)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the Dockerfile and .dockerignore are always sent, the daemon removes
+	// them from the context if they are excluded
+	handler := session.ServeContext(root, excludes, dockerfileName, ".dockerignore", api.DefaultDockerfileName, strings.ToLower(api.DefaultDockerfileName))
+
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		pw.CloseWithError(session.Serve(br, conn, pw, handler))
+	}()
+	return jsonmessage.DisplayJSONMessagesStream(pr, cli.out, cli.outFd, cli.isTerminalOut, nil)
+}
+
+// contextID returns the ID of the context at root, which the daemon uses to
+// keep the files it received between the builds of the same context.
+func contextID(root string) string {
+	hostname, _ := os.Hostname()
+	h := sha256.Sum256([]byte(hostname + ":" + root))
+	return hex.EncodeToString(h[:])
+}
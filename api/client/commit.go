@@ -23,6 +23,7 @@ func (cli *DockerCli) CmdCommit(args ...string) error {
 	flAuthor := cmd.String([]string{"a", "#author", "-author"}, "", "Author (e.g., \"John Hannibal Smith <hannibal@a-team.com>\")")
 	flChanges := opts.NewListOpts(nil)
 	cmd.Var(&flChanges, []string{"c", "-change"}, "Apply Dockerfile instruction to the created image")
+	flSquash := cmd.Bool([]string{"-squash"}, false, "Squash the layers of the image into one layer")
 	// FIXME: --run is deprecated, it will be replaced with inline Dockerfile commands.
 	flConfig := cmd.String([]string{"#run", "#-run"}, "", "This option is deprecated and will be removed in a future version in favor of inline Dockerfile-compatible commands")
 	cmd.Require(flag.Max, 2)
@@ -55,6 +56,10 @@ func (cli *DockerCli) CmdCommit(args ...string) error {
 		v.Set("pause", "0")
 	}
 
+	if *flSquash {
+		v.Set("squash", "1")
+	}
+
 	var (
 		config   *runconfig.Config
 		response types.ContainerCommitResponse
@@ -1,8 +1,15 @@
 package client
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
 	"strings"
 
 	"github.com/docker/docker/api/types"
@@ -10,48 +17,198 @@ import (
 	flag "github.com/docker/docker/pkg/mflag"
 )
 
-// CmdCp copies files/folders from a path on the container to a directory on the host running the command.
+// CmdCp copies files/folders between a container and the local filesystem.
 //
-// If HOSTDIR is '-', the data is written as a tar file to STDOUT.
+// A destination which is an existing directory receives the source with its
+// name, otherwise the source is copied to the destination path. If the local
+// path is '-', the data is read from STDIN or written to STDOUT as a tar
+// archive.
 //
-// Usage: docker cp CONTAINER:PATH HOSTDIR
+// Usage: docker cp CONTAINER:SRC_PATH DEST_PATH|-
+//        docker cp SRC_PATH|- CONTAINER:DEST_PATH
 func (cli *DockerCli) CmdCp(args ...string) error {
-	cmd := cli.Subcmd("cp", "CONTAINER:PATH HOSTDIR|-", "Copy files/folders from a PATH on the container to a HOSTDIR on the host\nrunning the command. Use '-' to write the data as a tar file to STDOUT.", true)
+	cmd := cli.Subcmd("cp", "CONTAINER:SRC_PATH DEST_PATH|-\n       docker cp SRC_PATH|- CONTAINER:DEST_PATH", "Copy files/folders between a container and the local filesystem.\nUse '-' as the local path to read a tar archive from STDIN or to\nwrite it to STDOUT.", true)
 	cmd.Require(flag.Exact, 2)
 
 	cmd.ParseFlags(args, true)
 
-	// deal with path name with `:`
-	info := strings.SplitN(cmd.Arg(0), ":", 2)
-
-	if len(info) != 2 {
+	srcContainer, srcPath := splitCpArg(cmd.Arg(0))
+	dstContainer, dstPath := splitCpArg(cmd.Arg(1))
+	if srcPath == "" || dstPath == "" {
 		return fmt.Errorf("Error: Path not specified")
 	}
 
-	cfg := &types.CopyConfig{
-		Resource: info[1],
+	switch {
+	case srcContainer != "" && dstContainer != "":
+		return fmt.Errorf("Error: copying between containers is not supported")
+	case srcContainer != "":
+		return cli.copyFromContainer(srcContainer, srcPath, dstPath)
+	case dstContainer != "":
+		return cli.copyToContainer(srcPath, dstContainer, dstPath)
+	}
+	return fmt.Errorf("Error: must specify a container in the source or the destination")
+}
+
+// splitCpArg splits an argument of docker cp into a container name and a
+// path in it, the container name being empty for a local path. A local path
+// which is absolute or starts with '.' is not split even if it contains ':'.
+func splitCpArg(arg string) (containerName, argPath string) {
+	if filepath.IsAbs(arg) || strings.HasPrefix(arg, ".") {
+		return "", arg
+	}
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) == 1 {
+		return "", arg
+	}
+	return parts[0], parts[1]
+}
+
+func archivePath(containerName, containerPath string, query url.Values) string {
+	query.Set("path", containerPath)
+	return fmt.Sprintf("/containers/%s/archive?%s", containerName, query.Encode())
+}
+
+// statContainerPath stats the given path in the container, following a
+// symbolic link at its last component. The returned stat is nil if the path
+// does not exist.
+func (cli *DockerCli) statContainerPath(containerName, containerPath string) (*types.ContainerPathStat, error) {
+	body, header, statusCode, err := cli.clientRequestHeader("HEAD", archivePath(containerName, containerPath, url.Values{}), nil, nil)
+	if body != nil {
+		body.Close()
 	}
-	stream, statusCode, err := cli.call("POST", "/containers/"+info[0]+"/copy", cfg, nil)
-	if stream != nil {
-		defer stream.Close()
+	if statusCode == http.StatusNotFound {
+		return nil, nil
 	}
-	if statusCode == 404 {
-		return fmt.Errorf("No such container: %v", info[0])
+	if err != nil {
+		return nil, err
 	}
+
+	stat, err := containerPathStatFromHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if stat.LinkTarget != "" {
+		return cli.statContainerPath(containerName, stat.LinkTarget)
+	}
+	return stat, nil
+}
+
+func containerPathStatFromHeader(header http.Header) (*types.ContainerPathStat, error) {
+	stat := &types.ContainerPathStat{}
+	statJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(header.Get("X-Docker-Container-Path-Stat")))
+	if err := json.NewDecoder(statJSON).Decode(stat); err != nil {
+		return nil, fmt.Errorf("Error decoding the stat of the container path: %v", err)
+	}
+	return stat, nil
+}
+
+func (cli *DockerCli) copyFromContainer(srcContainer, srcPath, dstPath string) error {
+	content, header, _, err := cli.clientRequestHeader("GET", archivePath(srcContainer, srcPath, url.Values{}), nil, nil)
 	if err != nil {
 		return err
 	}
+	defer content.Close()
 
-	hostPath := cmd.Arg(1)
-	if statusCode == 200 {
-		if hostPath == "-" {
-			_, err = io.Copy(cli.out, stream)
-		} else {
-			err = archive.Untar(stream, hostPath, &archive.TarOptions{NoLchown: true})
+	if dstPath == "-" {
+		_, err = io.Copy(cli.out, content)
+		return err
+	}
+
+	srcStat, err := containerPathStatFromHeader(header)
+	if err != nil {
+		return err
+	}
+	if srcStat.LinkTarget != "" {
+		// The archive is of the resource the symbolic link resolves to.
+		if srcStat, err = cli.statContainerPath(srcContainer, srcStat.LinkTarget); err != nil {
+			return err
 		}
+		if srcStat == nil {
+			return fmt.Errorf("Error: %s is a dangling symbolic link", srcPath)
+		}
+	}
+
+	options := &archive.TarOptions{NoLchown: true}
+	dstStat, err := os.Stat(dstPath)
+	switch {
+	case err == nil && dstStat.IsDir():
+		// Copy into the directory, the resource keeping its name.
+		return archive.Untar(content, dstPath, options)
+	case err == nil && srcStat.Mode.IsDir():
+		return fmt.Errorf("Error: cannot copy a directory to file %s", dstPath)
+	case err != nil && !os.IsNotExist(err):
+		return err
+	case err != nil && strings.HasSuffix(dstPath, string(os.PathSeparator)):
+		return fmt.Errorf("Error: destination directory %s does not exist", dstPath)
+	}
+
+	// Copy to the path, the resource being renamed after it.
+	dstDir, dstBase := archive.SplitPathDirEntry(dstPath)
+	if dirStat, err := os.Stat(dstDir); err != nil {
+		return err
+	} else if !dirStat.IsDir() {
+		return fmt.Errorf("Error: %s is not a directory", dstDir)
+	}
+	rebased := archive.RebaseArchiveEntries(content, srcStat.Name, dstBase)
+	defer rebased.Close()
+	return archive.Untar(rebased, dstDir, options)
+}
+
+func (cli *DockerCli) copyToContainer(srcPath, dstContainer, dstPath string) error {
+	dstStat, err := cli.statContainerPath(dstContainer, dstPath)
+	if err != nil {
+		return err
+	}
+
+	var (
+		content    io.Reader
+		extractDir = dstPath
+	)
+	if srcPath == "-" {
+		// The archive read from STDIN is extracted in the directory as is.
+		if dstStat == nil || !dstStat.Mode.IsDir() {
+			return fmt.Errorf("Error: destination %s must be an existing directory in container %s", dstPath, dstContainer)
+		}
+		content = cli.in
+	} else {
+		if srcPath, err = filepath.EvalSymlinks(srcPath); err != nil {
+			return err
+		}
+		srcStat, err := os.Stat(srcPath)
+		if err != nil {
+			return err
+		}
+		srcArchive, err := archive.TarResource(srcPath)
 		if err != nil {
 			return err
 		}
+		defer srcArchive.Close()
+		content = srcArchive
+
+		switch {
+		case dstStat != nil && dstStat.Mode.IsDir():
+			// Copy into the directory, the resource keeping its name.
+		case dstStat != nil && srcStat.IsDir():
+			return fmt.Errorf("Error: cannot copy a directory to file %s in container %s", dstPath, dstContainer)
+		case dstStat == nil && strings.HasSuffix(dstPath, "/"):
+			return fmt.Errorf("Error: destination directory %s does not exist in container %s", dstPath, dstContainer)
+		default:
+			// Copy to the path, the resource being renamed after it.
+			cleanedPath := path.Clean(dstPath)
+			extractDir = path.Dir(cleanedPath)
+			_, srcBase := archive.SplitPathDirEntry(srcPath)
+			rebased := archive.RebaseArchiveEntries(srcArchive, srcBase, path.Base(cleanedPath))
+			defer rebased.Close()
+			content = rebased
+		}
+	}
+
+	query := url.Values{}
+	query.Set("noOverwriteDirNonDir", "true")
+	headers := map[string][]string{"Content-Type": {"application/x-tar"}}
+	body, _, _, err := cli.clientRequest("PUT", archivePath(dstContainer, extractDir, query), content, headers)
+	if err != nil {
+		return err
 	}
-	return nil
+	return body.Close()
 }
@@ -1,7 +1,6 @@
 package client
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,6 +8,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/docker/distribution/digest"
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/graph/tags"
 	"github.com/docker/docker/pkg/parsers"
@@ -38,15 +38,16 @@ func (cli *DockerCli) pullImageCustomOut(image string, out io.Writer) error {
 	}
 
 	// Resolve the Auth config relevant for this server
-	authConfig := registry.ResolveAuthConfig(cli.configFile, repoInfo.Index)
-	buf, err := json.Marshal(authConfig)
+	authConfig, err := cli.resolveAuthConfig(repoInfo.Index)
 	if err != nil {
 		return err
 	}
-
-	registryAuthHeader := []string{
-		base64.URLEncoding.EncodeToString(buf),
+	encodedAuth, err := encodeAuthToBase64(authConfig)
+	if err != nil {
+		return err
 	}
+
+	registryAuthHeader := []string{encodedAuth}
 	sopts := &streamOpts{
 		rawTerminal: true,
 		out:         out,
@@ -83,6 +84,29 @@ func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runc
 		containerValues.Set("name", name)
 	}
 
+	// with content trust, the container is created from the image signed
+	// for the tag
+	var (
+		trustedRepoInfo *registry.RepositoryInfo
+		trustedTag      string
+		trustedDigest   digest.Digest
+	)
+	if repo, tag := parsers.ParseRepositoryTag(config.Image); isTrusted() && !utils.DigestReference(tag) {
+		if tag == "" {
+			tag = tags.DEFAULTTAG
+		}
+		repoInfo, err := registry.ParseRepositoryInfo(repo)
+		if err != nil {
+			return nil, err
+		}
+		dgst, err := cli.trustedReference(repoInfo, tag)
+		if err != nil {
+			return nil, err
+		}
+		config.Image = repo + "@" + dgst.String()
+		trustedRepoInfo, trustedTag, trustedDigest = repoInfo, tag, dgst
+	}
+
 	mergedConfig := runconfig.MergeConfigs(config, hostConfig)
 
 	var containerIDFile *cidFile
@@ -108,6 +132,11 @@ func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runc
 		if err = cli.pullImageCustomOut(config.Image, cli.err); err != nil {
 			return nil, err
 		}
+		if trustedRepoInfo != nil {
+			if err := cli.tagTrusted(trustedRepoInfo, trustedTag, trustedDigest, cli.err); err != nil {
+				return nil, err
+			}
+		}
 		// Retry
 		if stream, _, err = cli.call("POST", "/containers/create?"+containerValues.Encode(), mergedConfig, nil); err != nil {
 			return nil, err
@@ -141,6 +170,7 @@ func (cli *DockerCli) CmdCreate(args ...string) error {
 	var (
 		flName = cmd.String([]string{"-name"}, "", "Assign a name to the container")
 	)
+	addTrustedFlags(cmd, true)
 
 	config, hostConfig, cmd, err := runconfig.Parse(cmd, args)
 	if err != nil {
@@ -66,8 +66,8 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 	}
 
 	if info.IndexServerAddress != "" {
-		u := cli.configFile.AuthConfigs[info.IndexServerAddress].Username
-		if len(u) > 0 {
+		authConfig, _ := cli.credentialsStore(info.IndexServerAddress).Get(info.IndexServerAddress)
+		if u := authConfig.Username; len(u) > 0 {
 			fmt.Fprintf(cli.out, "Username: %v\n", u)
 			fmt.Fprintf(cli.out, "Registry: %v\n", info.IndexServerAddress)
 		}
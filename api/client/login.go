@@ -9,7 +9,6 @@ import (
 	"strings"
 
 	"github.com/docker/docker/api/types"
-	"github.com/docker/docker/cliconfig"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/term"
 	"github.com/docker/docker/registry"
@@ -55,9 +54,10 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 		return string(line)
 	}
 
-	authconfig, ok := cli.configFile.AuthConfigs[serverAddress]
-	if !ok {
-		authconfig = cliconfig.AuthConfig{}
+	store := cli.credentialsStore(serverAddress)
+	authconfig, err := store.Get(serverAddress)
+	if err != nil {
+		return err
 	}
 
 	if username == "" {
@@ -111,13 +111,11 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 	authconfig.Password = password
 	authconfig.Email = email
 	authconfig.ServerAddress = serverAddress
-	cli.configFile.AuthConfigs[serverAddress] = authconfig
 
-	stream, statusCode, err := cli.call("POST", "/auth", cli.configFile.AuthConfigs[serverAddress], nil)
+	stream, statusCode, err := cli.call("POST", "/auth", authconfig, nil)
 	if statusCode == 401 {
-		delete(cli.configFile.AuthConfigs, serverAddress)
-		if err2 := cli.configFile.Save(); err2 != nil {
-			fmt.Fprintf(cli.out, "WARNING: could not save config file: %v\n", err2)
+		if err2 := store.Erase(serverAddress); err2 != nil {
+			fmt.Fprintf(cli.out, "WARNING: could not erase login credentials: %v\n", err2)
 		}
 		return err
 	}
@@ -127,15 +125,17 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 
 	var response types.AuthResponse
 	if err := json.NewDecoder(stream).Decode(&response); err != nil {
-		// Upon error, remove entry
-		delete(cli.configFile.AuthConfigs, serverAddress)
 		return err
 	}
 
-	if err := cli.configFile.Save(); err != nil {
-		return fmt.Errorf("Error saving config file: %v", err)
+	if err := store.Store(authconfig); err != nil {
+		return fmt.Errorf("Error saving login credentials: %v", err)
+	}
+	if helper := cli.configFile.CredentialsHelper(serverAddress); helper == "" {
+		fmt.Fprintf(cli.out, "WARNING: login credentials saved in %s\n", cli.configFile.Filename())
+	} else {
+		fmt.Fprintf(cli.out, "Login credentials saved in the %s credential helper\n", helper)
 	}
-	fmt.Fprintf(cli.out, "WARNING: login credentials saved in %s\n", cli.configFile.Filename())
 
 	if response.Status != "" {
 		fmt.Fprintf(cli.out, "%s\n", response.Status)
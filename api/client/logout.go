@@ -22,15 +22,17 @@ func (cli *DockerCli) CmdLogout(args ...string) error {
 		serverAddress = cmd.Arg(0)
 	}
 
-	if _, ok := cli.configFile.AuthConfigs[serverAddress]; !ok {
+	store := cli.credentialsStore(serverAddress)
+	if authConfig, err := store.Get(serverAddress); err != nil {
+		return err
+	} else if _, ok := cli.configFile.AuthConfigs[serverAddress]; !ok && authConfig.Username == "" {
 		fmt.Fprintf(cli.out, "Not logged in to %s\n", serverAddress)
-	} else {
-		fmt.Fprintf(cli.out, "Remove login credentials for %s\n", serverAddress)
-		delete(cli.configFile.AuthConfigs, serverAddress)
+		return nil
+	}
 
-		if err := cli.configFile.Save(); err != nil {
-			return fmt.Errorf("Failed to save docker config: %v", err)
-		}
+	fmt.Fprintf(cli.out, "Remove login credentials for %s\n", serverAddress)
+	if err := store.Erase(serverAddress); err != nil {
+		return fmt.Errorf("Failed to remove login credentials: %v", err)
 	}
 	return nil
 }
@@ -37,8 +37,8 @@ func (cli *DockerCli) CmdLogs(args ...string) error {
 		return err
 	}
 
-	if logType := c.HostConfig.LogConfig.Type; logType != "json-file" {
-		return fmt.Errorf("\"logs\" command is supported only for \"json-file\" logging driver (got: %s)", logType)
+	if logType := c.HostConfig.LogConfig.Type; !supportsReading(logType) {
+		return fmt.Errorf("\"logs\" command is supported only for \"json-file\" and \"journald\" logging drivers (got: %s)", logType)
 	}
 
 	v := url.Values{}
@@ -66,3 +66,13 @@ func (cli *DockerCli) CmdLogs(args ...string) error {
 
 	return cli.stream("GET", "/containers/"+name+"/logs?"+v.Encode(), sopts)
 }
+
+// supportsReading returns whether the logs of the given logging driver can
+// be read back with "docker logs".
+func supportsReading(logType string) bool {
+	switch logType {
+	case "json-file", "journald":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// CmdNetwork manages the networks.
+//
+// Usage: docker network COMMAND
+func (cli *DockerCli) CmdNetwork(args ...string) error {
+	description := "Manage Docker networks\n\nCommands:\n"
+	commands := [][]string{
+		{"connect", "Connect a container to a network"},
+		{"create", "Create a network"},
+		{"disconnect", "Disconnect a container from a network"},
+		{"inspect", "Return low-level information on a network"},
+		{"ls", "List networks"},
+		{"rm", "Remove a network"},
+	}
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-12.12s%s\n", cmd[0], cmd[1])
+	}
+	description += "\nRun 'docker network COMMAND --help' for more information on a command."
+
+	cmd := cli.Subcmd("network", "[COMMAND]", description, true)
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return nil
+}
+
+// CmdNetworkCreate creates a network.
+//
+// Usage: docker network create [OPTIONS] NETWORK
+func (cli *DockerCli) CmdNetworkCreate(args ...string) error {
+	cmd := cli.Subcmd("network create", "NETWORK", "Create a network", true)
+	driver := cmd.String([]string{"d", "-driver"}, "bridge", "Specify the network driver name")
+	subnet := cmd.String([]string{"-subnet"}, "", "Subnet in CIDR format of the network")
+	gateway := cmd.String([]string{"-gateway"}, "", "IPv4 gateway of the subnet")
+	cmd.Require(flag.Exact, 1)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkCreate{
+		Name:    cmd.Arg(0),
+		Driver:  *driver,
+		Subnet:  *subnet,
+		Gateway: *gateway,
+	}
+
+	rdr, _, err := cli.call("POST", "/networks/create", req, nil)
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	var resp types.NetworkCreateResponse
+	if err := json.NewDecoder(rdr).Decode(&resp); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.out, resp.Id)
+	return nil
+}
+
+// CmdNetworkLs lists the networks.
+//
+// Usage: docker network ls [OPTIONS]
+func (cli *DockerCli) CmdNetworkLs(args ...string) error {
+	cmd := cli.Subcmd("network ls", "", "List networks", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display network IDs")
+	noTrunc := cmd.Bool([]string{"-no-trunc"}, false, "Do not truncate the output")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	rdr, _, err := cli.call("GET", "/networks", nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	var networks []types.NetworkResource
+	if err := json.NewDecoder(rdr).Decode(&networks); err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	if !*quiet {
+		fmt.Fprintln(w, "NETWORK ID\tNAME\tDRIVER\tSUBNET")
+	}
+	for _, network := range networks {
+		id := network.Id
+		if !*noTrunc {
+			id = stringid.TruncateID(id)
+		}
+		if *quiet {
+			fmt.Fprintln(w, id)
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", id, network.Name, network.Driver, network.Subnet)
+	}
+	w.Flush()
+	return nil
+}
+
+// CmdNetworkInspect displays low-level information on one or more networks.
+//
+// Usage: docker network inspect NETWORK [NETWORK...]
+func (cli *DockerCli) CmdNetworkInspect(args ...string) error {
+	cmd := cli.Subcmd("network inspect", "NETWORK [NETWORK...]", "Return low-level information on a network", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	indented := new(bytes.Buffer)
+	indented.WriteString("[\n")
+	status := 0
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/networks/"+name, nil, nil))
+		if err != nil {
+			if strings.Contains(err.Error(), "No such") {
+				fmt.Fprintf(cli.err, "Error: No such network: %s\n", name)
+			} else {
+				fmt.Fprintf(cli.err, "%s\n", err)
+			}
+			status = 1
+			continue
+		}
+		if err := json.Indent(indented, obj, "", "    "); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+		indented.WriteString(",")
+	}
+
+	if indented.Len() > 1 {
+		// Remove trailing ','
+		indented.Truncate(indented.Len() - 1)
+	}
+	indented.WriteString("]\n")
+	if _, err := indented.WriteTo(cli.out); err != nil {
+		return err
+	}
+
+	if status != 0 {
+		return StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+// CmdNetworkRm removes one or more networks.
+//
+// Usage: docker network rm NETWORK [NETWORK...]
+func (cli *DockerCli) CmdNetworkRm(args ...string) error {
+	cmd := cli.Subcmd("network rm", "NETWORK [NETWORK...]", "Remove a network", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var errNames []string
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/networks/"+name, nil, nil)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			errNames = append(errNames, name)
+			continue
+		}
+		fmt.Fprintf(cli.out, "%s\n", name)
+	}
+	if len(errNames) > 0 {
+		return fmt.Errorf("Error: failed to remove networks: %v", errNames)
+	}
+	return nil
+}
+
+// CmdNetworkConnect connects a container to a network.
+//
+// Usage: docker network connect [OPTIONS] NETWORK CONTAINER
+func (cli *DockerCli) CmdNetworkConnect(args ...string) error {
+	cmd := cli.Subcmd("network connect", "NETWORK CONTAINER", "Connect a container to a network", true)
+	flAliases := opts.NewListOpts(nil)
+	cmd.Var(&flAliases, []string{"-alias"}, "Add an alias of the container in the network")
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkConnect{Container: cmd.Arg(1), Aliases: flAliases.GetAll()}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/connect", req, nil))
+	return err
+}
+
+// CmdNetworkDisconnect disconnects a container from a network.
+//
+// Usage: docker network disconnect NETWORK CONTAINER
+func (cli *DockerCli) CmdNetworkDisconnect(args ...string) error {
+	cmd := cli.Subcmd("network disconnect", "NETWORK CONTAINER", "Disconnect a container from a network", true)
+	cmd.Require(flag.Exact, 2)
+	cmd.ParseFlags(args, true)
+
+	req := &types.NetworkConnect{Container: cmd.Arg(1)}
+	_, _, err := readBody(cli.call("POST", "/networks/"+cmd.Arg(0)+"/disconnect", req, nil))
+	return err
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"encoding/json"
 	"fmt"
+	"net"
 	"strings"
 
 	"github.com/docker/docker/nat"
@@ -47,7 +48,7 @@ func (cli *DockerCli) CmdPort(args ...string) error {
 		natPort := port + "/" + proto
 		if frontends, exists := c.NetworkSettings.Ports[nat.Port(port+"/"+proto)]; exists && frontends != nil {
 			for _, frontend := range frontends {
-				fmt.Fprintf(cli.out, "%s:%s\n", frontend.HostIp, frontend.HostPort)
+				fmt.Fprintln(cli.out, net.JoinHostPort(frontend.HostIp, frontend.HostPort))
 			}
 			return nil
 		}
@@ -56,7 +57,7 @@ func (cli *DockerCli) CmdPort(args ...string) error {
 
 	for from, frontends := range c.NetworkSettings.Ports {
 		for _, frontend := range frontends {
-			fmt.Fprintf(cli.out, "%s -> %s:%s\n", from, frontend.HostIp, frontend.HostPort)
+			fmt.Fprintf(cli.out, "%s -> %s\n", from, net.JoinHostPort(frontend.HostIp, frontend.HostPort))
 		}
 	}
 
@@ -17,6 +17,7 @@ import (
 func (cli *DockerCli) CmdPull(args ...string) error {
 	cmd := cli.Subcmd("pull", "NAME[:TAG|@DIGEST]", "Pull an image or a repository from the registry", true)
 	allTags := cmd.Bool([]string{"a", "-all-tags"}, false, "Download all tagged images in the repository")
+	addTrustedFlags(cmd, true)
 	cmd.Require(flag.Exact, 1)
 
 	cmd.ParseFlags(args, true)
@@ -42,6 +43,16 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 		return err
 	}
 
-	_, _, err = cli.clientRequestAttemptLogin("POST", "/images/create?"+v.Encode(), nil, cli.out, repoInfo.Index, "pull")
+	if isTrusted() && !utils.DigestReference(tag) {
+		if *allTags {
+			return fmt.Errorf("--all-tags can't be used with content trust, pull the tags one by one")
+		}
+		if tag == "" {
+			tag = tags.DEFAULTTAG
+		}
+		return cli.trustedPull(repoInfo, tag)
+	}
+
+	_, _, err = cli.clientRequestAttemptLogin("POST", "/images/create?"+v.Encode(), nil, cli.out, nil, repoInfo.Index, "pull")
 	return err
 }
@@ -14,6 +14,7 @@ import (
 // Usage: docker push NAME[:TAG]
 func (cli *DockerCli) CmdPush(args ...string) error {
 	cmd := cli.Subcmd("push", "NAME[:TAG]", "Push an image or a repository to the registry", true)
+	addTrustedFlags(cmd, false)
 	cmd.Require(flag.Exact, 1)
 
 	cmd.ParseFlags(args, true)
@@ -28,7 +29,10 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 		return err
 	}
 	// Resolve the Auth config relevant for this server
-	authConfig := registry.ResolveAuthConfig(cli.configFile, repoInfo.Index)
+	authConfig, err := cli.resolveAuthConfig(repoInfo.Index)
+	if err != nil {
+		return err
+	}
 	// If we're not using a custom registry, we know the restrictions
 	// applied to repository names and can warn the user in advance.
 	// Custom repositories can have different rules, and we must also
@@ -44,6 +48,10 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 	v := url.Values{}
 	v.Set("tag", tag)
 
-	_, _, err = cli.clientRequestAttemptLogin("POST", "/images/"+remote+"/push?"+v.Encode(), nil, cli.out, repoInfo.Index, "push")
+	path := "/images/" + remote + "/push?" + v.Encode()
+	if isTrusted() {
+		return cli.trustedPush(repoInfo, tag, path)
+	}
+	_, _, err = cli.clientRequestAttemptLogin("POST", path, nil, cli.out, nil, repoInfo.Index, "push")
 	return err
 }
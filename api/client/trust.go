@@ -0,0 +1,406 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/graph/tags"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+// Content trust is enabled with the DOCKER_CONTENT_TRUST environment
+// variable: the tags are then resolved to the digests signed for them on the
+// signing server given with DOCKER_CONTENT_TRUST_SERVER, and the images are
+// pulled and used by digest. The tags pushed are signed with the key of the
+// repository.
+
+var (
+	// untrusted disables content trust for the command being run. Only the
+	// commands adding the trusted flags enable it.
+	untrusted = true
+
+	// dockerfileFromPattern matches the FROM instructions of a Dockerfile.
+	dockerfileFromPattern = regexp.MustCompile(`(?i)^\s*FROM\s+(\S+)(?:\s+AS\s+(\S+))?`)
+)
+
+// addTrustedFlags adds the flag disabling content trust to the flags of a
+// command which verifies images, or signs them.
+func addTrustedFlags(fs *flag.FlagSet, verify bool) {
+	var trusted bool
+	if e := os.Getenv("DOCKER_CONTENT_TRUST"); e != "" {
+		if t, err := strconv.ParseBool(e); t || err != nil {
+			// treat any other value than a false boolean as enabled
+			trusted = true
+		}
+	}
+	message := "Skip image signing"
+	if verify {
+		message = "Skip image verification"
+	}
+	fs.BoolVar(&untrusted, []string{"-disable-content-trust"}, !trusted, message)
+}
+
+// isTrusted returns whether content trust is enabled.
+func isTrusted() bool {
+	return !untrusted
+}
+
+// trustServer returns the URL of the signing server, which is given with
+// DOCKER_CONTENT_TRUST_SERVER.
+func trustServer() (string, error) {
+	s := os.Getenv("DOCKER_CONTENT_TRUST_SERVER")
+	if s == "" {
+		return "", errors.New("Content trust requires the URL of a signing server in DOCKER_CONTENT_TRUST_SERVER")
+	}
+	if u, err := url.Parse(s); err != nil || u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("Invalid signing server URL in DOCKER_CONTENT_TRUST_SERVER: %s", s)
+	}
+	return s, nil
+}
+
+// trustDirectory returns the directory keeping the keys of the repositories.
+func (cli *DockerCli) trustDirectory() string {
+	return filepath.Join(filepath.Dir(cli.configFile.Filename()), "trust")
+}
+
+// trustClient returns a client of the signing server of the repository,
+// authenticated with the credentials of its registry.
+func (cli *DockerCli) trustClient(repoInfo *registry.RepositoryInfo) (*trust.Client, error) {
+	server, err := trustServer()
+	if err != nil {
+		return nil, err
+	}
+	authConfig, err := cli.resolveAuthConfig(repoInfo.Index)
+	if err != nil {
+		return nil, err
+	}
+	transport := registry.AuthTransport(http.DefaultTransport, &authConfig, authConfig.Username != "")
+	return trust.NewClient(server, transport), nil
+}
+
+// repositoryKey returns the key signing the tags of the repository, which
+// is generated when the first tag is signed.
+func (cli *DockerCli) repositoryKey(name string) (libtrust.PrivateKey, error) {
+	keyPath := filepath.Join(cli.trustDirectory(), "private", filepath.FromSlash(name), "key.json")
+	key, err := libtrust.LoadKeyFile(keyPath)
+	if err != libtrust.ErrKeyFileDoesNotExist {
+		return key, err
+	}
+
+	if key, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+		return nil, err
+	}
+	if err := libtrust.SaveKey(keyPath, key); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(cli.out, "Generated the key %s to sign the tags of %s\n", key.KeyID(), name)
+	return key, nil
+}
+
+// checkTrustedKey returns an error unless one of the keys is the key trusted
+// for the repository. The first key seen for a repository is trusted.
+func (cli *DockerCli) checkTrustedKey(name string, keys []libtrust.PublicKey) error {
+	if len(keys) == 0 {
+		return fmt.Errorf("No key signed the trust data of %s", name)
+	}
+	keyPath := filepath.Join(cli.trustDirectory(), "trusted", filepath.FromSlash(name), "key.json")
+	trusted, err := libtrust.LoadPublicKeyFile(keyPath)
+	if err == libtrust.ErrKeyFileDoesNotExist {
+		if err := os.MkdirAll(filepath.Dir(keyPath), 0700); err != nil {
+			return err
+		}
+		if err := libtrust.SavePublicKey(keyPath, keys[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.err, "Trusting the key %s for %s\n", keys[0].KeyID(), name)
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		if key.KeyID() == trusted.KeyID() {
+			return nil
+		}
+	}
+	return fmt.Errorf("The trust data of %s is not signed by its trusted key %s", name, trusted.KeyID())
+}
+
+// targetPath returns the path of the last target seen for the tag of the
+// repository.
+func (cli *DockerCli) targetPath(name, tag string) string {
+	return filepath.Join(cli.trustDirectory(), "targets", filepath.FromSlash(name), tag+".json")
+}
+
+// lastTarget returns the last target seen for the tag of the repository, or
+// nil if none was.
+func (cli *DockerCli) lastTarget(name, tag string) (*trust.Target, error) {
+	b, err := ioutil.ReadFile(cli.targetPath(name, tag))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var target trust.Target
+	if err := json.Unmarshal(b, &target); err != nil {
+		return nil, fmt.Errorf("Error reading the last trust data of %s: %v", utils.ImageReference(name, tag), err)
+	}
+	return &target, nil
+}
+
+// saveTarget saves the target as the last one seen for its tag, unless a
+// newer one was.
+func (cli *DockerCli) saveTarget(target *trust.Target) error {
+	last, err := cli.lastTarget(target.Name, target.Tag)
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Version >= target.Version {
+		return nil
+	}
+	b, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	path := cli.targetPath(target.Name, target.Tag)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// trustedReference returns the digest signed for the tag of the repository.
+// The trust data is refused if it expired, or if it is older than the data
+// seen already for the tag.
+func (cli *DockerCli) trustedReference(repoInfo *registry.RepositoryInfo, tag string) (digest.Digest, error) {
+	client, err := cli.trustClient(repoInfo)
+	if err != nil {
+		return "", err
+	}
+	signed, err := client.GetTarget(repoInfo.CanonicalName, tag)
+	if err == trust.ErrTargetNotFound {
+		return "", fmt.Errorf("No trust data for %s", utils.ImageReference(repoInfo.CanonicalName, tag))
+	} else if err != nil {
+		return "", fmt.Errorf("Error getting trust data of %s: %v", utils.ImageReference(repoInfo.CanonicalName, tag), err)
+	}
+
+	target, keys, err := trust.VerifyTarget(signed)
+	if err != nil {
+		return "", err
+	}
+	if target.Name != repoInfo.CanonicalName || target.Tag != tag {
+		return "", fmt.Errorf("The trust data of %s is signed for %s", utils.ImageReference(repoInfo.CanonicalName, tag), utils.ImageReference(target.Name, target.Tag))
+	}
+	if err := cli.checkTrustedKey(repoInfo.CanonicalName, keys); err != nil {
+		return "", err
+	}
+	last, err := cli.lastTarget(repoInfo.CanonicalName, tag)
+	if err != nil {
+		return "", err
+	}
+	if err := trust.CheckTarget(target, last); err != nil {
+		return "", err
+	}
+	if err := cli.saveTarget(target); err != nil {
+		return "", err
+	}
+	return target.Digest, nil
+}
+
+// tagTrusted tags the image pulled by digest with the tag the digest is
+// signed for.
+func (cli *DockerCli) tagTrusted(repoInfo *registry.RepositoryInfo, tag string, dgst digest.Digest, out io.Writer) error {
+	fmt.Fprintf(out, "Tagging %s@%s as %s\n", repoInfo.LocalName, dgst, utils.ImageReference(repoInfo.LocalName, tag))
+
+	v := url.Values{}
+	v.Set("repo", repoInfo.LocalName)
+	v.Set("tag", tag)
+	v.Set("force", "1")
+	_, _, err := readBody(cli.call("POST", "/images/"+repoInfo.LocalName+"@"+dgst.String()+"/tag?"+v.Encode(), nil, nil))
+	return err
+}
+
+// trustedPull pulls by digest the image signed for the tag of the
+// repository, and tags it.
+func (cli *DockerCli) trustedPull(repoInfo *registry.RepositoryInfo, tag string) error {
+	dgst, err := cli.trustedReference(repoInfo, tag)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cli.out, "Pulling %s at the signed digest %s\n", utils.ImageReference(repoInfo.LocalName, tag), dgst)
+
+	v := url.Values{}
+	v.Set("fromImage", repoInfo.LocalName+"@"+dgst.String())
+	if _, _, err := cli.clientRequestAttemptLogin("POST", "/images/create?"+v.Encode(), nil, cli.out, nil, repoInfo.Index, "pull"); err != nil {
+		return err
+	}
+	return cli.tagTrusted(repoInfo, tag, dgst, cli.out)
+}
+
+// nextTargetVersion returns the version of the next target of the tag of the
+// repository: the version following the last one seen, or signed on the
+// server with the trusted key.
+func (cli *DockerCli) nextTargetVersion(client *trust.Client, name, tag string) (int64, error) {
+	var version int64
+	last, err := cli.lastTarget(name, tag)
+	if err != nil {
+		return 0, err
+	}
+	if last != nil {
+		version = last.Version
+	}
+
+	signed, err := client.GetTarget(name, tag)
+	if err == trust.ErrTargetNotFound {
+		return version + 1, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("Error getting trust data of %s: %v", utils.ImageReference(name, tag), err)
+	}
+	if current, keys, err := trust.VerifyTarget(signed); err != nil {
+		logrus.Debugf("Ignoring the trust data of %s: %v", utils.ImageReference(name, tag), err)
+	} else if err := cli.checkTrustedKey(name, keys); err != nil {
+		logrus.Debugf("Ignoring the trust data of %s: %v", utils.ImageReference(name, tag), err)
+	} else if current.Version > version {
+		version = current.Version
+	}
+	return version + 1, nil
+}
+
+// trustedPush pushes the tags of the repository, or only the given tag, and
+// signs the digests the registry returned for them.
+func (cli *DockerCli) trustedPush(repoInfo *registry.RepositoryInfo, tag, path string) error {
+	key, err := cli.repositoryKey(repoInfo.CanonicalName)
+	if err != nil {
+		return err
+	}
+	// refuse to push with another key than the trusted one
+	if err := cli.checkTrustedKey(repoInfo.CanonicalName, []libtrust.PublicKey{key.PublicKey()}); err != nil {
+		return err
+	}
+	client, err := cli.trustClient(repoInfo)
+	if err != nil {
+		return err
+	}
+
+	var results []types.PushResult
+	handleResult := func(aux *json.RawMessage) {
+		var result types.PushResult
+		if err := json.Unmarshal(*aux, &result); err != nil {
+			logrus.Debugf("Ignoring the unexpected push result %s: %v", *aux, err)
+			return
+		}
+		results = append(results, result)
+	}
+	if _, _, err := cli.clientRequestAttemptLogin("POST", path, nil, cli.out, handleResult, repoInfo.Index, "push"); err != nil {
+		return err
+	}
+	name := repoInfo.CanonicalName
+	if tag != "" {
+		name = utils.ImageReference(name, tag)
+		var tagged []types.PushResult
+		for _, result := range results {
+			if result.Tag == tag {
+				tagged = append(tagged, result)
+			}
+		}
+		results = tagged
+	}
+	if len(results) == 0 {
+		return fmt.Errorf("No digest was returned by the registry for %s, nothing to sign", name)
+	}
+
+	for _, result := range results {
+		dgst, err := digest.ParseDigest(result.Digest)
+		if err != nil {
+			return fmt.Errorf("Invalid digest returned by the registry for %s: %v", utils.ImageReference(repoInfo.CanonicalName, result.Tag), err)
+		}
+		version, err := cli.nextTargetVersion(client, repoInfo.CanonicalName, result.Tag)
+		if err != nil {
+			return err
+		}
+		target := &trust.Target{
+			Name:    repoInfo.CanonicalName,
+			Tag:     result.Tag,
+			Digest:  dgst,
+			Version: version,
+			Expires: time.Now().Add(trust.DefaultTargetExpiry).UTC(),
+		}
+		signed, err := trust.SignTarget(target, key)
+		if err != nil {
+			return err
+		}
+		if err := client.PutTarget(target.Name, target.Tag, signed); err != nil {
+			return err
+		}
+		if err := cli.saveTarget(target); err != nil {
+			return err
+		}
+		fmt.Fprintf(cli.out, "Signed %s at %s with the key %s\n", utils.ImageReference(repoInfo.CanonicalName, target.Tag), target.Digest, key.KeyID())
+	}
+	return nil
+}
+
+// trustedBuildRefs resolves the base images of the FROM instructions of the
+// Dockerfile to the references of their signed digests. The earlier build
+// stages and scratch are not resolved.
+func (cli *DockerCli) trustedBuildRefs(dockerfile io.Reader) (map[string]string, error) {
+	var (
+		refs    = make(map[string]string)
+		stages  = make(map[string]bool)
+		scanner = bufio.NewScanner(dockerfile)
+	)
+	for scanner.Scan() {
+		m := dockerfileFromPattern.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		name := m[1]
+		if _, resolved := refs[name]; !resolved && !stages[strings.ToLower(name)] && name != "scratch" {
+			repo, tag := parsers.ParseRepositoryTag(name)
+			if utils.DigestReference(tag) {
+				refs[name] = name
+			} else {
+				if tag == "" {
+					tag = tags.DEFAULTTAG
+				}
+				repoInfo, err := registry.ParseRepositoryInfo(repo)
+				if err != nil {
+					return nil, err
+				}
+				dgst, err := cli.trustedReference(repoInfo, tag)
+				if err != nil {
+					return nil, err
+				}
+				fmt.Fprintf(cli.out, "%s resolved to the signed digest %s\n", utils.ImageReference(repo, tag), dgst)
+				refs[name] = repo + "@" + dgst.String()
+			}
+		}
+		if m[2] != "" {
+			stages[strings.ToLower(m[2])] = true
+		}
+	}
+	return refs, scanner.Err()
+}
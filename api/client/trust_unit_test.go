@@ -0,0 +1,317 @@
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/cliconfig"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/libtrust"
+)
+
+const (
+	trustTestDigest      = "sha256:4d5c1e6a8f2b3c7d9e0f1a2b3c4d5e6f7a8b9c0d1e2f3a4b5c6d7e8f9a0b1c2d"
+	trustTestOtherDigest = "sha256:0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+)
+
+// trustTestServer is a signing server keeping the signed targets in memory,
+// by path.
+type trustTestServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	signed map[string][]byte
+}
+
+// newTrustTestServer returns a signing server serving the targets signed
+// with the key, which the client uses with DOCKER_CONTENT_TRUST_SERVER until
+// the returned function is called.
+func newTrustTestServer(t *testing.T, key libtrust.PrivateKey, targets ...*trust.Target) (*trustTestServer, func()) {
+	s := &trustTestServer{signed: make(map[string][]byte)}
+	for _, target := range targets {
+		s.sign(t, key, target)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		switch r.Method {
+		case "GET":
+			b, exists := s.signed[r.URL.Path]
+			if !exists {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(b)
+		case "PUT":
+			b, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			s.signed[r.URL.Path] = b
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+	server := os.Getenv("DOCKER_CONTENT_TRUST_SERVER")
+	os.Setenv("DOCKER_CONTENT_TRUST_SERVER", s.URL)
+	return s, func() {
+		os.Setenv("DOCKER_CONTENT_TRUST_SERVER", server)
+		s.Close()
+	}
+}
+
+// sign stores the target signed with the key.
+func (s *trustTestServer) sign(t *testing.T, key libtrust.PrivateKey, target *trust.Target) {
+	b, err := trust.SignTarget(target, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.mu.Lock()
+	s.signed["/trust/v1/"+target.Name+"/targets/"+target.Tag] = b
+	s.mu.Unlock()
+}
+
+// target returns the target stored for the tag of the repository, or nil.
+func (s *trustTestServer) target(t *testing.T, name, tag string) *trust.Target {
+	s.mu.Lock()
+	b, exists := s.signed["/trust/v1/"+name+"/targets/"+tag]
+	s.mu.Unlock()
+	if !exists {
+		return nil
+	}
+	target, _, err := trust.VerifyTarget(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return target
+}
+
+// newTestTarget returns a target of the tag of the repository, valid for an
+// hour.
+func newTestTarget(name, tag, dgst string, version int64) *trust.Target {
+	return &trust.Target{Name: name, Tag: tag, Digest: digest.Digest(dgst), Version: version, Expires: time.Now().Add(time.Hour)}
+}
+
+func newTrustTestCli(t *testing.T) (*DockerCli, func()) {
+	dir, err := ioutil.TempDir("", "trust-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli := &DockerCli{
+		configFile: cliconfig.NewConfigFile(filepath.Join(dir, cliconfig.CONFIGFILE)),
+		out:        ioutil.Discard,
+		err:        ioutil.Discard,
+	}
+	return cli, func() { os.RemoveAll(dir) }
+}
+
+func TestTrustedBuildRefs(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, closeServer := newTrustTestServer(t, key,
+		newTestTarget("busybox", "latest", trustTestDigest, 1),
+		newTestTarget("example.com/app", "1.0", trustTestDigest, 1),
+	)
+	defer closeServer()
+
+	cli, cleanup := newTrustTestCli(t)
+	defer cleanup()
+
+	dockerfile := `FROM busybox AS base
+RUN true
+from example.com/app:1.0
+FROM base
+FROM scratch
+FROM example.com/app@` + trustTestDigest + `
+`
+	refs, err := cli.trustedBuildRefs(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"busybox":                            "busybox@" + trustTestDigest,
+		"example.com/app:1.0":                "example.com/app@" + trustTestDigest,
+		"example.com/app@" + trustTestDigest: "example.com/app@" + trustTestDigest,
+	}
+	if len(refs) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, refs)
+	}
+	for name, ref := range expected {
+		if refs[name] != ref {
+			t.Fatalf("Expected %s to resolve to %s, got %q", name, ref, refs[name])
+		}
+	}
+
+	if _, err := cli.trustedBuildRefs(strings.NewReader("FROM example.com/app:2.0\n")); err == nil || !strings.Contains(err.Error(), "No trust data") {
+		t.Fatalf("Expected an unsigned tag to be refused, got %v", err)
+	}
+}
+
+func TestTrustedReferenceKeyPinning(t *testing.T) {
+	target := newTestTarget("example.com/app", "latest", trustTestDigest, 1)
+	cli, cleanup := newTrustTestCli(t)
+	defer cleanup()
+
+	resolve := func(key libtrust.PrivateKey) error {
+		_, closeServer := newTrustTestServer(t, key, target)
+		defer closeServer()
+		repoInfo, err := registry.ParseRepositoryInfo("example.com/app")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dgst, err := cli.trustedReference(repoInfo, "latest")
+		if err == nil && dgst != trustTestDigest {
+			t.Fatalf("Expected %s, got %s", trustTestDigest, dgst)
+		}
+		return err
+	}
+
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the first key is trusted
+	if err := resolve(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := resolve(key); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := resolve(other); err == nil || !strings.Contains(err.Error(), key.KeyID()) {
+		t.Fatalf("Expected a target signed with another key to be refused, got %v", err)
+	}
+}
+
+func TestTrustServerRequired(t *testing.T) {
+	cli, cleanup := newTrustTestCli(t)
+	defer cleanup()
+	defer os.Setenv("DOCKER_CONTENT_TRUST_SERVER", os.Getenv("DOCKER_CONTENT_TRUST_SERVER"))
+	os.Setenv("DOCKER_CONTENT_TRUST_SERVER", "")
+
+	repoInfo, err := registry.ParseRepositoryInfo("example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.trustedReference(repoInfo, "latest"); err == nil || !strings.Contains(err.Error(), "DOCKER_CONTENT_TRUST_SERVER") {
+		t.Fatalf("Expected content trust to require a signing server, got %v", err)
+	}
+}
+
+func TestTrustedReferenceRollback(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, closeServer := newTrustTestServer(t, key, newTestTarget("example.com/app", "latest", trustTestDigest, 2))
+	defer closeServer()
+	cli, cleanup := newTrustTestCli(t)
+	defer cleanup()
+	repoInfo, err := registry.ParseRepositoryInfo("example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cli.trustedReference(repoInfo, "latest"); err != nil {
+		t.Fatal(err)
+	}
+
+	expired := newTestTarget("example.com/app", "latest", trustTestOtherDigest, 3)
+	expired.Expires = time.Now().Add(-time.Hour)
+	for _, c := range []struct {
+		target   *trust.Target
+		expected string
+	}{
+		{newTestTarget("example.com/app", "latest", trustTestOtherDigest, 1), "older"},
+		{newTestTarget("example.com/app", "latest", trustTestOtherDigest, 2), "several digests"},
+		{expired, "expired"},
+	} {
+		server.sign(t, key, c.target)
+		if _, err := cli.trustedReference(repoInfo, "latest"); err == nil || !strings.Contains(err.Error(), c.expected) {
+			t.Fatalf("Expected the version %d to be refused as %s, got %v", c.target.Version, c.expected, err)
+		}
+	}
+
+	server.sign(t, key, newTestTarget("example.com/app", "latest", trustTestOtherDigest, 3))
+	if dgst, err := cli.trustedReference(repoInfo, "latest"); err != nil || dgst != trustTestOtherDigest {
+		t.Fatalf("Expected the newer version to be accepted, got %s, %v", dgst, err)
+	}
+	if last, err := cli.lastTarget("example.com/app", "latest"); err != nil || last == nil || last.Version != 3 {
+		t.Fatalf("Expected the newer version to be saved, got %+v, %v", last, err)
+	}
+}
+
+func TestTrustedPush(t *testing.T) {
+	server, closeServer := newTrustTestServer(t, nil)
+	defer closeServer()
+	cli, cleanup := newTrustTestCli(t)
+	defer cleanup()
+
+	// the daemon streams the digests of the tags pushed as auxiliary data,
+	// the status messages are not parsed
+	sf := streamformatter.NewJSONStreamFormatter()
+	daemon := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(sf.FormatStatus("", "other: Digest: %s", trustTestOtherDigest))
+		w.Write(sf.FormatStatus("", "Digest: %s", trustTestDigest))
+		w.Write(sf.FormatAux(types.PushResult{Tag: "latest", Digest: trustTestDigest}))
+		w.Write(sf.FormatAux(types.PushResult{Tag: "1.0", Digest: trustTestOtherDigest}))
+	}))
+	defer daemon.Close()
+	u, err := url.Parse(daemon.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cli.addr, cli.scheme, cli.transport = u.Host, "http", &http.Transport{}
+
+	repoInfo, err := registry.ParseRepositoryInfo("example.com/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.trustedPush(repoInfo, "", "/images/example.com/app/push"); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.trustedPush(repoInfo, "latest", "/images/example.com/app/push?tag=latest"); err != nil {
+		t.Fatal(err)
+	}
+	// a tag without a digest is not signed silently
+	if err := cli.trustedPush(repoInfo, "2.0", "/images/example.com/app/push?tag=2.0"); err == nil || !strings.Contains(err.Error(), "nothing to sign") {
+		t.Fatalf("Expected the push of a tag without a digest to fail, got %v", err)
+	}
+
+	for _, expected := range []*trust.Target{
+		newTestTarget("example.com/app", "latest", trustTestDigest, 2),
+		newTestTarget("example.com/app", "1.0", trustTestOtherDigest, 1),
+	} {
+		target := server.target(t, expected.Name, expected.Tag)
+		if target == nil || target.Digest != expected.Digest || target.Version != expected.Version {
+			t.Fatalf("Expected %s:%s to be signed at version %d, got %+v", expected.Name, expected.Tag, expected.Version, target)
+		}
+		if target.Expires.Before(time.Now().Add(trust.DefaultTargetExpiry - time.Hour)) {
+			t.Fatalf("Expected the target to expire in %s, got %s", trust.DefaultTargetExpiry, target.Expires)
+		}
+	}
+	if target := server.target(t, "example.com/app", "other"); target != nil {
+		t.Fatalf("Expected only the tags pushed to be signed, got %+v", target)
+	}
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/runconfig"
+)
+
+// CmdUpdate updates the resources and the restart policy of one or more
+// containers.
+//
+// Usage: docker update [OPTIONS] CONTAINER [CONTAINER...]
+func (cli *DockerCli) CmdUpdate(args ...string) error {
+	cmd := cli.Subcmd("update", "CONTAINER [CONTAINER...]", "Update the resources and the restart policy of one or more containers", true)
+
+	updateConfig, names, err := runconfig.ParseUpdate(cmd, args)
+	if err != nil {
+		return err
+	}
+
+	var errNames []string
+	for _, name := range names {
+		stream, _, err := cli.call("POST", fmt.Sprintf("/containers/%s/update", name), updateConfig, nil)
+		if err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			errNames = append(errNames, name)
+			continue
+		}
+
+		var response types.ContainerUpdateResponse
+		err = json.NewDecoder(stream).Decode(&response)
+		stream.Close()
+		if err != nil {
+			return err
+		}
+		for _, warning := range response.Warnings {
+			fmt.Fprintf(cli.err, "WARNING: %s\n", warning)
+		}
+		fmt.Fprintf(cli.out, "%s\n", name)
+	}
+	if len(errNames) > 0 {
+		return fmt.Errorf("Error: failed to update containers: %v", errNames)
+	}
+	return nil
+}
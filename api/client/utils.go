@@ -22,6 +22,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/cliconfig"
+	"github.com/docker/docker/cliconfig/credentials"
 	"github.com/docker/docker/pkg/jsonmessage"
 	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/stdcopy"
@@ -49,13 +50,20 @@ func (cli *DockerCli) encodeData(data interface{}) (*bytes.Buffer, error) {
 }
 
 func (cli *DockerCli) clientRequest(method, path string, in io.Reader, headers map[string][]string) (io.ReadCloser, string, int, error) {
+	body, respHeader, statusCode, err := cli.clientRequestHeader(method, path, in, headers)
+	return body, respHeader.Get("Content-Type"), statusCode, err
+}
+
+// clientRequestHeader is like clientRequest, but returns all the headers of
+// the response.
+func (cli *DockerCli) clientRequestHeader(method, path string, in io.Reader, headers map[string][]string) (io.ReadCloser, http.Header, int, error) {
 	expectedPayload := (method == "POST" || method == "PUT")
 	if expectedPayload && in == nil {
 		in = bytes.NewReader([]byte{})
 	}
 	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", api.APIVERSION, path), in)
 	if err != nil {
-		return nil, "", -1, err
+		return nil, nil, -1, err
 	}
 
 	// Add CLI Config's HTTP Headers BEFORE we set the Docker headers
@@ -85,38 +93,40 @@ func (cli *DockerCli) clientRequest(method, path string, in io.Reader, headers m
 	}
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") {
-			return nil, "", statusCode, errConnectionRefused
+			return nil, nil, statusCode, errConnectionRefused
 		}
 
 		if cli.tlsConfig == nil {
-			return nil, "", statusCode, fmt.Errorf("%v. Are you trying to connect to a TLS-enabled daemon without TLS?", err)
+			return nil, nil, statusCode, fmt.Errorf("%v. Are you trying to connect to a TLS-enabled daemon without TLS?", err)
 		}
-		return nil, "", statusCode, fmt.Errorf("An error occurred trying to connect: %v", err)
+		return nil, nil, statusCode, fmt.Errorf("An error occurred trying to connect: %v", err)
 	}
 
 	if statusCode < 200 || statusCode >= 400 {
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return nil, "", statusCode, err
+			return nil, nil, statusCode, err
 		}
 		if len(body) == 0 {
-			return nil, "", statusCode, fmt.Errorf("Error: request returned %s for API route and version %s, check if the server supports the requested API version", http.StatusText(statusCode), req.URL)
+			return nil, nil, statusCode, fmt.Errorf("Error: request returned %s for API route and version %s, check if the server supports the requested API version", http.StatusText(statusCode), req.URL)
 		}
-		return nil, "", statusCode, fmt.Errorf("Error response from daemon: %s", bytes.TrimSpace(body))
+		return nil, nil, statusCode, fmt.Errorf("Error response from daemon: %s", bytes.TrimSpace(body))
 	}
 
-	return resp.Body, resp.Header.Get("Content-Type"), statusCode, nil
+	return resp.Body, resp.Header, statusCode, nil
 }
 
-func (cli *DockerCli) clientRequestAttemptLogin(method, path string, in io.Reader, out io.Writer, index *registry.IndexInfo, cmdName string) (io.ReadCloser, int, error) {
+// clientRequestAttemptLogin makes the request with the credentials of the
+// registry of the index, and asks to login if they are refused. The JSON
+// stream of the response is displayed to out, if not nil, and its auxiliary
+// data is given to auxCallback, if not nil.
+func (cli *DockerCli) clientRequestAttemptLogin(method, path string, in io.Reader, out io.Writer, auxCallback func(*json.RawMessage), index *registry.IndexInfo, cmdName string) (io.ReadCloser, int, error) {
 	cmdAttempt := func(authConfig cliconfig.AuthConfig) (io.ReadCloser, int, error) {
-		buf, err := json.Marshal(authConfig)
+		encodedAuth, err := encodeAuthToBase64(authConfig)
 		if err != nil {
 			return nil, -1, err
 		}
-		registryAuthHeader := []string{
-			base64.URLEncoding.EncodeToString(buf),
-		}
+		registryAuthHeader := []string{encodedAuth}
 
 		// begin the request
 		body, contentType, statusCode, err := cli.clientRequest(method, path, in, map[string][]string{
@@ -125,7 +135,7 @@ func (cli *DockerCli) clientRequestAttemptLogin(method, path string, in io.Reade
 		if err == nil && out != nil {
 			// If we are streaming output, complete the stream since
 			// errors may not appear until later.
-			err = cli.streamBody(body, contentType, true, out, nil)
+			err = cli.streamBody(body, contentType, true, out, nil, auxCallback)
 		}
 		if err != nil {
 			// Since errors in a stream appear after status 200 has been written,
@@ -140,19 +150,85 @@ func (cli *DockerCli) clientRequestAttemptLogin(method, path string, in io.Reade
 	}
 
 	// Resolve the Auth config relevant for this server
-	authConfig := registry.ResolveAuthConfig(cli.configFile, index)
+	authConfig, err := cli.resolveAuthConfig(index)
+	if err != nil {
+		return nil, -1, err
+	}
 	body, statusCode, err := cmdAttempt(authConfig)
 	if statusCode == http.StatusUnauthorized {
 		fmt.Fprintf(cli.out, "\nPlease login prior to %s:\n", cmdName)
 		if err = cli.CmdLogin(index.GetAuthConfigKey()); err != nil {
 			return nil, -1, err
 		}
-		authConfig = registry.ResolveAuthConfig(cli.configFile, index)
+		if authConfig, err = cli.resolveAuthConfig(index); err != nil {
+			return nil, -1, err
+		}
 		return cmdAttempt(authConfig)
 	}
 	return body, statusCode, err
 }
 
+// credentialsStore returns the store of the credentials of the registry at
+// serverAddress.
+func (cli *DockerCli) credentialsStore(serverAddress string) credentials.Store {
+	return credentials.NewStore(cli.configFile, serverAddress)
+}
+
+// resolveAuthConfig returns the credentials of the registry of the index,
+// from the store of its credentials.
+func (cli *DockerCli) resolveAuthConfig(index *registry.IndexInfo) (cliconfig.AuthConfig, error) {
+	// the configuration file may have the registry under another address
+	serverAddress := registry.ResolveAuthConfig(cli.configFile, index).ServerAddress
+	if serverAddress == "" {
+		serverAddress = index.GetAuthConfigKey()
+	}
+	return cli.credentialsStore(serverAddress).Get(serverAddress)
+}
+
+// getAllCredentials returns the credentials of all the registries known to
+// the configuration file, by address: those of the credentials store, and
+// those of the credential helpers of some registries.
+func (cli *DockerCli) getAllCredentials() (map[string]cliconfig.AuthConfig, error) {
+	authConfigs, err := credentials.NewStore(cli.configFile, "").GetAll()
+	if err != nil {
+		return nil, err
+	}
+	// the credentials of some registries are stored by other helpers
+	for serverAddress := range authConfigs {
+		if cli.configFile.CredentialsHelper(serverAddress) == cli.configFile.CredentialsStore {
+			continue
+		}
+		authConfig, err := cli.credentialsStore(serverAddress).Get(serverAddress)
+		if err != nil {
+			return nil, err
+		}
+		authConfigs[serverAddress] = authConfig
+	}
+	for serverAddress := range cli.configFile.CredentialHelpers {
+		if _, exists := authConfigs[serverAddress]; exists {
+			continue
+		}
+		authConfig, err := cli.credentialsStore(serverAddress).Get(serverAddress)
+		if err != nil {
+			return nil, err
+		}
+		if authConfig.Username != "" {
+			authConfigs[serverAddress] = authConfig
+		}
+	}
+	return authConfigs, nil
+}
+
+// encodeAuthToBase64 encodes the credentials of a registry for the
+// X-Registry-Auth header.
+func encodeAuthToBase64(authConfig cliconfig.AuthConfig) (string, error) {
+	buf, err := json.Marshal(authConfig)
+	if err != nil {
+		return "", err
+	}
+	return base64.URLEncoding.EncodeToString(buf), nil
+}
+
 func (cli *DockerCli) call(method, path string, data interface{}, headers map[string][]string) (io.ReadCloser, int, error) {
 	params, err := cli.encodeData(data)
 	if err != nil {
@@ -183,14 +259,14 @@ func (cli *DockerCli) stream(method, path string, opts *streamOpts) error {
 	if err != nil {
 		return err
 	}
-	return cli.streamBody(body, contentType, opts.rawTerminal, opts.out, opts.err)
+	return cli.streamBody(body, contentType, opts.rawTerminal, opts.out, opts.err, nil)
 }
 
-func (cli *DockerCli) streamBody(body io.ReadCloser, contentType string, rawTerminal bool, stdout, stderr io.Writer) error {
+func (cli *DockerCli) streamBody(body io.ReadCloser, contentType string, rawTerminal bool, stdout, stderr io.Writer, auxCallback func(*json.RawMessage)) error {
 	defer body.Close()
 
 	if api.MatchesContentType(contentType, "application/json") {
-		return jsonmessage.DisplayJSONMessagesStream(body, stdout, cli.outFd, cli.isTerminalOut)
+		return jsonmessage.DisplayJSONMessagesStream(body, stdout, cli.outFd, cli.isTerminalOut, auxCallback)
 	}
 	if stdout != nil || stderr != nil {
 		// When TTY is ON, use regular copy
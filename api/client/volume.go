@@ -0,0 +1,202 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/opts"
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// CmdVolume manages the volumes.
+//
+// Usage: docker volume COMMAND
+func (cli *DockerCli) CmdVolume(args ...string) error {
+	description := "Manage Docker volumes\n\nCommands:\n"
+	commands := [][]string{
+		{"create", "Create a volume"},
+		{"inspect", "Return low-level information on a volume"},
+		{"ls", "List volumes"},
+		{"rm", "Remove a volume"},
+	}
+	for _, cmd := range commands {
+		description += fmt.Sprintf("  %-10.10s%s\n", cmd[0], cmd[1])
+	}
+	description += "\nRun 'docker volume COMMAND --help' for more information on a command."
+
+	cmd := cli.Subcmd("volume", "[COMMAND]", description, true)
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+	cmd.Usage()
+	return nil
+}
+
+// CmdVolumeCreate creates a volume.
+//
+// Usage: docker volume create [OPTIONS]
+func (cli *DockerCli) CmdVolumeCreate(args ...string) error {
+	cmd := cli.Subcmd("volume create", "", "Create a volume", true)
+	name := cmd.String([]string{"-name"}, "", "Specify the volume name")
+	driver := cmd.String([]string{"d", "-driver"}, "local", "Specify the volume driver name")
+	flLabels := opts.NewListOpts(opts.ValidateEnv)
+	cmd.Var(&flLabels, []string{"l", "-label"}, "Set metadata on the volume")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	req := &types.VolumeCreateRequest{
+		Name:   *name,
+		Driver: *driver,
+		Labels: make(map[string]string),
+	}
+	for _, label := range flLabels.GetAll() {
+		kv := strings.SplitN(label, "=", 2)
+		if len(kv) == 1 {
+			req.Labels[kv[0]] = ""
+		} else {
+			req.Labels[kv[0]] = kv[1]
+		}
+	}
+
+	rdr, _, err := cli.call("POST", "/volumes/create", req, nil)
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	var volume types.Volume
+	if err := json.NewDecoder(rdr).Decode(&volume); err != nil {
+		return err
+	}
+	fmt.Fprintln(cli.out, volume.Name)
+	return nil
+}
+
+// CmdVolumeLs lists the volumes.
+//
+// Usage: docker volume ls [OPTIONS]
+func (cli *DockerCli) CmdVolumeLs(args ...string) error {
+	cmd := cli.Subcmd("volume ls", "", "List volumes", true)
+	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only display volume names")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values (i.e. 'dangling=true')")
+	cmd.Require(flag.Exact, 0)
+	cmd.ParseFlags(args, true)
+
+	volFilterArgs := filters.Args{}
+	for _, f := range flFilter.GetAll() {
+		var err error
+		volFilterArgs, err = filters.ParseFlag(f, volFilterArgs)
+		if err != nil {
+			return err
+		}
+	}
+
+	v := url.Values{}
+	if len(volFilterArgs) > 0 {
+		filterJSON, err := filters.ToParam(volFilterArgs)
+		if err != nil {
+			return err
+		}
+		v.Set("filters", filterJSON)
+	}
+
+	rdr, _, err := cli.call("GET", "/volumes?"+v.Encode(), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer rdr.Close()
+
+	var volumes types.VolumesListResponse
+	if err := json.NewDecoder(rdr).Decode(&volumes); err != nil {
+		return err
+	}
+
+	if *quiet {
+		for _, vol := range volumes.Volumes {
+			fmt.Fprintln(cli.out, vol.Name)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
+	fmt.Fprintln(w, "DRIVER\tVOLUME NAME\tMOUNTPOINT")
+	for _, vol := range volumes.Volumes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", vol.Driver, vol.Name, vol.Mountpoint)
+	}
+	w.Flush()
+	return nil
+}
+
+// CmdVolumeInspect displays low-level information on one or more volumes.
+//
+// Usage: docker volume inspect VOLUME [VOLUME...]
+func (cli *DockerCli) CmdVolumeInspect(args ...string) error {
+	cmd := cli.Subcmd("volume inspect", "VOLUME [VOLUME...]", "Return low-level information on a volume", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	indented := new(bytes.Buffer)
+	indented.WriteString("[\n")
+	status := 0
+	for _, name := range cmd.Args() {
+		obj, _, err := readBody(cli.call("GET", "/volumes/"+name, nil, nil))
+		if err != nil {
+			if strings.Contains(err.Error(), "No such") {
+				fmt.Fprintf(cli.err, "Error: No such volume: %s\n", name)
+			} else {
+				fmt.Fprintf(cli.err, "%s\n", err)
+			}
+			status = 1
+			continue
+		}
+		if err := json.Indent(indented, obj, "", "    "); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			status = 1
+			continue
+		}
+		indented.WriteString(",")
+	}
+
+	if indented.Len() > 1 {
+		// Remove trailing ','
+		indented.Truncate(indented.Len() - 1)
+	}
+	indented.WriteString("]\n")
+	if _, err := indented.WriteTo(cli.out); err != nil {
+		return err
+	}
+
+	if status != 0 {
+		return StatusError{StatusCode: status}
+	}
+	return nil
+}
+
+// CmdVolumeRm removes one or more volumes.
+//
+// Usage: docker volume rm VOLUME [VOLUME...]
+func (cli *DockerCli) CmdVolumeRm(args ...string) error {
+	cmd := cli.Subcmd("volume rm", "VOLUME [VOLUME...]", "Remove a volume", true)
+	cmd.Require(flag.Min, 1)
+	cmd.ParseFlags(args, true)
+
+	var errNames []string
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("DELETE", "/volumes/"+name, nil, nil)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			errNames = append(errNames, name)
+			continue
+		}
+		fmt.Fprintf(cli.out, "%s\n", name)
+	}
+	if len(errNames) > 0 {
+		return fmt.Errorf("Error: failed to remove volumes: %v", errNames)
+	}
+	return nil
+}
@@ -3,8 +3,10 @@ package api
 import (
 	"fmt"
 	"mime"
+	"net"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
@@ -45,7 +47,7 @@ func DisplayablePorts(ports []types.Port) string {
 		)
 		if port.IP != "" {
 			if port.PublicPort != current {
-				hostMappings = append(hostMappings, fmt.Sprintf("%s:%d->%d/%s", port.IP, port.PublicPort, port.PrivatePort, port.Type))
+				hostMappings = append(hostMappings, fmt.Sprintf("%s->%d/%s", net.JoinHostPort(port.IP, strconv.Itoa(port.PublicPort)), port.PrivatePort, port.Type))
 				continue
 			}
 			portKey = fmt.Sprintf("%s/%s", port.IP, port.Type)
@@ -91,7 +93,7 @@ func FormGroup(key string, start, last int) string {
 		group = fmt.Sprintf("%d-%d", start, last)
 	}
 	if ip != "" {
-		group = fmt.Sprintf("%s:%s->%s", ip, group, group)
+		group = fmt.Sprintf("%s->%s", net.JoinHostPort(ip, group), group)
 	}
 	return fmt.Sprintf("%s/%s", group, groupType)
 }
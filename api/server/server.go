@@ -21,11 +21,13 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/builder"
+	"github.com/docker/docker/builder/session"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/daemon"
+	"github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/pkg/ioutils"
-	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/pkg/metrics"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/filters"
 	"github.com/docker/docker/pkg/parsers/kernel"
@@ -40,16 +42,17 @@ import (
 )
 
 type ServerConfig struct {
-	Logging     bool
-	EnableCors  bool
-	CorsHeaders string
-	Version     string
-	SocketGroup string
-	Tls         bool
-	TlsVerify   bool
-	TlsCa       string
-	TlsCert     string
-	TlsKey      string
+	Logging       bool
+	EnableCors    bool
+	CorsHeaders   string
+	EnableMetrics bool
+	Version       string
+	SocketGroup   string
+	Tls           bool
+	TlsVerify     bool
+	TlsCa         string
+	TlsCert       string
+	TlsKey        string
 }
 
 type Server struct {
@@ -402,48 +405,23 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 		return err
 	}
 
-	isFiltered := func(field string, filter []string) bool {
-		if len(filter) == 0 {
-			return false
-		}
-		for _, v := range filter {
-			if v == field {
-				return false
-			}
-			if strings.Contains(field, ":") {
-				image := strings.Split(field, ":")
-				if image[0] == v {
-					return false
-				}
-			}
-		}
-		return true
-	}
-
 	d := s.daemon
 	es := d.EventsService
 	w.Header().Set("Content-Type", "application/json")
 	enc := json.NewEncoder(ioutils.NewWriteFlusher(w))
 
-	getContainerId := func(cn string) string {
-		c, err := d.Get(cn)
-		if err != nil {
-			return ""
+	// incoming container filter can be name, id or partial id, replace it with the full container id
+	for i, cn := range ef["container"] {
+		if c, err := d.Get(cn); err == nil {
+			ef["container"][i] = c.ID
 		}
-		return c.ID
 	}
+	filter := events.NewFilter(ef)
 
-	sendEvent := func(ev *jsonmessage.JSONMessage) error {
-		//incoming container filter can be name,id or partial id, convert and replace as a full container id
-		for i, cn := range ef["container"] {
-			ef["container"][i] = getContainerId(cn)
-		}
-
-		if isFiltered(ev.Status, ef["event"]) || isFiltered(ev.From, ef["image"]) ||
-			isFiltered(ev.ID, ef["container"]) {
+	sendEvent := func(ev *types.Event) error {
+		if !filter.Include(ev) {
 			return nil
 		}
-
 		return enc.Encode(ev)
 	}
 
@@ -460,7 +438,7 @@ func (s *Server) getEvents(version version.Version, w http.ResponseWriter, r *ht
 	for {
 		select {
 		case ev := <-l:
-			jev, ok := ev.(*jsonmessage.JSONMessage)
+			jev, ok := ev.(*types.Event)
 			if !ok {
 				continue
 			}
@@ -580,6 +558,11 @@ func (s *Server) getContainersLogs(version version.Version, w http.ResponseWrite
 		since = time.Unix(s, 0)
 	}
 
+	var closeNotifier <-chan bool
+	if notifier, ok := w.(http.CloseNotifier); ok {
+		closeNotifier = notifier.CloseNotify()
+	}
+
 	logsConfig := &daemon.ContainerLogsConfig{
 		Follow:     boolValue(r, "follow"),
 		Timestamps: boolValue(r, "timestamps"),
@@ -588,6 +571,7 @@ func (s *Server) getContainersLogs(version version.Version, w http.ResponseWrite
 		UseStdout:  stdout,
 		UseStderr:  stderr,
 		OutStream:  ioutils.NewWriteFlusher(w),
+		Stop:       closeNotifier,
 	}
 
 	if err := s.daemon.ContainerLogs(vars["name"], logsConfig); err != nil {
@@ -612,7 +596,7 @@ func (s *Server) postImagesTag(version version.Version, w http.ResponseWriter, r
 	if err := s.daemon.Repositories().Tag(repo, tag, name, force); err != nil {
 		return err
 	}
-	s.daemon.EventsService.Log("tag", utils.ImageReference(repo, tag), "")
+	s.daemon.EventsService.Log(types.ImageEventType, "tag", types.EventActor{ID: utils.ImageReference(repo, tag)})
 	w.WriteHeader(http.StatusCreated)
 	return nil
 }
@@ -650,6 +634,7 @@ func (s *Server) postCommit(version version.Version, w http.ResponseWriter, r *h
 		Comment: r.Form.Get("comment"),
 		Changes: r.Form["changes"],
 		Config:  c,
+		Squash:  boolValue(r, "squash"),
 	}
 
 	imgID, err := builder.Commit(s.daemon, cont, containerCommitConfig)
@@ -925,6 +910,29 @@ func (s *Server) postContainerRename(version version.Version, w http.ResponseWri
 	return nil
 }
 
+func (s *Server) postContainerUpdate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	var updateConfig runconfig.UpdateConfig
+	if err := json.NewDecoder(r.Body).Decode(&updateConfig); err != nil {
+		return err
+	}
+
+	warnings, err := s.daemon.ContainerUpdate(vars["name"], &updateConfig)
+	if err != nil {
+		return err
+	}
+
+	return writeJSON(w, http.StatusOK, &types.ContainerUpdateResponse{
+		Warnings: warnings,
+	})
+}
+
 func (s *Server) deleteContainers(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -1210,6 +1218,7 @@ func (s *Server) postBuild(version version.Version, w http.ResponseWriter, r *ht
 	buildConfig.SuppressOutput = boolValue(r, "q")
 	buildConfig.NoCache = boolValue(r, "nocache")
 	buildConfig.ForceRemove = boolValue(r, "forcerm")
+	buildConfig.Squash = boolValue(r, "squash")
 	buildConfig.AuthConfig = authConfig
 	buildConfig.ConfigFile = configFile
 	buildConfig.MemorySwap = int64ValueOrZero(r, "memswap")
@@ -1221,6 +1230,61 @@ func (s *Server) postBuild(version version.Version, w http.ResponseWriter, r *ht
 	buildConfig.CpuSetMems = r.FormValue("cpusetmems")
 	buildConfig.CgroupParent = r.FormValue("cgroupparent")
 
+	if buildArgsJSON := r.FormValue("buildargs"); buildArgsJSON != "" {
+		buildArgs := map[string]string{}
+		if err := json.NewDecoder(strings.NewReader(buildArgsJSON)).Decode(&buildArgs); err != nil {
+			return fmt.Errorf("Invalid buildargs: %v", err)
+		}
+		buildConfig.BuildArgs = buildArgs
+	}
+	if trustedRefsJSON := r.FormValue("trustedrefs"); trustedRefsJSON != "" {
+		trustedRefs := map[string]string{}
+		if err := json.NewDecoder(strings.NewReader(trustedRefsJSON)).Decode(&trustedRefs); err != nil {
+			return fmt.Errorf("Invalid trustedrefs: %v", err)
+		}
+		buildConfig.TrustedRefs = trustedRefs
+	}
+
+	if boolValue(r, "stream") {
+		if buildConfig.RemoteURL != "" {
+			return fmt.Errorf("Cannot stream the context of a remote build")
+		}
+		// The client sends the files of the context on the hijacked
+		// connection, when the builder requests them.
+		inStream, outStream, err := hijackServer(w)
+		if err != nil {
+			return err
+		}
+		defer closeStreams(inStream, outStream)
+
+		fmt.Fprintf(outStream, "HTTP/1.1 101 UPGRADED\r\nContent-Type: application/vnd.docker.raw-stream\r\nConnection: Upgrade\r\nUpgrade: tcp\r\n\r\n")
+
+		sess := session.New(inStream, outStream)
+		defer sess.Close()
+		buildConfig.Stdout = sess
+		buildConfig.Session = sess
+		buildConfig.ContextID = r.FormValue("contextid")
+
+		// the connection is hijacked, the session notices when the
+		// client goes away
+		finished := make(chan struct{})
+		defer close(finished)
+		go func() {
+			select {
+			case <-finished:
+			case <-sess.Closed():
+				logrus.Infof("Client disconnected, cancelling job: build")
+				buildConfig.Cancel()
+			}
+		}()
+
+		if err := builder.Build(s.daemon, buildConfig); err != nil {
+			sf := streamformatter.NewJSONStreamFormatter()
+			sess.Write(sf.FormatError(err))
+		}
+		return nil
+	}
+
 	// Job cancellation. Note: not all job types support this.
 	if closeNotifier, ok := w.(http.CloseNotifier); ok {
 		finished := make(chan struct{})
@@ -1286,6 +1350,97 @@ func (s *Server) postContainersCopy(version version.Version, w http.ResponseWrit
 	return nil
 }
 
+// archiveFormValues returns the name of the container and the path of the
+// archive endpoints.
+func archiveFormValues(r *http.Request, vars map[string]string) (name, path string, err error) {
+	if vars == nil {
+		return "", "", fmt.Errorf("Missing parameter")
+	}
+	if err := parseForm(r); err != nil {
+		return "", "", err
+	}
+	name, path = vars["name"], r.Form.Get("path")
+	if path == "" {
+		return "", "", fmt.Errorf("Bad parameter: path cannot be empty")
+	}
+	return name, path, nil
+}
+
+// archiveError converts the errors of the archive endpoints, for them to be
+// returned with the right status code.
+func archiveError(err error, name, path string) error {
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Could not find the file %s in container %s: no such file or directory", path, name)
+	}
+	return err
+}
+
+// setContainerPathStatHeader sets the X-Docker-Container-Path-Stat header of
+// the response to the base64-encoded JSON of the given stat.
+func setContainerPathStatHeader(stat *types.ContainerPathStat, header http.Header) error {
+	statJSON, err := json.Marshal(stat)
+	if err != nil {
+		return err
+	}
+	header.Set("X-Docker-Container-Path-Stat", base64.URLEncoding.EncodeToString(statJSON))
+	return nil
+}
+
+func (s *Server) headContainersArchive(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name, path, err := archiveFormValues(r, vars)
+	if err != nil {
+		return err
+	}
+
+	stat, err := s.daemon.ContainerStatPath(name, path)
+	if err != nil {
+		return archiveError(err, name, path)
+	}
+
+	return setContainerPathStatHeader(stat, w.Header())
+}
+
+func (s *Server) getContainersArchive(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name, path, err := archiveFormValues(r, vars)
+	if err != nil {
+		return err
+	}
+
+	data, stat, err := s.daemon.ContainerArchivePath(name, path)
+	if err != nil {
+		return archiveError(err, name, path)
+	}
+	defer data.Close()
+
+	if err := setContainerPathStatHeader(stat, w.Header()); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/x-tar")
+	_, err = io.Copy(w, data)
+	return err
+}
+
+func (s *Server) putContainersArchive(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	name, path, err := archiveFormValues(r, vars)
+	if err != nil {
+		return err
+	}
+
+	noOverwriteDirNonDir := boolValue(r, "noOverwriteDirNonDir")
+	err = s.daemon.ContainerExtractToDir(name, path, noOverwriteDirNonDir, r.Body)
+	switch err {
+	case nil:
+		return nil
+	case daemon.ErrExtractPointNotDirectory:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil
+	case daemon.ErrReadOnlyPath:
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return nil
+	}
+	return archiveError(err, name, path)
+}
+
 func (s *Server) postContainerExecCreate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return nil
@@ -1425,6 +1580,10 @@ func (s *Server) initTcpSocket(addr string) (l net.Listener, err error) {
 
 func makeHttpHandler(logging bool, localMethod string, localRoute string, handlerFunc HttpApiFunc, corsHeaders string, dockerVersion version.Version) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		defer func(start time.Time) {
+			requestDuration.Observe(time.Since(start).Seconds(), localMethod, localRoute)
+		}(time.Now())
+
 		// log the request
 		logrus.Debugf("Calling %s %s", localMethod, localRoute)
 
@@ -1458,6 +1617,163 @@ func makeHttpHandler(logging bool, localMethod string, localRoute string, handle
 	}
 }
 
+// requestDuration is the latency of the API requests, by method and route.
+var requestDuration = metrics.NewHistogram("docker_api_request_duration_seconds", "Latency of the remote API requests", metrics.DefaultBuckets, "method", "route")
+
+func init() {
+	metrics.Register(requestDuration)
+}
+
+func (s *Server) getMetrics(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", metrics.ContentType)
+	return metrics.DefaultRegistry.Write(w)
+}
+
+func (s *Server) getVolumesList(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+
+	volumes, err := s.daemon.Volumes(r.Form.Get("filters"))
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, &types.VolumesListResponse{Volumes: volumes})
+}
+
+func (s *Server) getVolumeByName(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	volume, err := s.daemon.VolumeInspect(vars["name"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, volume)
+}
+
+func (s *Server) postVolumesCreate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.VolumeCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	volume, err := s.daemon.VolumeCreate(req.Name, req.Driver, req.Labels)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, volume)
+}
+
+func (s *Server) deleteVolumes(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	if err := s.daemon.VolumeRm(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (s *Server) getNetworksList(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	networks, err := s.daemon.Networks()
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, networks)
+}
+
+func (s *Server) getNetworkByName(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	network, err := s.daemon.NetworkInspect(vars["name"])
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusOK, network)
+}
+
+func (s *Server) postNetworksCreate(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.NetworkCreate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	network, err := s.daemon.NetworkCreate(req.Name, req.Driver, req.Subnet, req.Gateway)
+	if err != nil {
+		return err
+	}
+	return writeJSON(w, http.StatusCreated, &types.NetworkCreateResponse{Id: network.Id})
+}
+
+func (s *Server) postNetworkConnect(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.NetworkConnect
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := s.daemon.NetworkConnect(vars["name"], req.Container, req.Aliases); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) postNetworkDisconnect(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+
+	var req types.NetworkConnect
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return err
+	}
+
+	if err := s.daemon.NetworkDisconnect(vars["name"], req.Container); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+func (s *Server) deleteNetworks(version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+
+	if err := s.daemon.NetworkRm(vars["name"]); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 // we keep enableCors just for legacy usage, need to be removed in the future
 func createRouter(s *Server) *mux.Router {
 	r := mux.NewRouter()
@@ -1465,6 +1781,9 @@ func createRouter(s *Server) *mux.Router {
 		ProfilerSetup(r, "/debug/")
 	}
 	m := map[string]map[string]HttpApiFunc{
+		"HEAD": {
+			"/containers/{name:.*}/archive": s.headContainersArchive,
+		},
 		"GET": {
 			"/_ping":                          s.ping,
 			"/events":                         s.getEvents,
@@ -1485,41 +1804,60 @@ func createRouter(s *Server) *mux.Router {
 			"/containers/{name:.*}/logs":      s.getContainersLogs,
 			"/containers/{name:.*}/stats":     s.getContainersStats,
 			"/containers/{name:.*}/attach/ws": s.wsContainersAttach,
+			"/containers/{name:.*}/archive":   s.getContainersArchive,
 			"/exec/{id:.*}/json":              s.getExecByID,
+			"/volumes":                        s.getVolumesList,
+			"/volumes/{name:.*}":              s.getVolumeByName,
+			"/networks":                       s.getNetworksList,
+			"/networks/{name:.*}":             s.getNetworkByName,
 		},
 		"POST": {
-			"/auth":                         s.postAuth,
-			"/commit":                       s.postCommit,
-			"/build":                        s.postBuild,
-			"/images/create":                s.postImagesCreate,
-			"/images/load":                  s.postImagesLoad,
-			"/images/{name:.*}/push":        s.postImagesPush,
-			"/images/{name:.*}/tag":         s.postImagesTag,
-			"/containers/create":            s.postContainersCreate,
-			"/containers/{name:.*}/kill":    s.postContainersKill,
-			"/containers/{name:.*}/pause":   s.postContainersPause,
-			"/containers/{name:.*}/unpause": s.postContainersUnpause,
-			"/containers/{name:.*}/restart": s.postContainersRestart,
-			"/containers/{name:.*}/start":   s.postContainersStart,
-			"/containers/{name:.*}/stop":    s.postContainersStop,
-			"/containers/{name:.*}/wait":    s.postContainersWait,
-			"/containers/{name:.*}/resize":  s.postContainersResize,
-			"/containers/{name:.*}/attach":  s.postContainersAttach,
-			"/containers/{name:.*}/copy":    s.postContainersCopy,
-			"/containers/{name:.*}/exec":    s.postContainerExecCreate,
-			"/exec/{name:.*}/start":         s.postContainerExecStart,
-			"/exec/{name:.*}/resize":        s.postContainerExecResize,
-			"/containers/{name:.*}/rename":  s.postContainerRename,
+			"/auth":                          s.postAuth,
+			"/commit":                        s.postCommit,
+			"/build":                         s.postBuild,
+			"/images/create":                 s.postImagesCreate,
+			"/images/load":                   s.postImagesLoad,
+			"/images/{name:.*}/push":         s.postImagesPush,
+			"/images/{name:.*}/tag":          s.postImagesTag,
+			"/containers/create":             s.postContainersCreate,
+			"/containers/{name:.*}/kill":     s.postContainersKill,
+			"/containers/{name:.*}/pause":    s.postContainersPause,
+			"/containers/{name:.*}/unpause":  s.postContainersUnpause,
+			"/containers/{name:.*}/restart":  s.postContainersRestart,
+			"/containers/{name:.*}/start":    s.postContainersStart,
+			"/containers/{name:.*}/stop":     s.postContainersStop,
+			"/containers/{name:.*}/wait":     s.postContainersWait,
+			"/containers/{name:.*}/resize":   s.postContainersResize,
+			"/containers/{name:.*}/attach":   s.postContainersAttach,
+			"/containers/{name:.*}/copy":     s.postContainersCopy,
+			"/containers/{name:.*}/exec":     s.postContainerExecCreate,
+			"/exec/{name:.*}/start":          s.postContainerExecStart,
+			"/exec/{name:.*}/resize":         s.postContainerExecResize,
+			"/containers/{name:.*}/rename":   s.postContainerRename,
+			"/containers/{name:.*}/update":   s.postContainerUpdate,
+			"/volumes/create":                s.postVolumesCreate,
+			"/networks/create":               s.postNetworksCreate,
+			"/networks/{name:.*}/connect":    s.postNetworkConnect,
+			"/networks/{name:.*}/disconnect": s.postNetworkDisconnect,
+		},
+		"PUT": {
+			"/containers/{name:.*}/archive": s.putContainersArchive,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": s.deleteContainers,
 			"/images/{name:.*}":     s.deleteImages,
+			"/volumes/{name:.*}":    s.deleteVolumes,
+			"/networks/{name:.*}":   s.deleteNetworks,
 		},
 		"OPTIONS": {
 			"": s.optionsHandler,
 		},
 	}
 
+	if s.cfg.EnableMetrics {
+		m["GET"]["/metrics"] = s.getMetrics
+	}
+
 	// If "api-cors-header" is not given, but "api-enable-cors" is true, we set cors to "*"
 	// otherwise, all head values will be passed to HTTP handler
 	corsHeaders := s.cfg.CorsHeaders
@@ -1,6 +1,7 @@
 package types
 
 import (
+	"os"
 	"time"
 
 	"github.com/docker/docker/daemon/network"
@@ -24,6 +25,12 @@ type ContainerExecCreateResponse struct {
 	ID string `json:"Id"`
 }
 
+// POST /containers/{name:.*}/update
+type ContainerUpdateResponse struct {
+	// Warnings are any warnings encountered during the update of the container.
+	Warnings []string `json:"Warnings"`
+}
+
 // POST /auth
 type AuthResponse struct {
 	// Status is the authentication status
@@ -63,6 +70,13 @@ type ImageDelete struct {
 	Deleted  string `json:",omitempty"`
 }
 
+// PushResult is the auxiliary data sent in the stream of
+// POST "/images/{name:.*}/push" for each tag pushed to a v2 registry
+type PushResult struct {
+	Tag    string
+	Digest string
+}
+
 // GET "/images/json"
 type Image struct {
 	ID          string `json:"Id"`
@@ -118,6 +132,18 @@ type CopyConfig struct {
 	Resource string
 }
 
+// HEAD and GET "/containers/{name:.*}/archive"
+// ContainerPathStat is sent base64-encoded in the X-Docker-Container-Path-Stat
+// header. LinkTarget is the path in the container the resource resolves to
+// when it is a symbolic link.
+type ContainerPathStat struct {
+	Name       string
+	Size       int64
+	Mode       os.FileMode
+	Mtime      time.Time
+	LinkTarget string
+}
+
 // GET "/containers/{name:.*}/top"
 type ContainerProcessList struct {
 	Processes [][]string
@@ -191,6 +217,29 @@ type ContainerState struct {
 	Error      string
 	StartedAt  time.Time
 	FinishedAt time.Time
+	Health     *Health `json:",omitempty"`
+}
+
+// Health states of a container with a healthcheck
+const (
+	Starting  = "starting"  // Starting is the state until the first probe succeeds
+	Healthy   = "healthy"   // Healthy is the state while the probe succeeds
+	Unhealthy = "unhealthy" // Unhealthy is the state once the probe failed too many times in a row
+)
+
+// Health is the health of a container with a healthcheck
+type Health struct {
+	Status        string               // Status is Starting, Healthy or Unhealthy
+	FailingStreak int                  // FailingStreak is the number of consecutive failed probes
+	Log           []*HealthcheckResult // Log holds the results of the last probes
+}
+
+// HealthcheckResult is the result of a single run of the healthcheck probe
+type HealthcheckResult struct {
+	Start    time.Time // Start is the time the probe started
+	End      time.Time // End is the time the probe finished
+	ExitCode int       // ExitCode is 0 if healthy, 1 if unhealthy, -1 if the probe could not be run
+	Output   string    // Output is the truncated output of the probe
 }
 
 // GET "/containers/{name:.*}/json"
@@ -219,3 +268,94 @@ type ContainerJSON struct {
 	ExecIDs         []string
 	HostConfig      *runconfig.HostConfig
 }
+
+// GET "/volumes/{name:.*}"
+type Volume struct {
+	Name       string
+	Driver     string
+	Mountpoint string
+	Labels     map[string]string
+}
+
+// GET "/volumes"
+type VolumesListResponse struct {
+	Volumes []*Volume
+}
+
+// POST "/volumes/create"
+type VolumeCreateRequest struct {
+	Name   string
+	Driver string
+	Labels map[string]string
+}
+
+// GET "/networks" and "/networks/{name:.*}"
+type NetworkResource struct {
+	Name       string
+	Id         string
+	Driver     string
+	Subnet     string
+	Gateway    string
+	Containers map[string]EndpointResource
+}
+
+// EndpointResource is the endpoint of a container in a network
+type EndpointResource struct {
+	Name        string
+	EndpointID  string
+	MacAddress  string
+	IPv4Address string
+	IPv6Address string
+}
+
+// POST "/networks/create"
+type NetworkCreate struct {
+	Name    string
+	Driver  string
+	Subnet  string
+	Gateway string
+}
+
+// POST "/networks/create"
+type NetworkCreateResponse struct {
+	Id string
+}
+
+// POST "/networks/{name:.*}/connect" and "/networks/{name:.*}/disconnect"
+type NetworkConnect struct {
+	Container string
+	Aliases   []string `json:",omitempty"`
+}
+
+// The types of the objects the events are about
+const (
+	ContainerEventType = "container"
+	ImageEventType     = "image"
+	VolumeEventType    = "volume"
+	NetworkEventType   = "network"
+	DaemonEventType    = "daemon"
+)
+
+// EventActor is the object an event is about: its ID, and attributes such as
+// its name, its labels or the exit code of a container
+type EventActor struct {
+	ID         string
+	Attributes map[string]string
+	// the labels among the attributes, which the label filter matches
+	Labels map[string]string `json:"-"`
+}
+
+// GET "/events"
+type Event struct {
+	// The action, the ID of the actor and the image of a container, as
+	// returned before the events were typed
+	Status string `json:"status,omitempty"`
+	ID     string `json:"id,omitempty"`
+	From   string `json:"from,omitempty"`
+
+	Type   string
+	Action string
+	Actor  EventActor
+
+	Time int64 `json:"time,omitempty"`
+}
@@ -14,11 +14,14 @@ import (
 	"regexp"
 	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/nat"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -165,43 +168,80 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 	return b.runContextCommand(args, true, true, "ADD")
 }
 
-// COPY foo /path
+// COPY [--from=stage] foo /path
 //
-// Same as 'ADD' but without the tar and remote url handling.
+// Same as 'ADD' but without the tar and remote url handling. With --from,
+// the files are copied from the image of an earlier build stage instead of
+// the context.
 //
 func dispatchCopy(b *Builder, args []string, attributes map[string]bool, original string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("COPY requires at least two arguments")
 	}
 
+	flFrom := b.BuilderFlags.AddString("from", "")
+
 	if err := b.BuilderFlags.Parse(); err != nil {
 		return err
 	}
 
+	if flFrom.Value != "" {
+		// the flags are not expanded with the arguments, the stage can
+		// be given by a build-time variable
+		envs := append(b.Config.Env[:len(b.Config.Env):len(b.Config.Env)], b.buildArgsEnv()...)
+		stageName, err := ProcessWord(flFrom.Value, envs)
+		if err != nil {
+			return err
+		}
+		return b.runStageCopyCommand(args, stageName)
+	}
+
 	return b.runContextCommand(args, false, false, "COPY")
 }
 
-// FROM imagename
+// FROM imagename [AS name]
 //
-// This sets the image the dockerfile will build on top of.
+// This sets the image the dockerfile will build on top of. Each FROM starts
+// a new build stage, which can be named so that later stages can copy files
+// from it. The image built by the last stage is the result of the build.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return fmt.Errorf("FROM requires one argument")
+	if len(args) != 1 && (len(args) != 3 || !strings.EqualFold(args[1], "AS")) {
+		return fmt.Errorf("FROM requires either one argument, or three: FROM <image> AS <name>")
 	}
 
 	if err := b.BuilderFlags.Parse(); err != nil {
 		return err
 	}
 
+	if err := b.startStage(args[2:]); err != nil {
+		return err
+	}
+
 	name := args[0]
 
+	// an earlier stage can be used as the base image
+	if stage := b.findStage(name); stage != nil && stage.name != "" {
+		if stage.image == "" {
+			return fmt.Errorf("Build stage %s did not produce an image", name)
+		}
+		image, err := b.Daemon.Graph().Get(stage.image)
+		if err != nil {
+			return err
+		}
+		return b.processImageFrom(image)
+	}
+
 	if name == NoBaseImageSpecifier {
 		b.image = ""
 		b.noBaseImage = true
 		return nil
 	}
 
+	if b.trustedRefs != nil {
+		return b.fromTrusted(name)
+	}
+
 	image, err := b.Daemon.Repositories().LookupImage(name)
 	if b.Pull {
 		image, err = b.pullImage(name)
@@ -325,8 +365,22 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 
 	defer func(cmd *runconfig.Command) { b.Config.Cmd = cmd }(cmd)
 
+	// The build-time variables are set in the environment of the command,
+	// but not persisted in the image config. They are part of the command
+	// recorded in the container config instead, prefixed with their count,
+	// so that the cache is busted when they change:
+	//
+	//   |2 FOO=1 BAR=2 /bin/sh -c cmd
+	saveCmd := b.Config.Cmd
+	cmdBuildEnv := b.buildArgsEnv()
+	if len(cmdBuildEnv) > 0 {
+		tmpCmd := append([]string{"|" + strconv.Itoa(len(cmdBuildEnv))}, cmdBuildEnv...)
+		saveCmd = runconfig.NewCommand(append(tmpCmd, b.Config.Cmd.Slice()...)...)
+	}
+
 	logrus.Debugf("[BUILDER] Command to be executed: %v", b.Config.Cmd)
 
+	b.Config.Cmd = saveCmd
 	hit, err := b.probeCache()
 	if err != nil {
 		return err
@@ -335,6 +389,13 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return nil
 	}
 
+	// run the actual command with the build-time variables in its
+	// environment
+	env := b.Config.Env
+	b.Config.Env = append(env[:len(env):len(env)], cmdBuildEnv...)
+	b.Config.Cmd = config.Cmd
+	defer func() { b.Config.Env = env }()
+
 	c, err := b.create()
 	if err != nil {
 		return err
@@ -349,6 +410,12 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	if err != nil {
 		return err
 	}
+
+	// The container shares b.Config, revert the environment and record
+	// the build-time variables in the command, so that they are not
+	// committed but future cache lookups match it.
+	b.Config.Env = env
+	b.Config.Cmd = saveCmd
 	if err := b.commit(c.ID, cmd, "run"); err != nil {
 		return err
 	}
@@ -536,3 +603,160 @@ func volume(b *Builder, args []string, attributes map[string]bool, original stri
 	}
 	return nil
 }
+
+// ARG name[=value]
+//
+// Declares a build-time variable, which the user can set with --build-arg,
+// with an optional default value. It can be used by the following
+// instructions like an ENV variable, but is not persisted in the image.
+//
+func arg(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ARG requires exactly one argument definition")
+	}
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	var (
+		name       string
+		value      string
+		hasDefault bool
+	)
+
+	arg := args[0]
+	if parts := strings.SplitN(arg, "=", 2); len(parts) == 2 {
+		name = parts[0]
+		value = parts[1]
+		hasDefault = true
+	} else {
+		name = arg
+	}
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("Invalid ARG name %q", name)
+	}
+
+	b.allowedBuildArgs[name] = true
+	b.declaredBuildArgs[name] = true
+
+	// the default value is used unless the user passed one
+	if hasDefault {
+		b.buildArgDefaults[name] = value
+	}
+
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("ARG %s", arg))
+}
+
+// STOPSIGNAL signal
+//
+// Set the signal that will be used to stop the container.
+//
+func stopSignal(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("STOPSIGNAL requires exactly one argument")
+	}
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	sig := args[0]
+	if _, err := signal.ParseSignal(sig); err != nil {
+		return err
+	}
+
+	b.Config.StopSignal = sig
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("STOPSIGNAL %v", sig))
+}
+
+// HEALTHCHECK [--interval=30s] [--timeout=30s] [--retries=3] CMD command
+// HEALTHCHECK NONE
+//
+// Set the command run periodically in the container to check that it is
+// still working, or disable the healthcheck inherited from the base image.
+// Argument handling is the same as RUN.
+//
+func healthcheck(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("HEALTHCHECK requires an argument")
+	}
+
+	typ := strings.ToUpper(args[0])
+	args = args[1:]
+
+	if typ == "NONE" {
+		if len(args) != 0 {
+			return fmt.Errorf("HEALTHCHECK NONE takes no arguments")
+		}
+		if err := b.BuilderFlags.Parse(); err != nil {
+			return err
+		}
+		b.Config.Healthcheck = &runconfig.HealthConfig{
+			Test: []string{typ},
+		}
+		return b.commit("", b.Config.Cmd, "HEALTHCHECK NONE")
+	}
+
+	if typ != "CMD" {
+		return fmt.Errorf("Unknown type %q in HEALTHCHECK (try CMD)", typ)
+	}
+
+	flInterval := b.BuilderFlags.AddString("interval", "")
+	flTimeout := b.BuilderFlags.AddString("timeout", "")
+	flRetries := b.BuilderFlags.AddString("retries", "")
+
+	if err := b.BuilderFlags.Parse(); err != nil {
+		return err
+	}
+
+	cmdSlice := handleJsonArgs(args, attributes)
+	if len(cmdSlice) == 0 || (len(cmdSlice) == 1 && cmdSlice[0] == "") {
+		return fmt.Errorf("Missing command after HEALTHCHECK CMD")
+	}
+	if !attributes["json"] {
+		typ = "CMD-SHELL"
+	}
+
+	healthcheck := &runconfig.HealthConfig{
+		Test: append([]string{typ}, cmdSlice...),
+	}
+
+	var err error
+	if healthcheck.Interval, err = parseOptInterval(flInterval); err != nil {
+		return err
+	}
+	if healthcheck.Timeout, err = parseOptInterval(flTimeout); err != nil {
+		return err
+	}
+	if flRetries.Value != "" {
+		retries, err := strconv.Atoi(flRetries.Value)
+		if err != nil {
+			return fmt.Errorf("Invalid --retries for HEALTHCHECK: %v", err)
+		}
+		if retries < 1 {
+			return fmt.Errorf("--retries must be at least 1 (not %d)", retries)
+		}
+		healthcheck.Retries = retries
+	}
+
+	b.Config.Healthcheck = healthcheck
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("HEALTHCHECK --interval=%s --timeout=%s --retries=%d %q",
+		healthcheck.Interval, healthcheck.Timeout, healthcheck.Retries, healthcheck.Test))
+}
+
+// parseOptInterval parses the duration of a HEALTHCHECK flag, which has to
+// be positive if set.
+func parseOptInterval(f *Flag) (time.Duration, error) {
+	if f.Value == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(f.Value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid --%s for HEALTHCHECK: %v", f.name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("--%s must be positive (not %s)", f.name, f.Value)
+	}
+	return d, nil
+}
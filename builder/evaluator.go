@@ -24,12 +24,14 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/builder/command"
 	"github.com/docker/docker/builder/parser"
+	"github.com/docker/docker/builder/session"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/pkg/fileutils"
@@ -43,37 +45,54 @@ import (
 
 // Environment variable interpolation will happen on these statements only.
 var replaceEnvAllowed = map[string]struct{}{
-	command.Env:     {},
-	command.Label:   {},
-	command.Add:     {},
-	command.Copy:    {},
-	command.Workdir: {},
-	command.Expose:  {},
-	command.Volume:  {},
-	command.User:    {},
+	command.Env:        {},
+	command.Label:      {},
+	command.Add:        {},
+	command.Copy:       {},
+	command.Workdir:    {},
+	command.Expose:     {},
+	command.Volume:     {},
+	command.User:       {},
+	command.StopSignal: {},
 }
 
 var evaluateTable map[string]func(*Builder, []string, map[string]bool, string) error
 
 func init() {
 	evaluateTable = map[string]func(*Builder, []string, map[string]bool, string) error{
-		command.Env:        env,
-		command.Label:      label,
-		command.Maintainer: maintainer,
-		command.Add:        add,
-		command.Copy:       dispatchCopy, // copy() is a go builtin
-		command.From:       from,
-		command.Onbuild:    onbuild,
-		command.Workdir:    workdir,
-		command.Run:        run,
-		command.Cmd:        cmd,
-		command.Entrypoint: entrypoint,
-		command.Expose:     expose,
-		command.Volume:     volume,
-		command.User:       user,
+		command.Env:         env,
+		command.Label:       label,
+		command.Maintainer:  maintainer,
+		command.Add:         add,
+		command.Copy:        dispatchCopy, // copy() is a go builtin
+		command.From:        from,
+		command.Onbuild:     onbuild,
+		command.Workdir:     workdir,
+		command.Run:         run,
+		command.Cmd:         cmd,
+		command.Entrypoint:  entrypoint,
+		command.Expose:      expose,
+		command.Volume:      volume,
+		command.User:        user,
+		command.Arg:         arg,
+		command.Healthcheck: healthcheck,
+		command.StopSignal:  stopSignal,
 	}
 }
 
+// BuiltinAllowedBuildArgs are the build-time variables which can be passed
+// with --build-arg without being declared by an ARG instruction.
+var BuiltinAllowedBuildArgs = map[string]bool{
+	"HTTP_PROXY":  true,
+	"http_proxy":  true,
+	"HTTPS_PROXY": true,
+	"https_proxy": true,
+	"FTP_PROXY":   true,
+	"ftp_proxy":   true,
+	"NO_PROXY":    true,
+	"no_proxy":    true,
+}
+
 // internal struct, used to maintain configuration of the Dockerfile's
 // processing as it evaluates the parsing result.
 type Builder struct {
@@ -93,6 +112,10 @@ type Builder struct {
 	ForceRemove bool
 	Pull        bool
 
+	// set this to true to squash the layers created by the build into one
+	// layer on top of the base image.
+	Squash bool
+
 	// set this to true if we want the builder to not commit between steps.
 	// This is useful when we only want to use the evaluator table to generate
 	// the final configs of the Dockerfile but dont want the layers
@@ -113,6 +136,7 @@ type Builder struct {
 	dockerfileName string        // name of Dockerfile
 	dockerfile     *parser.Node  // the syntax tree of the dockerfile
 	image          string        // image name for commit processing
+	baseImage      string        // the image of the FROM instruction of the current stage, empty for scratch
 	maintainer     string        // maintainer name. could probably be removed.
 	cmdSet         bool          // indicates is CMD was set in current Dockerfile
 	BuilderFlags   *BuilderFlags // current cmd's BuilderFlags - temporary
@@ -120,6 +144,24 @@ type Builder struct {
 	contextPath    string        // the path of the temporary directory the local context is unpacked to (server side)
 	noBaseImage    bool          // indicates that this build does not start from any base image, but is being built from an empty file system.
 
+	// streaming context, used instead of the uploaded context when set
+	session      *session.Session
+	contextID    string              // ID given by the client to the streamed context, to reuse the files received by the previous builds
+	contextCache string              // the path of the directory keeping the files received from the client
+	knownSums    map[string]string   // the tarsums of the files in the contextCache, as sent by the client
+	streamedSums tarsum.FileInfoSums // the tarsums of the files copied to the contextPath so far
+
+	buildArgs         map[string]string // build-time variables passed by the user with --build-arg
+	allowedBuildArgs  map[string]bool   // build-time variables declared with ARG in the current stage, which it can use
+	buildArgDefaults  map[string]string // default values of the ARG instructions of the current stage
+	declaredBuildArgs map[string]bool   // build-time variables declared with ARG in any stage
+
+	trustedRefs map[string]string // references to the signed digests of the base images, by name, with content trust
+
+	stageCount int           // number of build stages started, one per FROM instruction
+	stageName  string        // name of the current build stage, set with FROM image AS name
+	stages     []*buildStage // the completed build stages, which COPY --from can copy from
+
 	// Set resource restrictions for build containers
 	cpuSetCpus   string
 	cpuSetMems   string
@@ -137,7 +179,8 @@ type Builder struct {
 // will (barring errors):
 //
 // * call readContext() which will set up the temporary directory and unpack
-//   the context into it.
+//   the context into it, or openSession() when the context is streamed, in
+//   which case the files are requested from the client as they are needed.
 // * read the dockerfile
 // * parse the dockerfile
 // * walk the parse tree and execute it by dispatching to handlers. If Remove
@@ -146,7 +189,13 @@ type Builder struct {
 // * Print a happy message and return the image ID.
 //
 func (b *Builder) Run(context io.Reader) (string, error) {
-	if err := b.readContext(context); err != nil {
+	if b.session != nil {
+		closeSession, err := b.openSession()
+		if err != nil {
+			return "", err
+		}
+		defer closeSession()
+	} else if err := b.readContext(context); err != nil {
 		return "", err
 	}
 
@@ -164,6 +213,9 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 	b.Config = &runconfig.Config{}
 
 	b.TmpContainers = map[string]struct{}{}
+	b.allowedBuildArgs = make(map[string]bool)
+	b.buildArgDefaults = make(map[string]string)
+	b.declaredBuildArgs = make(map[string]bool)
 
 	for i, n := range b.dockerfile.Children {
 		select {
@@ -186,10 +238,30 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 		}
 	}
 
+	// check that all the build-args passed by the user were consumed by
+	// the Dockerfile, to catch typos
+	leftoverArgs := []string{}
+	for arg := range b.buildArgs {
+		if _, ok := BuiltinAllowedBuildArgs[arg]; !ok && !b.declaredBuildArgs[arg] {
+			leftoverArgs = append(leftoverArgs, arg)
+		}
+	}
+	if len(leftoverArgs) > 0 {
+		sort.Strings(leftoverArgs)
+		return "", fmt.Errorf("One or more build-args %v were not consumed, failing build.", leftoverArgs)
+	}
+
+	// only the image of the last stage is the result of the build
 	if b.image == "" {
 		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?")
 	}
 
+	if b.Squash {
+		if err := b.squash(); err != nil {
+			return "", err
+		}
+	}
+
 	fmt.Fprintf(b.OutStream, "Successfully built %s\n", stringid.TruncateID(b.image))
 	return b.image, nil
 }
@@ -197,6 +269,16 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 // Reads a Dockerfile from the current context. It assumes that the
 // 'filename' is a relative path from the root of the context
 func (b *Builder) readDockerfile() error {
+	if b.session != nil {
+		names := []string{b.dockerfileName, ".dockerignore"}
+		if b.dockerfileName == "" {
+			names = []string{api.DefaultDockerfileName, strings.ToLower(api.DefaultDockerfileName), ".dockerignore"}
+		}
+		if err := b.fetchContextFiles(names); err != nil {
+			return err
+		}
+	}
+
 	// If no -f was specified then look for 'Dockerfile'. If we can't find
 	// that then look for 'dockerfile'.  If neither are found then default
 	// back to 'Dockerfile' and use that in the error message.
@@ -250,11 +332,11 @@ func (b *Builder) readDockerfile() error {
 	excludes, _ := utils.ReadDockerIgnore(filepath.Join(b.contextPath, ".dockerignore"))
 	if rm, _ := fileutils.Matches(".dockerignore", excludes); rm == true {
 		os.Remove(filepath.Join(b.contextPath, ".dockerignore"))
-		b.context.(tarsum.BuilderContext).Remove(".dockerignore")
+		b.removeFromContext(".dockerignore")
 	}
 	if rm, _ := fileutils.Matches(b.dockerfileName, excludes); rm == true {
 		os.Remove(filepath.Join(b.contextPath, b.dockerfileName))
-		b.context.(tarsum.BuilderContext).Remove(b.dockerfileName)
+		b.removeFromContext(b.dockerfileName)
 	}
 
 	return nil
@@ -314,6 +396,13 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	copy(strList, strs)
 	msgList := make([]string, n)
 
+	// the build-time variables are expanded too, but the variables set
+	// with ENV take precedence over them
+	envs := b.Config.Env
+	if _, ok := replaceEnvAllowed[cmd]; ok {
+		envs = append(envs[:len(envs):len(envs)], b.buildArgsEnv()...)
+	}
+
 	var i int
 	for ast.Next != nil {
 		ast = ast.Next
@@ -321,7 +410,7 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 		str = ast.Value
 		if _, ok := replaceEnvAllowed[cmd]; ok {
 			var err error
-			str, err = ProcessWord(ast.Value, b.Config.Env)
+			str, err = ProcessWord(ast.Value, envs)
 			if err != nil {
 				return err
 			}
@@ -344,3 +433,46 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 
 	return fmt.Errorf("Unknown instruction: %s", strings.ToUpper(cmd))
 }
+
+// isBuildArgAllowed returns whether the build-time variable can be used by
+// the Dockerfile, either because it was declared with ARG or because it is
+// one of the builtin ones.
+func (b *Builder) isBuildArgAllowed(arg string) bool {
+	if _, ok := BuiltinAllowedBuildArgs[arg]; ok {
+		return true
+	}
+	if _, ok := b.allowedBuildArgs[arg]; ok {
+		return true
+	}
+	return false
+}
+
+// buildArgsEnv returns the allowed build-time variables which are not
+// overridden by an ENV instruction, in "key=value" form, sorted so that
+// they can be part of the build cache key. The values passed by the user
+// take precedence over the defaults of the ARG instructions.
+func (b *Builder) buildArgsEnv() []string {
+	configEnv := make(map[string]struct{}, len(b.Config.Env))
+	for _, kv := range b.Config.Env {
+		configEnv[strings.SplitN(kv, "=", 2)[0]] = struct{}{}
+	}
+	values := make(map[string]string, len(b.buildArgs)+len(b.buildArgDefaults))
+	for key, val := range b.buildArgDefaults {
+		values[key] = val
+	}
+	for key, val := range b.buildArgs {
+		values[key] = val
+	}
+	env := []string{}
+	for key, val := range values {
+		if !b.isBuildArgAllowed(key) {
+			continue
+		}
+		if _, ok := configEnv[key]; ok {
+			continue
+		}
+		env = append(env, key+"="+val)
+	}
+	sort.Strings(env)
+	return env
+}
@@ -14,7 +14,9 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -23,6 +25,7 @@ import (
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/graph"
+	"github.com/docker/docker/graph/tags"
 	imagepkg "github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/chrootarchive"
@@ -32,11 +35,13 @@ import (
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/progressreader"
 	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/system"
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/pkg/urlutil"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
 )
 
 func (b *Builder) readContext(context io.Reader) error {
@@ -112,6 +117,23 @@ func (b *Builder) commit(id string, autoCmd *runconfig.Command, comment string)
 	return nil
 }
 
+// squash replaces the image of the build with an image whose single layer
+// holds the changes of all the layers created on top of the base image. The
+// intermediate images of the build are kept, they are the build cache of the
+// next builds.
+func (b *Builder) squash() error {
+	img, err := b.Daemon.Graph().Squash(b.image, b.baseImage)
+	if err != nil {
+		return err
+	}
+	if img.ID == b.image {
+		return nil
+	}
+	fmt.Fprintf(b.OutStream, "Squashed the layers of the build into %s\n", stringid.TruncateID(img.ID))
+	b.image = img.ID
+	return nil
+}
+
 type copyInfo struct {
 	origPath   string
 	destPath   string
@@ -121,7 +143,7 @@ type copyInfo struct {
 }
 
 func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecompression bool, cmdName string) error {
-	if b.context == nil {
+	if b.context == nil && b.session == nil {
 		return fmt.Errorf("No context given. Impossible to use %s", cmdName)
 	}
 
@@ -143,6 +165,21 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 		}
 	}()
 
+	// Get the sources from the client first if the context is streamed
+	if b.session != nil {
+		var paths []string
+		for _, orig := range args[0 : len(args)-1] {
+			if !urlutil.IsURL(orig) {
+				paths = append(paths, orig)
+			}
+		}
+		if len(paths) > 0 {
+			if err := b.fetchContextFiles(paths); err != nil {
+				return err
+			}
+		}
+	}
+
 	// Loop through each src file and calculate the info we need to
 	// do the copy (e.g. hash value if cached).  Don't actually do
 	// the copy until we've looked at all src files
@@ -352,7 +389,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 
 	// Deal with wildcards
 	if allowWildcards && ContainsWildcards(origPath) {
-		for _, fileInfo := range b.context.GetSums() {
+		for _, fileInfo := range b.contextSums() {
 			if fileInfo.Name() == "" {
 				continue
 			}
@@ -385,7 +422,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	// Deal with the single file case
 	if !fi.IsDir() {
 		// This will match first file in sums of the archive
-		fis := b.context.GetSums().GetFile(ci.origPath)
+		fis := b.contextSums().GetFile(ci.origPath)
 		if fis != nil {
 			ci.hash = "file:" + fis.Sum()
 		}
@@ -406,7 +443,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	// Need path w/o / too to find matching dir w/o trailing /
 	absOrigPathNoSlash := absOrigPath[:len(absOrigPath)-1]
 
-	for _, fileInfo := range b.context.GetSums() {
+	for _, fileInfo := range b.contextSums() {
 		absFile := path.Join(b.contextPath, fileInfo.Name())
 		// Any file in the context that starts with the given path will be
 		// picked up and its hashcode used.  However, we'll exclude the
@@ -474,8 +511,40 @@ func (b *Builder) pullImage(name string) (*imagepkg.Image, error) {
 	return image, nil
 }
 
+// fromTrusted starts from the base image which the client resolved name to
+// with content trust. The image is pulled by digest if it is missing, and
+// tagged with name.
+func (b *Builder) fromTrusted(name string) error {
+	ref, ok := b.trustedRefs[name]
+	if !ok {
+		return fmt.Errorf("The image %s was not resolved to a signed digest by the client, which enabled content trust", name)
+	}
+
+	image, err := b.Daemon.Repositories().LookupImage(ref)
+	if err != nil {
+		if !b.Daemon.Graph().IsNotExist(err, ref) {
+			return err
+		}
+		if image, err = b.pullImage(ref); err != nil {
+			return err
+		}
+	}
+
+	// tag the image, unless name is a digest reference already
+	if remote, tag := parsers.ParseRepositoryTag(name); !utils.DigestReference(tag) {
+		if tag == "" {
+			tag = tags.DEFAULTTAG
+		}
+		if err := b.Daemon.Repositories().Tag(remote, tag, image.ID, true); err != nil {
+			return err
+		}
+	}
+	return b.processImageFrom(image)
+}
+
 func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	b.image = img.ID
+	b.baseImage = img.ID
 
 	if img.Config != nil {
 		b.Config = img.Config
@@ -520,6 +589,193 @@ func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	return nil
 }
 
+// buildStage is a completed build stage of a multi-stage Dockerfile.
+type buildStage struct {
+	name  string // the name given with FROM image AS name, if any
+	image string // the ID of the image built by the stage
+}
+
+var validStageName = regexp.MustCompile(`^[a-z][a-z0-9_.-]*$`)
+
+// startStage is called by each FROM instruction. It records the stage which
+// just ended, if any, and resets the state of the builder for the next one,
+// so that a stage does not inherit the config, build-time variables or
+// cache misses of the previous one.
+func (b *Builder) startStage(nameArgs []string) error {
+	var name string
+	if len(nameArgs) > 0 {
+		name = strings.ToLower(nameArgs[0])
+		if !validStageName.MatchString(name) {
+			return fmt.Errorf("Invalid name for build stage: %q, name can't start with a number or contain symbols", nameArgs[0])
+		}
+	}
+
+	if b.stageCount > 0 {
+		b.stages = append(b.stages, &buildStage{name: b.stageName, image: b.image})
+
+		b.Config = &runconfig.Config{}
+		b.image = ""
+		b.baseImage = ""
+		b.noBaseImage = false
+		b.maintainer = ""
+		b.cmdSet = false
+		b.cacheBusted = false
+		b.allowedBuildArgs = make(map[string]bool)
+		b.buildArgDefaults = make(map[string]string)
+	}
+
+	if name != "" {
+		for _, stage := range b.stages {
+			if stage.name == name {
+				return fmt.Errorf("Duplicate name for build stage: %q", name)
+			}
+		}
+	}
+	b.stageCount++
+	b.stageName = name
+	return nil
+}
+
+// findStage returns the completed build stage with the given name, or
+// index (starting at 0), or nil if there is none.
+func (b *Builder) findStage(nameOrIndex string) *buildStage {
+	name := strings.ToLower(nameOrIndex)
+	for _, stage := range b.stages {
+		if stage.name != "" && stage.name == name {
+			return stage
+		}
+	}
+	if i, err := strconv.Atoi(nameOrIndex); err == nil && i >= 0 && i < len(b.stages) {
+		return b.stages[i]
+	}
+	return nil
+}
+
+// runStageCopyCommand copies files from the image of an earlier build stage
+// into a new layer. The cache key of the copy is the ID of the image of the
+// stage, together with the copied paths: when the earlier stage is rebuilt,
+// the copy is done again.
+func (b *Builder) runStageCopyCommand(args []string, stageName string) error {
+	stage := b.findStage(stageName)
+	if stage == nil {
+		return fmt.Errorf("COPY --from: unknown build stage %q", stageName)
+	}
+	if stage.image == "" {
+		return fmt.Errorf("COPY --from: build stage %s did not produce an image", stageName)
+	}
+
+	dest := args[len(args)-1] // last one is always the dest
+	if !filepath.IsAbs(dest) {
+		hasSlash := strings.HasSuffix(dest, "/")
+		dest = filepath.Join("/", b.Config.WorkingDir, dest)
+		if hasSlash {
+			dest += "/"
+		}
+	}
+
+	img, err := b.Daemon.Graph().Get(stage.image)
+	if err != nil {
+		return err
+	}
+	driver := b.Daemon.Graph().Driver()
+	root, err := driver.Get(img.LayerID, "")
+	if err != nil {
+		return err
+	}
+	defer driver.Put(img.LayerID)
+
+	var origPaths []string
+	for _, orig := range args[0 : len(args)-1] {
+		orig = filepath.Clean("/" + orig)
+		if !ContainsWildcards(orig) {
+			origPaths = append(origPaths, orig)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(root, orig))
+		if err != nil {
+			return err
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(root, m)
+			if err != nil {
+				return err
+			}
+			origPaths = append(origPaths, "/"+rel)
+		}
+	}
+	if len(origPaths) == 0 {
+		return fmt.Errorf("No source files were specified")
+	}
+	if len(origPaths) > 1 && !strings.HasSuffix(dest, "/") {
+		return fmt.Errorf("When using COPY with more than one source file, the destination must be a directory and end with a /")
+	}
+
+	// resolve the sources in the rootfs of the stage, without following
+	// symlinks out of it
+	srcPaths := make([]string, len(origPaths))
+	for i, orig := range origPaths {
+		src, err := symlink.FollowSymlinkInScope(filepath.Join(root, orig), root)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(src); err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("COPY --from=%s: %s: no such file or directory", stageName, orig)
+			}
+			return err
+		}
+		srcPaths[i] = src
+	}
+
+	b.Config.Image = b.image
+
+	cmd := b.Config.Cmd
+	b.Config.Cmd = runconfig.NewCommand("/bin/sh", "-c", fmt.Sprintf("#(nop) COPY from:%s:%s in %s", stage.image, strings.Join(origPaths, ","), dest))
+	defer func(cmd *runconfig.Command) { b.Config.Cmd = cmd }(cmd)
+
+	hit, err := b.probeCache()
+	if err != nil {
+		return err
+	}
+	if hit {
+		return nil
+	}
+
+	container, _, err := b.Daemon.Create(b.Config, nil, "")
+	if err != nil {
+		return err
+	}
+	b.TmpContainers[container.ID] = struct{}{}
+
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	destPath, err := container.GetResourcePath(dest)
+	if err != nil {
+		return err
+	}
+	destIsDir := strings.HasSuffix(dest, "/")
+	if fi, err := os.Stat(destPath); err == nil && fi.IsDir() {
+		destIsDir = true
+	}
+
+	for i, src := range srcPaths {
+		fileDest := dest
+		// a file copied into a directory keeps the name of the source,
+		// not the one of the file a symlink source points to
+		if fi, err := os.Stat(src); err == nil && !fi.IsDir() && destIsDir {
+			fileDest = filepath.Join(dest, filepath.Base(origPaths[i]))
+		}
+		if err := b.addPath(container, src, fileDest, false); err != nil {
+			return err
+		}
+	}
+
+	return b.commit(container.ID, cmd, fmt.Sprintf("COPY --from=%s %s in %s", stageName, strings.Join(origPaths, " "), dest))
+}
+
 // probeCache checks to see if image-caching is enabled (`b.UtilizeCache`)
 // and if so attempts to look up the current `b.image` and `b.Config` pair
 // in the current server `b.Daemon`. If an image is found, probeCache returns
@@ -652,10 +908,19 @@ func (b *Builder) checkPathForAddition(orig string) error {
 }
 
 func (b *Builder) addContext(container *daemon.Container, orig, dest string, decompress bool) error {
+	origPath := path.Join(b.contextPath, orig)
+	if _, err := os.Stat(origPath); os.IsNotExist(err) {
+		return fmt.Errorf("%s: no such file or directory", orig)
+	}
+	return b.addPath(container, origPath, dest, decompress)
+}
+
+// addPath copies the file or directory at origPath on the host to dest in
+// the container.
+func (b *Builder) addPath(container *daemon.Container, origPath, dest string, decompress bool) error {
 	var (
 		err        error
 		destExists = true
-		origPath   = path.Join(b.contextPath, orig)
 		destPath   string
 	)
 
@@ -679,9 +944,6 @@ func (b *Builder) addContext(container *daemon.Container, orig, dest string, dec
 
 	fi, err := os.Stat(origPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return fmt.Errorf("%s: no such file or directory", orig)
-		}
 		return err
 	}
 
@@ -8,14 +8,17 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/builder/parser"
+	"github.com/docker/docker/builder/session"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/graph/tags"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/httputils"
+	"github.com/docker/docker/pkg/metrics"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/urlutil"
@@ -24,16 +27,25 @@ import (
 	"github.com/docker/docker/utils"
 )
 
+// buildDuration is the duration of the successful builds.
+var buildDuration = metrics.NewHistogram("docker_image_build_duration_seconds", "Duration of the image builds", []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800})
+
+func init() {
+	metrics.Register(buildDuration)
+}
+
 // whitelist of commands allowed for a commit/import
 var validCommitCommands = map[string]bool{
-	"entrypoint": true,
-	"cmd":        true,
-	"user":       true,
-	"workdir":    true,
-	"env":        true,
-	"volume":     true,
-	"expose":     true,
-	"onbuild":    true,
+	"entrypoint":  true,
+	"cmd":         true,
+	"user":        true,
+	"workdir":     true,
+	"env":         true,
+	"volume":      true,
+	"expose":      true,
+	"onbuild":     true,
+	"healthcheck": true,
+	"stopsignal":  true,
 }
 
 type Config struct {
@@ -45,6 +57,7 @@ type Config struct {
 	Remove         bool
 	ForceRemove    bool
 	Pull           bool
+	Squash         bool
 	Memory         int64
 	MemorySwap     int64
 	CpuShares      int64
@@ -53,11 +66,20 @@ type Config struct {
 	CpuSetCpus     string
 	CpuSetMems     string
 	CgroupParent   string
+	BuildArgs      map[string]string
 	AuthConfig     *cliconfig.AuthConfig
 	ConfigFile     *cliconfig.ConfigFile
+	// TrustedRefs are the references to the signed digests of the base
+	// images, by name, when the client enabled content trust.
+	TrustedRefs map[string]string
 
 	Stdout  io.Writer
 	Context io.ReadCloser
+	// Session streams the context from the client instead of Context, and
+	// ContextID identifies the context to reuse the files received by the
+	// previous builds.
+	Session   *session.Session
+	ContextID string
 	// When closed, the job has been cancelled.
 	// Note: not all jobs implement cancellation.
 	// See Job.Cancel() and Job.WaitCancelled()
@@ -104,7 +126,10 @@ func Build(d *daemon.Daemon, buildConfig *Config) error {
 		}
 	}
 
-	if buildConfig.RemoteURL == "" {
+	if buildConfig.Session != nil {
+		// the files are requested from the client during the build
+		context = ioutil.NopCloser(strings.NewReader(""))
+	} else if buildConfig.RemoteURL == "" {
 		context = ioutil.NopCloser(buildConfig.Context)
 	} else if urlutil.IsGitURL(buildConfig.RemoteURL) {
 		root, err := utils.GitClone(buildConfig.RemoteURL)
@@ -158,6 +183,7 @@ func Build(d *daemon.Daemon, buildConfig *Config) error {
 		Remove:          buildConfig.Remove,
 		ForceRemove:     buildConfig.ForceRemove,
 		Pull:            buildConfig.Pull,
+		Squash:          buildConfig.Squash,
 		OutOld:          buildConfig.Stdout,
 		StreamFormatter: sf,
 		AuthConfig:      buildConfig.AuthConfig,
@@ -171,13 +197,19 @@ func Build(d *daemon.Daemon, buildConfig *Config) error {
 		cgroupParent:    buildConfig.CgroupParent,
 		memory:          buildConfig.Memory,
 		memorySwap:      buildConfig.MemorySwap,
+		buildArgs:       buildConfig.BuildArgs,
+		trustedRefs:     buildConfig.TrustedRefs,
+		session:         buildConfig.Session,
+		contextID:       buildConfig.ContextID,
 		cancelled:       buildConfig.WaitCancelled(),
 	}
 
+	start := time.Now()
 	id, err := builder.Run(context)
 	if err != nil {
 		return err
 	}
+	buildDuration.Observe(time.Since(start).Seconds())
 
 	if repoName != "" {
 		return d.Repositories().Tag(repoName, tag, id, true)
@@ -230,10 +262,34 @@ func Commit(d *daemon.Daemon, name string, c *daemon.ContainerCommitConfig) (str
 		return "", err
 	}
 
-	img, err := d.Commit(container, c.Repo, c.Tag, c.Comment, c.Author, c.Pause, newConfig)
+	if !c.Squash {
+		img, err := d.Commit(container, c.Repo, c.Tag, c.Comment, c.Author, c.Pause, newConfig)
+		if err != nil {
+			return "", err
+		}
+		return img.ID, nil
+	}
+
+	// squash the image with the layers of the image of the container, and
+	// only tag the squashed image
+	committed, err := d.Commit(container, "", "", c.Comment, c.Author, c.Pause, newConfig)
 	if err != nil {
 		return "", err
 	}
+	img, err := d.Graph().Squash(committed.ID, "")
+	if err != nil {
+		return "", err
+	}
+	if img.ID != committed.ID {
+		if _, err := d.DeleteIntermediateImages(committed.ID, container.ImageID); err != nil {
+			return "", err
+		}
+	}
+	if c.Repo != "" {
+		if err := d.Repositories().Tag(c.Repo, c.Tag, img.ID, true); err != nil {
+			return "", err
+		}
+	}
 
 	return img.ID, nil
 }
@@ -293,3 +293,26 @@ func parseMaybeJSONToList(rest string) (*Node, map[string]bool, error) {
 
 	return parseStringsWhitespaceDelimited(rest)
 }
+
+// parseHealthConfig parses the arguments of HEALTHCHECK: the type of check,
+// NONE or CMD, and the command to run, like RUN.
+//
+// HEALTHCHECK CMD curl -f http://localhost/ -> (healthcheck "CMD" "curl -f http://localhost/")
+//
+func parseHealthConfig(rest string) (*Node, map[string]bool, error) {
+	if rest == "" {
+		return nil, nil, nil
+	}
+
+	typ, cmdline := rest, ""
+	if i := strings.IndexFunc(rest, unicode.IsSpace); i >= 0 {
+		typ = rest[:i]
+		cmdline = strings.TrimLeftFunc(rest[i:], unicode.IsSpace)
+	}
+
+	cmd, attrs, err := parseMaybeJSON(cmdline)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Node{Value: typ, Next: cmd}, attrs, nil
+}
@@ -0,0 +1,322 @@
+// Package session implements the streaming build context protocol.
+//
+// Instead of uploading the whole build context before the build starts, the
+// client keeps the hijacked connection of the build request open, and the
+// daemon requests the files it needs while it evaluates the Dockerfile: the
+// Dockerfile and .dockerignore first, then the sources of each ADD and COPY
+// instruction.
+//
+// Both ends send frames on the connection, made of a one byte type, the
+// length of the payload as a 4 bytes big-endian integer, and the payload:
+//
+//   - the daemon sends the build output in output frames, and the requests in
+//     request frames holding a JSON Request.
+//   - the client answers each request with a tar archive of the requested
+//     files which changed, split into data frames, followed by an end frame
+//     holding a JSON Response, or by an error frame.
+//
+// The daemon keeps the files it received between builds of the same context,
+// and sends their tarsum with each request, so that the client only sends
+// the files which changed since the previous build.
+package session
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// The frame types
+const (
+	FrameOutput  byte = iota + 1 // daemon to client: build output
+	FrameRequest                 // daemon to client: JSON Request
+	FrameData                    // client to daemon: part of the tar archive answering a request
+	FrameEnd                     // client to daemon: JSON Response, ends the answer to a request
+	FrameError                   // client to daemon: error message, ends the answer to a request
+)
+
+// maxFrameSize is the maximum size of the payload of a frame
+const maxFrameSize = 1 << 20
+
+// ErrFrameTooLarge is returned when reading a frame larger than maxFrameSize
+var ErrFrameTooLarge = errors.New("session: frame too large")
+
+// Request is sent by the daemon to get files of the build context.
+type Request struct {
+	// Paths are the paths of the requested files, relative to the root of
+	// the context. They can contain wildcards, and directories are sent with
+	// their content.
+	Paths []string
+	// Known are the tarsums of the files the daemon already has, by path,
+	// which do not need to be sent again if they did not change.
+	Known map[string]string
+}
+
+// Response ends the answer of the client to a request.
+type Response struct {
+	// Files are the paths of all the files matching the request which are
+	// not excluded by the .dockerignore file, whether they were sent or
+	// not, in the order they should be extracted.
+	Files []string
+}
+
+func writeFrame(w io.Writer, typ byte, payload []byte) error {
+	var header [5]byte
+	header[0] = typ
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxFrameSize {
+		return 0, nil, ErrFrameTooLarge
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return header[0], payload, nil
+}
+
+// frameWriter writes everything written to it as frames of the same type,
+// split to fit in maxFrameSize.
+type frameWriter struct {
+	mu  *sync.Mutex
+	w   io.Writer
+	typ byte
+}
+
+func (fw *frameWriter) Write(p []byte) (int, error) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+	n := 0
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > maxFrameSize {
+			chunk = chunk[:maxFrameSize]
+		}
+		if err := writeFrame(fw.w, fw.typ, chunk); err != nil {
+			return n, err
+		}
+		n += len(chunk)
+		p = p[len(chunk):]
+	}
+	return n, nil
+}
+
+type frame struct {
+	typ     byte
+	payload []byte
+}
+
+// Session is the daemon end of a streaming build context session.
+type Session struct {
+	mu sync.Mutex // serializes the writes of the frames
+	w  io.Writer
+
+	// frames are the frames read from the client
+	frames chan frame
+	// closed is closed when the connection is closed, once err is set
+	closed chan struct{}
+	err    error
+	// stop is closed by Close, to stop reading frames
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// New returns the daemon end of a session on the hijacked connection of a
+// build request. The connection is read in the background, so that the
+// session notices when the client goes away during the build.
+func New(r io.Reader, w io.Writer) *Session {
+	s := &Session{
+		w:      w,
+		frames: make(chan frame),
+		closed: make(chan struct{}),
+		stop:   make(chan struct{}),
+	}
+	go s.readFrames(bufio.NewReader(r))
+	return s
+}
+
+func (s *Session) readFrames(r io.Reader) {
+	defer close(s.closed)
+	for {
+		typ, payload, err := readFrame(r)
+		if err != nil {
+			s.err = err
+			return
+		}
+		select {
+		case s.frames <- frame{typ, payload}:
+		case <-s.stop:
+			s.err = io.EOF
+			return
+		}
+	}
+}
+
+// Closed returns a channel which is closed when the client closes the
+// connection.
+func (s *Session) Closed() <-chan struct{} {
+	return s.closed
+}
+
+// Close stops reading the frames sent by the client. It does not close the
+// connection.
+func (s *Session) Close() {
+	s.closeOnce.Do(func() { close(s.stop) })
+}
+
+// Write sends p to the client as build output. It is safe to call it from
+// several goroutines.
+func (s *Session) Write(p []byte) (int, error) {
+	fw := &frameWriter{mu: &s.mu, w: s.w, typ: FrameOutput}
+	return fw.Write(p)
+}
+
+// RequestFiles requests files of the build context from the client. The tar
+// archive of the files which changed is passed to extract, which must read
+// it until EOF. It returns the response of the client once the archive was
+// extracted. Requests must not be made concurrently.
+func (s *Session) RequestFiles(req *Request, extract func(io.Reader) error) (*Response, error) {
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	err = writeFrame(s.w, FrameRequest, payload)
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	ar := &answerReader{s: s}
+	extractErr := extract(ar)
+	// drain the answer, so that the next request starts at a frame boundary
+	if _, err := io.Copy(ioutil.Discard, ar); err != nil && extractErr == nil {
+		extractErr = err
+	}
+	if ar.err != nil {
+		return nil, ar.err
+	}
+	if extractErr != nil {
+		return nil, extractErr
+	}
+	return ar.resp, nil
+}
+
+// answerReader reads the data frames answering a request, until the end or
+// error frame.
+type answerReader struct {
+	s    *Session
+	buf  []byte
+	resp *Response
+	err  error // the error sent by the client, or a protocol error
+	done bool
+}
+
+func (ar *answerReader) Read(p []byte) (int, error) {
+	for len(ar.buf) == 0 {
+		if ar.done {
+			return 0, io.EOF
+		}
+		var typ byte
+		var payload []byte
+		select {
+		case f := <-ar.s.frames:
+			typ, payload = f.typ, f.payload
+		case <-ar.s.closed:
+			err := ar.s.err
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			ar.done = true
+			ar.err = err
+			return 0, io.EOF
+		}
+		switch typ {
+		case FrameData:
+			ar.buf = payload
+		case FrameEnd:
+			ar.done = true
+			ar.resp = &Response{}
+			if err := json.Unmarshal(payload, ar.resp); err != nil {
+				ar.err = err
+			}
+		case FrameError:
+			ar.done = true
+			ar.err = errors.New(string(payload))
+		default:
+			ar.done = true
+			ar.err = fmt.Errorf("session: unexpected frame type %d", typ)
+		}
+	}
+	n := copy(p, ar.buf)
+	ar.buf = ar.buf[n:]
+	return n, nil
+}
+
+// Handler answers a request of the daemon: it writes the tar archive of the
+// requested files which changed to w, and returns the response.
+type Handler func(req *Request, w io.Writer) (*Response, error)
+
+// Serve runs the client end of a session: it writes the build output sent
+// by the daemon to out, and answers its requests with handler, until the
+// daemon closes the connection.
+func Serve(r io.Reader, w io.Writer, out io.Writer, handler Handler) error {
+	br := bufio.NewReader(r)
+	mu := &sync.Mutex{}
+	for {
+		typ, payload, err := readFrame(br)
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		switch typ {
+		case FrameOutput:
+			if _, err := out.Write(payload); err != nil {
+				return err
+			}
+		case FrameRequest:
+			req := &Request{}
+			if err := json.Unmarshal(payload, req); err != nil {
+				return err
+			}
+			resp, err := handler(req, &frameWriter{mu: mu, w: w, typ: FrameData})
+			if err != nil {
+				if err := writeFrame(w, FrameError, []byte(err.Error())); err != nil {
+					return err
+				}
+				continue
+			}
+			payload, err := json.Marshal(resp)
+			if err != nil {
+				return err
+			}
+			if err := writeFrame(w, FrameEnd, payload); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("session: unexpected frame type %d", typ)
+		}
+	}
+}
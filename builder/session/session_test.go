@@ -0,0 +1,186 @@
+package session
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func makeContext(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "docker-session-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestResolve(t *testing.T) {
+	root := makeContext(t, map[string]string{
+		"Dockerfile":   "FROM busybox",
+		"foo":          "foo",
+		"dir/bar":      "bar",
+		"dir/baz.log":  "baz",
+		"dir/sub/qux":  "qux",
+		"other/file1":  "file1",
+		"other/file2":  "file2",
+		"other/ignore": "ignore",
+	})
+	defer os.RemoveAll(root)
+
+	tests := []struct {
+		paths    []string
+		excludes []string
+		expected []string
+	}{
+		{[]string{"foo"}, nil, []string{"foo"}},
+		{[]string{"/foo", "./foo"}, nil, []string{"foo"}},
+		{[]string{"missing"}, nil, nil},
+		{[]string{"dir"}, nil, []string{"dir", "dir/bar", "dir/baz.log", "dir/sub", "dir/sub/qux"}},
+		{[]string{"dir"}, []string{"dir/*.log", "dir/sub"}, []string{"dir", "dir/bar"}},
+		{[]string{"other/file*"}, nil, []string{"other/file1", "other/file2"}},
+		{[]string{"foo"}, []string{"foo"}, nil},
+	}
+	for _, test := range tests {
+		names, err := Resolve(root, test.paths, test.excludes)
+		if err != nil {
+			t.Fatalf("%v: %v", test.paths, err)
+		}
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Fatalf("%v excluding %v: expected %v, got %v", test.paths, test.excludes, test.expected, names)
+		}
+	}
+
+	if _, err := Resolve(root, []string{"../foo"}, nil); err == nil {
+		t.Fatal("expected an error for a path outside the context")
+	}
+}
+
+// tarNames returns the names of the entries of a tar archive.
+func tarNames(r io.Reader) ([]string, error) {
+	var names []string
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return names, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, hdr.Name)
+	}
+}
+
+func TestSession(t *testing.T) {
+	root := makeContext(t, map[string]string{
+		"Dockerfile":    "FROM busybox",
+		".dockerignore": "*.log",
+		"foo":           "foo",
+		"bar":           "bar",
+		"baz.log":       "baz",
+	})
+	defer os.RemoveAll(root)
+
+	// client to daemon and daemon to client
+	cr, cw := io.Pipe()
+	dr, dw := io.Pipe()
+	defer cw.Close()
+
+	var output bytes.Buffer
+	served := make(chan error)
+	go func() {
+		served <- Serve(dr, cw, &output, ServeContext(root, []string{"*.log"}, "Dockerfile", ".dockerignore"))
+	}()
+
+	s := New(cr, dw)
+	if _, err := s.Write([]byte("Step 0 : FROM busybox\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	var sent []string
+	extract := func(r io.Reader) error {
+		names, err := tarNames(r)
+		sent = names
+		return err
+	}
+
+	// everything is sent the first time
+	resp, err := s.RequestFiles(&Request{Paths: []string{"Dockerfile", "foo", "*.log"}}, extract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Dockerfile", "foo"}; !reflect.DeepEqual(resp.Files, expected) || !reflect.DeepEqual(sent, expected) {
+		t.Fatalf("expected %v to be sent, got %v in %v", expected, sent, resp.Files)
+	}
+
+	// only the files which changed are sent again
+	sums, err := Sums(root, []string{"Dockerfile", "foo", "bar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sums["foo"] = "stale"
+	resp, err = s.RequestFiles(&Request{Paths: []string{"Dockerfile", "foo", "bar"}, Known: sums}, extract)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"Dockerfile", "foo", "bar"}; !reflect.DeepEqual(resp.Files, expected) {
+		t.Fatalf("expected %v, got %v", expected, resp.Files)
+	}
+	if expected := []string{"foo"}; !reflect.DeepEqual(sent, expected) {
+		t.Fatalf("expected %v to be sent, got %v", expected, sent)
+	}
+
+	// the errors of the client are returned
+	if _, err := s.RequestFiles(&Request{Paths: []string{"../foo"}}, extract); err == nil || !strings.Contains(err.Error(), "outside the build context") {
+		t.Fatalf("expected an error for a path outside the context, got %v", err)
+	}
+
+	dw.Close()
+	if err := <-served; err != nil {
+		t.Fatal(err)
+	}
+	if output.String() != "Step 0 : FROM busybox\n" {
+		t.Fatalf("unexpected output %q", output.String())
+	}
+}
+
+func TestSessionClosed(t *testing.T) {
+	cr, cw := io.Pipe()
+	s := New(cr, ioutil.Discard)
+	defer s.Close()
+
+	select {
+	case <-s.Closed():
+		t.Fatal("the session is closed before the client went away")
+	default:
+	}
+
+	cw.Close()
+	select {
+	case <-s.Closed():
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the session to notice the client went away")
+	}
+	if _, err := s.RequestFiles(&Request{Paths: []string{"foo"}}, func(r io.Reader) error {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err
+	}); err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
@@ -0,0 +1,244 @@
+package session
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/fileutils"
+	"github.com/docker/docker/pkg/tarsum"
+)
+
+// Resolve returns the paths of the files of the context at root matching the
+// requested paths, relative to root, with the content of the directories.
+// The files excluded by the patterns of the .dockerignore file are skipped,
+// and the requested paths which do not exist are ignored.
+func Resolve(root string, paths []string, excludes []string) ([]string, error) {
+	var (
+		names []string
+		seen  = make(map[string]bool)
+	)
+	add := func(name string) error {
+		if seen[name] {
+			return nil
+		}
+		if excluded, err := fileutils.Matches(name, excludes); err != nil {
+			return err
+		} else if excluded {
+			return nil
+		}
+		seen[name] = true
+		names = append(names, name)
+		return nil
+	}
+
+	var resolve func(p string, followLinks bool) error
+	resolve = func(p string, followLinks bool) error {
+		full := filepath.Join(root, p)
+		fi, err := os.Lstat(full)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if fi.Mode()&os.ModeSymlink != 0 && followLinks {
+			// the target of the link is needed to copy it, send it too if
+			// it is in the context
+			if target, err := filepath.EvalSymlinks(full); err == nil {
+				if rel, err := filepath.Rel(root, target); err == nil && !isOutside(rel) && rel != "." {
+					if err := resolve(rel, false); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if !fi.IsDir() {
+			return add(filepath.ToSlash(p))
+		}
+		return filepath.Walk(full, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return nil
+			}
+			return add(filepath.ToSlash(rel))
+		})
+	}
+
+	for _, p := range paths {
+		p = filepath.Clean(filepath.FromSlash(strings.TrimPrefix(p, "/")))
+		if isOutside(p) {
+			return nil, fmt.Errorf("Forbidden path outside the build context: %s", p)
+		}
+		if !containsWildcards(p) {
+			if err := resolve(p, true); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(root, p))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range matches {
+			rel, err := filepath.Rel(root, m)
+			if err != nil {
+				return nil, err
+			}
+			if err := resolve(rel, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return names, nil
+}
+
+// WriteTar writes a tar archive of the given files of the context at root
+// to w, without the content of the directories, which have to be listed
+// too. The headers only hold the metadata which is preserved when the files
+// are extracted, so that the tarsum of a file is the same on both ends of
+// the session.
+func WriteTar(w io.Writer, root string, names []string) error {
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		if err := writeTarEntry(tw, root, name); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+func writeTarEntry(tw *tar.Writer, root, name string) error {
+	full := filepath.Join(root, filepath.FromSlash(name))
+	fi, err := os.Lstat(full)
+	if err != nil {
+		return err
+	}
+	link := ""
+	if fi.Mode()&os.ModeSymlink != 0 {
+		if link, err = os.Readlink(full); err != nil {
+			return err
+		}
+	}
+	hdr, err := tar.FileInfoHeader(fi, link)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	if fi.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uname = ""
+	hdr.Gname = ""
+	hdr.ModTime = hdr.ModTime.Truncate(time.Second)
+	hdr.AccessTime = time.Time{}
+	hdr.ChangeTime = time.Time{}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+		return nil
+	}
+	f, err := os.Open(full)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// Sums returns the tarsums of the given files of the context at root, by
+// name, as computed on the archive written by WriteTar.
+func Sums(root string, names []string) (map[string]string, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(WriteTar(pw, root, names))
+	}()
+	defer pr.Close()
+
+	ts, err := tarsum.NewTarSum(pr, true, tarsum.Version0)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		return nil, err
+	}
+	sums := make(map[string]string, len(names))
+	for _, fis := range ts.GetSums() {
+		sums[fis.Name()] = fis.Sum()
+	}
+	return sums, nil
+}
+
+// ServeContext returns the handler answering the requests of the daemon
+// with the files of the context at root which are not excluded by the
+// patterns of the .dockerignore file. The files which always have to be
+// sent, like the Dockerfile, are never excluded.
+func ServeContext(root string, excludes []string, always ...string) Handler {
+	return func(req *Request, w io.Writer) (*Response, error) {
+		var (
+			paths []string
+			names []string
+		)
+		for _, p := range req.Paths {
+			if contains(always, p) {
+				n, err := Resolve(root, []string{p}, nil)
+				if err != nil {
+					return nil, err
+				}
+				names = append(names, n...)
+				continue
+			}
+			paths = append(paths, p)
+		}
+		n, err := Resolve(root, paths, excludes)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, n...)
+
+		sums, err := Sums(root, names)
+		if err != nil {
+			return nil, err
+		}
+		changed := []string{}
+		for _, name := range names {
+			if sum, ok := req.Known[name]; !ok || sum != sums[name] {
+				changed = append(changed, name)
+			}
+		}
+		if err := WriteTar(w, root, changed); err != nil {
+			return nil, err
+		}
+		return &Response{Files: names}, nil
+	}
+}
+
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func containsWildcards(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,260 @@
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/builder/session"
+	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/tarsum"
+)
+
+// validContextID is the format of the IDs the clients give to their
+// streamed contexts, to find the files received by the previous builds
+var validContextID = regexp.MustCompile(`^[a-f0-9]{64}$`)
+
+// contextCacheExpiry is how long the files received for a streamed context
+// are kept after its last build
+const contextCacheExpiry = 24 * time.Hour
+
+var (
+	contextCacheLocksMu sync.Mutex
+	contextCacheLocks   = make(map[string]*sync.Mutex)
+)
+
+// contextCacheLock returns the lock serializing the builds using the
+// context cache with the given ID.
+func contextCacheLock(id string) *sync.Mutex {
+	contextCacheLocksMu.Lock()
+	defer contextCacheLocksMu.Unlock()
+	l, ok := contextCacheLocks[id]
+	if !ok {
+		l = &sync.Mutex{}
+		contextCacheLocks[id] = l
+	}
+	return l
+}
+
+// contextSums returns the tarsums of the files of the context received so far.
+func (b *Builder) contextSums() tarsum.FileInfoSums {
+	if b.session != nil {
+		return b.streamedSums
+	}
+	return b.context.GetSums()
+}
+
+// removeFromContext removes a file from the sums of the context.
+func (b *Builder) removeFromContext(name string) {
+	if b.session != nil {
+		b.streamedSums = removeSums(b.streamedSums, name)
+		return
+	}
+	b.context.(tarsum.BuilderContext).Remove(name)
+}
+
+func removeSums(sums tarsum.FileInfoSums, name string) tarsum.FileInfoSums {
+	kept := sums[:0]
+	for _, fis := range sums {
+		if fis.Name() != name {
+			kept = append(kept, fis)
+		}
+	}
+	return kept
+}
+
+// openSession sets up the temporary directory the files of the streamed
+// context are copied to, and the cache of the files received by the previous
+// builds of the same context. The returned function saves and releases the
+// cache, and removes the caches of the contexts which were not built for
+// contextCacheExpiry.
+func (b *Builder) openSession() (func(), error) {
+	var (
+		cacheDir string
+		err      error
+	)
+	if b.contextID == "" {
+		if cacheDir, err = ioutil.TempDir("", "docker-build-cache"); err != nil {
+			return nil, err
+		}
+	} else {
+		if !validContextID.MatchString(b.contextID) {
+			return nil, fmt.Errorf("Invalid context ID: %s", b.contextID)
+		}
+		cacheDir = filepath.Join(b.Daemon.Config().Root, "build-contexts", b.contextID)
+		if err := os.MkdirAll(cacheDir, 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	var lock *sync.Mutex
+	if b.contextID != "" {
+		lock = contextCacheLock(b.contextID)
+		lock.Lock()
+	}
+
+	b.contextCache = cacheDir
+	b.knownSums = make(map[string]string)
+	if f, err := os.Open(filepath.Join(cacheDir, "sums.json")); err == nil {
+		if err := json.NewDecoder(f).Decode(&b.knownSums); err != nil {
+			logrus.Debugf("[BUILDER] ignoring the invalid sums of the context cache: %s", err)
+			b.knownSums = make(map[string]string)
+		}
+		f.Close()
+	}
+
+	if b.contextPath, err = ioutil.TempDir("", "docker-build"); err != nil {
+		if lock != nil {
+			lock.Unlock()
+		}
+		return nil, err
+	}
+
+	return func() {
+		if b.contextID == "" {
+			os.RemoveAll(cacheDir)
+			return
+		}
+		if err := b.saveKnownSums(); err != nil {
+			logrus.Debugf("[BUILDER] failed to save the sums of the context cache: %s", err)
+		}
+		// the modification time of the cache is the time of its last build
+		now := time.Now()
+		if err := os.Chtimes(cacheDir, now, now); err != nil {
+			logrus.Debugf("[BUILDER] failed to update the time of the context cache: %s", err)
+		}
+		lock.Unlock()
+		go removeStaleContextCaches(filepath.Dir(cacheDir), contextCacheExpiry)
+	}, nil
+}
+
+// removeStaleContextCaches removes the caches of the contexts in root which
+// were not built for longer than expiry.
+func removeStaleContextCaches(root string, expiry time.Duration) {
+	dirs, err := ioutil.ReadDir(root)
+	if err != nil {
+		logrus.Debugf("[BUILDER] failed to list the context caches: %s", err)
+		return
+	}
+	for _, fi := range dirs {
+		id := fi.Name()
+		if !validContextID.MatchString(id) || time.Since(fi.ModTime()) < expiry {
+			continue
+		}
+		lock := contextCacheLock(id)
+		lock.Lock()
+		// the context may have been built while waiting for the lock
+		if fi, err := os.Stat(filepath.Join(root, id)); err == nil && time.Since(fi.ModTime()) >= expiry {
+			logrus.Debugf("[BUILDER] removing the cache of the context %s, unused since %s", id, fi.ModTime())
+			if err := os.RemoveAll(filepath.Join(root, id)); err != nil {
+				logrus.Errorf("Error removing the cache of the build context %s: %s", id, err)
+			}
+		}
+		lock.Unlock()
+	}
+}
+
+func (b *Builder) saveKnownSums() error {
+	data, err := json.Marshal(b.knownSums)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(b.contextCache, "sums.json"), data, 0600)
+}
+
+// fetchContextFiles requests the files matching paths from the client, and
+// copies them to the context directory. Only the files which changed since
+// they were last received are sent by the client.
+func (b *Builder) fetchContextFiles(paths []string) error {
+	known := make(map[string]string)
+	for name, sum := range b.knownSums {
+		if matchesContextPaths(name, paths) {
+			known[name] = sum
+		}
+	}
+
+	filesDir := filepath.Join(b.contextCache, "files")
+	resp, err := b.session.RequestFiles(&session.Request{Paths: paths, Known: known}, func(r io.Reader) error {
+		ts, err := tarsum.NewTarSum(r, true, tarsum.Version0)
+		if err != nil {
+			return err
+		}
+		if err := chrootarchive.Untar(ts, filesDir, nil); err != nil {
+			return err
+		}
+		if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+			return err
+		}
+		for _, fis := range ts.GetSums() {
+			b.knownSums[fis.Name()] = fis.Sum()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, name := range resp.Files {
+		if path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("Forbidden path outside the build context: %s", name)
+		}
+		if _, ok := b.knownSums[name]; !ok {
+			return fmt.Errorf("The file %s of the build context was not sent", name)
+		}
+	}
+	if len(resp.Files) == 0 {
+		return nil
+	}
+
+	// copy the files from the cache to the context directory, computing the
+	// sums used for the cache of the build steps on the way
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(session.WriteTar(pw, filesDir, resp.Files))
+	}()
+	defer pr.Close()
+
+	ts, err := tarsum.NewTarSum(pr, true, tarsum.Version0)
+	if err != nil {
+		return err
+	}
+	if err := chrootarchive.Untar(ts, b.contextPath, nil); err != nil {
+		return err
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		return err
+	}
+	for _, fis := range ts.GetSums() {
+		b.streamedSums = append(removeSums(b.streamedSums, fis.Name()), fis)
+	}
+	return nil
+}
+
+// matchesContextPaths returns whether the file is one of the paths, or is
+// in one of them.
+func matchesContextPaths(name string, paths []string) bool {
+	for _, p := range paths {
+		p = path.Clean(strings.TrimPrefix(p, "/"))
+		if p == "." {
+			return true
+		}
+		for n := name; n != "." && n != "/"; n = path.Dir(n) {
+			if n == p {
+				return true
+			}
+			if match, _ := path.Match(p, n); match {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package builder
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleContextCaches(t *testing.T) {
+	root, err := ioutil.TempDir("", "docker-build-contexts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	stale := strings.Repeat("a", 64)
+	recent := strings.Repeat("b", 64)
+	other := "not-a-context"
+	for _, id := range []string{stale, recent, other} {
+		if err := os.MkdirAll(filepath.Join(root, id, "files"), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	for _, id := range []string{stale, other} {
+		if err := os.Chtimes(filepath.Join(root, id), old, old); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removeStaleContextCaches(root, time.Hour)
+
+	if _, err := os.Stat(filepath.Join(root, stale)); !os.IsNotExist(err) {
+		t.Fatalf("expected the stale context cache to be removed, got %v", err)
+	}
+	for _, id := range []string{recent, other} {
+		if _, err := os.Stat(filepath.Join(root, id)); err != nil {
+			t.Fatalf("expected %s to be kept, got %v", id, err)
+		}
+	}
+}
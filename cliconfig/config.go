@@ -41,7 +41,13 @@ type AuthConfig struct {
 type ConfigFile struct {
 	AuthConfigs map[string]AuthConfig `json:"auths"`
 	HttpHeaders map[string]string     `json:"HttpHeaders,omitempty"`
-	filename    string                // Note: not serialized - for internal use only
+	// CredentialsStore is the name of the credential helper storing the
+	// credentials of the registries, instead of this file
+	CredentialsStore string `json:"credsStore,omitempty"`
+	// CredentialHelpers are the names of the credential helpers storing
+	// the credentials of some registries, by registry
+	CredentialHelpers map[string]string `json:"credHelpers,omitempty"`
+	filename          string            // Note: not serialized - for internal use only
 }
 
 func NewConfigFile(fn string) *ConfigFile {
@@ -77,9 +83,13 @@ func Load(configDir string) (*ConfigFile, error) {
 		}
 
 		for addr, ac := range configFile.AuthConfigs {
-			ac.Username, ac.Password, err = DecodeAuth(ac.Auth)
-			if err != nil {
-				return &configFile, err
+			// the credentials kept by a credential helper are not in
+			// the file
+			if ac.Auth != "" {
+				ac.Username, ac.Password, err = DecodeAuth(ac.Auth)
+				if err != nil {
+					return &configFile, err
+				}
 			}
 			ac.Auth = ""
 			ac.ServerAddress = addr
@@ -146,7 +156,9 @@ func (configFile *ConfigFile) Save() error {
 	for k, authConfig := range configFile.AuthConfigs {
 		authCopy := authConfig
 
-		authCopy.Auth = EncodeAuth(&authCopy)
+		if authCopy.Username != "" || authCopy.Password != "" {
+			authCopy.Auth = EncodeAuth(&authCopy)
+		}
 		authCopy.Username = ""
 		authCopy.Password = ""
 		authCopy.ServerAddress = ""
@@ -177,6 +189,35 @@ func (config *ConfigFile) Filename() string {
 	return config.filename
 }
 
+// CredentialsHelper returns the name of the credential helper storing the
+// credentials of the registry at serverAddress, or "" if they are stored in
+// the file. The helpers given for a registry take precedence over the
+// credentials store.
+func (config *ConfigFile) CredentialsHelper(serverAddress string) string {
+	if helper, ok := config.CredentialHelpers[serverAddress]; ok {
+		return helper
+	}
+	hostname := convertToHostname(serverAddress)
+	for registry, helper := range config.CredentialHelpers {
+		if convertToHostname(registry) == hostname {
+			return helper
+		}
+	}
+	return config.CredentialsStore
+}
+
+// convertToHostname returns the hostname of a registry address, which may be
+// a URL.
+func convertToHostname(url string) string {
+	stripped := url
+	if strings.HasPrefix(url, "http://") {
+		stripped = strings.TrimPrefix(url, "http://")
+	} else if strings.HasPrefix(url, "https://") {
+		stripped = strings.TrimPrefix(url, "https://")
+	}
+	return strings.SplitN(stripped, "/", 2)[0]
+}
+
 // create a base64 encoded auth string to store in config
 func EncodeAuth(authConfig *AuthConfig) string {
 	authStr := authConfig.Username + ":" + authConfig.Password
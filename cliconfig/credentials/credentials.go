@@ -0,0 +1,30 @@
+// Package credentials stores the credentials of the registries used by the
+// client, in the configuration file or with external credential helpers.
+package credentials
+
+import (
+	"github.com/docker/docker/cliconfig"
+)
+
+// Store stores the credentials of the registries.
+type Store interface {
+	// Erase removes the credentials of the registry at serverAddress.
+	Erase(serverAddress string) error
+	// Get returns the credentials of the registry at serverAddress, which
+	// are empty if there are none.
+	Get(serverAddress string) (cliconfig.AuthConfig, error)
+	// GetAll returns the credentials of all the registries, by address.
+	GetAll() (map[string]cliconfig.AuthConfig, error)
+	// Store saves the credentials of the registry at their server address.
+	Store(authConfig cliconfig.AuthConfig) error
+}
+
+// NewStore returns the store of the credentials of the registry at
+// serverAddress: the credential helper selected for the registry in the
+// configuration file, or the configuration file itself.
+func NewStore(file *cliconfig.ConfigFile, serverAddress string) Store {
+	if helper := file.CredentialsHelper(serverAddress); helper != "" {
+		return NewNativeStore(file, helper)
+	}
+	return NewFileStore(file)
+}
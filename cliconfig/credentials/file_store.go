@@ -0,0 +1,38 @@
+package credentials
+
+import (
+	"github.com/docker/docker/cliconfig"
+)
+
+// fileStore stores the credentials in the configuration file, in plain
+// text.
+type fileStore struct {
+	file *cliconfig.ConfigFile
+}
+
+// NewFileStore returns a store of the credentials in the configuration file.
+func NewFileStore(file *cliconfig.ConfigFile) Store {
+	return &fileStore{file: file}
+}
+
+func (s *fileStore) Erase(serverAddress string) error {
+	delete(s.file.AuthConfigs, serverAddress)
+	return s.file.Save()
+}
+
+func (s *fileStore) Get(serverAddress string) (cliconfig.AuthConfig, error) {
+	return s.file.AuthConfigs[serverAddress], nil
+}
+
+func (s *fileStore) GetAll() (map[string]cliconfig.AuthConfig, error) {
+	authConfigs := make(map[string]cliconfig.AuthConfig, len(s.file.AuthConfigs))
+	for serverAddress, authConfig := range s.file.AuthConfigs {
+		authConfigs[serverAddress] = authConfig
+	}
+	return authConfigs, nil
+}
+
+func (s *fileStore) Store(authConfig cliconfig.AuthConfig) error {
+	s.file.AuthConfigs[authConfig.ServerAddress] = authConfig
+	return s.file.Save()
+}
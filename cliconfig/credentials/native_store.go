@@ -0,0 +1,155 @@
+package credentials
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/cliconfig"
+)
+
+const (
+	// HelperPrefix is the prefix of the names of the credential helper
+	// binaries, which are looked for in the PATH.
+	HelperPrefix = "docker-credential-"
+
+	// errNotFoundMessage is written by the credential helpers which do not
+	// have the credentials of a registry.
+	errNotFoundMessage = "credentials not found in native keychain"
+)
+
+var errNotFound = errors.New(errNotFoundMessage)
+
+// helperCredentials are the credentials exchanged with a credential helper.
+//
+// A credential helper is run with the action as its only argument: "store"
+// reads the credentials as JSON from its standard input, "get" reads the
+// server URL from its standard input and writes the username and secret of
+// the credentials as JSON to its standard output, and "erase" reads the
+// server URL from its standard input. The helper exits with a non-zero
+// status on errors, after writing the error message to its standard output.
+type helperCredentials struct {
+	ServerURL string `json:"ServerURL,omitempty"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+// helperFunc runs the action of a credential helper with the given input,
+// and returns its output.
+type helperFunc func(action string, in io.Reader) ([]byte, error)
+
+// shellHelper returns the helperFunc running the credential helper binary
+// with the given name.
+func shellHelper(name string) helperFunc {
+	return func(action string, in io.Reader) ([]byte, error) {
+		cmd := exec.Command(HelperPrefix+name, action)
+		cmd.Stdin = in
+		cmd.Stderr = os.Stderr
+		return cmd.Output()
+	}
+}
+
+// nativeStore stores the credentials with a credential helper. The other
+// fields of the configuration of the registries, like the email, are kept
+// in the configuration file.
+type nativeStore struct {
+	helper    string
+	run       helperFunc
+	fileStore Store
+}
+
+// NewNativeStore returns a store of the credentials in the credential helper
+// with the given name, which is run as the docker-credential-<name> binary.
+func NewNativeStore(file *cliconfig.ConfigFile, helper string) Store {
+	return &nativeStore{
+		helper:    helper,
+		run:       shellHelper(helper),
+		fileStore: NewFileStore(file),
+	}
+}
+
+func (s *nativeStore) Erase(serverAddress string) error {
+	if _, err := s.call("erase", strings.NewReader(serverAddress)); err != nil && err != errNotFound {
+		return err
+	}
+	return s.fileStore.Erase(serverAddress)
+}
+
+func (s *nativeStore) Get(serverAddress string) (cliconfig.AuthConfig, error) {
+	authConfig, err := s.fileStore.Get(serverAddress)
+	if err != nil {
+		return authConfig, err
+	}
+
+	out, err := s.call("get", strings.NewReader(serverAddress))
+	if err == errNotFound {
+		return authConfig, nil
+	} else if err != nil {
+		return authConfig, err
+	}
+	var creds helperCredentials
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return authConfig, fmt.Errorf("error reading credentials from %s%s: %v", HelperPrefix, s.helper, err)
+	}
+	authConfig.Username = creds.Username
+	authConfig.Password = creds.Secret
+	authConfig.ServerAddress = serverAddress
+	return authConfig, nil
+}
+
+func (s *nativeStore) GetAll() (map[string]cliconfig.AuthConfig, error) {
+	auths, err := s.fileStore.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	authConfigs := make(map[string]cliconfig.AuthConfig, len(auths))
+	for serverAddress := range auths {
+		authConfig, err := s.Get(serverAddress)
+		if err != nil {
+			return nil, err
+		}
+		authConfigs[serverAddress] = authConfig
+	}
+	return authConfigs, nil
+}
+
+func (s *nativeStore) Store(authConfig cliconfig.AuthConfig) error {
+	buf, err := json.Marshal(helperCredentials{
+		ServerURL: authConfig.ServerAddress,
+		Username:  authConfig.Username,
+		Secret:    authConfig.Password,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := s.call("store", bytes.NewReader(buf)); err != nil {
+		return err
+	}
+
+	// keep the other fields in the file, without the credentials
+	authConfig.Username = ""
+	authConfig.Password = ""
+	return s.fileStore.Store(authConfig)
+}
+
+// call runs an action of the credential helper, and returns errNotFound if
+// the helper does not have the credentials.
+func (s *nativeStore) call(action string, in io.Reader) ([]byte, error) {
+	out, err := s.run(action, in)
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if msg == errNotFoundMessage {
+			return nil, errNotFound
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("error running %s%s %s: %s", HelperPrefix, s.helper, action, msg)
+	}
+	return out, nil
+}
@@ -0,0 +1,169 @@
+package credentials
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/cliconfig"
+)
+
+// fakeHelper is a credential helper keeping the credentials in memory.
+type fakeHelper map[string]helperCredentials
+
+func (h fakeHelper) run(action string, in io.Reader) ([]byte, error) {
+	buf, err := ioutil.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case "store":
+		var creds helperCredentials
+		if err := json.Unmarshal(buf, &creds); err != nil {
+			return nil, err
+		}
+		h[creds.ServerURL] = creds
+		return nil, nil
+	case "get", "erase":
+		creds, ok := h[string(buf)]
+		if !ok {
+			return []byte(errNotFoundMessage + "\n"), errors.New("exit status 1")
+		}
+		if action == "erase" {
+			delete(h, string(buf))
+			return nil, nil
+		}
+		return json.Marshal(creds)
+	}
+	return []byte("unknown action"), errors.New("exit status 1")
+}
+
+func newTestStore(t *testing.T) (*nativeStore, fakeHelper, *cliconfig.ConfigFile, func()) {
+	dir, err := ioutil.TempDir("", "credentials-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := cliconfig.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	helper := make(fakeHelper)
+	s := &nativeStore{helper: "fake", run: helper.run, fileStore: NewFileStore(file)}
+	return s, helper, file, func() { os.RemoveAll(dir) }
+}
+
+func TestNativeStore(t *testing.T) {
+	s, helper, file, cleanup := newTestStore(t)
+	defer cleanup()
+
+	const address = "registry.example.com"
+	authConfig := cliconfig.AuthConfig{
+		Username:      "joejoe",
+		Password:      "hello",
+		Email:         "user@example.com",
+		ServerAddress: address,
+	}
+	if err := s.Store(authConfig); err != nil {
+		t.Fatal(err)
+	}
+	if creds := helper[address]; creds.Username != "joejoe" || creds.Secret != "hello" {
+		t.Fatalf("Credentials not stored by the helper: %+v", creds)
+	}
+
+	buf, err := ioutil.ReadFile(file.Filename())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(buf), "hello") || strings.Contains(string(buf), "am9lam9lOmhlbGxv") || !strings.Contains(string(buf), "user@example.com") {
+		t.Fatalf("Credentials should not be in the file: %s", buf)
+	}
+	loaded, err := cliconfig.Load(strings.TrimSuffix(file.Filename(), cliconfig.CONFIGFILE))
+	if err != nil {
+		t.Fatalf("Failed loading the file without credentials: %v", err)
+	}
+	if ac := loaded.AuthConfigs[address]; ac.Username != "" || ac.Email != "user@example.com" {
+		t.Fatalf("Unexpected config in the file: %+v", ac)
+	}
+
+	got, err := s.Get(address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != authConfig {
+		t.Fatalf("Expected %+v, got %+v", authConfig, got)
+	}
+	all, err := s.GetAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 1 || all[address] != authConfig {
+		t.Fatalf("Expected only %+v, got %+v", authConfig, all)
+	}
+
+	if err := s.Erase(address); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := helper[address]; exists {
+		t.Fatal("Credentials not erased by the helper")
+	}
+	if _, exists := file.AuthConfigs[address]; exists {
+		t.Fatal("Config not erased from the file")
+	}
+	// erasing missing credentials is not an error
+	if err := s.Erase(address); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNativeStoreNotFound(t *testing.T) {
+	s, _, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	authConfig, err := s.Get("registry.example.com")
+	if err != nil {
+		t.Fatalf("Missing credentials should not be an error: %v", err)
+	}
+	if authConfig.Username != "" || authConfig.Password != "" {
+		t.Fatalf("Expected no credentials, got %+v", authConfig)
+	}
+}
+
+func TestNativeStoreError(t *testing.T) {
+	s, _, _, cleanup := newTestStore(t)
+	defer cleanup()
+
+	s.run = func(action string, in io.Reader) ([]byte, error) {
+		return []byte("keychain locked\n"), errors.New("exit status 1")
+	}
+	_, err := s.Get("registry.example.com")
+	if err == nil || !strings.Contains(err.Error(), "keychain locked") {
+		t.Fatalf("Expected the error of the helper, got %v", err)
+	}
+	if err := s.Store(cliconfig.AuthConfig{ServerAddress: "registry.example.com"}); err == nil {
+		t.Fatal("Expected the error of the helper")
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	file := cliconfig.NewConfigFile("")
+	if _, ok := NewStore(file, "registry.example.com").(*fileStore); !ok {
+		t.Fatal("Expected the file store without credential helpers")
+	}
+
+	file.CredentialsStore = "secretservice"
+	file.CredentialHelpers = map[string]string{"https://registry.example.com/v1/": "fake"}
+	for address, helper := range map[string]string{
+		"registry.example.com":         "fake",
+		"https://registry.example.com": "fake",
+		"https://index.docker.io/v1/":  "secretservice",
+	} {
+		s, ok := NewStore(file, address).(*nativeStore)
+		if !ok || s.helper != helper {
+			t.Fatalf("Expected the helper %s for %s, got %+v", helper, address, s)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/ioutils"
+)
+
+var (
+	// ErrExtractPointNotDirectory is returned when extracting an archive to
+	// a path of a container which is not a directory.
+	ErrExtractPointNotDirectory = errors.New("Extraction point is not a directory")
+	// ErrReadOnlyPath is returned when extracting an archive to a path of a
+	// container which is in a read-only root filesystem or volume.
+	ErrReadOnlyPath = errors.New("Extraction point is in a read-only filesystem")
+)
+
+// ContainerStatPath stats the filesystem resource at the given path in the
+// container identified by name.
+func (daemon *Daemon) ContainerStatPath(name string, path string) (*types.ContainerPathStat, error) {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	return container.StatPath(path)
+}
+
+// ContainerArchivePath returns a tar archive of the filesystem resource at the
+// given path in the container identified by name, and its stat.
+func (daemon *Daemon) ContainerArchivePath(name string, path string) (io.ReadCloser, *types.ContainerPathStat, error) {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	return container.ArchivePath(path)
+}
+
+// ContainerExtractToDir extracts the given tar archive to the directory at the
+// given path in the container identified by name. If noOverwriteDirNonDir is
+// true, it is an error for the archive to replace an existing directory with a
+// non-directory or the other way around.
+func (daemon *Daemon) ContainerExtractToDir(name, path string, noOverwriteDirNonDir bool, content io.Reader) error {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return err
+	}
+	return container.ExtractToDir(path, noOverwriteDirNonDir, content)
+}
+
+// StatPath stats the filesystem resource at the given path in the container.
+// A symbolic link at the last component of the path is not followed, but the
+// path it resolves to in the container is returned as LinkTarget.
+func (container *Container) StatPath(path string) (*types.ContainerPathStat, error) {
+	container.Lock()
+	defer container.Unlock()
+
+	if err := container.Mount(); err != nil {
+		return nil, err
+	}
+	defer container.Unmount()
+
+	if err := container.mountVolumes(); err != nil {
+		return nil, err
+	}
+	defer container.unmountVolumes()
+
+	return container.statPath(filepath.Join("/", path))
+}
+
+// ArchivePath returns a tar archive of the filesystem resource at the given
+// path in the container, symbolic links being followed, and the stat of the
+// path. The filesystem and volumes of the container stay mounted until the
+// archive is closed.
+func (container *Container) ArchivePath(path string) (content io.ReadCloser, stat *types.ContainerPathStat, err error) {
+	container.Lock()
+	defer container.Unlock()
+
+	if err = container.Mount(); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			container.Unmount()
+		}
+	}()
+
+	if err = container.mountVolumes(); err != nil {
+		return nil, nil, err
+	}
+	defer func() {
+		if err != nil {
+			container.unmountVolumes()
+		}
+	}()
+
+	absPath := filepath.Join("/", path)
+	stat, err = container.statPath(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resolvedPath, err := container.GetResourcePath(absPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := archive.TarResource(resolvedPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content = ioutils.NewReadCloserWrapper(data, func() error {
+		err := data.Close()
+		container.unmountVolumes()
+		container.Unmount()
+		return err
+	})
+	return content, stat, nil
+}
+
+// ExtractToDir extracts the given tar archive to the directory at the given
+// path in the container, symbolic links being followed. The directory must be
+// in a writable volume, or in the root filesystem of the container if it is
+// not read-only.
+func (container *Container) ExtractToDir(path string, noOverwriteDirNonDir bool, content io.Reader) error {
+	container.Lock()
+	defer container.Unlock()
+
+	if err := container.Mount(); err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	if err := container.mountVolumes(); err != nil {
+		return err
+	}
+	defer container.unmountVolumes()
+
+	resolvedPath, err := container.GetResourcePath(path)
+	if err != nil {
+		return err
+	}
+	stat, err := os.Lstat(resolvedPath)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return ErrExtractPointNotDirectory
+	}
+
+	containerPath, err := container.containerPath(resolvedPath)
+	if err != nil {
+		return err
+	}
+	if !container.canWriteTo(containerPath) {
+		return ErrReadOnlyPath
+	}
+
+	return chrootarchive.Untar(content, resolvedPath, &archive.TarOptions{
+		NoOverwriteDirNonDir: noOverwriteDirNonDir,
+	})
+}
+
+// statPath stats the resource at the given absolute path in the container,
+// whose filesystem and volumes must be mounted. Symbolic links are followed in
+// the parent directories of the path only.
+func (container *Container) statPath(absPath string) (*types.ContainerPathStat, error) {
+	dirPath, base := archive.SplitPathDirEntry(absPath)
+	resolvedDirPath, err := container.GetResourcePath(dirPath)
+	if err != nil {
+		return nil, err
+	}
+
+	lstat, err := os.Lstat(filepath.Join(resolvedDirPath, base))
+	if err != nil {
+		return nil, err
+	}
+
+	var linkTarget string
+	if lstat.Mode()&os.ModeSymlink != 0 {
+		resolvedPath, err := container.GetResourcePath(absPath)
+		if err != nil {
+			return nil, err
+		}
+		if linkTarget, err = container.containerPath(resolvedPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return &types.ContainerPathStat{
+		Name:       base,
+		Size:       lstat.Size(),
+		Mode:       lstat.Mode(),
+		Mtime:      lstat.ModTime(),
+		LinkTarget: linkTarget,
+	}, nil
+}
+
+// containerPath returns the absolute path in the container of the given path
+// in its mounted root filesystem.
+func (container *Container) containerPath(resolvedPath string) (string, error) {
+	rel, err := filepath.Rel(container.basefs, resolvedPath)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join("/", rel), nil
+}
+
+// canWriteTo returns whether the given absolute path in the container is
+// writable, according to the volume it is in, or to the root filesystem of the
+// container if it is not in a volume.
+func (container *Container) canWriteTo(absPath string) bool {
+	var volumeDest string
+	for dest := range container.Volumes {
+		if absPath != dest && !strings.HasPrefix(absPath, dest+"/") {
+			continue
+		}
+		if len(dest) > len(volumeDest) {
+			volumeDest = dest
+		}
+	}
+	if volumeDest != "" {
+		return container.VolumesRW[volumeDest]
+	}
+	return !container.hostConfig.ReadonlyRootfs
+}
@@ -13,6 +13,8 @@ type ContainerCommitConfig struct {
 	Comment string
 	Changes []string
 	Config  *runconfig.Config
+	// Squash squashes the layers of the image into one layer
+	Squash bool
 }
 
 // Commit creates a new filesystem image from the current state of a container.
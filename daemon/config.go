@@ -3,6 +3,8 @@ package daemon
 import (
 	"net"
 
+	"github.com/docker/docker/daemon/events"
+	"github.com/docker/docker/graph"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/runconfig"
@@ -18,22 +20,26 @@ const (
 type CommonConfig struct {
 	AutoRestart bool
 	// Bridge holds bridge network specific configuration.
-	Bridge         bridgeConfig
-	Context        map[string][]string
-	CorsHeaders    string
-	DisableNetwork bool
-	Dns            []string
-	DnsSearch      []string
-	EnableCors     bool
-	ExecDriver     string
-	ExecRoot       string
-	GraphDriver    string
-	Labels         []string
-	LogConfig      runconfig.LogConfig
-	Mtu            int
-	Pidfile        string
-	Root           string
-	TrustKeyPath   string
+	Bridge                 bridgeConfig
+	Context                map[string][]string
+	CorsHeaders            string
+	DisableNetwork         bool
+	Dns                    []string
+	DnsSearch              []string
+	EmbeddedDNS            bool
+	EnableCors             bool
+	EnableMetrics          bool
+	EventsLimit            int
+	ExecDriver             string
+	ExecRoot               string
+	GraphDriver            string
+	Labels                 []string
+	LogConfig              runconfig.LogConfig
+	MaxConcurrentDownloads int
+	Mtu                    int
+	Pidfile                string
+	Root                   string
+	TrustKeyPath           string
 }
 
 // bridgeConfig stores all the bridge driver specific
@@ -84,9 +90,13 @@ func (config *Config) InstallCommonFlags() {
 	// FIXME: why the inconsistency between "hosts" and "sockets"?
 	opts.IPListVar(&config.Dns, []string{"#dns", "-dns"}, "DNS server to use")
 	opts.DnsSearchListVar(&config.DnsSearch, []string{"-dns-search"}, "DNS search domains to use")
+	flag.BoolVar(&config.EmbeddedDNS, []string{"-embedded-dns"}, false, "Resolve the names of containers with a DNS server embedded in each container")
 	opts.LabelListVar(&config.Labels, []string{"-label"}, "Set key=value labels to the daemon")
 	flag.StringVar(&config.LogConfig.Type, []string{"-log-driver"}, "json-file", "Default driver for container logs")
 	opts.LogOptsVar(config.LogConfig.Config, []string{"-log-opt"}, "Set log driver options")
 	flag.BoolVar(&config.Bridge.EnableUserlandProxy, []string{"-userland-proxy"}, true, "Use userland proxy for loopback traffic")
+	flag.IntVar(&config.EventsLimit, []string{"-events-buffer-size"}, events.DefaultLimit, "Number of recent events replayed to new listeners")
+	flag.BoolVar(&config.EnableMetrics, []string{"-metrics"}, false, "Expose Prometheus metrics on the /metrics endpoint of the remote API")
+	flag.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, graph.DefaultMaxConcurrentDownloads, "Maximum number of layers pulled at the same time")
 
 }
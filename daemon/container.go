@@ -16,6 +16,7 @@ import (
 	"github.com/docker/libcontainer/label"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/daemon/logger/jsonfilelog"
@@ -25,10 +26,11 @@ import (
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/broadcastwriter"
 	"github.com/docker/docker/pkg/ioutils"
-	"github.com/docker/docker/pkg/jsonlog"
 	"github.com/docker/docker/pkg/promise"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/volumes"
 )
 
 var (
@@ -63,6 +65,9 @@ type CommonContainer struct {
 	ImageID string `json:"Image"`
 
 	NetworkSettings *network.Settings
+	// guards the networks of NetworkSettings, which the other containers and
+	// the embedded DNS servers read without locking the container
+	networksLock sync.RWMutex
 
 	ResolvConfPath string
 	HostnamePath   string
@@ -85,6 +90,8 @@ type CommonContainer struct {
 	// volume on disk
 	Volumes    map[string]string
 	hostConfig *runconfig.HostConfig
+	// the volumes of volume drivers mounted for the container while it runs
+	driverVolumes []*volumes.Volume
 
 	monitor      *containerMonitor
 	execCommands *execStore
@@ -93,6 +100,27 @@ type CommonContainer struct {
 	logCopier *logger.Copier
 }
 
+// endpointSettings returns the settings of the container in a network.
+func (container *Container) endpointSettings(name string) (*network.EndpointSettings, bool) {
+	container.networksLock.RLock()
+	defer container.networksLock.RUnlock()
+
+	if container.NetworkSettings == nil {
+		return nil, false
+	}
+	settings, ok := container.NetworkSettings.Networks[name]
+	return settings, ok
+}
+
+// addresses returns the IPv4 and IPv6 addresses of the container in the
+// network it is started with.
+func (container *Container) addresses() (string, string) {
+	container.networksLock.RLock()
+	defer container.networksLock.RUnlock()
+
+	return container.NetworkSettings.IPAddress, container.NetworkSettings.GlobalIPv6Address
+}
+
 func (container *Container) FromDisk() error {
 	pth, err := container.jsonPath()
 	if err != nil {
@@ -182,13 +210,30 @@ func (container *Container) WriteHostConfig() error {
 	return ioutil.WriteFile(pth, data, 0666)
 }
 
+// LogEvent logs an event about the container, with its name, its image and
+// its labels as attributes.
 func (container *Container) LogEvent(action string) {
-	d := container.daemon
-	d.EventsService.Log(
-		action,
-		container.ID,
-		container.Config.Image,
-	)
+	container.logEventWithAttributes(action, nil)
+}
+
+// logEventWithAttributes logs an event about the container, with attributes
+// in addition to its name, its image and its labels.
+func (container *Container) logEventWithAttributes(action string, extra map[string]string) {
+	attributes := make(map[string]string)
+	for k, v := range container.Config.Labels {
+		attributes[k] = v
+	}
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	attributes["image"] = container.Config.Image
+	attributes["name"] = strings.TrimPrefix(container.Name, "/")
+
+	container.daemon.EventsService.Log(types.ContainerEventType, action, types.EventActor{
+		ID:         container.ID,
+		Attributes: attributes,
+		Labels:     container.Config.Labels,
+	})
 }
 
 // Evaluates `path` in the scope of the container's basefs, with proper path
@@ -262,6 +307,9 @@ func (container *Container) Start() (err error) {
 	if err := container.prepareVolumes(); err != nil {
 		return err
 	}
+	if err := container.mountDriverVolumes(); err != nil {
+		return err
+	}
 	linkedEnv, err := container.setupLinkedContainers()
 	if err != nil {
 		return err
@@ -323,18 +371,6 @@ func (streamConfig *StreamConfig) StderrPipe() io.ReadCloser {
 	return ioutils.NewBufReader(reader)
 }
 
-func (streamConfig *StreamConfig) StdoutLogPipe() io.ReadCloser {
-	reader, writer := io.Pipe()
-	streamConfig.stdout.AddWriter(writer, "stdout")
-	return ioutils.NewBufReader(reader)
-}
-
-func (streamConfig *StreamConfig) StderrLogPipe() io.ReadCloser {
-	reader, writer := io.Pipe()
-	streamConfig.stderr.AddWriter(writer, "stderr")
-	return ioutils.NewBufReader(reader)
-}
-
 func (container *Container) isNetworkAllocated() bool {
 	return container.NetworkSettings.IPAddress != ""
 }
@@ -350,6 +386,8 @@ func (container *Container) cleanup() {
 		logrus.Errorf("%v: Failed to umount filesystem: %v", container.ID, err)
 	}
 
+	container.unmountDriverVolumes()
+
 	for _, eConfig := range container.execCommands.s {
 		container.daemon.unregisterExecCommand(eConfig)
 	}
@@ -459,9 +497,10 @@ func (container *Container) Stop(seconds int) error {
 		return nil
 	}
 
-	// 1. Send a SIGTERM
-	if err := container.killPossiblyDeadProcess(15); err != nil {
-		logrus.Infof("Failed to send SIGTERM to the process, force killing")
+	// 1. Send the stop signal, SIGTERM by default
+	stopSignal := container.StopSignal()
+	if err := container.killPossiblyDeadProcess(stopSignal); err != nil {
+		logrus.Infof("Failed to send signal %d to the process, force killing", stopSignal)
 		if err := container.killPossiblyDeadProcess(9); err != nil {
 			return err
 		}
@@ -469,7 +508,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 2. Wait for the process to exit on its own
 	if _, err := container.WaitStop(time.Duration(seconds) * time.Second); err != nil {
-		logrus.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
+		logrus.Infof("Container %v failed to exit within %d seconds of signal %d - using the force", container.ID, seconds, stopSignal)
 		// 3. If it doesn't, then send SIGKILL
 		if err := container.Kill(); err != nil {
 			container.WaitStop(-1 * time.Second)
@@ -479,6 +518,19 @@ func (container *Container) Stop(seconds int) error {
 	return nil
 }
 
+// StopSignal returns the signal used to stop the container gracefully: the
+// one set in its config, or SIGTERM.
+func (container *Container) StopSignal() int {
+	var stopSignal syscall.Signal
+	if container.Config.StopSignal != "" {
+		stopSignal, _ = signal.ParseSignal(container.Config.StopSignal)
+	}
+	if int(stopSignal) <= 0 {
+		stopSignal = syscall.SIGTERM
+	}
+	return int(stopSignal)
+}
+
 func (container *Container) Restart(seconds int) error {
 	// Avoid unnecessarily unmounting and then directly mounting
 	// the container when the container stops and then starts
@@ -578,7 +630,6 @@ func (container *Container) Copy(resource string) (io.ReadCloser, error) {
 	}()
 
 	if err = container.mountVolumes(); err != nil {
-		container.unmountVolumes()
 		return nil, err
 	}
 	defer func() {
@@ -642,44 +693,94 @@ func (container *Container) getLogConfig() runconfig.LogConfig {
 	if cfg.Type != "" { // container has log driver configured
 		return cfg
 	}
+	if len(cfg.Config) > 0 { // container has log opts for the default log driver
+		return runconfig.LogConfig{Type: container.daemon.defaultLogConfig.Type, Config: cfg.Config}
+	}
 	// Use daemon's default log config for containers
 	return container.daemon.defaultLogConfig
 }
 
 func (container *Container) getLogger() (logger.Logger, error) {
-	cfg := container.getLogConfig()
-	c, err := logger.GetLogDriver(cfg.Type)
+	ctx, err := container.getLogContext()
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get logging factory: %v", err)
+		return nil, err
 	}
+	return container.newLogger(ctx)
+}
+
+// getLogContext returns the context passed to the logging driver, with
+// the container metadata used for log tags and extra attributes.
+func (container *Container) getLogContext() (logger.Context, error) {
+	cfg := container.getLogConfig()
 	ctx := logger.Context{
-		Config:        cfg.Config,
-		ContainerID:   container.ID,
-		ContainerName: container.Name,
+		Config:              cfg.Config,
+		ContainerID:         container.ID,
+		ContainerName:       container.Name,
+		ContainerEntrypoint: container.Path,
+		ContainerArgs:       container.Args,
+		ContainerImageID:    container.ImageID,
+		ContainerImageName:  container.Config.Image,
+		ContainerCreated:    container.Created,
+		ContainerEnv:        container.Config.Env,
+		ContainerLabels:     container.Config.Labels,
+		DaemonName:          "docker",
 	}
 
 	// Set logging file for "json-logger"
 	if cfg.Type == jsonfilelog.Name {
+		var err error
 		ctx.LogPath, err = container.GetRootResourcePath(fmt.Sprintf("%s-json.log", container.ID))
 		if err != nil {
-			return nil, err
+			return ctx, err
 		}
 	}
+	return ctx, nil
+}
+
+func (container *Container) newLogger(ctx logger.Context) (logger.Logger, error) {
+	c, err := logger.GetLogDriver(container.getLogConfig().Type)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get logging factory: %v", err)
+	}
 	return c(ctx)
 }
 
+// getLogReader returns the logging driver to read the container's logs
+// from. The running logging driver is returned if there is one, so that
+// readers following the logs are stopped along with it. Otherwise a new
+// driver instance is created and running is false; the caller has to
+// close it once done reading.
+func (container *Container) getLogReader() (l logger.Logger, running bool, err error) {
+	container.Lock()
+	l = container.logDriver
+	container.Unlock()
+	if l != nil {
+		return l, true, nil
+	}
+
+	if container.getLogConfig().Type == "none" {
+		return nil, false, logger.ReadLogsNotSupported
+	}
+	l, err = container.getLogger()
+	return l, false, err
+}
+
 func (container *Container) startLogging() error {
 	cfg := container.getLogConfig()
 	if cfg.Type == "none" {
 		return nil // do not start logging routines
 	}
 
-	l, err := container.getLogger()
+	ctx, err := container.getLogContext()
+	if err != nil {
+		return fmt.Errorf("Failed to initialize logging driver: %v", err)
+	}
+	l, err := container.newLogger(ctx)
 	if err != nil {
 		return fmt.Errorf("Failed to initialize logging driver: %v", err)
 	}
 
-	copier, err := logger.NewCopier(container.ID, map[string]io.Reader{"stdout": container.StdoutPipe(), "stderr": container.StderrPipe()}, l)
+	copier, err := logger.NewCopier(container.ID, map[string]io.Reader{"stdout": container.StdoutPipe(), "stderr": container.StderrPipe()}, l, ctx.ExtraAttributes())
 	if err != nil {
 		return err
 	}
@@ -813,29 +914,34 @@ func (c *Container) Attach(stdin io.ReadCloser, stdout io.Writer, stderr io.Writ
 
 func (c *Container) AttachWithLogs(stdin io.ReadCloser, stdout, stderr io.Writer, logs, stream bool) error {
 	if logs {
-		logDriver, err := c.getLogger()
-		cLog, err := logDriver.GetReader()
-
+		l, running, err := c.getLogReader()
 		if err != nil {
-			logrus.Errorf("Error reading logs: %s", err)
-		} else if c.LogDriverType() != jsonfilelog.Name {
+			return err
+		}
+		if !running {
+			defer l.Close()
+		}
+		if logReader, ok := l.(logger.LogReader); !ok {
 			logrus.Errorf("Reading logs not implemented for driver %s", c.LogDriverType())
 		} else {
-			dec := json.NewDecoder(cLog)
+			logs := logReader.ReadLogs(logger.ReadConfig{Tail: -1})
+			defer logs.Close()
+		LogLoop:
 			for {
-				l := &jsonlog.JSONLog{}
-
-				if err := dec.Decode(l); err == io.EOF {
-					break
-				} else if err != nil {
+				select {
+				case msg, ok := <-logs.Msg:
+					if !ok {
+						break LogLoop
+					}
+					if msg.Source == "stdout" && stdout != nil {
+						stdout.Write(append(msg.Line, '\n'))
+					}
+					if msg.Source == "stderr" && stderr != nil {
+						stderr.Write(append(msg.Line, '\n'))
+					}
+				case err := <-logs.Err:
 					logrus.Errorf("Error streaming logs: %s", err)
-					break
-				}
-				if l.Stream == "stdout" && stdout != nil {
-					io.WriteString(stdout, l.Log)
-				}
-				if l.Stream == "stderr" && stderr != nil {
-					io.WriteString(stderr, l.Log)
+					break LogLoop
 				}
 			}
 		}
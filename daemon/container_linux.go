@@ -21,6 +21,7 @@ import (
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/directory"
+	"github.com/docker/docker/pkg/dnsserver"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/pkg/ulimit"
@@ -29,6 +30,7 @@ import (
 	"github.com/docker/libcontainer/configs"
 	"github.com/docker/libcontainer/devices"
 	"github.com/docker/libnetwork"
+	"github.com/docker/libnetwork/etchosts"
 	"github.com/docker/libnetwork/netlabel"
 	"github.com/docker/libnetwork/netutils"
 	"github.com/docker/libnetwork/options"
@@ -50,6 +52,9 @@ type Container struct {
 	AppliedVolumesFrom map[string]struct{}
 
 	activeLinks map[string]*links.Link
+
+	// The embedded DNS server of the container, when the daemon runs them
+	resolver *dnsserver.Server
 }
 
 func killProcessDirectly(container *Container) error {
@@ -357,7 +362,7 @@ func (container *Container) buildHostnameFile() error {
 	return ioutil.WriteFile(container.HostnamePath, []byte(container.Config.Hostname+"\n"), 0644)
 }
 
-func (container *Container) buildJoinOptions() ([]libnetwork.EndpointOption, error) {
+func (container *Container) buildJoinOptions(n libnetwork.Network) ([]libnetwork.EndpointOption, error) {
 	var (
 		joinOptions []libnetwork.EndpointOption
 		err         error
@@ -384,7 +389,10 @@ func (container *Container) buildJoinOptions() ([]libnetwork.EndpointOption, err
 	}
 	joinOptions = append(joinOptions, libnetwork.JoinOptionResolvConfPath(container.ResolvConfPath))
 
-	if len(container.hostConfig.Dns) > 0 {
+	if container.useResolver() {
+		// the embedded DNS server forwards to the DNS servers of the container
+		dns = []string{resolverIP}
+	} else if len(container.hostConfig.Dns) > 0 {
 		dns = container.hostConfig.Dns
 	} else if len(container.daemon.config.Dns) > 0 {
 		dns = container.daemon.config.Dns
@@ -423,14 +431,16 @@ func (container *Container) buildJoinOptions() ([]libnetwork.EndpointOption, err
 	}
 
 	for linkAlias, child := range children {
-		_, alias := path.Split(linkAlias)
-		// allow access to the linked container via the alias, real name, and container hostname
-		aliasList := alias + " " + child.Config.Hostname
-		// only add the name if alias isn't equal to the name
-		if alias != child.Name[1:] {
-			aliasList = aliasList + " " + child.Name[1:]
-		}
-		joinOptions = append(joinOptions, libnetwork.JoinOptionExtraHost(aliasList, child.NetworkSettings.IPAddress))
+		if !container.useResolver() {
+			_, alias := path.Split(linkAlias)
+			// allow access to the linked container via the alias, real name, and container hostname
+			aliasList := alias + " " + child.Config.Hostname
+			// only add the name if alias isn't equal to the name
+			if alias != child.Name[1:] {
+				aliasList = aliasList + " " + child.Name[1:]
+			}
+			joinOptions = append(joinOptions, libnetwork.JoinOptionExtraHost(aliasList, child.NetworkSettings.IPAddress))
+		}
 		if child.NetworkSettings.EndpointID != "" {
 			childEndpoints = append(childEndpoints, child.NetworkSettings.EndpointID)
 		}
@@ -442,6 +452,16 @@ func (container *Container) buildJoinOptions() ([]libnetwork.EndpointOption, err
 		joinOptions = append(joinOptions, libnetwork.JoinOptionExtraHost(parts[0], parts[1]))
 	}
 
+	// allow access to the containers sharing a network created by the user via their name
+	for _, name := range container.networkNames() {
+		if !runconfig.NetworkMode(name).IsUserDefined() || container.useResolver() {
+			continue
+		}
+		for peer, settings := range container.networkPeers(name) {
+			joinOptions = append(joinOptions, libnetwork.JoinOptionExtraHost(peer.Name[1:], settings.IPAddress))
+		}
+	}
+
 	refs := container.daemon.ContainerGraph().RefPaths(container.ID)
 	for _, ref := range refs {
 		if ref.ParentID == "0" {
@@ -454,22 +474,28 @@ func (container *Container) buildJoinOptions() ([]libnetwork.EndpointOption, err
 		}
 
 		if c != nil && !container.daemon.config.DisableNetwork && container.hostConfig.NetworkMode.IsPrivate() {
-			logrus.Debugf("Update /etc/hosts of %s for alias %s with ip %s", c.ID, ref.Name, container.NetworkSettings.IPAddress)
-			joinOptions = append(joinOptions, libnetwork.JoinOptionParentUpdate(c.NetworkSettings.EndpointID, ref.Name, container.NetworkSettings.IPAddress))
+			// the embedded DNS server of the parent always resolves the alias to the current ip
+			if !c.useResolver() {
+				logrus.Debugf("Update /etc/hosts of %s for alias %s with ip %s", c.ID, ref.Name, container.NetworkSettings.IPAddress)
+				joinOptions = append(joinOptions, libnetwork.JoinOptionParentUpdate(c.NetworkSettings.EndpointID, ref.Name, container.NetworkSettings.IPAddress))
+			}
 			if c.NetworkSettings.EndpointID != "" {
 				parentEndpoints = append(parentEndpoints, c.NetworkSettings.EndpointID)
 			}
 		}
 	}
 
-	linkOptions := options.Generic{
-		netlabel.GenericData: options.Generic{
-			"ParentEndpoints": parentEndpoints,
-			"ChildEndpoints":  childEndpoints,
-		},
-	}
+	// links only apply to the network the container was started with
+	if n.ID() == container.NetworkSettings.NetworkID {
+		linkOptions := options.Generic{
+			netlabel.GenericData: options.Generic{
+				"ParentEndpoints": parentEndpoints,
+				"ChildEndpoints":  childEndpoints,
+			},
+		}
 
-	joinOptions = append(joinOptions, libnetwork.JoinOptionGeneric(linkOptions))
+		joinOptions = append(joinOptions, libnetwork.JoinOptionGeneric(linkOptions))
+	}
 
 	return joinOptions, nil
 }
@@ -585,7 +611,10 @@ func (container *Container) updateJoinInfo(ep libnetwork.Endpoint) error {
 }
 
 func (container *Container) updateNetworkSettings(n libnetwork.Network, ep libnetwork.Endpoint) error {
-	networkSettings := &network.Settings{NetworkID: n.ID(), EndpointID: ep.ID()}
+	networkSettings := &network.Settings{NetworkID: n.ID(), EndpointID: ep.ID(), Networks: container.NetworkSettings.Networks}
+	if networkSettings.Networks == nil {
+		networkSettings.Networks = make(map[string]*network.EndpointSettings)
+	}
 
 	networkSettings, err := container.buildPortMapInfo(n, ep, networkSettings)
 	if err != nil {
@@ -601,7 +630,7 @@ func (container *Container) updateNetworkSettings(n libnetwork.Network, ep libne
 		networkSettings.Bridge = container.daemon.config.Bridge.Iface
 	}
 
-	container.NetworkSettings = networkSettings
+	container.setNetworkSettings(networkSettings)
 	return nil
 }
 
@@ -621,7 +650,7 @@ func (container *Container) UpdateNetwork() error {
 
 	}
 
-	joinOptions, err := container.buildJoinOptions()
+	joinOptions, err := container.buildJoinOptions(n)
 	if err != nil {
 		return fmt.Errorf("Update network failed: %v", err)
 	}
@@ -718,6 +747,18 @@ func (container *Container) buildCreateEndpointOptions() ([]libnetwork.EndpointO
 		createOptions = append(createOptions, libnetwork.EndpointOptionGeneric(genericOption))
 	}
 
+	// Request the static addresses, which the container keeps across restarts
+	genericOption := options.Generic{}
+	if ip := net.ParseIP(container.hostConfig.IPv4Address); ip != nil {
+		genericOption[netlabel.IPv4Address] = ip
+	}
+	if ip := net.ParseIP(container.hostConfig.IPv6Address); ip != nil {
+		genericOption[netlabel.IPv6Address] = ip
+	}
+	if len(genericOption) > 0 {
+		createOptions = append(createOptions, libnetwork.EndpointOptionGeneric(genericOption))
+	}
+
 	return createOptions, nil
 }
 
@@ -739,7 +780,7 @@ func (container *Container) AllocateNetwork() error {
 		return err
 	}
 
-	ep, err := n.CreateEndpoint(container.Name, createOptions...)
+	ep, err := container.createEndpoint(n, createOptions...)
 	if err != nil {
 		return err
 	}
@@ -748,7 +789,7 @@ func (container *Container) AllocateNetwork() error {
 		return err
 	}
 
-	joinOptions, err := container.buildJoinOptions()
+	joinOptions, err := container.buildJoinOptions(n)
 	if err != nil {
 		return err
 	}
@@ -761,6 +802,32 @@ func (container *Container) AllocateNetwork() error {
 		return fmt.Errorf("Updating join info failed: %v", err)
 	}
 
+	settings := endpointSettings(n, ep)
+	container.setEndpointSettings(n.Name(), settings)
+	container.updateNetworkHosts(n.Name(), settings, true)
+
+	// join the networks the container was connected to
+	for name, settings := range container.NetworkSettings.Networks {
+		if name == n.Name() {
+			continue
+		}
+
+		connected, err := container.daemon.netController.NetworkByName(name)
+		if err != nil {
+			return fmt.Errorf("error locating network with name %s: %v", name, err)
+		}
+
+		if err := container.joinNetwork(connected, settings.Aliases); err != nil {
+			return fmt.Errorf("joining network %s failed: %v", name, err)
+		}
+	}
+
+	if container.useResolver() {
+		if err := container.startResolver(); err != nil {
+			return fmt.Errorf("starting the embedded DNS server failed: %v", err)
+		}
+	}
+
 	if err := container.WriteHostConfig(); err != nil {
 		return err
 	}
@@ -768,6 +835,279 @@ func (container *Container) AllocateNetwork() error {
 	return nil
 }
 
+// createEndpoint creates the endpoint of the container in a network. The
+// static addresses of the other containers of the network are reserved for
+// them, even when they are stopped: an endpoint given one of them is only
+// deleted once another endpoint was created, so that the next address is
+// allocated instead.
+func (container *Container) createEndpoint(n libnetwork.Network, options ...libnetwork.EndpointOption) (libnetwork.Endpoint, error) {
+	reserved := container.daemon.staticAddresses(n.Name(), container)
+	// the addresses the container requests in the network it is started
+	// with were checked against the reserved ones when it was created
+	requested := n.Name() == container.defaultNetworkName()
+
+	var skipped []libnetwork.Endpoint
+	defer func() {
+		for _, ep := range skipped {
+			if err := ep.Delete(); err != nil {
+				logrus.Errorf("deleting endpoint failed: %v", err)
+			}
+		}
+	}()
+	for {
+		ep, err := n.CreateEndpoint(container.Name, options...)
+		if err != nil {
+			return nil, err
+		}
+		var (
+			settings = endpointSettings(n, ep)
+			owner    *Container
+			addr     string
+		)
+		if c, ok := reserved[settings.IPAddress]; ok && !(requested && container.hostConfig.IPv4Address != "") {
+			owner, addr = c, settings.IPAddress
+		} else if c, ok := reserved[settings.GlobalIPv6Address]; ok && !(requested && container.hostConfig.IPv6Address != "") {
+			owner, addr = c, settings.GlobalIPv6Address
+		}
+		if owner == nil {
+			return ep, nil
+		}
+		logrus.Debugf("Skipping the address %s of container %s in network %s", addr, owner.Name, n.Name())
+		skipped = append(skipped, ep)
+	}
+}
+
+// joinNetwork creates an endpoint of the running container in a network it
+// is connected to, in addition to the network it was started with. The
+// aliases are the other names of the container in the network.
+func (container *Container) joinNetwork(n libnetwork.Network, aliases []string) error {
+	ep, err := container.createEndpoint(n)
+	if err != nil {
+		return err
+	}
+
+	joinOptions, err := container.buildJoinOptions(n)
+	if err == nil {
+		_, err = ep.Join(container.ID, joinOptions...)
+	}
+	if err != nil {
+		if err := ep.Delete(); err != nil {
+			logrus.Errorf("deleting endpoint failed: %v", err)
+		}
+		return err
+	}
+
+	settings := endpointSettings(n, ep)
+	settings.Aliases = aliases
+	container.setEndpointSettings(n.Name(), settings)
+	container.updateNetworkHosts(n.Name(), settings, true)
+
+	return nil
+}
+
+// leaveNetwork deletes the endpoint of the running container in a network it
+// is connected to.
+func (container *Container) leaveNetwork(n libnetwork.Network, settings *network.EndpointSettings) error {
+	ep, err := n.EndpointByID(settings.EndpointID)
+	if err != nil {
+		return fmt.Errorf("error locating endpoint id %s: %v", settings.EndpointID, err)
+	}
+
+	container.updateNetworkHosts(n.Name(), settings, false)
+
+	if err := ep.Leave(container.ID); err != nil {
+		return fmt.Errorf("leaving endpoint failed: %v", err)
+	}
+
+	if err := ep.Delete(); err != nil {
+		return fmt.Errorf("deleting endpoint failed: %v", err)
+	}
+
+	return nil
+}
+
+// ConnectToNetwork connects the container to a network in addition to the
+// network it is started with. A running container joins the network right
+// away. The embedded DNS servers of the other containers of the network
+// resolve the aliases to the address of the container in it.
+func (container *Container) ConnectToNetwork(n libnetwork.Network, aliases []string) error {
+	container.Lock()
+	defer container.Unlock()
+
+	if !container.hostConfig.NetworkMode.IsPrivate() || container.Config.NetworkDisabled {
+		return fmt.Errorf("Container %s cannot be connected to network %s: it does not have its own network stack", container.Name[1:], n.Name())
+	}
+
+	if container.isConnectedTo(n.Name()) {
+		return fmt.Errorf("Conflict. Container %s is already connected to network %s", container.Name[1:], n.Name())
+	}
+
+	if container.Running {
+		if err := container.joinNetwork(n, aliases); err != nil {
+			return err
+		}
+	} else {
+		container.setEndpointSettings(n.Name(), &network.EndpointSettings{Aliases: aliases})
+	}
+
+	return container.toDisk()
+}
+
+// DisconnectFromNetwork disconnects the container from a network it was
+// connected to. A running container leaves the network right away.
+func (container *Container) DisconnectFromNetwork(n libnetwork.Network) error {
+	container.Lock()
+	defer container.Unlock()
+
+	if n.Name() == container.defaultNetworkName() {
+		return fmt.Errorf("Container %s cannot be disconnected from network %s, which it is started with", container.Name[1:], n.Name())
+	}
+
+	settings, ok := container.NetworkSettings.Networks[n.Name()]
+	if !ok {
+		return fmt.Errorf("Container %s is not connected to network %s", container.Name[1:], n.Name())
+	}
+
+	if settings.EndpointID != "" {
+		if err := container.leaveNetwork(n, settings); err != nil {
+			return err
+		}
+	}
+	container.setEndpointSettings(n.Name(), nil)
+
+	return container.toDisk()
+}
+
+// defaultNetworkName returns the name of the network the container is
+// started with.
+func (container *Container) defaultNetworkName() string {
+	if container.hostConfig.NetworkMode == "" {
+		return "bridge"
+	}
+	return string(container.hostConfig.NetworkMode)
+}
+
+// networkNames returns the names of the network the container is started
+// with and of the networks it is connected to.
+func (container *Container) networkNames() []string {
+	container.networksLock.RLock()
+	defer container.networksLock.RUnlock()
+
+	names := []string{container.defaultNetworkName()}
+	for name := range container.NetworkSettings.Networks {
+		if name != names[0] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// setEndpointSettings records the settings of the container in a network, or
+// forgets them if settings is nil.
+func (container *Container) setEndpointSettings(name string, settings *network.EndpointSettings) {
+	container.networksLock.Lock()
+	defer container.networksLock.Unlock()
+
+	if container.NetworkSettings.Networks == nil {
+		container.NetworkSettings.Networks = make(map[string]*network.EndpointSettings)
+	}
+	if settings != nil {
+		container.NetworkSettings.Networks[name] = settings
+	} else {
+		delete(container.NetworkSettings.Networks, name)
+	}
+}
+
+// setNetworkSettings replaces the network settings of the container.
+func (container *Container) setNetworkSettings(settings *network.Settings) {
+	container.networksLock.Lock()
+	container.NetworkSettings = settings
+	container.networksLock.Unlock()
+}
+
+func (container *Container) isConnectedTo(name string) bool {
+	if name == container.defaultNetworkName() {
+		return true
+	}
+	_, ok := container.endpointSettings(name)
+	return ok
+}
+
+// networkPeers returns the other running containers which have an endpoint
+// in the network, and their endpoint settings.
+func (container *Container) networkPeers(name string) map[*Container]*network.EndpointSettings {
+	peers := make(map[*Container]*network.EndpointSettings)
+	for _, c := range container.daemon.List() {
+		if c == container || c.NetworkSettings == nil {
+			continue
+		}
+		if settings, ok := c.endpointSettings(name); ok && settings.EndpointID != "" {
+			peers[c] = settings
+		}
+	}
+	return peers
+}
+
+// updateNetworkHosts adds the container to the /etc/hosts of the other
+// containers of a network created by the user, or removes the container from
+// their /etc/hosts and them from its own if add is false.
+func (container *Container) updateNetworkHosts(name string, settings *network.EndpointSettings, add bool) {
+	if !runconfig.NetworkMode(name).IsUserDefined() || settings.IPAddress == "" || container.useResolver() {
+		return
+	}
+
+	record := []etchosts.Record{{Hosts: container.Name[1:], IP: settings.IPAddress}}
+	for peer, peerSettings := range container.networkPeers(name) {
+		if add {
+			if err := network.AddHosts(peer.HostsPath, record); err != nil {
+				logrus.Errorf("Error adding %s to the hosts of %s: %v", container.Name, peer.Name, err)
+			}
+			continue
+		}
+
+		if err := network.DeleteHosts(peer.HostsPath, record); err != nil {
+			logrus.Errorf("Error removing %s from the hosts of %s: %v", container.Name, peer.Name, err)
+		}
+		peerRecord := []etchosts.Record{{Hosts: peer.Name[1:], IP: peerSettings.IPAddress}}
+		if err := network.DeleteHosts(container.HostsPath, peerRecord); err != nil {
+			logrus.Errorf("Error removing %s from the hosts of %s: %v", peer.Name, container.Name, err)
+		}
+	}
+}
+
+func endpointSettings(n libnetwork.Network, ep libnetwork.Endpoint) *network.EndpointSettings {
+	settings := &network.EndpointSettings{NetworkID: n.ID(), EndpointID: ep.ID()}
+
+	epInfo := ep.Info()
+	if epInfo == nil {
+		return settings
+	}
+
+	if ifaceList := epInfo.InterfaceList(); len(ifaceList) > 0 {
+		iface := ifaceList[0]
+
+		ones, _ := iface.Address().Mask.Size()
+		settings.IPAddress = iface.Address().IP.String()
+		settings.IPPrefixLen = ones
+		settings.MacAddress = iface.MacAddress().String()
+
+		if iface.AddressIPv6().IP.To16() != nil {
+			onesv6, _ := iface.AddressIPv6().Mask.Size()
+			settings.GlobalIPv6Address = iface.AddressIPv6().IP.String()
+			settings.GlobalIPv6PrefixLen = onesv6
+		}
+	}
+
+	if gw := epInfo.Gateway(); len(gw) > 0 {
+		settings.Gateway = gw.String()
+	}
+	if gw6 := epInfo.GatewayIPv6(); gw6.To16() != nil {
+		settings.IPv6Gateway = gw6.String()
+	}
+
+	return settings
+}
+
 func (container *Container) initializeNetworking() error {
 	var err error
 
@@ -917,6 +1257,31 @@ func (container *Container) ReleaseNetwork() {
 		return
 	}
 
+	container.stopResolver()
+
+	// keep the networks the container is connected to, it joins them again
+	// when it is started
+	networks := make(map[string]*network.EndpointSettings)
+	for name, settings := range container.NetworkSettings.Networks {
+		networks[name] = &network.EndpointSettings{Aliases: settings.Aliases}
+		if settings.EndpointID == "" || settings.EndpointID == container.NetworkSettings.EndpointID {
+			continue
+		}
+
+		n, err := container.daemon.netController.NetworkByID(settings.NetworkID)
+		if err != nil {
+			logrus.Errorf("error locating network id %s: %v", settings.NetworkID, err)
+			continue
+		}
+
+		if err := container.leaveNetwork(n, settings); err != nil {
+			logrus.Error(err)
+		}
+	}
+	defer func() {
+		container.setNetworkSettings(&network.Settings{Networks: networks})
+	}()
+
 	n, err := container.daemon.netController.NetworkByID(container.NetworkSettings.NetworkID)
 	if err != nil {
 		logrus.Errorf("error locating network id %s: %v", container.NetworkSettings.NetworkID, err)
@@ -929,6 +1294,10 @@ func (container *Container) ReleaseNetwork() {
 		return
 	}
 
+	if settings, ok := container.NetworkSettings.Networks[n.Name()]; ok {
+		container.updateNetworkHosts(n.Name(), settings, false)
+	}
+
 	if err := ep.Leave(container.ID); err != nil {
 		logrus.Errorf("leaving endpoint failed: %v", err)
 	}
@@ -936,8 +1305,6 @@ func (container *Container) ReleaseNetwork() {
 	if err := ep.Delete(); err != nil {
 		logrus.Errorf("deleting endpoint failed: %v", err)
 	}
-
-	container.NetworkSettings = &network.Settings{}
 }
 
 func disableAllActiveLinks(container *Container) {
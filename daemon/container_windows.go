@@ -8,6 +8,7 @@ import (
 
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/libnetwork"
 )
 
 // TODO Windows. A reasonable default at the moment.
@@ -166,6 +167,20 @@ func (container *Container) RestoreNetwork() error {
 	return nil
 }
 
+func (container *Container) ConnectToNetwork(n libnetwork.Network, aliases []string) error {
+	// TODO Windows. Rework with libnetwork
+	return fmt.Errorf("Connecting to networks is not supported on this platform")
+}
+
+func (container *Container) DisconnectFromNetwork(n libnetwork.Network) error {
+	// TODO Windows. Rework with libnetwork
+	return fmt.Errorf("Disconnecting from networks is not supported on this platform")
+}
+
+func (container *Container) isConnectedTo(name string) bool {
+	return false
+}
+
 func disableAllActiveLinks(container *Container) {
 }
 
@@ -7,6 +7,7 @@ import (
 	"github.com/docker/docker/graph"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 	"github.com/docker/libcontainer/label"
 )
@@ -23,6 +24,11 @@ func (daemon *Daemon) ContainerCreate(name string, config *runconfig.Config, hos
 	if config.WorkingDir != "" && !filepath.IsAbs(config.WorkingDir) {
 		return "", warnings, fmt.Errorf("The working directory '%s' is invalid. It needs to be an absolute path.", config.WorkingDir)
 	}
+	if config.StopSignal != "" {
+		if _, err := signal.ParseSignal(config.StopSignal); err != nil {
+			return "", warnings, err
+		}
+	}
 
 	container, buildWarnings, err := daemon.Create(config, hostConfig, name)
 	if err != nil {
@@ -72,6 +78,16 @@ func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.Hos
 	if hostConfig == nil {
 		hostConfig = &runconfig.HostConfig{}
 	}
+	if hostConfig.IPv4Address != "" || hostConfig.IPv6Address != "" {
+		// The containers with static addresses are created one at a time,
+		// so that each one is checked against the host configs of the others
+		daemon.staticIPLock.Lock()
+		defer daemon.staticIPLock.Unlock()
+
+		if err := daemon.checkIPAddressConflicts(hostConfig); err != nil {
+			return nil, nil, err
+		}
+	}
 	if hostConfig.SecurityOpt == nil {
 		hostConfig.SecurityOpt, err = daemon.GenerateSecurityOpt(hostConfig.IpcMode, hostConfig.PidMode)
 		if err != nil {
@@ -21,6 +21,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/api"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/autogen/dockerversion"
 	"github.com/docker/docker/daemon/events"
 	"github.com/docker/docker/daemon/execdriver"
@@ -36,6 +37,7 @@ import (
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/graphdb"
 	"github.com/docker/docker/pkg/ioutils"
+	"github.com/docker/docker/pkg/metrics"
 	"github.com/docker/docker/pkg/namesgenerator"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/parsers/kernel"
@@ -105,10 +107,13 @@ type Daemon struct {
 	driver           graphdriver.Driver
 	execDriver       execdriver.Driver
 	statsCollector   *statsCollector
+	metrics          *metricsCollector
 	defaultLogConfig runconfig.LogConfig
 	RegistryService  *registry.Service
 	EventsService    *events.Events
 	netController    libnetwork.NetworkController
+	networks         *networkStore
+	staticIPLock     sync.Mutex
 }
 
 // Get looks for a container using the provided information, which could be
@@ -211,6 +216,10 @@ func (daemon *Daemon) register(container *Container, updateSuffixarray bool) err
 
 	container.registerVolumes()
 
+	if daemon.metrics != nil {
+		daemon.metrics.watch(container)
+	}
+
 	if container.IsRunning() {
 		logrus.Debugf("killing old running container %s", container.ID)
 
@@ -278,6 +287,15 @@ func (daemon *Daemon) restore() error {
 		if (container.Driver == "" && currentDriver == "aufs") || container.Driver == currentDriver {
 			logrus.Debugf("Loaded container %v", container.ID)
 
+			// The images migrated to content-addressable IDs are still
+			// known by their old IDs
+			if img, err := daemon.graph.Get(container.ImageID); err == nil && img.ID != container.ImageID {
+				container.ImageID = img.ID
+				if err := container.ToDisk(); err != nil {
+					logrus.Errorf("Failed to save container %s: %v", container.ID, err)
+				}
+			}
+
 			containers[container.ID] = container
 		} else {
 			logrus.Debugf("Cannot load container %s because it was created with another graph driver.", container.ID)
@@ -327,7 +345,18 @@ func (daemon *Daemon) restore() error {
 	if daemon.config.AutoRestart {
 		logrus.Debug("Restarting containers...")
 
+		// Start the containers with static IP addresses first, so that the
+		// addresses allocated to the others do not take theirs
+		var static, dynamic []*Container
 		for _, container := range registeredContainers {
+			if container.hostConfig.IPv4Address != "" || container.hostConfig.IPv6Address != "" {
+				static = append(static, container)
+			} else {
+				dynamic = append(dynamic, container)
+			}
+		}
+
+		for _, container := range append(static, dynamic...) {
 			if container.hostConfig.RestartPolicy.IsAlways() ||
 				(container.hostConfig.RestartPolicy.IsOnFailure() && container.ExitCode != 0) {
 				logrus.Debugf("Starting container %s", container.ID)
@@ -548,8 +577,12 @@ func (daemon *Daemon) createRootfs(container *Container) error {
 	if err := os.Mkdir(container.root, 0700); err != nil {
 		return err
 	}
+	img, err := daemon.graph.Get(container.ImageID)
+	if err != nil {
+		return err
+	}
 	initID := fmt.Sprintf("%s-init", container.ID)
-	if err := daemon.driver.Create(initID, container.ImageID); err != nil {
+	if err := daemon.driver.Create(initID, img.LayerID); err != nil {
 		return err
 	}
 	initPath, err := daemon.driver.Get(initID, "")
@@ -752,6 +785,9 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 			return nil, fmt.Errorf("error finding the logging driver: %v", err)
 		}
 	}
+	if err := logger.ValidateLogOpts(config.LogConfig.Type, config.LogConfig.Config); err != nil {
+		return nil, fmt.Errorf("invalid default log opts: %v", err)
+	}
 	logrus.Debugf("Using default logging driver %s", config.LogConfig.Type)
 
 	if config.EnableSelinuxSupport {
@@ -809,7 +845,7 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		return nil, fmt.Errorf("could not create trust store: %s", err)
 	}
 
-	eventsService := events.New()
+	eventsService := events.New(config.EventsLimit)
 	logrus.Debug("Creating repository list")
 	tagCfg := &graph.TagStoreConfig{
 		Graph:    g,
@@ -817,6 +853,8 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 		Registry: registryService,
 		Events:   eventsService,
 		Trust:    trustService,
+
+		MaxConcurrentDownloads: config.MaxConcurrentDownloads,
 	}
 	repositories, err := graph.NewTagStore(path.Join(config.Root, "repositories-"+d.driver.String()), tagCfg)
 	if err != nil {
@@ -877,9 +915,21 @@ func NewDaemon(config *Config, registryService *registry.Service) (daemon *Daemo
 	d.sysInitPath = sysInitPath
 	d.execDriver = ed
 	d.statsCollector = newStatsCollector(1 * time.Second)
+	if config.EnableMetrics {
+		d.metrics = newMetricsCollector(d)
+		metrics.Register(d.metrics)
+	}
 	d.defaultLogConfig = config.LogConfig
 	d.RegistryService = registryService
 	d.EventsService = eventsService
+	d.logDaemonEvent("start")
+
+	if d.netController != nil {
+		if d.networks, err = newNetworkStore(path.Join(config.Root, "networks.json")); err != nil {
+			return nil, err
+		}
+		d.restoreNetworks()
+	}
 
 	if err := d.restore(); err != nil {
 		return nil, err
@@ -978,7 +1028,29 @@ func initNetworkController(config *Config) (libnetwork.NetworkController, error)
 	return controller, nil
 }
 
+// logDaemonEvent logs an event about the daemon, with its host name as
+// attribute.
+func (daemon *Daemon) logDaemonEvent(action string) {
+	daemon.EventsService.Log(types.DaemonEventType, action, daemon.eventActor())
+}
+
+func (daemon *Daemon) eventActor() types.EventActor {
+	attributes := make(map[string]string)
+	if hostname, err := os.Hostname(); err == nil {
+		attributes["name"] = hostname
+	}
+	return types.EventActor{
+		ID:         daemon.ID,
+		Attributes: attributes,
+	}
+}
+
 func (daemon *Daemon) Shutdown() error {
+	if daemon.EventsService != nil {
+		// broadcast before returning, the listeners being closed with the
+		// API server once the daemon is shut down
+		daemon.EventsService.LogSync(types.DaemonEventType, "shutdown", daemon.eventActor())
+	}
 	if daemon.containerGraph != nil {
 		if err := daemon.containerGraph.Close(); err != nil {
 			logrus.Errorf("Error during container graph.Close(): %v", err)
@@ -1000,8 +1072,9 @@ func (daemon *Daemon) Shutdown() error {
 
 				go func() {
 					defer group.Done()
-					if err := c.KillSig(15); err != nil {
-						logrus.Debugf("kill 15 error for %s - %s", c.ID, err)
+					sig := c.StopSignal()
+					if err := c.KillSig(sig); err != nil {
+						logrus.Debugf("kill %d error for %s - %s", sig, c.ID, err)
 					}
 					c.WaitStop(-1 * time.Second)
 					logrus.Debugf("container stopped %s", c.ID)
@@ -1213,10 +1286,124 @@ func (daemon *Daemon) verifyHostConfig(hostConfig *runconfig.HostConfig) ([]stri
 		hostConfig.OomKillDisable = false
 		return warnings, fmt.Errorf("Your kernel does not support oom kill disable.")
 	}
+	if hostConfig.LogConfig.Type != "" || len(hostConfig.LogConfig.Config) > 0 {
+		// the log opts set without a log driver are for the default one
+		logType := hostConfig.LogConfig.Type
+		if logType == "" {
+			logType = daemon.defaultLogConfig.Type
+		}
+		if err := logger.ValidateLogOpts(logType, hostConfig.LogConfig.Config); err != nil {
+			return warnings, err
+		}
+	}
+	if hostConfig.NetworkMode.IsUserDefined() && daemon.netController != nil {
+		if _, err := daemon.netController.NetworkByName(string(hostConfig.NetworkMode)); err != nil {
+			return warnings, fmt.Errorf("No such network: %s", hostConfig.NetworkMode)
+		}
+	}
+	if err := daemon.verifyIPAddresses(hostConfig); err != nil {
+		return warnings, err
+	}
 
 	return warnings, nil
 }
 
+// verifyIPAddresses checks that the static addresses requested for a
+// container can be assigned on its network. The addresses on the default
+// bridge must be in the ranges of --fixed-cidr and --fixed-cidr-v6; the
+// bridge driver checks the others when allocating them.
+func (daemon *Daemon) verifyIPAddresses(hostConfig *runconfig.HostConfig) error {
+	if hostConfig.IPv4Address == "" && hostConfig.IPv6Address == "" {
+		return nil
+	}
+	mode := hostConfig.NetworkMode
+	if mode.IsHost() || mode.IsNone() || mode.IsContainer() {
+		return fmt.Errorf("Conflicting options: static IP addresses and the network mode %s", mode)
+	}
+
+	bridge := daemon.config.Bridge
+	if hostConfig.IPv4Address != "" {
+		ip := net.ParseIP(hostConfig.IPv4Address)
+		if ip == nil || ip.To4() == nil {
+			return fmt.Errorf("Invalid IPv4 address: %s", hostConfig.IPv4Address)
+		}
+		if (mode == "" || mode.IsBridge()) && bridge.FixedCIDR != "" {
+			if _, fCIDR, err := net.ParseCIDR(bridge.FixedCIDR); err == nil && !fCIDR.Contains(ip) {
+				return fmt.Errorf("IPv4 address %s is not in the range of --fixed-cidr %s", ip, bridge.FixedCIDR)
+			}
+		}
+	}
+	if hostConfig.IPv6Address != "" {
+		ip := net.ParseIP(hostConfig.IPv6Address)
+		if ip == nil || ip.To4() != nil {
+			return fmt.Errorf("Invalid IPv6 address: %s", hostConfig.IPv6Address)
+		}
+		if mode == "" || mode.IsBridge() {
+			if !bridge.EnableIPv6 {
+				return fmt.Errorf("Cannot assign IPv6 address %s: IPv6 is not enabled on the daemon (--ipv6)", ip)
+			}
+			if _, fCIDR, err := net.ParseCIDR(bridge.FixedCIDRv6); err == nil && !fCIDR.Contains(ip) {
+				return fmt.Errorf("IPv6 address %s is not in the range of --fixed-cidr-v6 %s", ip, bridge.FixedCIDRv6)
+			}
+		}
+	}
+	return nil
+}
+
+// checkIPAddressConflicts returns an error if a static address requested for
+// a container is the static address of another container of its network, or
+// the address a running container of the network was given.
+func (daemon *Daemon) checkIPAddressConflicts(hostConfig *runconfig.HostConfig) error {
+	if hostConfig == nil || (hostConfig.IPv4Address == "" && hostConfig.IPv6Address == "") {
+		return nil
+	}
+	name := staticNetworkName(hostConfig.NetworkMode)
+	reserved := daemon.staticAddresses(name, nil)
+
+	for _, ip := range []string{hostConfig.IPv4Address, hostConfig.IPv6Address} {
+		if ip == "" {
+			continue
+		}
+		if c, ok := reserved[net.ParseIP(ip).String()]; ok {
+			return fmt.Errorf("Conflict. The IP address %s is already assigned to container %s.", ip, strings.TrimPrefix(c.Name, "/"))
+		}
+		for _, c := range daemon.List() {
+			if settings, ok := c.endpointSettings(name); ok && settings.EndpointID != "" &&
+				(net.ParseIP(ip).Equal(net.ParseIP(settings.IPAddress)) || net.ParseIP(ip).Equal(net.ParseIP(settings.GlobalIPv6Address))) {
+				return fmt.Errorf("Conflict. The IP address %s is already in use by container %s.", ip, strings.TrimPrefix(c.Name, "/"))
+			}
+		}
+	}
+	return nil
+}
+
+// staticAddresses returns the static addresses of the containers started
+// with the network, other than except, with the containers they belong to.
+func (daemon *Daemon) staticAddresses(name string, except *Container) map[string]*Container {
+	addrs := make(map[string]*Container)
+	for _, c := range daemon.List() {
+		other := c.hostConfig
+		if c == except || other == nil || staticNetworkName(other.NetworkMode) != name {
+			continue
+		}
+		for _, addr := range []string{other.IPv4Address, other.IPv6Address} {
+			if ip := net.ParseIP(addr); ip != nil {
+				addrs[ip.String()] = c
+			}
+		}
+	}
+	return addrs
+}
+
+// staticNetworkName returns the name of the network the static addresses of
+// a container started with the network mode are on.
+func staticNetworkName(mode runconfig.NetworkMode) string {
+	if mode == "" {
+		return "bridge"
+	}
+	return string(mode)
+}
+
 func (daemon *Daemon) setHostConfig(container *Container, hostConfig *runconfig.HostConfig) error {
 	container.Lock()
 	defer container.Unlock()
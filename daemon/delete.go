@@ -79,6 +79,10 @@ func (daemon *Daemon) ContainerRm(name string, config *ContainerRmConfig) error
 
 func (daemon *Daemon) DeleteVolumes(volumeIDs map[string]struct{}) {
 	for id := range volumeIDs {
+		// the named volumes are only removed explicitly
+		if v := daemon.volumes.Get(id); v != nil && v.Name != "" {
+			continue
+		}
 		if err := daemon.volumes.Delete(id); err != nil {
 			logrus.Infof("%s", err)
 			continue
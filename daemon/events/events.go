@@ -4,34 +4,43 @@ import (
 	"sync"
 	"time"
 
-	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/pubsub"
 )
 
-const eventsLimit = 64
+// DefaultLimit is the default number of events stored to be replayed to the
+// new listeners
+const DefaultLimit = 256
 
-// Events is pubsub channel for *jsonmessage.JSONMessage
+// Events is pubsub channel for *types.Event
 type Events struct {
-	mu     sync.Mutex
-	events []*jsonmessage.JSONMessage
+	mu sync.Mutex
+	// ring buffer of the last events, the oldest one being at start once
+	// it is full
+	events []*types.Event
+	start  int
 	pub    *pubsub.Publisher
 }
 
-// New returns new *Events instance
-func New() *Events {
+// New returns new *Events instance, which stores the last limit events
+func New(limit int) *Events {
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
 	return &Events{
-		events: make([]*jsonmessage.JSONMessage, 0, eventsLimit),
+		events: make([]*types.Event, 0, limit),
 		pub:    pubsub.NewPublisher(100*time.Millisecond, 1024),
 	}
 }
 
-// Subscribe adds new listener to events, returns slice of 64 stored last events
+// Subscribe adds new listener to events, returns slice of the stored last events
 // channel in which you can expect new events in form of interface{}, so you
 // need type assertion.
-func (e *Events) Subscribe() ([]*jsonmessage.JSONMessage, chan interface{}) {
+func (e *Events) Subscribe() ([]*types.Event, chan interface{}) {
 	e.mu.Lock()
-	current := make([]*jsonmessage.JSONMessage, len(e.events))
-	copy(current, e.events)
+	current := make([]*types.Event, 0, len(e.events))
+	current = append(current, e.events[e.start:]...)
+	current = append(current, e.events[:e.start]...)
 	l := e.pub.Subscribe()
 	e.mu.Unlock()
 	return current, l
@@ -42,22 +51,36 @@ func (e *Events) Evict(l chan interface{}) {
 	e.pub.Evict(l)
 }
 
-// Log broadcasts event to listeners. Each listener has 100 millisecond for
-// receiving event or it will be skipped.
-func (e *Events) Log(action, id, from string) {
-	go func() {
-		e.mu.Lock()
-		jm := &jsonmessage.JSONMessage{Status: action, ID: id, From: from, Time: time.Now().UTC().Unix()}
-		if len(e.events) == cap(e.events) {
-			// discard oldest event
-			copy(e.events, e.events[1:])
-			e.events[len(e.events)-1] = jm
-		} else {
-			e.events = append(e.events, jm)
-		}
-		e.mu.Unlock()
-		e.pub.Publish(jm)
-	}()
+// Log broadcasts an event of the given type about actor to listeners. Each
+// listener has 100 millisecond for receiving event or it will be skipped.
+func (e *Events) Log(eventType, action string, actor types.EventActor) {
+	go e.LogSync(eventType, action, actor)
+}
+
+// LogSync broadcasts an event like Log, but returns once the listeners
+// received it or were skipped.
+func (e *Events) LogSync(eventType, action string, actor types.EventActor) {
+	e.mu.Lock()
+	ev := &types.Event{
+		Status: action,
+		ID:     actor.ID,
+		Type:   eventType,
+		Action: action,
+		Actor:  actor,
+		Time:   time.Now().UTC().Unix(),
+	}
+	if eventType == types.ContainerEventType {
+		ev.From = actor.Attributes["image"]
+	}
+	if len(e.events) == cap(e.events) {
+		// replace oldest event
+		e.events[e.start] = ev
+		e.start = (e.start + 1) % len(e.events)
+	} else {
+		e.events = append(e.events, ev)
+	}
+	e.mu.Unlock()
+	e.pub.Publish(ev)
 }
 
 // SubscribersCount returns number of event listeners
@@ -5,11 +5,13 @@ import (
 	"testing"
 	"time"
 
-	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/docker/api/types"
 )
 
+const eventsLimit = 64
+
 func TestEventsLog(t *testing.T) {
-	e := New()
+	e := New(eventsLimit)
 	_, l1 := e.Subscribe()
 	_, l2 := e.Subscribe()
 	defer e.Evict(l1)
@@ -18,10 +20,10 @@ func TestEventsLog(t *testing.T) {
 	if count != 2 {
 		t.Fatalf("Must be 2 subscribers, got %d", count)
 	}
-	e.Log("test", "cont", "image")
+	e.Log(types.ContainerEventType, "test", types.EventActor{ID: "cont", Attributes: map[string]string{"image": "image"}})
 	select {
 	case msg := <-l1:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -42,7 +44,7 @@ func TestEventsLog(t *testing.T) {
 	}
 	select {
 	case msg := <-l2:
-		jmsg, ok := msg.(*jsonmessage.JSONMessage)
+		jmsg, ok := msg.(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", msg)
 		}
@@ -64,13 +66,13 @@ func TestEventsLog(t *testing.T) {
 }
 
 func TestEventsLogTimeout(t *testing.T) {
-	e := New()
+	e := New(eventsLimit)
 	_, l := e.Subscribe()
 	defer e.Evict(l)
 
 	c := make(chan struct{})
 	go func() {
-		e.Log("test", "cont", "image")
+		e.Log(types.ContainerEventType, "test", types.EventActor{ID: "cont", Attributes: map[string]string{"image": "image"}})
 		close(c)
 	}()
 
@@ -81,32 +83,48 @@ func TestEventsLogTimeout(t *testing.T) {
 	}
 }
 
+func TestEventsLogSync(t *testing.T) {
+	e := New(eventsLimit)
+	_, l := e.Subscribe()
+	defer e.Evict(l)
+
+	e.LogSync(types.DaemonEventType, "shutdown", types.EventActor{ID: "daemon"})
+	select {
+	case msg := <-l:
+		if jmsg := msg.(*types.Event); jmsg.Status != "shutdown" {
+			t.Fatalf("Status should be shutdown, got %s", jmsg.Status)
+		}
+	default:
+		t.Fatal("Expected the event to be broadcast once LogSync returns")
+	}
+}
+
 func TestLogEvents(t *testing.T) {
-	e := New()
+	// the events are logged synchronously to be stored in order
+	e := New(eventsLimit)
 
 	for i := 0; i < eventsLimit+16; i++ {
 		action := fmt.Sprintf("action_%d", i)
 		id := fmt.Sprintf("cont_%d", i)
 		from := fmt.Sprintf("image_%d", i)
-		e.Log(action, id, from)
+		e.LogSync(types.ContainerEventType, action, types.EventActor{ID: id, Attributes: map[string]string{"image": from}})
 	}
-	time.Sleep(50 * time.Millisecond)
 	current, l := e.Subscribe()
 	for i := 0; i < 10; i++ {
 		num := i + eventsLimit + 16
 		action := fmt.Sprintf("action_%d", num)
 		id := fmt.Sprintf("cont_%d", num)
 		from := fmt.Sprintf("image_%d", num)
-		e.Log(action, id, from)
+		e.LogSync(types.ContainerEventType, action, types.EventActor{ID: id, Attributes: map[string]string{"image": from}})
 	}
 	if len(e.events) != eventsLimit {
 		t.Fatalf("Must be %d events, got %d", eventsLimit, len(e.events))
 	}
 
-	var msgs []*jsonmessage.JSONMessage
+	var msgs []*types.Event
 	for len(msgs) < 10 {
 		m := <-l
-		jm, ok := (m).(*jsonmessage.JSONMessage)
+		jm, ok := (m).(*types.Event)
 		if !ok {
 			t.Fatalf("Unexpected type %T", m)
 		}
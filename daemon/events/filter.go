@@ -0,0 +1,79 @@
+package events
+
+import (
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+// Filter selects the events matching all of its filters: the type, the
+// action, the container, the image, the volume and the network of the
+// events, and the labels of their actors.
+type Filter struct {
+	filter filters.Args
+}
+
+// NewFilter returns a filter of the events from the filters of a request.
+// The containers are given by ID.
+func NewFilter(filter filters.Args) *Filter {
+	return &Filter{filter: filter}
+}
+
+// Include returns whether the event passes the filter.
+func (ef *Filter) Include(ev *types.Event) bool {
+	return matches(ev.Action, ef.filter["event"]) &&
+		matches(ev.Type, ef.filter["type"]) &&
+		ef.matchActor(ev, types.ContainerEventType, "container") &&
+		ef.matchImage(ev) &&
+		ef.matchActor(ev, types.VolumeEventType, "volume") &&
+		ef.matchActor(ev, types.NetworkEventType, "network") &&
+		ef.filter.MatchKVList("label", ev.Actor.Labels)
+}
+
+// matchActor matches the events of the given type by the ID or the name of
+// their actor. No event of another type passes the filter.
+func (ef *Filter) matchActor(ev *types.Event, eventType, field string) bool {
+	values := ef.filter[field]
+	if len(values) == 0 {
+		return true
+	}
+	if ev.Type != eventType {
+		return false
+	}
+	return matches(ev.Actor.ID, values) || matches(ev.Actor.Attributes["name"], values)
+}
+
+// matchImage matches the image events by their image, and the container
+// events by the image of their container.
+func (ef *Filter) matchImage(ev *types.Event) bool {
+	values := ef.filter["image"]
+	if len(values) == 0 {
+		return true
+	}
+	switch ev.Type {
+	case types.ImageEventType:
+		return matches(ev.Actor.ID, values)
+	case types.ContainerEventType:
+		return matches(ev.Actor.Attributes["image"], values)
+	}
+	return false
+}
+
+// matches returns whether the field is one of the values, or its part before
+// a colon is, as the repository of an image reference or the name of an exec
+// action. An empty list of values matches all the fields.
+func matches(field string, values []string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == field {
+			return true
+		}
+		if i := strings.Index(field, ":"); i != -1 && field[:i] == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+func TestFilterInclude(t *testing.T) {
+	die := &types.Event{
+		Type:   types.ContainerEventType,
+		Action: "die",
+		Actor: types.EventActor{
+			ID:         "cont",
+			Attributes: map[string]string{"image": "busybox:latest", "name": "web", "exitCode": "1", "com.example.tier": "front"},
+			Labels:     map[string]string{"com.example.tier": "front"},
+		},
+	}
+	execStart := &types.Event{
+		Type:   types.ContainerEventType,
+		Action: "exec_start: ls -l",
+		Actor:  types.EventActor{ID: "cont", Attributes: map[string]string{"image": "busybox:latest", "name": "web"}},
+	}
+	tag := &types.Event{
+		Type:   types.ImageEventType,
+		Action: "tag",
+		Actor:  types.EventActor{ID: "busybox:latest"},
+	}
+	create := &types.Event{
+		Type:   types.VolumeEventType,
+		Action: "create",
+		Actor:  types.EventActor{ID: "data", Attributes: map[string]string{"driver": "local"}},
+	}
+
+	for _, c := range []struct {
+		filter   filters.Args
+		included []*types.Event
+	}{
+		{filters.Args{}, []*types.Event{die, execStart, tag, create}},
+		{filters.Args{"event": {"die", "create"}}, []*types.Event{die, create}},
+		{filters.Args{"event": {"exec_start"}}, []*types.Event{execStart}},
+		{filters.Args{"type": {"image", "volume"}}, []*types.Event{tag, create}},
+		{filters.Args{"container": {"web"}}, []*types.Event{die, execStart}},
+		{filters.Args{"container": {"cont"}, "event": {"die"}}, []*types.Event{die}},
+		{filters.Args{"image": {"busybox"}}, []*types.Event{die, execStart, tag}},
+		{filters.Args{"volume": {"data"}}, []*types.Event{create}},
+		{filters.Args{"network": {"data"}}, nil},
+		{filters.Args{"label": {"com.example.tier=front"}}, []*types.Event{die}},
+		{filters.Args{"label": {"com.example.tier", "com.example.tier=front"}}, []*types.Event{die}},
+		{filters.Args{"label": {"exitCode=1"}}, nil},
+		{filters.Args{"label": {"name=web"}}, nil},
+		{filters.Args{"label": {"driver=local"}}, nil},
+	} {
+		filter := NewFilter(c.filter)
+		for _, ev := range []*types.Event{die, execStart, tag, create} {
+			expected := false
+			for _, included := range c.included {
+				expected = expected || ev == included
+			}
+			if filter.Include(ev) != expected {
+				t.Fatalf("Expected the filter %v to include the %s event %q: %v", c.filter, ev.Type, ev.Action, expected)
+			}
+		}
+	}
+}
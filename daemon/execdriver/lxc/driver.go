@@ -547,6 +547,10 @@ func (d *driver) Unpause(c *execdriver.Command) error {
 	return err
 }
 
+func (d *driver) Update(c *execdriver.Command) error {
+	return fmt.Errorf("lxc: Resources of a running container cannot be updated")
+}
+
 func (d *driver) Terminate(c *execdriver.Command) error {
 	return KillLxc(c.ID, 9)
 }
@@ -65,6 +65,10 @@ func (d *driver) Unpause(c *execdriver.Command) error {
 	return fmt.Errorf("Windows: Containers cannot be paused")
 }
 
+func (d *driver) Update(c *execdriver.Command) error {
+	return fmt.Errorf("Windows: Resources of a running container cannot be updated")
+}
+
 func (i *info) IsRunning() bool {
 	return false
 }
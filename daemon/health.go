@@ -0,0 +1,263 @@
+package daemon
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	// defaultProbeInterval is the time between probes when the
+	// healthcheck does not set an interval
+	defaultProbeInterval = 30 * time.Second
+	// defaultProbeTimeout is the time a probe can run before it is
+	// considered hung when the healthcheck does not set a timeout
+	defaultProbeTimeout = 30 * time.Second
+	// defaultProbeRetries is the number of consecutive failures needed to
+	// report a container as unhealthy when the healthcheck does not set it
+	defaultProbeRetries = 3
+
+	// maxLogEntries is the number of probe results kept in the state
+	maxLogEntries = 5
+	// maxOutputLen is the number of bytes of the output of a probe kept in
+	// the state
+	maxOutputLen = 4096
+)
+
+// Health holds the health of a container with a healthcheck, and stops the
+// probes when the container stops.
+type Health struct {
+	types.Health
+
+	// stop is closed to stop the probes, nil if they are not running
+	stop chan struct{}
+}
+
+// String returns a human-readable description of the health, for the
+// status of the container
+func (h *Health) String() string {
+	if h.Status == types.Starting {
+		return "health: starting"
+	}
+	return h.Status
+}
+
+// initHealthMonitor starts running the healthcheck probe of the container,
+// if it has one. It is called with the container lock held, once the
+// container started.
+func (container *Container) initHealthMonitor() {
+	container.stopHealthMonitor()
+
+	config := container.Config.Healthcheck
+	if config == nil || len(config.Test) == 0 || config.Test[0] == "NONE" {
+		container.State.Health = nil
+		return
+	}
+	if err := checkExecSupport(container.daemon.execDriver.Name()); err != nil {
+		logrus.Warnf("Cannot run the healthcheck of container %s: %v", container.ID, err)
+		container.State.Health = nil
+		return
+	}
+
+	h := &Health{stop: make(chan struct{})}
+	h.Status = types.Starting
+	container.State.Health = h
+
+	go container.monitorHealth(config, h)
+}
+
+// stopHealthMonitor stops the healthcheck probes. It is called with the
+// container lock held.
+func (container *Container) stopHealthMonitor() {
+	if h := container.State.Health; h != nil && h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// monitorHealth runs the probe periodically until h is stopped.
+func (container *Container) monitorHealth(config *runconfig.HealthConfig, h *Health) {
+	interval := durationOrDefault(config.Interval, defaultProbeInterval)
+	timeout := durationOrDefault(config.Timeout, defaultProbeTimeout)
+	retries := config.Retries
+	if retries <= 0 {
+		retries = defaultProbeRetries
+	}
+
+	stop := h.stop
+	for {
+		select {
+		case <-stop:
+			logrus.Debugf("Stop healthcheck monitoring of container %s", container.ID)
+			return
+		case <-time.After(interval):
+			result := container.runHealthcheck(config.Test, timeout)
+			container.handleProbeResult(h, stop, result, retries)
+		}
+	}
+}
+
+// runHealthcheck execs the probe in the container through the execdriver,
+// and kills it if it runs for longer than timeout. It returns once the exec
+// ended.
+func (container *Container) runHealthcheck(test []string, timeout time.Duration) *types.HealthcheckResult {
+	result := &types.HealthcheckResult{Start: time.Now().UTC()}
+
+	var cmd []string
+	switch test[0] {
+	case "CMD":
+		cmd = test[1:]
+	case "CMD-SHELL":
+		cmd = append([]string{"/bin/sh", "-c"}, test[1:]...)
+	default:
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("Unknown healthcheck type %q", test[0])
+		result.End = time.Now().UTC()
+		return result
+	}
+	if len(cmd) == 0 {
+		result.ExitCode = -1
+		result.Output = "Missing healthcheck command"
+		result.End = time.Now().UTC()
+		return result
+	}
+
+	output := &limitedBuffer{}
+	processConfig := &execdriver.ProcessConfig{
+		Entrypoint: cmd[0],
+		Arguments:  cmd[1:],
+		User:       container.Config.User,
+	}
+	pipes := execdriver.NewPipes(nil, output, output, false)
+
+	started := make(chan int, 1)
+	callback := func(processConfig *execdriver.ProcessConfig, pid int) {
+		started <- pid
+	}
+
+	type execResult struct {
+		exitCode int
+		err      error
+	}
+	done := make(chan execResult, 1)
+	go func() {
+		exitCode, err := container.daemon.execDriver.Exec(container.command, processConfig, pipes, callback)
+		done <- execResult{exitCode, err}
+	}()
+
+	select {
+	case res := <-done:
+		if res.err != nil {
+			result.ExitCode = -1
+			result.Output = res.err.Error()
+		} else {
+			result.ExitCode = res.exitCode
+			result.Output = output.String()
+		}
+	case <-time.After(timeout):
+		// the probe is hung: kill it, once it started if it did not yet,
+		// and wait for the exec to end so that it does not outlive the probe
+		select {
+		case pid := <-started:
+			if p, err := os.FindProcess(pid); err == nil {
+				p.Kill()
+			}
+			<-done
+		case <-done:
+		}
+		result.ExitCode = -1
+		result.Output = fmt.Sprintf("Health check exceeded timeout (%v)", timeout)
+	}
+	result.End = time.Now().UTC()
+	return result
+}
+
+// handleProbeResult records the result of a probe in the state of the
+// container, and updates its health status.
+func (container *Container) handleProbeResult(h *Health, stop chan struct{}, result *types.HealthcheckResult, retries int) {
+	container.Lock()
+
+	select {
+	case <-stop:
+		// the container stopped while the probe was running
+		container.Unlock()
+		return
+	default:
+	}
+
+	h.Log = append(h.Log, result)
+	if len(h.Log) > maxLogEntries {
+		h.Log = h.Log[len(h.Log)-maxLogEntries:]
+	}
+
+	oldStatus := h.Status
+	if result.ExitCode == 0 {
+		h.FailingStreak = 0
+		h.Status = types.Healthy
+	} else {
+		h.FailingStreak++
+		if h.FailingStreak >= retries {
+			h.Status = types.Unhealthy
+		}
+		// else the status stays the same: starting containers get a few
+		// retries before being reported as unhealthy too
+	}
+
+	newStatus := h.Status
+
+	if err := container.toDisk(); err != nil {
+		logrus.Errorf("Error saving the health of container %s: %v", container.ID, err)
+	}
+	container.Unlock()
+
+	if newStatus != oldStatus {
+		container.LogEvent("health_status: " + newStatus)
+	}
+}
+
+func durationOrDefault(d, defaultValue time.Duration) time.Duration {
+	if d <= 0 {
+		return defaultValue
+	}
+	return d
+}
+
+// limitedBuffer is a thread-safe buffer which keeps the first maxOutputLen
+// bytes written to it.
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	n := len(p)
+	if room := maxOutputLen - b.buf.Len(); n > room {
+		p = p[:room]
+		b.truncated = true
+	}
+	b.buf.Write(p)
+	return n, nil
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	out := b.buf.String()
+	if b.truncated {
+		out += "..."
+	}
+	return strings.TrimSpace(out)
+}
@@ -108,7 +108,10 @@ func (daemon *Daemon) imgDeleteHelper(name string, list *[]types.ImageDelete, fi
 				*list = append(*list, types.ImageDelete{
 					Untagged: utils.ImageReference(repoName, tag),
 				})
-				daemon.EventsService.Log("untag", img.ID, "")
+				daemon.EventsService.Log(types.ImageEventType, "untag", types.EventActor{
+					ID:         img.ID,
+					Attributes: map[string]string{"name": utils.ImageReference(repoName, tag)},
+				})
 			}
 		}
 	}
@@ -124,7 +127,7 @@ func (daemon *Daemon) imgDeleteHelper(name string, list *[]types.ImageDelete, fi
 			*list = append(*list, types.ImageDelete{
 				Deleted: img.ID,
 			})
-			daemon.EventsService.Log("delete", img.ID, "")
+			daemon.EventsService.Log(types.ImageEventType, "delete", types.EventActor{ID: img.ID})
 			if img.Parent != "" && !noprune {
 				err := daemon.imgDeleteHelper(img.Parent, list, false, force, noprune)
 				if first {
@@ -138,6 +141,39 @@ func (daemon *Daemon) imgDeleteHelper(name string, list *[]types.ImageDelete, fi
 	return nil
 }
 
+// DeleteIntermediateImages deletes the image id and its parents, up to and
+// excluding the image stop, as long as they are not tagged and not used by
+// other images or by containers. It returns the IDs of the deleted images.
+func (daemon *Daemon) DeleteIntermediateImages(id, stop string) ([]string, error) {
+	var deleted []string
+	for id != "" && id != stop {
+		img, err := daemon.Graph().Get(id)
+		if err != nil {
+			return deleted, err
+		}
+		if len(daemon.Repositories().ByID()[id]) > 0 {
+			break
+		}
+		byParents, err := daemon.Graph().ByParent()
+		if err != nil {
+			return deleted, err
+		}
+		if len(byParents[id]) > 0 {
+			break
+		}
+		if err := daemon.canDeleteImage(id, false); err != nil {
+			break
+		}
+		if err := daemon.Graph().Delete(id); err != nil {
+			return deleted, err
+		}
+		daemon.EventsService.Log(types.ImageEventType, "delete", types.EventActor{ID: id})
+		deleted = append(deleted, id)
+		id = img.Parent
+	}
+	return deleted, nil
+}
+
 func (daemon *Daemon) canDeleteImage(imgID string, force bool) error {
 	for _, container := range daemon.List() {
 		parent, err := daemon.Repositories().LookupImage(container.ImageID)
@@ -32,7 +32,7 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 	// we need this trick to preserve empty log driver, so
 	// container will use daemon defaults even if daemon change them
 	if hostConfig.LogConfig.Type == "" {
-		hostConfig.LogConfig = daemon.defaultLogConfig
+		hostConfig.LogConfig = container.getLogConfig()
 	}
 
 	containerState := &types.ContainerState{
@@ -47,6 +47,12 @@ func (daemon *Daemon) ContainerInspect(name string) (*types.ContainerJSON, error
 		StartedAt:  container.State.StartedAt,
 		FinishedAt: container.State.FinishedAt,
 	}
+	if h := container.State.Health; h != nil {
+		// copy the log, which is appended to by the probes
+		health := h.Health
+		health.Log = append([]*types.HealthcheckResult(nil), h.Log...)
+		containerState.Health = &health
+	}
 
 	contJSON := &types.ContainerJSON{
 		Id:              container.ID,
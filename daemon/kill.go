@@ -2,6 +2,7 @@ package daemon
 
 import (
 	"fmt"
+	"strconv"
 	"syscall"
 )
 
@@ -16,7 +17,10 @@ func (daemon *Daemon) ContainerKill(name string, sig uint64) error {
 	}
 
 	// If no signal is passed, or SIGKILL, perform regular Kill (SIGKILL + wait())
-	if sig == 0 || syscall.Signal(sig) == syscall.SIGKILL {
+	if sig == 0 {
+		sig = uint64(syscall.SIGKILL)
+	}
+	if syscall.Signal(sig) == syscall.SIGKILL {
 		if err := container.Kill(); err != nil {
 			return fmt.Errorf("Cannot kill container %s: %s", name, err)
 		}
@@ -26,6 +30,6 @@ func (daemon *Daemon) ContainerKill(name string, sig uint64) error {
 			return fmt.Errorf("Cannot kill container %s: %s", name, err)
 		}
 	}
-	container.LogEvent("kill")
+	container.logEventWithAttributes("kill", map[string]string{"signal": strconv.FormatUint(sig, 10)})
 	return nil
 }
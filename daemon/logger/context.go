@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// Context provides enough information for a logging driver to do its function
+type Context struct {
+	Config              map[string]string
+	ContainerID         string
+	ContainerName       string
+	ContainerEntrypoint string
+	ContainerArgs       []string
+	ContainerImageID    string
+	ContainerImageName  string
+	ContainerCreated    time.Time
+	ContainerEnv        []string
+	ContainerLabels     map[string]string
+	LogPath             string
+	DaemonName          string
+}
+
+// ID returns the short ID of the container, for use in log tag templates
+func (ctx *Context) ID() string {
+	return stringid.TruncateID(ctx.ContainerID)
+}
+
+// FullID returns the full ID of the container
+func (ctx *Context) FullID() string {
+	return ctx.ContainerID
+}
+
+// Name returns the name of the container, without the leading slash
+func (ctx *Context) Name() string {
+	return strings.TrimPrefix(ctx.ContainerName, "/")
+}
+
+// ImageID returns the short ID of the image of the container
+func (ctx *Context) ImageID() string {
+	return stringid.TruncateID(ctx.ContainerImageID)
+}
+
+// ImageFullID returns the full ID of the image of the container
+func (ctx *Context) ImageFullID() string {
+	return ctx.ContainerImageID
+}
+
+// ImageName returns the name of the image as it was passed to create the
+// container
+func (ctx *Context) ImageName() string {
+	return ctx.ContainerImageName
+}
+
+// Command returns the command of the container, entrypoint and arguments
+func (ctx *Context) Command() string {
+	terms := []string{ctx.ContainerEntrypoint}
+	terms = append(terms, ctx.ContainerArgs...)
+	return strings.Join(terms, " ")
+}
+
+// ExtraAttributes returns the labels and environment variables of the
+// container selected with the "labels" and "env" log opts, which are comma
+// separated lists of label keys and variable names.
+func (ctx *Context) ExtraAttributes() map[string]string {
+	extra := make(map[string]string)
+
+	if labels, ok := ctx.Config["labels"]; ok && len(labels) > 0 {
+		for _, l := range strings.Split(labels, ",") {
+			if v, ok := ctx.ContainerLabels[l]; ok {
+				extra[l] = v
+			}
+		}
+	}
+
+	if env, ok := ctx.Config["env"]; ok && len(env) > 0 {
+		envMapping := make(map[string]string)
+		for _, kv := range ctx.ContainerEnv {
+			if parts := strings.SplitN(kv, "=", 2); len(parts) == 2 {
+				envMapping[parts[0]] = parts[1]
+			}
+		}
+		for _, name := range strings.Split(env, ",") {
+			if v, ok := envMapping[name]; ok {
+				extra[name] = v
+			}
+		}
+	}
+
+	return extra
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContextExtraAttributes(t *testing.T) {
+	ctx := Context{
+		Config: map[string]string{
+			"labels": "com.example.stage,missing",
+			"env":    "REGION,EMPTY,MISSING",
+		},
+		ContainerLabels: map[string]string{
+			"com.example.stage": "prod",
+			"com.example.team":  "web",
+		},
+		ContainerEnv: []string{"REGION=eu-west", "EMPTY=", "PATH=/bin", "NOVALUE"},
+	}
+	expected := map[string]string{
+		"com.example.stage": "prod",
+		"REGION":            "eu-west",
+		"EMPTY":             "",
+	}
+	if extra := ctx.ExtraAttributes(); !reflect.DeepEqual(extra, expected) {
+		t.Fatalf("expected %v, got %v", expected, extra)
+	}
+
+	ctx.Config = map[string]string{}
+	if extra := ctx.ExtraAttributes(); len(extra) != 0 {
+		t.Fatalf("expected no attributes without labels and env, got %v", extra)
+	}
+}
+
+func TestContextNames(t *testing.T) {
+	ctx := Context{
+		ContainerID:         "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		ContainerName:       "/web",
+		ContainerImageID:    "511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158",
+		ContainerImageName:  "busybox:latest",
+		ContainerEntrypoint: "/bin/sh",
+		ContainerArgs:       []string{"-c", "top"},
+	}
+	for _, c := range []struct{ got, expected string }{
+		{ctx.ID(), "a7317399f3f8"},
+		{ctx.FullID(), ctx.ContainerID},
+		{ctx.Name(), "web"},
+		{ctx.ImageID(), "511136ea3c5a"},
+		{ctx.ImageFullID(), ctx.ContainerImageID},
+		{ctx.ImageName(), "busybox:latest"},
+		{ctx.Command(), "/bin/sh -c top"},
+	} {
+		if c.got != c.expected {
+			t.Fatalf("expected %q, got %q", c.expected, c.got)
+		}
+	}
+}
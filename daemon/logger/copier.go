@@ -10,7 +10,7 @@ import (
 )
 
 // Copier can copy logs from specified sources to Logger and attach
-// ContainerID and Timestamp.
+// ContainerID, Timestamp and the extra attributes of the container.
 // Writes are concurrent, so you need implement some sync in your logger
 type Copier struct {
 	// cid is container id for which we copying logs
@@ -19,14 +19,17 @@ type Copier struct {
 	srcs     map[string]io.Reader
 	dst      Logger
 	copyJobs sync.WaitGroup
+	// attrs are attached to every message
+	attrs map[string]string
 }
 
 // NewCopier creates new Copier
-func NewCopier(cid string, srcs map[string]io.Reader, dst Logger) (*Copier, error) {
+func NewCopier(cid string, srcs map[string]io.Reader, dst Logger, attrs map[string]string) (*Copier, error) {
 	return &Copier{
-		cid:  cid,
-		srcs: srcs,
-		dst:  dst,
+		cid:   cid,
+		srcs:  srcs,
+		dst:   dst,
+		attrs: attrs,
 	}, nil
 }
 
@@ -42,7 +45,7 @@ func (c *Copier) copySrc(name string, src io.Reader) {
 	defer c.copyJobs.Done()
 	scanner := bufio.NewScanner(src)
 	for scanner.Scan() {
-		if err := c.dst.Log(&Message{ContainerID: c.cid, Line: scanner.Bytes(), Source: name, Timestamp: time.Now().UTC()}); err != nil {
+		if err := c.dst.Log(&Message{ContainerID: c.cid, Line: scanner.Bytes(), Source: name, Timestamp: time.Now().UTC(), Attrs: c.attrs}); err != nil {
 			logrus.Errorf("Failed to log msg %q for logger %s: %s", scanner.Bytes(), c.dst.Name(), err)
 		}
 	}
@@ -3,7 +3,6 @@ package logger
 import (
 	"bytes"
 	"encoding/json"
-	"errors"
 	"io"
 	"testing"
 	"time"
@@ -19,10 +18,6 @@ func (l *TestLoggerJSON) Close() error { return nil }
 
 func (l *TestLoggerJSON) Name() string { return "json" }
 
-func (l *TestLoggerJSON) GetReader() (io.Reader, error) {
-	return nil, errors.New("not used in the test")
-}
-
 type TestLoggerText struct {
 	*bytes.Buffer
 }
@@ -36,10 +31,6 @@ func (l *TestLoggerText) Close() error { return nil }
 
 func (l *TestLoggerText) Name() string { return "text" }
 
-func (l *TestLoggerText) GetReader() (io.Reader, error) {
-	return nil, errors.New("not used in the test")
-}
-
 func TestCopier(t *testing.T) {
 	stdoutLine := "Line that thinks that it is log line from docker stdout"
 	stderrLine := "Line that thinks that it is log line from docker stderr"
@@ -64,7 +55,8 @@ func TestCopier(t *testing.T) {
 			"stdout": &stdout,
 			"stderr": &stderr,
 		},
-		jsonLog)
+		jsonLog,
+		map[string]string{"stage": "test"})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -94,6 +86,9 @@ func TestCopier(t *testing.T) {
 		if msg.ContainerID != cid {
 			t.Fatalf("Wrong ContainerID: %q, expected %q", msg.ContainerID, cid)
 		}
+		if msg.Attrs["stage"] != "test" {
+			t.Fatalf("Wrong Attrs: %v, expected stage=test", msg.Attrs)
+		}
 		if msg.Source == "stdout" {
 			if string(msg.Line) != stdoutLine {
 				t.Fatalf("Wrong Line: %q, expected %q", msg.Line, stdoutLine)
@@ -8,17 +8,14 @@ import (
 // Creator is a method that builds a logging driver instance with given context
 type Creator func(Context) (Logger, error)
 
-// Context provides enough information for a logging driver to do its function
-type Context struct {
-	Config        map[string]string
-	ContainerID   string
-	ContainerName string
-	LogPath       string
-}
+// LogOptValidator checks the options specific to the underlying
+// logging implementation.
+type LogOptValidator func(cfg map[string]string) error
 
 type logdriverFactory struct {
-	registry map[string]Creator
-	m        sync.Mutex
+	registry     map[string]Creator
+	optValidator map[string]LogOptValidator
+	m            sync.Mutex
 }
 
 func (lf *logdriverFactory) register(name string, c Creator) error {
@@ -32,6 +29,17 @@ func (lf *logdriverFactory) register(name string, c Creator) error {
 	return nil
 }
 
+func (lf *logdriverFactory) registerLogOptValidator(name string, l LogOptValidator) error {
+	lf.m.Lock()
+	defer lf.m.Unlock()
+
+	if _, ok := lf.optValidator[name]; ok {
+		return fmt.Errorf("logger: log validator named '%s' is already registered", name)
+	}
+	lf.optValidator[name] = l
+	return nil
+}
+
 func (lf *logdriverFactory) get(name string) (Creator, error) {
 	lf.m.Lock()
 	defer lf.m.Unlock()
@@ -43,7 +51,14 @@ func (lf *logdriverFactory) get(name string) (Creator, error) {
 	return c, nil
 }
 
-var factory = &logdriverFactory{registry: make(map[string]Creator)} // global factory instance
+func (lf *logdriverFactory) getLogOptValidator(name string) LogOptValidator {
+	lf.m.Lock()
+	defer lf.m.Unlock()
+
+	return lf.optValidator[name]
+}
+
+var factory = &logdriverFactory{registry: make(map[string]Creator), optValidator: make(map[string]LogOptValidator)} // global factory instance
 
 // RegisterLogDriver registers the given logging driver builder with given logging
 // driver name.
@@ -55,3 +70,32 @@ func RegisterLogDriver(name string, c Creator) error {
 func GetLogDriver(name string) (Creator, error) {
 	return factory.get(name)
 }
+
+// RegisterLogOptValidator registers the validator for the options of the
+// logging driver with given name.
+func RegisterLogOptValidator(name string, l LogOptValidator) error {
+	return factory.registerLogOptValidator(name, l)
+}
+
+// ValidateLogOpts checks the options for the given logging driver. Drivers
+// which did not register a validator accept no options.
+func ValidateLogOpts(name string, cfg map[string]string) error {
+	if name == "none" {
+		if len(cfg) > 0 {
+			return fmt.Errorf("logger: no log opts are allowed for the \"none\" log driver")
+		}
+		return nil
+	}
+	if _, err := factory.get(name); err != nil {
+		return err
+	}
+
+	validator := factory.getLogOptValidator(name)
+	if validator == nil {
+		for key := range cfg {
+			return fmt.Errorf("logger: unknown log opt '%s' for %s log driver", key, name)
+		}
+		return nil
+	}
+	return validator(cfg)
+}
@@ -0,0 +1,144 @@
+// Package fluentd provides the log driver for forwarding server logs
+// to fluentd endpoints with the forward protocol.
+package fluentd
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
+)
+
+const (
+	name = "fluentd"
+
+	defaultHost = "127.0.0.1"
+	defaultPort = "24224"
+
+	dialTimeout = 5 * time.Second
+)
+
+// fluentd sends the messages as [tag, time, record] entries of the
+// forward protocol over TCP.
+type fluentd struct {
+	tag           string
+	containerID   string
+	containerName string
+	sender        *loggerutils.BufferedSender
+}
+
+func init() {
+	if err := logger.RegisterLogDriver(name, New); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// New creates a fluentd logger using the configuration passed in on the
+// context. Supported context configuration variables are fluentd-address,
+// tag (or the older fluentd-tag), labels and env.
+func New(ctx logger.Context) (logger.Logger, error) {
+	address, err := parseAddress(ctx.Config["fluentd-address"])
+	if err != nil {
+		return nil, err
+	}
+	// fluentd-tag is the older name of the tag log opt
+	defaultTag := "docker.{{.ID}}"
+	if t := ctx.Config["fluentd-tag"]; t != "" {
+		defaultTag = t
+	}
+	tag, err := loggerutils.ParseLogTag(ctx, defaultTag)
+	if err != nil {
+		return nil, err
+	}
+	logrus.Debugf("logging driver fluentd configured for container %s, address %s", ctx.ContainerID, address)
+
+	dial := func() (net.Conn, error) {
+		return net.DialTimeout("tcp", address, dialTimeout)
+	}
+	return &fluentd{
+		tag:           tag,
+		containerID:   ctx.ContainerID,
+		containerName: strings.TrimPrefix(ctx.ContainerName, "/"),
+		sender:        loggerutils.NewBufferedSender(dial, loggerutils.DefaultBufferSize),
+	}, nil
+}
+
+func (f *fluentd) Log(msg *logger.Message) error {
+	record := map[string]string{
+		"container_id":   f.containerID,
+		"container_name": f.containerName,
+		"source":         msg.Source,
+		"log":            string(msg.Line),
+	}
+	for k, v := range msg.Attrs {
+		record[k] = v
+	}
+
+	var buf bytes.Buffer
+	writeArrayHeader(&buf, 3)
+	writeString(&buf, f.tag)
+	writeInt(&buf, msg.Timestamp.Unix())
+	writeStringMap(&buf, record)
+	return f.sender.Send(buf.Bytes())
+}
+
+func (f *fluentd) Close() error {
+	return f.sender.Close()
+}
+
+func (f *fluentd) Name() string {
+	return name
+}
+
+// ValidateLogOpt looks for fluentd specific log options fluentd-address
+// and fluentd-tag, and the tag, labels and env options.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "fluentd-address":
+		case "fluentd-tag":
+		default:
+			if !loggerutils.IsCommonLogOpt(key) {
+				return fmt.Errorf("unknown log opt '%s' for fluentd log driver", key)
+			}
+		}
+	}
+	if err := loggerutils.ValidateLogTag(cfg); err != nil {
+		return err
+	}
+	_, err := parseAddress(cfg["fluentd-address"])
+	return err
+}
+
+// parseAddress returns the host:port to connect to, filling in the
+// defaults for what is missing from address.
+func parseAddress(address string) (string, error) {
+	if address == "" {
+		return net.JoinHostPort(defaultHost, defaultPort), nil
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		// an address without a port: a host name, or an IPv6 address
+		// which may be in brackets
+		host = strings.TrimSuffix(strings.TrimPrefix(address, "["), "]")
+		if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+			return "", fmt.Errorf("fluentd: invalid fluentd-address %s: %v", address, err)
+		}
+		port = defaultPort
+	}
+	if host == "" {
+		host = defaultHost
+	}
+	if port == "" {
+		port = defaultPort
+	}
+	return net.JoinHostPort(host, port), nil
+}
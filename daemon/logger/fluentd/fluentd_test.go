@@ -0,0 +1,162 @@
+package fluentd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+const cid = "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+
+// decode reads a single value of the msgpack subset written by the driver.
+func decode(rd *bufio.Reader) (interface{}, error) {
+	b, err := rd.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	readN := func(n int) ([]byte, error) {
+		buf := make([]byte, n)
+		_, err := io.ReadFull(rd, buf)
+		return buf, err
+	}
+	switch {
+	case b < 0x80:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x90:
+		arr := make([]interface{}, b&0x0f)
+		for i := range arr {
+			if arr[i], err = decode(rd); err != nil {
+				return nil, err
+			}
+		}
+		return arr, nil
+	case b&0xf0 == 0x80:
+		m := make(map[string]interface{})
+		for i := 0; i < int(b&0x0f); i++ {
+			k, err := decode(rd)
+			if err != nil {
+				return nil, err
+			}
+			if m[k.(string)], err = decode(rd); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case b&0xe0 == 0xa0:
+		s, err := readN(int(b & 0x1f))
+		return string(s), err
+	case b == 0xd9:
+		n, err := rd.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		s, err := readN(int(n))
+		return string(s), err
+	case b == 0xd3:
+		var i int64
+		err := binary.Read(rd, binary.BigEndian, &i)
+		return i, err
+	}
+	return nil, fmt.Errorf("unexpected msgpack type %x", b)
+}
+
+func TestFluentd(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	l, err := New(logger.Context{
+		ContainerID:   cid,
+		ContainerName: "/web",
+		Config:        map[string]string{"fluentd-address": ln.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	long := fmt.Sprintf("%040d", 42)
+	attrs := map[string]string{"stage": "prod"}
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("hello"), Source: "stdout", Timestamp: time.Unix(1000, 0), Attrs: attrs}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte(long), Source: "stderr", Timestamp: time.Unix(5, 0), Attrs: attrs}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	rd := bufio.NewReader(conn)
+
+	for _, expected := range []struct {
+		time   int64
+		source string
+		line   string
+	}{
+		{1000, "stdout", "hello"},
+		{5, "stderr", long},
+	} {
+		v, err := decode(rd)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry, ok := v.([]interface{})
+		if !ok || len(entry) != 3 {
+			t.Fatalf("expected a [tag, time, record] entry, got %v", v)
+		}
+		if entry[0] != "docker.a7317399f3f8" {
+			t.Fatalf("unexpected tag %v", entry[0])
+		}
+		if entry[1] != expected.time {
+			t.Fatalf("expected time %d, got %v", expected.time, entry[1])
+		}
+		record := entry[2].(map[string]interface{})
+		if record["log"] != expected.line || record["source"] != expected.source ||
+			record["container_id"] != cid || record["container_name"] != "web" || record["stage"] != "prod" {
+			t.Fatalf("unexpected record %v", record)
+		}
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	for address, expected := range map[string]string{
+		"":             "127.0.0.1:24224",
+		"fluent":       "fluent:24224",
+		"fluent:24225": "fluent:24225",
+		":24225":       "127.0.0.1:24225",
+		"[::1]:24225":  "[::1]:24225",
+		"::1":          "[::1]:24224",
+		"[fe80::1]":    "[fe80::1]:24224",
+	} {
+		got, err := parseAddress(address)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, address, got)
+		}
+	}
+	if _, err := parseAddress("fluent:24225:1"); err == nil {
+		t.Fatal("expected an invalid address to be rejected")
+	}
+	if err := ValidateLogOpt(map[string]string{"fluentd-tag": "a", "foo": "bar"}); err == nil {
+		t.Fatal("expected unknown log opt to be rejected")
+	}
+	if err := ValidateLogOpt(map[string]string{"tag": "{{.Name}}", "labels": "a", "env": "b"}); err != nil {
+		t.Fatal(err)
+	}
+}
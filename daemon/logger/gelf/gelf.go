@@ -0,0 +1,231 @@
+// Package gelf provides the log driver for forwarding server logs to
+// endpoints that support the Graylog Extended Log Format.
+package gelf
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
+)
+
+const (
+	name = "gelf"
+
+	// chunkSize is the maximum size of a UDP datagram payload, small enough
+	// to go through most networks without fragmentation
+	chunkSize = 1420
+	// maxChunks is the maximum number of chunks of a message allowed by
+	// the GELF specification
+	maxChunks = 128
+	// chunkHeaderSize is the size of the magic bytes, message id, sequence
+	// number and sequence count heading each chunk
+	chunkHeaderSize = 12
+
+	dialTimeout = 5 * time.Second
+
+	levelErr  = 3
+	levelInfo = 6
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+// gelfLogger sends the messages to a GELF endpoint, compressed and chunked
+// over UDP or null byte delimited over TCP.
+type gelfLogger struct {
+	hostname string
+	extra    map[string]string
+
+	// udp is the connection for the udp transport
+	udp net.Conn
+	// sender buffers the messages for the tcp transport
+	sender *loggerutils.BufferedSender
+}
+
+func init() {
+	if err := logger.RegisterLogDriver(name, New); err != nil {
+		logrus.Fatal(err)
+	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// New creates a gelf logger using the configuration passed in on the
+// context. Supported context configuration variables are gelf-address,
+// tag (or the older gelf-tag), labels and env.
+func New(ctx logger.Context) (logger.Logger, error) {
+	address, err := parseAddress(ctx.Config["gelf-address"])
+	if err != nil {
+		return nil, err
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("gelf: cannot access hostname to set source field")
+	}
+
+	extra := map[string]string{
+		"container_id":   ctx.ContainerID,
+		"container_name": strings.TrimPrefix(ctx.ContainerName, "/"),
+	}
+	// gelf-tag is the older name of the tag log opt
+	defaultTag := "{{.ID}}"
+	if t := ctx.Config["gelf-tag"]; t != "" {
+		defaultTag = t
+	}
+	tag, err := loggerutils.ParseLogTag(ctx, defaultTag)
+	if err != nil {
+		return nil, err
+	}
+	extra["tag"] = tag
+
+	l := &gelfLogger{
+		hostname: hostname,
+		extra:    extra,
+	}
+	switch address.Scheme {
+	case "udp":
+		if l.udp, err = net.DialTimeout("udp", address.Host, dialTimeout); err != nil {
+			return nil, fmt.Errorf("gelf: cannot connect to GELF endpoint %s: %v", address.Host, err)
+		}
+	case "tcp":
+		dial := func() (net.Conn, error) {
+			return net.DialTimeout("tcp", address.Host, dialTimeout)
+		}
+		l.sender = loggerutils.NewBufferedSender(dial, loggerutils.DefaultBufferSize)
+	}
+	return l, nil
+}
+
+func (l *gelfLogger) Log(msg *logger.Message) error {
+	level := levelInfo
+	if msg.Source == "stderr" {
+		level = levelErr
+	}
+	m := map[string]interface{}{
+		"version":       "1.1",
+		"host":          l.hostname,
+		"short_message": string(msg.Line),
+		"timestamp":     float64(msg.Timestamp.UnixNano()/int64(time.Millisecond)) / 1000.0,
+		"level":         level,
+	}
+	// additional fields are prefixed with an underscore
+	for k, v := range msg.Attrs {
+		m["_"+k] = v
+	}
+	for k, v := range l.extra {
+		m["_"+k] = v
+	}
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+
+	if l.sender != nil {
+		// GELF over TCP is delimited by null bytes, and can't be compressed
+		return l.sender.Send(append(b, 0))
+	}
+	return l.writeUDP(b)
+}
+
+// writeUDP sends the gzipped message, split into chunks if it does not fit
+// into a single datagram.
+func (l *gelfLogger) writeUDP(b []byte) error {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	zb := buf.Bytes()
+
+	if len(zb) <= chunkSize {
+		_, err := l.udp.Write(zb)
+		return err
+	}
+
+	dataSize := chunkSize - chunkHeaderSize
+	count := (len(zb) + dataSize - 1) / dataSize
+	if count > maxChunks {
+		return fmt.Errorf("gelf: message too large, needs %d chunks, maximum is %d", count, maxChunks)
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	chunk := make([]byte, 0, chunkSize)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * dataSize
+		if end > len(zb) {
+			end = len(zb)
+		}
+		chunk = append(chunk[:0], chunkMagic...)
+		chunk = append(chunk, id...)
+		chunk = append(chunk, byte(i), byte(count))
+		chunk = append(chunk, zb[i*dataSize:end]...)
+		if _, err := l.udp.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *gelfLogger) Close() error {
+	if l.sender != nil {
+		return l.sender.Close()
+	}
+	return l.udp.Close()
+}
+
+func (l *gelfLogger) Name() string {
+	return name
+}
+
+// ValidateLogOpt looks for gelf specific log options gelf-address and
+// gelf-tag, and the tag, labels and env options.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		switch key {
+		case "gelf-address":
+		case "gelf-tag":
+		default:
+			if !loggerutils.IsCommonLogOpt(key) {
+				return fmt.Errorf("unknown log opt '%s' for gelf log driver", key)
+			}
+		}
+	}
+	if err := loggerutils.ValidateLogTag(cfg); err != nil {
+		return err
+	}
+	_, err := parseAddress(cfg["gelf-address"])
+	return err
+}
+
+func parseAddress(address string) (*url.URL, error) {
+	if address == "" {
+		return nil, fmt.Errorf("gelf: gelf-address is required, e.g. udp://host:12201")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" {
+		return nil, fmt.Errorf("gelf: endpoint needs to be UDP or TCP, got %q", address)
+	}
+	if _, _, err := net.SplitHostPort(u.Host); err != nil {
+		return nil, fmt.Errorf("gelf: please provide gelf-address as proto://host:port")
+	}
+	return u, nil
+}
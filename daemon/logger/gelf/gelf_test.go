@@ -0,0 +1,212 @@
+package gelf
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+const cid = "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+
+func TestGelfUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l, err := New(logger.Context{
+		ContainerID:   cid,
+		ContainerName: "/web",
+		Config: map[string]string{
+			"gelf-address": "udp://" + conn.LocalAddr().String(),
+			"gelf-tag":     "frontend",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("hello"), Source: "stderr", Timestamp: time.Unix(1, 5e8), Attrs: map[string]string{"stage": "prod"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(buf[:n]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"version":         "1.1",
+		"short_message":   "hello",
+		"timestamp":       1.5,
+		"level":           float64(levelErr),
+		"_container_id":   cid,
+		"_container_name": "web",
+		"_tag":            "frontend",
+		"_stage":          "prod",
+	}
+	for k, v := range expected {
+		if m[k] != v {
+			t.Fatalf("expected %s to be %v, got %v in %s", k, v, m[k], b)
+		}
+	}
+}
+
+func TestGelfUDPChunked(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		Config:      map[string]string{"gelf-address": "udp://" + conn.LocalAddr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	// random data does not compress into a single datagram
+	random := make([]byte, 3*chunkSize)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	line := []byte(base64.StdEncoding.EncodeToString(random))
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: line, Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		data  []byte
+		count = -1
+		buf   = make([]byte, 65536)
+	)
+	for i := 0; i != count; i++ {
+		conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chunk := buf[:n]
+		if !bytes.HasPrefix(chunk, chunkMagic) {
+			t.Fatalf("chunk %d has no magic bytes", i)
+		}
+		if int(chunk[10]) != i {
+			t.Fatalf("expected chunk %d, got %d", i, chunk[10])
+		}
+		count = int(chunk[11])
+		data = append(data, chunk[chunkHeaderSize:]...)
+	}
+	if count < 2 {
+		t.Fatalf("expected several chunks, got %d", count)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatal(err)
+	}
+	if m["short_message"] != string(line) {
+		t.Fatalf("message was not reassembled correctly: %s", b)
+	}
+}
+
+func TestGelfTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		Config:      map[string]string{"gelf-address": "tcp://" + ln.Addr().String()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	for _, line := range []string{"one", "two"} {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte(line), Source: "stdout"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	rd := bufio.NewReader(conn)
+	for _, expected := range []string{"one", "two"} {
+		b, err := rd.ReadBytes(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m map[string]interface{}
+		if err := json.Unmarshal(b[:len(b)-1], &m); err != nil {
+			t.Fatal(err)
+		}
+		if m["short_message"] != expected || m["level"] != float64(levelInfo) {
+			t.Fatalf("unexpected message %s", b)
+		}
+	}
+}
+
+func TestValidateLogOpt(t *testing.T) {
+	valid := []map[string]string{
+		{"gelf-address": "udp://127.0.0.1:12201"},
+		{"gelf-address": "tcp://graylog:12201", "gelf-tag": "web"},
+	}
+	for _, cfg := range valid {
+		if err := ValidateLogOpt(cfg); err != nil {
+			t.Fatalf("expected %v to be valid, got %v", cfg, err)
+		}
+	}
+	invalid := map[string]map[string]string{
+		"required":          {},
+		"UDP or TCP":        {"gelf-address": "http://graylog:12201"},
+		"proto://host:port": {"gelf-address": "udp://graylog"},
+		"unknown log opt":   {"gelf-address": "udp://graylog:12201", "foo": "bar"},
+	}
+	for expected, cfg := range invalid {
+		if err := ValidateLogOpt(cfg); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected %v to fail with %q, got %v", cfg, expected, err)
+		}
+	}
+}
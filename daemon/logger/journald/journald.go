@@ -4,23 +4,39 @@ package journald
 
 import (
 	"fmt"
-	"io"
+	"strings"
+	"sync"
+	"unicode"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/coreos/go-systemd/journal"
 	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
 )
 
 const name = "journald"
 
 type Journald struct {
 	Jmap map[string]string
+
+	containerID string
+	mu          sync.Mutex
+	closed      bool
+	// readers following the journal, each with a channel closed when the
+	// logger is closed
+	readers map[*logger.LogWatcher]chan struct{}
+	// cursor of the last entry of the container when the logger was
+	// closed, the followers stop after it
+	lastCursor string
 }
 
 func init() {
 	if err := logger.RegisterLogDriver(name, New); err != nil {
 		logrus.Fatal(err)
 	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
 }
 
 func New(ctx logger.Context) (logger.Logger, error) {
@@ -33,28 +49,83 @@ func New(ctx logger.Context) (logger.Logger, error) {
 	if name[0] == '/' {
 		name = name[1:]
 	}
+	tag, err := loggerutils.ParseLogTag(ctx, "{{.ID}}")
+	if err != nil {
+		return nil, err
+	}
 	jmap := map[string]string{
 		"CONTAINER_ID":      ctx.ContainerID[:12],
 		"CONTAINER_ID_FULL": ctx.ContainerID,
-		"CONTAINER_NAME":    name}
-	return &Journald{Jmap: jmap}, nil
+		"CONTAINER_NAME":    name,
+		"CONTAINER_TAG":     tag}
+	return &Journald{
+		Jmap:        jmap,
+		containerID: ctx.ContainerID,
+		readers:     make(map[*logger.LogWatcher]chan struct{}),
+	}, nil
 }
 
 func (s *Journald) Log(msg *logger.Message) error {
+	vars := s.Jmap
+	if len(msg.Attrs) > 0 {
+		vars = make(map[string]string, len(s.Jmap)+len(msg.Attrs))
+		for k, v := range msg.Attrs {
+			vars[fieldName(k)] = v
+		}
+		for k, v := range s.Jmap {
+			vars[k] = v
+		}
+	}
 	if msg.Source == "stderr" {
-		return journal.Send(string(msg.Line), journal.PriErr, s.Jmap)
+		return journal.Send(string(msg.Line), journal.PriErr, vars)
+	}
+	return journal.Send(string(msg.Line), journal.PriInfo, vars)
+}
+
+// fieldName converts the key of an extra attribute to a valid journal
+// field name: upper case letters, digits and underscores, not starting
+// with an underscore.
+func fieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, key)
+	return strings.TrimLeft(name, "_")
+}
+
+// ValidateLogOpt looks for the tag, labels and env log options, the only
+// ones supported by the journald driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		if !loggerutils.IsCommonLogOpt(key) {
+			return fmt.Errorf("unknown log opt '%s' for journald log driver", key)
+		}
 	}
-	return journal.Send(string(msg.Line), journal.PriInfo, s.Jmap)
+	return loggerutils.ValidateLogTag(cfg)
 }
 
+// Close stops the readers following the journal once they have read the
+// entries logged before it
 func (s *Journald) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.readers) > 0 {
+		cursor, err := lastCursor(s.containerID)
+		if err != nil {
+			logrus.Errorf("Error getting the journal cursor of container %s, its followers read to the end of the journal: %v", s.containerID, err)
+		}
+		s.lastCursor = cursor
+	}
+	for w, stop := range s.readers {
+		close(stop)
+		delete(s.readers, w)
+	}
+	s.closed = true
 	return nil
 }
 
 func (s *Journald) Name() string {
 	return name
 }
-
-func (s *Journald) GetReader() (io.Reader, error) {
-	return nil, logger.ReadLogsNotSupported
-}
@@ -0,0 +1,233 @@
+// +build linux
+
+package journald
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/coreos/go-systemd/journal"
+	"github.com/docker/docker/daemon/logger"
+)
+
+// journalEntry is an entry of the journal in the JSON format of journalctl
+type journalEntry struct {
+	// Message is a string, or an array of bytes if it is not valid UTF-8
+	Message  json.RawMessage `json:"MESSAGE"`
+	Priority string          `json:"PRIORITY"`
+	// Timestamp is in microseconds since the epoch
+	Timestamp string `json:"__REALTIME_TIMESTAMP"`
+	// Cursor identifies the entry in the journal
+	Cursor string `json:"__CURSOR"`
+}
+
+// ReadLogs implements the logger.LogReader interface by querying the
+// journal for the entries of the container with journalctl.
+func (s *Journald) ReadLogs(config logger.ReadConfig) *logger.LogWatcher {
+	logWatcher := logger.NewLogWatcher()
+	go s.readLogs(logWatcher, config)
+	return logWatcher
+}
+
+func (s *Journald) readLogs(logWatcher *logger.LogWatcher, config logger.ReadConfig) {
+	defer close(logWatcher.Msg)
+
+	if config.Tail == 0 && !config.Follow {
+		return
+	}
+
+	args := []string{"--no-pager", "--output=json", "CONTAINER_ID_FULL=" + s.containerID}
+	if config.Tail >= 0 {
+		args = append(args, fmt.Sprintf("--lines=%d", config.Tail))
+	} else {
+		args = append(args, "--no-tail")
+	}
+	if !config.Since.IsZero() {
+		args = append(args, "--since="+config.Since.Local().Format("2006-01-02 15:04:05"))
+	}
+
+	var stop chan struct{}
+	s.mu.Lock()
+	if config.Follow && !s.closed {
+		stop = make(chan struct{})
+		s.readers[logWatcher] = stop
+		defer func() {
+			s.mu.Lock()
+			delete(s.readers, logWatcher)
+			s.mu.Unlock()
+		}()
+	}
+	s.mu.Unlock()
+
+	if stop == nil {
+		s.runJournalctl(args, logWatcher, config.Since, nil, "")
+		return
+	}
+
+	cursor, ok := s.runJournalctl(append(args, "--follow"), logWatcher, config.Since, stop, "")
+	if !ok {
+		return
+	}
+	select {
+	case <-stop:
+	default:
+		return
+	}
+
+	// The logger was closed while following: send the entries the
+	// follower did not get to yet, up to the last one logged before the
+	// close.
+	s.mu.Lock()
+	last := s.lastCursor
+	s.mu.Unlock()
+	if cursor != "" {
+		args = []string{"--no-pager", "--output=json", "--after-cursor=" + cursor, "CONTAINER_ID_FULL=" + s.containerID}
+	}
+	if cursor == "" || cursor != last {
+		s.runJournalctl(args, logWatcher, config.Since, nil, last)
+	}
+}
+
+// runJournalctl runs journalctl with args and sends the entries it outputs
+// to the watcher. It stops journalctl when the watcher is closed, when stop
+// is closed, or once the entry with the cursor until was read. It returns
+// the cursor of the last entry read, and false if an error was reported.
+func (s *Journald) runJournalctl(args []string, logWatcher *logger.LogWatcher, since time.Time, stop <-chan struct{}, until string) (string, bool) {
+	cmd := exec.Command("journalctl", args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		logWatcher.Err <- err
+		return "", false
+	}
+	if err := cmd.Start(); err != nil {
+		logWatcher.Err <- fmt.Errorf("error running journalctl: %v", err)
+		return "", false
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		select {
+		case <-logWatcher.WatchClose():
+			// stop journalctl if the reader went away while it is
+			// still reading the journal
+		case <-stop:
+		case <-done:
+			return
+		}
+		close(stopped)
+		cmd.Process.Kill()
+	}()
+
+	cursor, err := sendEntries(stdout, logWatcher, s.containerID, since, stopped, until)
+	close(done)
+	if err != nil {
+		logWatcher.Err <- err
+	}
+	// journalctl may still be following the journal after the entry
+	// with the cursor until
+	cmd.Process.Kill()
+	if err := cmd.Wait(); err != nil {
+		logrus.Debugf("journalctl exited: %v", err)
+	}
+	return cursor, err == nil
+}
+
+// sendEntries decodes the journal entries from r and sends them to the
+// watcher until r is exhausted, the entry with the cursor until was read,
+// or the watcher is closed. Decoding errors are ignored once stopped is
+// closed, as journalctl was killed. It returns the cursor of the last
+// entry read.
+func sendEntries(r io.Reader, logWatcher *logger.LogWatcher, containerID string, since time.Time, stopped <-chan struct{}, until string) (string, error) {
+	var cursor string
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var entry journalEntry
+		if err := dec.Decode(&entry); err != nil {
+			if err == io.EOF {
+				return cursor, nil
+			}
+			select {
+			case <-stopped:
+				return cursor, nil
+			default:
+				return cursor, err
+			}
+		}
+		msg, err := entry.message(containerID)
+		if err != nil {
+			return cursor, err
+		}
+		if since.IsZero() || !msg.Timestamp.Before(since) {
+			select {
+			case logWatcher.Msg <- msg:
+			case <-logWatcher.WatchClose():
+				return cursor, nil
+			}
+		}
+		cursor = entry.Cursor
+		if until != "" && cursor == until {
+			return cursor, nil
+		}
+	}
+}
+
+// lastCursor returns the cursor of the last entry of the container in the
+// journal, or "" if there is none.
+func lastCursor(containerID string) (string, error) {
+	out, err := exec.Command("journalctl", "--no-pager", "--output=json", "--lines=1", "CONTAINER_ID_FULL="+containerID).Output()
+	if err != nil {
+		return "", fmt.Errorf("error running journalctl: %v", err)
+	}
+	if len(bytes.TrimSpace(out)) == 0 {
+		return "", nil
+	}
+	var entry journalEntry
+	if err := json.Unmarshal(out, &entry); err != nil {
+		return "", err
+	}
+	return entry.Cursor, nil
+}
+
+// message converts the journal entry to a logger.Message. The journald
+// driver logs stderr with error priority, and stdout with info priority.
+func (e *journalEntry) message(containerID string) (*logger.Message, error) {
+	msg := &logger.Message{
+		ContainerID: containerID,
+		Source:      "stdout",
+	}
+	if e.Priority == strconv.Itoa(int(journal.PriErr)) {
+		msg.Source = "stderr"
+	}
+
+	usec, err := strconv.ParseInt(e.Timestamp, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid journal timestamp %q: %v", e.Timestamp, err)
+	}
+	msg.Timestamp = time.Unix(0, usec*int64(time.Microsecond)).UTC()
+
+	if len(e.Message) == 0 || string(e.Message) == "null" {
+		return msg, nil
+	}
+	var line string
+	if err := json.Unmarshal(e.Message, &line); err == nil {
+		msg.Line = []byte(line)
+		return msg, nil
+	}
+	var raw []int
+	if err := json.Unmarshal(e.Message, &raw); err != nil {
+		return nil, fmt.Errorf("invalid journal message %s: %v", e.Message, err)
+	}
+	msg.Line = make([]byte, len(raw))
+	for i, b := range raw {
+		msg.Line[i] = byte(b)
+	}
+	return msg, nil
+}
@@ -0,0 +1,43 @@
+// +build linux
+
+package journald
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+func TestSendEntriesUntil(t *testing.T) {
+	entries := `{"MESSAGE":"line1","PRIORITY":"6","__REALTIME_TIMESTAMP":"1000000","__CURSOR":"c1"}
+{"MESSAGE":[108,105,110,101,50],"PRIORITY":"3","__REALTIME_TIMESTAMP":"2000000","__CURSOR":"c2"}
+{"MESSAGE":"line3","PRIORITY":"6","__REALTIME_TIMESTAMP":"3000000","__CURSOR":"c3"}
+`
+	logWatcher := logger.NewLogWatcher()
+	msgs := make(chan *logger.Message, 3)
+	go func() {
+		for msg := range logWatcher.Msg {
+			msgs <- msg
+		}
+		close(msgs)
+	}()
+
+	cursor, err := sendEntries(strings.NewReader(entries), logWatcher, "cid", time.Time{}, nil, "c2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(logWatcher.Msg)
+	if cursor != "c2" {
+		t.Fatalf("Expected to stop at cursor c2, got %q", cursor)
+	}
+
+	var got []string
+	for msg := range msgs {
+		got = append(got, msg.Source+":"+string(msg.Line))
+	}
+	if strings.Join(got, ",") != "stdout:line1,stderr:line2" {
+		t.Fatalf("Wrong messages: %q", got)
+	}
+}
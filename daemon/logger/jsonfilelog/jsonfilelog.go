@@ -2,14 +2,18 @@ package jsonfilelog
 
 import (
 	"bytes"
-	"io"
+	"encoding/json"
+	"fmt"
 	"os"
+	"strconv"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
 	"github.com/docker/docker/pkg/jsonlog"
 	"github.com/docker/docker/pkg/timeutils"
+	"github.com/docker/docker/pkg/units"
 )
 
 const (
@@ -19,9 +23,22 @@ const (
 // JSONFileLogger is Logger implementation for default docker logging:
 // JSON objects to file
 type JSONFileLogger struct {
-	buf *bytes.Buffer
-	f   *os.File   // store for closing
-	mu  sync.Mutex // protects buffer
+	buf      *bytes.Buffer
+	f        *os.File   // store for closing
+	mu       sync.Mutex // protects buffer, file, size, truncations, readers and closed
+	size     int64      // current size of the log file
+	capacity int64      // maximum size of each file, -1 for unlimited
+	n        int        // maximum number of files, including the current one
+	closed   bool
+	tag      string // the tag added to the attributes of the messages, if set
+
+	// number of times the log file was truncated in place, when
+	// max-file is 1
+	truncations int
+
+	// readers following the log, each with a channel notifying it of
+	// new messages
+	readers map[*logger.LogWatcher]chan struct{}
 
 	ctx logger.Context
 }
@@ -30,18 +47,59 @@ func init() {
 	if err := logger.RegisterLogDriver(Name, New); err != nil {
 		logrus.Fatal(err)
 	}
+	if err := logger.RegisterLogOptValidator(Name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
 }
 
 // New creates new JSONFileLogger which writes to filename
 func New(ctx logger.Context) (logger.Logger, error) {
+	var (
+		capacity int64 = -1
+		n              = 1
+		err      error
+	)
+	if sizeStr, ok := ctx.Config["max-size"]; ok {
+		capacity, err = units.RAMInBytes(sizeStr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if fileStr, ok := ctx.Config["max-file"]; ok {
+		n, err = strconv.Atoi(fileStr)
+		if err != nil {
+			return nil, err
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("max-file cannot be less than 1")
+		}
+	}
+
+	var tag string
+	if ctx.Config["tag"] != "" {
+		if tag, err = loggerutils.ParseLogTag(ctx, ""); err != nil {
+			return nil, err
+		}
+	}
+
 	log, err := os.OpenFile(ctx.LogPath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0600)
 	if err != nil {
 		return nil, err
 	}
+	fi, err := log.Stat()
+	if err != nil {
+		log.Close()
+		return nil, err
+	}
 	return &JSONFileLogger{
-		f:   log,
-		buf: bytes.NewBuffer(nil),
-		ctx: ctx,
+		f:        log,
+		buf:      bytes.NewBuffer(nil),
+		size:     fi.Size(),
+		capacity: capacity,
+		n:        n,
+		tag:      tag,
+		readers:  make(map[*logger.LogWatcher]chan struct{}),
+		ctx:      ctx,
 	}, nil
 }
 
@@ -54,30 +112,131 @@ func (l *JSONFileLogger) Log(msg *logger.Message) error {
 	if err != nil {
 		return err
 	}
-	err = (&jsonlog.JSONLogBytes{Log: append(msg.Line, '\n'), Stream: msg.Source, Created: timestamp}).MarshalJSONBuf(l.buf)
+	msgAttrs := msg.Attrs
+	if l.tag != "" {
+		msgAttrs = make(map[string]string, len(msg.Attrs)+1)
+		for k, v := range msg.Attrs {
+			msgAttrs[k] = v
+		}
+		msgAttrs["tag"] = l.tag
+	}
+	var attrs json.RawMessage
+	if len(msgAttrs) > 0 {
+		if attrs, err = json.Marshal(msgAttrs); err != nil {
+			return err
+		}
+	}
+	err = (&jsonlog.JSONLogBytes{Log: append(msg.Line, '\n'), Stream: msg.Source, Created: timestamp, RawAttrs: attrs}).MarshalJSONBuf(l.buf)
 	if err != nil {
 		return err
 	}
 	l.buf.WriteByte('\n')
-	_, err = l.buf.WriteTo(l.f)
+	n, err := l.buf.WriteTo(l.f)
+	l.size += n
 	if err != nil {
 		// this buffer is screwed, replace it with another to avoid races
 		l.buf = bytes.NewBuffer(nil)
 		return err
 	}
+	if l.capacity != -1 && l.size >= l.capacity {
+		err = l.rotate()
+	}
+	for _, notify := range l.readers {
+		select {
+		case notify <- struct{}{}:
+		default:
+		}
+	}
+	return err
+}
+
+// rotate shifts the rotated files by one, moves the current log file to
+// <LogPath>.1 and starts a new one. Callers must hold l.mu, so that no
+// message is written while the files are being moved. The current file is
+// only replaced once the new one is open, so that the messages are still
+// written to it if the rotation fails.
+func (l *JSONFileLogger) rotate() error {
+	name := l.ctx.LogPath
+	if l.n < 2 {
+		if err := l.f.Truncate(0); err != nil {
+			return err
+		}
+		l.size = 0
+		l.truncations++
+		return nil
+	}
+
+	for i := l.n - 1; i > 1; i-- {
+		older := fmt.Sprintf("%s.%d", name, i)
+		newer := fmt.Sprintf("%s.%d", name, i-1)
+		if err := os.Rename(newer, older); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if err := os.Rename(name, name+".1"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := l.f.Close(); err != nil {
+		logrus.Errorf("Error closing the rotated log file %s: %v", name+".1", err)
+	}
+	l.f = f
+	l.size = 0
 	return nil
 }
 
-func (l *JSONFileLogger) GetReader() (io.Reader, error) {
-	return os.Open(l.ctx.LogPath)
+// ValidateLogOpt looks for json specific log options max-file & max-size,
+// and the tag, labels and env options.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key, value := range cfg {
+		switch key {
+		case "max-size":
+			if _, err := units.RAMInBytes(value); err != nil {
+				return fmt.Errorf("invalid value for log opt 'max-size': %v", err)
+			}
+		case "max-file":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid value for log opt 'max-file': %v", err)
+			}
+			if n < 1 {
+				return fmt.Errorf("max-file cannot be less than 1")
+			}
+		default:
+			if !loggerutils.IsCommonLogOpt(key) {
+				return fmt.Errorf("unknown log opt '%s' for json-file log driver", key)
+			}
+		}
+	}
+	if err := loggerutils.ValidateLogTag(cfg); err != nil {
+		return err
+	}
+	if _, ok := cfg["max-file"]; ok {
+		if _, ok := cfg["max-size"]; !ok {
+			return fmt.Errorf("max-file requires max-size to be set")
+		}
+	}
+	return nil
 }
 
 func (l *JSONFileLogger) LogPath() string {
 	return l.ctx.LogPath
 }
 
-// Close closes underlying file
+// Close closes underlying file and stops the readers following the log
+// once they have read the last messages
 func (l *JSONFileLogger) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for w, notify := range l.readers {
+		close(notify)
+		delete(l.readers, w)
+	}
+	l.closed = true
 	return l.f.Close()
 }
 
@@ -4,6 +4,8 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -51,6 +53,413 @@ func TestJSONFileLogger(t *testing.T) {
 	}
 }
 
+func TestJSONFileLoggerWithAttrs(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	attrs := map[string]string{"rack": "r1", "stage": "prod"}
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line1"), Source: "src1", Attrs: attrs}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"log":"line1\n","stream":"src1","time":"0001-01-01T00:00:00Z","attrs":{"rack":"r1","stage":"prod"}}
+`
+	if string(res) != expected {
+		t.Fatalf("Wrong log content: %q, expected %q", res, expected)
+	}
+
+	logs := l.(*JSONFileLogger).ReadLogs(logger.ReadConfig{Tail: -1})
+	defer logs.Close()
+	select {
+	case msg := <-logs.Msg:
+		if msg == nil || msg.Attrs["rack"] != "r1" || msg.Attrs["stage"] != "prod" {
+			t.Fatalf("unexpected message read back: %v", msg)
+		}
+	case err := <-logs.Err:
+		t.Fatal(err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout reading logs")
+	}
+}
+
+func TestJSONFileLoggerWithTag(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID:   cid,
+		ContainerName: "/web",
+		LogPath:       filename,
+		Config:        map[string]string{"tag": "{{.Name}}/{{.ID}}"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line1"), Source: "src1", Attrs: map[string]string{"rack": "r1"}}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"log":"line1\n","stream":"src1","time":"0001-01-01T00:00:00Z","attrs":{"rack":"r1","tag":"web/a7317399f3f8"}}
+`
+	if string(res) != expected {
+		t.Fatalf("Wrong log content: %q, expected %q", res, expected)
+	}
+}
+
+func TestJSONFileLoggerWithOpts(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	config := map[string]string{"max-file": "3", "max-size": "1k"}
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      config,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 20; i++ {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line" + strconv.Itoa(i)), Source: "src1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	penUlt, err := ioutil.ReadFile(filename + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPenultimate := `{"log":"line0\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line1\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line2\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line3\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line4\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line5\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line6\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line7\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line8\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line9\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line10\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line11\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line12\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line13\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line14\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line15\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+`
+	expected := `{"log":"line16\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line17\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line18\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line19\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+`
+
+	if string(res) != expected {
+		t.Fatalf("Wrong log content: %q, expected %q", res, expected)
+	}
+	if string(penUlt) != expectedPenultimate {
+		t.Fatalf("Wrong log content: %q, expected %q", penUlt, expectedPenultimate)
+	}
+
+	lines := readAll(t, l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: -1}))
+	if len(lines) != 20 || lines[0] != "line0" || lines[19] != "line19" {
+		t.Fatalf("Wrong log content across files: %q", lines)
+	}
+
+	lines = readAll(t, l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: 6}))
+	expectedTail := []string{"line14", "line15", "line16", "line17", "line18", "line19"}
+	if strings.Join(lines, ",") != strings.Join(expectedTail, ",") {
+		t.Fatalf("Wrong tail content: %q, expected %q", lines, expectedTail)
+	}
+}
+
+func TestJSONFileLoggerFollow(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      map[string]string{"max-file": "2", "max-size": "1k"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("before"), Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+	logs := l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: 1, Follow: true})
+	msg := <-logs.Msg
+	if string(msg.Line) != "before" || msg.Source != "stdout" {
+		t.Fatalf("Wrong message: %q from %s", msg.Line, msg.Source)
+	}
+
+	// write enough to rotate the file while following
+	for i := 0; i < 20; i++ {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line" + strconv.Itoa(i)), Source: "stderr"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	lines := readAll(t, logs)
+	if len(lines) != 20 || lines[0] != "line0" || lines[19] != "line19" {
+		t.Fatalf("Wrong followed content: %q", lines)
+	}
+}
+
+func TestJSONFileLoggerFollowBehind(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	// every message rotates the file, and all the files are kept
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      map[string]string{"max-file": "20", "max-size": "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("before"), Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+	logs := l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: 1, Follow: true})
+	if msg := <-logs.Msg; string(msg.Line) != "before" {
+		t.Fatalf("Wrong message: %q", msg.Line)
+	}
+
+	// nothing is read until all the messages are written, so the reader
+	// falls behind by several rotations
+	for i := 0; i < 10; i++ {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line" + strconv.Itoa(i)), Source: "stdout"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	lines := readAll(t, logs)
+	var expected []string
+	for i := 0; i < 10; i++ {
+		expected = append(expected, "line"+strconv.Itoa(i))
+	}
+	if strings.Join(lines, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Wrong followed content: %q, expected %q", lines, expected)
+	}
+}
+
+func TestJSONFileLoggerFollowTruncated(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      map[string]string{"max-file": "1", "max-size": "1k"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("before"), Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+	logs := l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: 1, Follow: true})
+	if msg := <-logs.Msg; string(msg.Line) != "before" {
+		t.Fatalf("Wrong message: %q", msg.Line)
+	}
+
+	// the long message truncates the file, and the next ones grow it back
+	// past the offset of the reader
+	long := strings.Repeat("x", 1024)
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte(long), Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line" + strconv.Itoa(i)), Source: "stdout"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+
+	// the long message is only read if the reader got to it before the
+	// file was truncated
+	lines := readAll(t, logs)
+	if len(lines) > 0 && lines[0] == long {
+		lines = lines[1:]
+	}
+	if strings.Join(lines, ",") != "line0,line1,line2" {
+		t.Fatalf("Wrong followed content: %q", lines)
+	}
+}
+
+func TestJSONFileLoggerMalformedLine(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	content := `{"log":"line1\n","stream":"stdout","time":"0001-01-01T00:00:00Z"}
+{"log":"broken
+`
+	if err := ioutil.WriteFile(filename, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line2"), Source: "stdout"}); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := readAll(t, l.(logger.LogReader).ReadLogs(logger.ReadConfig{Tail: -1}))
+	if strings.Join(lines, ",") != "line1,line2" {
+		t.Fatalf("Wrong content: %q, expected the malformed line to be skipped", lines)
+	}
+}
+
+func TestJSONFileLoggerRotateError(t *testing.T) {
+	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
+	tmp, err := ioutil.TempDir("", "docker-logger-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	filename := filepath.Join(tmp, "container.log")
+	// the log file cannot be moved over a directory which is not empty
+	if err := os.MkdirAll(filepath.Join(filename+".1", "busy"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := New(logger.Context{
+		ContainerID: cid,
+		LogPath:     filename,
+		Config:      map[string]string{"max-file": "2", "max-size": "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line1"), Source: "src1"}); err == nil {
+		t.Fatal("Expected the rotation to fail")
+	}
+	if err := os.RemoveAll(filename + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Log(&logger.Message{ContainerID: cid, Line: []byte("line2"), Source: "src1"}); err != nil {
+		t.Fatalf("Expected the log to still be written after a failed rotation: %v", err)
+	}
+
+	res, err := ioutil.ReadFile(filename + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"log":"line1\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+{"log":"line2\n","stream":"src1","time":"0001-01-01T00:00:00Z"}
+`
+	if string(res) != expected {
+		t.Fatalf("Wrong log content: %q, expected %q", res, expected)
+	}
+}
+
+func readAll(t *testing.T, logs *logger.LogWatcher) []string {
+	defer logs.Close()
+	var lines []string
+	for {
+		select {
+		case msg, ok := <-logs.Msg:
+			if !ok {
+				return lines
+			}
+			lines = append(lines, string(msg.Line))
+		case err := <-logs.Err:
+			t.Fatal(err)
+		case <-time.After(10 * time.Second):
+			t.Fatalf("Timed out reading logs, got %q", lines)
+		}
+	}
+}
+
+func TestValidateLogOpt(t *testing.T) {
+	valid := []map[string]string{
+		{},
+		{"max-size": "10m"},
+		{"max-size": "10m", "max-file": "3"},
+		{"labels": "rack,stage", "env": "ENV"},
+		{"tag": "{{.ImageName}}/{{.Name}}"},
+	}
+	for _, cfg := range valid {
+		if err := ValidateLogOpt(cfg); err != nil {
+			t.Fatalf("expected %v to be valid, got %v", cfg, err)
+		}
+	}
+	invalid := []map[string]string{
+		{"max-size": "big"},
+		{"max-size": "10m", "max-file": "0"},
+		{"max-file": "3"},
+		{"foo": "bar"},
+		{"tag": "{{.Name"},
+	}
+	for _, cfg := range invalid {
+		if err := ValidateLogOpt(cfg); err == nil {
+			t.Fatalf("expected %v to be invalid", cfg)
+		}
+	}
+}
+
 func BenchmarkJSONFileLogger(b *testing.B) {
 	cid := "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657"
 	tmp, err := ioutil.TempDir("", "docker-logger-")
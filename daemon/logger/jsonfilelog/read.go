@@ -0,0 +1,310 @@
+package jsonfilelog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/pkg/jsonlog"
+	"github.com/docker/docker/pkg/tailfile"
+)
+
+// ReadLogs implements the logger.LogReader interface. The rotated log files
+// are read as well.
+func (l *JSONFileLogger) ReadLogs(config logger.ReadConfig) *logger.LogWatcher {
+	logWatcher := logger.NewLogWatcher()
+	go l.readLogs(logWatcher, config)
+	return logWatcher
+}
+
+func (l *JSONFileLogger) readLogs(logWatcher *logger.LogWatcher, config logger.ReadConfig) {
+	defer close(logWatcher.Msg)
+
+	// Open the files and take the size of the current one under the lock:
+	// the history is everything up to that size, and following starts
+	// right after it, so no message is read twice or lost.
+	l.mu.Lock()
+	files, err := l.openLogFiles()
+	if err != nil {
+		l.mu.Unlock()
+		logWatcher.Err <- err
+		return
+	}
+	size := l.size
+	truncations := l.truncations
+	var notify chan struct{}
+	if config.Follow && !l.closed {
+		notify = make(chan struct{}, 1)
+		l.readers[logWatcher] = notify
+	}
+	l.mu.Unlock()
+
+	latest := files[len(files)-1]
+	defer func() {
+		if notify != nil {
+			l.mu.Lock()
+			delete(l.readers, logWatcher)
+			l.mu.Unlock()
+		}
+		latest.Close()
+	}()
+
+	history := make([]io.ReadSeeker, 0, len(files))
+	for _, f := range files[:len(files)-1] {
+		defer f.Close()
+		history = append(history, f)
+	}
+	history = append(history, io.NewSectionReader(latest, 0, size))
+
+	if config.Tail != 0 {
+		var r io.Reader
+		if config.Tail > 0 {
+			r, err = tailLogs(history, config.Tail)
+			if err != nil {
+				logWatcher.Err <- err
+				return
+			}
+		} else {
+			readers := make([]io.Reader, len(history))
+			for i, rs := range history {
+				readers[i] = rs
+			}
+			r = io.MultiReader(readers...)
+		}
+		if _, ok := l.sendLogs(bufio.NewReader(r), logWatcher, config.Since, nil); !ok {
+			return
+		}
+	}
+
+	if notify == nil {
+		return
+	}
+	if _, err := latest.Seek(size, os.SEEK_SET); err != nil {
+		logWatcher.Err <- err
+		return
+	}
+	latest = l.followLogs(latest, logWatcher, notify, truncations, config.Since)
+}
+
+// openLogFiles opens all existing log files, oldest first. The last one is
+// the current log file. Callers must hold l.mu.
+func (l *JSONFileLogger) openLogFiles() ([]*os.File, error) {
+	var files []*os.File
+	for i := l.n - 1; i > 0; i-- {
+		f, err := os.Open(fmt.Sprintf("%s.%d", l.ctx.LogPath, i))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			for _, f := range files {
+				f.Close()
+			}
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	f, err := os.Open(l.ctx.LogPath)
+	if err != nil {
+		for _, f := range files {
+			f.Close()
+		}
+		return nil, err
+	}
+	return append(files, f), nil
+}
+
+// tailLogs returns a reader for the last n lines of the log files, reading
+// back through them from the newest as far as needed.
+func tailLogs(files []io.ReadSeeker, n int) (io.Reader, error) {
+	var lines [][]byte
+	for i := len(files) - 1; i >= 0 && len(lines) < n; i-- {
+		ls, err := tailfile.TailFile(files[i], n-len(lines))
+		if err != nil {
+			return nil, err
+		}
+		lines = append(ls, lines...)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	for _, line := range lines {
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf, nil
+}
+
+// sendLogs decodes the log lines from rd and sends them to the watcher
+// until rd is exhausted. A trailing incomplete line is returned, so that
+// it can be completed by a later read; partial is the one returned
+// previously. It returns false if the watcher was closed or an error was
+// reported.
+func (l *JSONFileLogger) sendLogs(rd *bufio.Reader, logWatcher *logger.LogWatcher, since time.Time, partial []byte) ([]byte, bool) {
+	jl := &jsonlog.JSONLog{}
+	for {
+		line, err := rd.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF {
+				return append(partial, line...), true
+			}
+			logWatcher.Err <- err
+			return nil, false
+		}
+		if len(partial) > 0 {
+			line = append(partial, line...)
+			partial = nil
+		}
+
+		jl.Reset()
+		if err := json.Unmarshal(line, jl); err != nil {
+			logrus.Debugf("Skipping malformed log line of container %s: %v", l.ctx.ContainerID, err)
+			continue
+		}
+		if !since.IsZero() && jl.Created.Before(since) {
+			continue
+		}
+		msg := &logger.Message{
+			ContainerID: l.ctx.ContainerID,
+			Line:        bytes.TrimSuffix([]byte(jl.Log), []byte{'\n'}),
+			Source:      jl.Stream,
+			Timestamp:   jl.Created,
+			Attrs:       jl.Attrs,
+		}
+		select {
+		case logWatcher.Msg <- msg:
+		case <-logWatcher.WatchClose():
+			return nil, false
+		}
+	}
+}
+
+// followLogs sends the messages appended to f until the watcher is closed,
+// or the logger is closed and the remaining messages were sent. It follows
+// the log through the rotated files in order, and returns the file it was
+// reading last. truncations is the number of times the log file had been
+// truncated in place when f was opened.
+func (l *JSONFileLogger) followLogs(f *os.File, logWatcher *logger.LogWatcher, notify chan struct{}, truncations int, since time.Time) *os.File {
+	var (
+		rd      = bufio.NewReader(f)
+		partial []byte
+		ok      bool
+		closed  bool
+	)
+	for {
+		if n := l.truncated(); n != truncations {
+			// max-file is 1, the file was truncated in place; the
+			// offset can't tell, the file may have grown back past it
+			truncations = n
+			if _, err := f.Seek(0, os.SEEK_SET); err != nil {
+				logWatcher.Err <- err
+				return f
+			}
+			rd.Reset(f)
+			partial = nil
+		}
+
+		if partial, ok = l.sendLogs(rd, logWatcher, since, partial); !ok {
+			return f
+		}
+
+		next, err := l.openNext(f)
+		if err != nil {
+			logWatcher.Err <- err
+			return f
+		}
+		if next != nil {
+			// nothing is written to a file after it was rotated, so
+			// what's left in it is all there is
+			if _, ok = l.sendLogs(rd, logWatcher, since, partial); !ok {
+				next.Close()
+				return f
+			}
+			f.Close()
+			f, partial = next, nil
+			rd.Reset(f)
+			continue
+		}
+		if closed {
+			return f
+		}
+
+		select {
+		case _, open := <-notify:
+			// once the logger is closed, send what's left and stop
+			closed = !open
+		case <-logWatcher.WatchClose():
+			return f
+		}
+	}
+}
+
+// truncated returns the number of times the log file was truncated in
+// place.
+func (l *JSONFileLogger) truncated() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.truncations
+}
+
+// openNext opens the log file written after f if f was rotated away, and
+// returns nil if f is still the current log file. A reader that fell
+// behind by several rotations goes through each rotated file in turn; if
+// f was already removed, it goes on with the oldest file left.
+func (l *JSONFileLogger) openNext(f *os.File) (*os.File, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	// the files are only moved under the lock
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var cur os.FileInfo
+	if l.closed {
+		cur, err = os.Stat(l.ctx.LogPath)
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+	} else {
+		cur, err = l.f.Stat()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if os.SameFile(fi, cur) {
+		// still written to, even if a failed rotation moved it
+		return nil, nil
+	}
+
+	var names []string
+	for i := l.n - 1; i > 0; i-- {
+		names = append(names, fmt.Sprintf("%s.%d", l.ctx.LogPath, i))
+	}
+	names = append(names, l.ctx.LogPath)
+
+	// the files after f, or all of them if f is gone
+	next := names
+	for i, name := range names {
+		nfi, err := os.Stat(name)
+		if err == nil && os.SameFile(fi, nfi) {
+			next = names[i+1:]
+			break
+		}
+	}
+	for _, name := range next {
+		nf, err := os.Open(name)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return nf, nil
+	}
+	return nil, nil
+}
@@ -2,7 +2,7 @@ package logger
 
 import (
 	"errors"
-	"io"
+	"sync"
 	"time"
 )
 
@@ -14,6 +14,9 @@ type Message struct {
 	Line        []byte
 	Source      string
 	Timestamp   time.Time
+	// Attrs are the container labels and environment variables selected
+	// with the "labels" and "env" log opts
+	Attrs map[string]string
 }
 
 // Logger is interface for docker logging drivers
@@ -21,5 +24,55 @@ type Logger interface {
 	Log(*Message) error
 	Name() string
 	Close() error
-	GetReader() (io.Reader, error)
+}
+
+// ReadConfig is the configuration passed into ReadLogs
+type ReadConfig struct {
+	// Since drops messages logged before the given time, if not zero
+	Since time.Time
+	// Tail is the number of most recent messages to read, -1 for all
+	Tail int
+	// Follow keeps the watcher open and sends new messages as they are
+	// logged, until the watcher or the logger is closed
+	Follow bool
+}
+
+// LogReader is the interface for logging drivers which can read back the
+// messages they logged
+type LogReader interface {
+	ReadLogs(ReadConfig) *LogWatcher
+}
+
+// LogWatcher is used to consume the messages read by a LogReader. Msg is
+// closed by the reader once there is nothing more to read.
+type LogWatcher struct {
+	// Msg is the channel the read messages are sent to
+	Msg chan *Message
+	// Err receives the error which stopped the reading, if any
+	Err chan error
+
+	closeNotifier chan struct{}
+	closeOnce     sync.Once
+}
+
+// NewLogWatcher returns a new LogWatcher
+func NewLogWatcher() *LogWatcher {
+	return &LogWatcher{
+		Msg:           make(chan *Message, 1),
+		Err:           make(chan error, 1),
+		closeNotifier: make(chan struct{}),
+	}
+}
+
+// Close tells the reader to stop sending messages. It is safe to call it
+// more than once.
+func (w *LogWatcher) Close() {
+	w.closeOnce.Do(func() {
+		close(w.closeNotifier)
+	})
+}
+
+// WatchClose returns a channel which is closed when the watcher is closed
+func (w *LogWatcher) WatchClose() <-chan struct{} {
+	return w.closeNotifier
 }
@@ -0,0 +1,47 @@
+package loggerutils
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+// ParseLogTag generates a context aware tag for consistency across
+// different log drivers, from the "tag" log opt or, if it is not set,
+// from defaultTemplate. The tag is a Go template using the methods of
+// logger.Context, e.g. "{{.ImageName}}/{{.Name}}/{{.ID}}".
+func ParseLogTag(ctx logger.Context, defaultTemplate string) (string, error) {
+	tagTemplate := ctx.Config["tag"]
+	if tagTemplate == "" {
+		tagTemplate = defaultTemplate
+	}
+
+	tmpl, err := template.New("log-tag").Parse(tagTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid log tag template %q: %v", tagTemplate, err)
+	}
+	buf := new(bytes.Buffer)
+	if err := tmpl.Execute(buf, &ctx); err != nil {
+		return "", fmt.Errorf("invalid log tag template %q: %v", tagTemplate, err)
+	}
+	return buf.String(), nil
+}
+
+// IsCommonLogOpt returns whether key is one of the log opts supported by
+// every logging driver: tag, labels and env.
+func IsCommonLogOpt(key string) bool {
+	switch key {
+	case "tag", "labels", "env":
+		return true
+	}
+	return false
+}
+
+// ValidateLogTag checks that the "tag" log opt, if set, is a valid
+// template.
+func ValidateLogTag(cfg map[string]string) error {
+	_, err := ParseLogTag(logger.Context{Config: cfg}, "")
+	return err
+}
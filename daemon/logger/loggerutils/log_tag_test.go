@@ -0,0 +1,39 @@
+package loggerutils
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/logger"
+)
+
+func TestParseLogTag(t *testing.T) {
+	ctx := logger.Context{
+		ContainerID:        "a7317399f3f857173c6179d44823594f8294678dea9999662e5c625b5a1c7657",
+		ContainerName:      "/web",
+		ContainerImageID:   "511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158",
+		ContainerImageName: "busybox:latest",
+		DaemonName:         "docker",
+	}
+	for tag, expected := range map[string]string{
+		"":                                 "docker/a7317399f3f8",
+		"{{.ImageName}}/{{.Name}}/{{.ID}}": "busybox:latest/web/a7317399f3f8",
+		"{{.FullID}}":                      ctx.ContainerID,
+		"{{.ImageID}} {{.ImageFullID}}":    "511136ea3c5a " + ctx.ContainerImageID,
+		"static":                           "static",
+	} {
+		ctx.Config = map[string]string{"tag": tag}
+		got, err := ParseLogTag(ctx, "{{.DaemonName}}/{{.ID}}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != expected {
+			t.Fatalf("expected %q for %q, got %q", expected, tag, got)
+		}
+	}
+
+	for _, tag := range []string{"{{.ID}", "{{.Unknown}}"} {
+		if err := ValidateLogTag(map[string]string{"tag": tag}); err == nil {
+			t.Fatalf("expected %q to be rejected", tag)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Package loggerutils contains helpers shared by the logging drivers.
+package loggerutils
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	// DefaultBufferSize is the number of messages kept in memory while
+	// the connection is down
+	DefaultBufferSize = 1024
+	// DefaultFlushTimeout is how long Close waits for the buffered
+	// messages to be sent
+	DefaultFlushTimeout = 5 * time.Second
+
+	minBackoff   = 100 * time.Millisecond
+	maxBackoff   = 10 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+var (
+	// ErrBufferFull is returned by Send when the buffer is full, for the
+	// first message dropped
+	ErrBufferFull = errors.New("logger: buffer is full, message dropped")
+	// ErrSenderClosed is returned by Send after the sender was closed
+	ErrSenderClosed = errors.New("logger: sender is closed")
+)
+
+// DialFunc opens a new connection to the log collector
+type DialFunc func() (net.Conn, error)
+
+// BufferedSender writes messages to a stream connection from a background
+// goroutine, so that logging does not block on the network. Messages are
+// buffered while the connection is down, and it is re-established with an
+// exponential backoff.
+type BufferedSender struct {
+	dial         DialFunc
+	queue        chan []byte
+	flushTimeout time.Duration
+
+	mu      sync.Mutex // protects closed and dropped
+	closed  bool
+	dropped int // messages dropped since the buffer got full
+	stop    chan struct{}
+	done    chan struct{}
+	// unsent is set when the sender is stopped while sending a message
+	unsent bool
+}
+
+// NewBufferedSender returns a BufferedSender buffering up to bufferSize
+// messages, which connects to the collector with dial.
+func NewBufferedSender(dial DialFunc, bufferSize int) *BufferedSender {
+	s := &BufferedSender{
+		dial:         dial,
+		queue:        make(chan []byte, bufferSize),
+		flushTimeout: DefaultFlushTimeout,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Send queues the encoded message b to be sent. It does not block; if the
+// buffer is full the message is dropped. ErrBufferFull is only returned for
+// the first message dropped, the next ones are counted and reported once
+// the buffer has room again, so that a collector being down does not log
+// an error for every message.
+func (s *BufferedSender) Send(b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrSenderClosed
+	}
+	select {
+	case s.queue <- b:
+		return nil
+	default:
+		if s.dropped++; s.dropped > 1 {
+			return nil
+		}
+		return ErrBufferFull
+	}
+}
+
+// reportDropped logs the number of messages dropped while the buffer was
+// full, if any.
+func (s *BufferedSender) reportDropped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dropped > 0 {
+		logrus.Errorf("logger: dropped %d messages while the buffer was full", s.dropped)
+		s.dropped = 0
+	}
+}
+
+// Close sends the buffered messages, waiting for the flush timeout at
+// most, and closes the connection.
+func (s *BufferedSender) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(s.flushTimeout):
+		close(s.stop)
+		<-s.done
+		dropped := len(s.queue)
+		if s.unsent {
+			dropped++
+		}
+		logrus.Errorf("logger: dropped %d messages which could not be sent in time", dropped)
+	}
+	s.reportDropped()
+	return nil
+}
+
+func (s *BufferedSender) run() {
+	defer close(s.done)
+
+	var conn net.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+
+	backoff := minBackoff
+	for b := range s.queue {
+		for {
+			select {
+			case <-s.stop:
+				s.unsent = true
+				return
+			default:
+			}
+			if conn == nil {
+				var err error
+				if conn, err = s.dial(); err != nil {
+					logrus.Debugf("logger: failed to connect, retrying in %s: %v", backoff, err)
+					conn = nil
+					if !s.wait(backoff) {
+						s.unsent = true
+						return
+					}
+					if backoff *= 2; backoff > maxBackoff {
+						backoff = maxBackoff
+					}
+					continue
+				}
+				backoff = minBackoff
+			}
+			conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if _, err := conn.Write(b); err != nil {
+				logrus.Debugf("logger: failed to send message, reconnecting: %v", err)
+				conn.Close()
+				conn = nil
+				continue
+			}
+			break
+		}
+		s.reportDropped()
+	}
+}
+
+// wait sleeps for d, and returns false if the sender was stopped meanwhile.
+func (s *BufferedSender) wait(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-s.stop:
+		return false
+	}
+}
@@ -0,0 +1,162 @@
+package loggerutils
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBufferedSenderReconnects(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	lines := make(chan string)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			// read a single line from each connection, so that the
+			// sender has to reconnect to send the next ones
+			line, err := bufio.NewReader(conn).ReadString('\n')
+			conn.Close()
+			if err == nil {
+				lines <- line
+			}
+		}
+	}()
+
+	dial := func() (net.Conn, error) {
+		return net.Dial("tcp", l.Addr().String())
+	}
+	s := NewBufferedSender(dial, DefaultBufferSize)
+	defer s.Close()
+
+	if err := s.Send([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	if line := <-lines; line != "first\n" {
+		t.Fatalf("expected first line, got %q", line)
+	}
+	// writes to the closed connection may not fail right away, keep
+	// sending until one gets through a new connection
+	timeout := time.After(10 * time.Second)
+	for {
+		if err := s.Send([]byte("again\n")); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case line := <-lines:
+			if line != "again\n" {
+				t.Fatalf("expected another line, got %q", line)
+			}
+			return
+		case <-time.After(50 * time.Millisecond):
+		case <-timeout:
+			t.Fatal("timed out waiting for the sender to reconnect")
+		}
+	}
+}
+
+func TestBufferedSenderBuffersWhileDown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	// nobody is listening until the messages are queued
+	l.Close()
+
+	dial := func() (net.Conn, error) {
+		return net.Dial("tcp", addr)
+	}
+	s := NewBufferedSender(dial, 2)
+	for _, m := range []string{"one\n", "two\n"} {
+		if err := s.Send([]byte(m)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	got := make(chan []string)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		var lines []string
+		for len(lines) < 2 {
+			line, err := rd.ReadString('\n')
+			if err != nil {
+				break
+			}
+			lines = append(lines, line)
+		}
+		got <- lines
+	}()
+
+	select {
+	case lines := <-got:
+		if len(lines) != 2 || lines[0] != "one\n" || lines[1] != "two\n" {
+			t.Fatalf("unexpected lines %q", lines)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the buffered messages")
+	}
+	s.Close()
+	if err := s.Send([]byte("three\n")); err != ErrSenderClosed {
+		t.Fatalf("expected ErrSenderClosed, got %v", err)
+	}
+}
+
+func TestBufferedSenderDropsWhenFull(t *testing.T) {
+	dialed := make(chan struct{}, 1)
+	dial := func() (net.Conn, error) {
+		select {
+		case dialed <- struct{}{}:
+		default:
+		}
+		return nil, errors.New("collector is down")
+	}
+	s := NewBufferedSender(dial, 1)
+	s.flushTimeout = 10 * time.Millisecond
+
+	// the first message is being sent, the second one is buffered
+	if err := s.Send([]byte("one\n")); err != nil {
+		t.Fatal(err)
+	}
+	<-dialed
+	if err := s.Send([]byte("two\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send([]byte("three\n")); err != ErrBufferFull {
+		t.Fatalf("expected ErrBufferFull, got %v", err)
+	}
+	// only the first dropped message is reported as an error
+	if err := s.Send([]byte("four\n")); err != nil {
+		t.Fatalf("expected the next dropped message to be counted, got %v", err)
+	}
+	if s.dropped != 2 {
+		t.Fatalf("expected 2 dropped messages, got %d", s.dropped)
+	}
+
+	s.Close()
+	if !s.unsent {
+		t.Fatal("expected the message being sent to be counted as dropped")
+	}
+	if s.dropped != 0 {
+		t.Fatalf("expected the dropped messages to be reported on close, got %d", s.dropped)
+	}
+}
@@ -3,14 +3,14 @@
 package syslog
 
 import (
+	"bytes"
 	"fmt"
-	"io"
 	"log/syslog"
-	"os"
-	"path"
+	"sort"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/logger"
+	"github.com/docker/docker/daemon/logger/loggerutils"
 )
 
 const name = "syslog"
@@ -23,11 +23,17 @@ func init() {
 	if err := logger.RegisterLogDriver(name, New); err != nil {
 		logrus.Fatal(err)
 	}
+	if err := logger.RegisterLogOptValidator(name, ValidateLogOpt); err != nil {
+		logrus.Fatal(err)
+	}
 }
 
 func New(ctx logger.Context) (logger.Logger, error) {
-	tag := ctx.ContainerID[:12]
-	log, err := syslog.New(syslog.LOG_DAEMON, fmt.Sprintf("%s/%s", path.Base(os.Args[0]), tag))
+	tag, err := loggerutils.ParseLogTag(ctx, "{{.DaemonName}}/{{.ID}}")
+	if err != nil {
+		return nil, err
+	}
+	log, err := syslog.New(syslog.LOG_DAEMON, tag)
 	if err != nil {
 		return nil, err
 	}
@@ -38,10 +44,11 @@ func New(ctx logger.Context) (logger.Logger, error) {
 }
 
 func (s *Syslog) Log(msg *logger.Message) error {
+	line := formatAttrs(msg.Attrs) + string(msg.Line)
 	if msg.Source == "stderr" {
-		return s.writer.Err(string(msg.Line))
+		return s.writer.Err(line)
 	}
-	return s.writer.Info(string(msg.Line))
+	return s.writer.Info(line)
 }
 
 func (s *Syslog) Close() error {
@@ -52,6 +59,37 @@ func (s *Syslog) Name() string {
 	return name
 }
 
-func (s *Syslog) GetReader() (io.Reader, error) {
-	return nil, logger.ReadLogsNotSupported
+// ValidateLogOpt looks for the tag, labels and env log options, the only
+// ones supported by the syslog driver.
+func ValidateLogOpt(cfg map[string]string) error {
+	for key := range cfg {
+		if !loggerutils.IsCommonLogOpt(key) {
+			return fmt.Errorf("unknown log opt '%s' for syslog log driver", key)
+		}
+	}
+	return loggerutils.ValidateLogTag(cfg)
+}
+
+// formatAttrs formats the extra attributes of a message as a sorted list of
+// key="value" pairs in brackets, to be prepended to the logged line.
+func formatAttrs(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%s=%q", k, attrs[k])
+	}
+	buf.WriteString("] ")
+	return buf.String()
 }
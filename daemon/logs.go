@@ -1,21 +1,16 @@
 package daemon
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net"
-	"os"
 	"strconv"
 	"syscall"
 	"time"
 
 	"github.com/Sirupsen/logrus"
-	"github.com/docker/docker/daemon/logger/jsonfilelog"
-	"github.com/docker/docker/pkg/jsonlog"
+	"github.com/docker/docker/daemon/logger"
 	"github.com/docker/docker/pkg/stdcopy"
-	"github.com/docker/docker/pkg/tailfile"
 	"github.com/docker/docker/pkg/timeutils"
 )
 
@@ -25,6 +20,7 @@ type ContainerLogsConfig struct {
 	Since                time.Time
 	UseStdout, UseStderr bool
 	OutStream            io.Writer
+	Stop                 <-chan bool
 }
 
 func (daemon *Daemon) ContainerLogs(name string, config *ContainerLogsConfig) error {
@@ -58,106 +54,73 @@ func (daemon *Daemon) ContainerLogs(name string, config *ContainerLogsConfig) er
 		errStream = outStream
 	}
 
-	if container.LogDriverType() != jsonfilelog.Name {
-		return fmt.Errorf("\"logs\" endpoint is supported only for \"json-file\" logging driver")
-	}
-	logDriver, err := container.getLogger()
-	cLog, err := logDriver.GetReader()
+	l, running, err := container.getLogReader()
 	if err != nil {
-		logrus.Errorf("Error reading logs: %s", err)
-	} else {
-		// json-file driver
-		if config.Tail != "all" {
-			var err error
-			lines, err = strconv.Atoi(config.Tail)
-			if err != nil {
-				logrus.Errorf("Failed to parse tail %s, error: %v, show all logs", config.Tail, err)
-				lines = -1
-			}
-		}
-
-		if lines != 0 {
-			if lines > 0 {
-				f := cLog.(*os.File)
-				ls, err := tailfile.TailFile(f, lines)
-				if err != nil {
-					return err
-				}
-				tmp := bytes.NewBuffer([]byte{})
-				for _, l := range ls {
-					fmt.Fprintf(tmp, "%s\n", l)
-				}
-				cLog = tmp
-			}
+		return err
+	}
+	if !running {
+		defer l.Close()
+	}
+	logReader, ok := l.(logger.LogReader)
+	if !ok {
+		return logger.ReadLogsNotSupported
+	}
 
-			dec := json.NewDecoder(cLog)
-			l := &jsonlog.JSONLog{}
-			for {
-				l.Reset()
-				if err := dec.Decode(l); err == io.EOF {
-					break
-				} else if err != nil {
-					logrus.Errorf("Error streaming logs: %s", err)
-					break
-				}
-				logLine := l.Log
-				if !config.Since.IsZero() && l.Created.Before(config.Since) {
-					continue
-				}
-				if config.Timestamps {
-					// format can be "" or time format, so here can't be error
-					logLine, _ = l.Format(format)
-				}
-				if l.Stream == "stdout" && config.UseStdout {
-					io.WriteString(outStream, logLine)
-				}
-				if l.Stream == "stderr" && config.UseStderr {
-					io.WriteString(errStream, logLine)
-				}
-			}
+	if config.Tail != "all" {
+		var err error
+		lines, err = strconv.Atoi(config.Tail)
+		if err != nil {
+			logrus.Errorf("Failed to parse tail %s, error: %v, show all logs", config.Tail, err)
+			lines = -1
 		}
 	}
 
-	if config.Follow && container.IsRunning() {
-		chErr := make(chan error)
-		var stdoutPipe, stderrPipe io.ReadCloser
-
-		// write an empty chunk of data (this is to ensure that the
-		// HTTP Response is sent immediatly, even if the container has
-		// not yet produced any data)
-		outStream.Write(nil)
-
-		if config.UseStdout {
-			stdoutPipe = container.StdoutLogPipe()
-			go func() {
-				logrus.Debug("logs: stdout stream begin")
-				chErr <- jsonlog.WriteLog(stdoutPipe, outStream, format, config.Since)
-				logrus.Debug("logs: stdout stream end")
-			}()
-		}
-		if config.UseStderr {
-			stderrPipe = container.StderrLogPipe()
-			go func() {
-				logrus.Debug("logs: stderr stream begin")
-				chErr <- jsonlog.WriteLog(stderrPipe, errStream, format, config.Since)
-				logrus.Debug("logs: stderr stream end")
-			}()
-		}
+	readConfig := logger.ReadConfig{
+		Since:  config.Since,
+		Tail:   lines,
+		Follow: config.Follow && running,
+	}
+	logs := logReader.ReadLogs(readConfig)
+	defer logs.Close()
 
-		err = <-chErr
-		if stdoutPipe != nil {
-			stdoutPipe.Close()
-		}
-		if stderrPipe != nil {
-			stderrPipe.Close()
-		}
-		<-chErr // wait for 2nd goroutine to exit, otherwise bad things will happen
+	// write an empty chunk of data (this is to ensure that the
+	// HTTP Response is sent immediatly, even if the container has
+	// not yet produced any data)
+	outStream.Write(nil)
 
-		if err != nil && err != io.EOF && err != io.ErrClosedPipe {
-			if e, ok := err.(*net.OpError); ok && e.Err != syscall.EPIPE {
-				logrus.Errorf("error streaming logs: %v", err)
+	for {
+		select {
+		case err := <-logs.Err:
+			logrus.Errorf("Error streaming logs: %v", err)
+			return nil
+		case <-config.Stop:
+			return nil
+		case msg, ok := <-logs.Msg:
+			if !ok {
+				select {
+				case err := <-logs.Err:
+					logrus.Errorf("Error streaming logs: %v", err)
+				default:
+				}
+				return nil
+			}
+			logLine := append(msg.Line, '\n')
+			if config.Timestamps {
+				logLine = append([]byte(msg.Timestamp.Format(format)+" "), logLine...)
+			}
+			var err error
+			if msg.Source == "stdout" && config.UseStdout {
+				_, err = outStream.Write(logLine)
+			}
+			if msg.Source == "stderr" && config.UseStderr {
+				_, err = errStream.Write(logLine)
+			}
+			if err != nil {
+				if e, ok := err.(*net.OpError); !ok || e.Err != syscall.EPIPE {
+					logrus.Errorf("error streaming logs: %v", err)
+				}
+				return nil
 			}
 		}
 	}
-	return nil
 }
@@ -0,0 +1,192 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/metrics"
+)
+
+// containerStates are the states the containers are counted by.
+var containerStates = []string{"running", "paused", "restarting", "exited", "dead"}
+
+// metricsCollector collects the metrics of the daemon and the resource
+// usage of its running containers, as received from the stats collector.
+type metricsCollector struct {
+	daemon *Daemon
+
+	mu    sync.Mutex
+	stats map[string]*execdriver.ResourceStats
+}
+
+func newMetricsCollector(daemon *Daemon) *metricsCollector {
+	return &metricsCollector{
+		daemon: daemon,
+		stats:  make(map[string]*execdriver.ResourceStats),
+	}
+}
+
+// watch subscribes to the stats of the container until it is destroyed.
+func (m *metricsCollector) watch(c *Container) {
+	updates := m.daemon.statsCollector.collect(c)
+	go func() {
+		for v := range updates {
+			m.mu.Lock()
+			m.stats[c.ID] = v.(*execdriver.ResourceStats)
+			m.mu.Unlock()
+		}
+		m.mu.Lock()
+		delete(m.stats, c.ID)
+		m.mu.Unlock()
+	}()
+}
+
+// Collect returns the number of containers by state, the number of event
+// listeners, and the resource usage of the running containers.
+func (m *metricsCollector) Collect() []*metrics.Family {
+	containers := &metrics.Family{
+		Name: "docker_containers",
+		Help: "Number of containers by state",
+		Type: metrics.GaugeType,
+	}
+	counts := make(map[string]int)
+	for _, c := range m.daemon.List() {
+		counts[c.State.StateString()]++
+	}
+	for _, state := range containerStates {
+		containers.Samples = append(containers.Samples, metrics.Sample{
+			Labels: metrics.Labels{"state": state},
+			Value:  float64(counts[state]),
+		})
+	}
+
+	subscribers := &metrics.Family{
+		Name: "docker_events_subscribers",
+		Help: "Number of listeners of the events",
+		Type: metrics.GaugeType,
+		Samples: []metrics.Sample{
+			{Value: float64(m.daemon.EventsService.SubscribersCount())},
+		},
+	}
+
+	return append([]*metrics.Family{containers, subscribers}, m.collectContainers()...)
+}
+
+type containerMetric struct {
+	name  string
+	help  string
+	typ   metrics.Type
+	value func(*execdriver.ResourceStats) float64
+}
+
+var containerMetrics = []containerMetric{
+	{"docker_container_cpu_usage_seconds_total", "Total CPU time consumed by the container", metrics.CounterType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.CpuStats.CpuUsage.TotalUsage) / 1e9
+	}},
+	{"docker_container_cpu_kernel_seconds_total", "CPU time consumed by the container in kernel mode", metrics.CounterType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.CpuStats.CpuUsage.UsageInKernelmode) / 1e9
+	}},
+	{"docker_container_cpu_user_seconds_total", "CPU time consumed by the container in user mode", metrics.CounterType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.CpuStats.CpuUsage.UsageInUsermode) / 1e9
+	}},
+	{"docker_container_cpu_throttled_seconds_total", "Time the container was throttled for", metrics.CounterType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.CpuStats.ThrottlingData.ThrottledTime) / 1e9
+	}},
+	{"docker_container_memory_usage_bytes", "Memory used by the container", metrics.GaugeType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.MemoryStats.Usage)
+	}},
+	{"docker_container_memory_max_usage_bytes", "Maximum memory used by the container", metrics.GaugeType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.MemoryStats.MaxUsage)
+	}},
+	{"docker_container_memory_limit_bytes", "Memory limit of the container", metrics.GaugeType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.MemoryLimit)
+	}},
+	{"docker_container_memory_failures_total", "Number of times the container hit its memory limit", metrics.CounterType, func(s *execdriver.ResourceStats) float64 {
+		return float64(s.CgroupStats.MemoryStats.Failcnt)
+	}},
+}
+
+// collectContainers returns the resource usage of the running containers,
+// labeled with their ID and name.
+func (m *metricsCollector) collectContainers() []*metrics.Family {
+	m.mu.Lock()
+	ids := make([]string, 0, len(m.stats))
+	stats := make(map[string]*execdriver.ResourceStats, len(m.stats))
+	for id, s := range m.stats {
+		ids = append(ids, id)
+		stats[id] = s
+	}
+	m.mu.Unlock()
+	sort.Strings(ids)
+
+	families := make(map[string]*metrics.Family)
+	add := func(name, help string, typ metrics.Type, labels metrics.Labels, value float64) {
+		f, exists := families[name]
+		if !exists {
+			f = &metrics.Family{Name: name, Help: help, Type: typ}
+			families[name] = f
+		}
+		f.Samples = append(f.Samples, metrics.Sample{Labels: labels, Value: value})
+	}
+	labels := func(c *Container, extra ...string) metrics.Labels {
+		l := metrics.Labels{"id": c.ID, "name": strings.TrimPrefix(c.Name, "/")}
+		for i := 0; i+1 < len(extra); i += 2 {
+			l[extra[i]] = extra[i+1]
+		}
+		return l
+	}
+
+	for _, id := range ids {
+		s := stats[id]
+		c, err := m.daemon.Get(id)
+		if err != nil || !c.IsRunning() || s.Stats == nil {
+			continue
+		}
+
+		if s.CgroupStats != nil {
+			for _, cm := range containerMetrics {
+				add(cm.name, cm.help, cm.typ, labels(c), cm.value(s))
+			}
+			blkio := s.CgroupStats.BlkioStats
+			for _, e := range blkio.IoServiceBytesRecursive {
+				add("docker_container_blkio_bytes_total", "Bytes transferred to and from the block devices by the container", metrics.CounterType,
+					labels(c, "device", blkioDevice(e.Major, e.Minor), "op", e.Op), float64(e.Value))
+			}
+			for _, e := range blkio.IoServicedRecursive {
+				add("docker_container_blkio_ios_total", "I/O operations performed on the block devices by the container", metrics.CounterType,
+					labels(c, "device", blkioDevice(e.Major, e.Minor), "op", e.Op), float64(e.Value))
+			}
+		}
+
+		for _, iface := range s.Interfaces {
+			for _, n := range []struct {
+				name, help string
+				value      uint64
+			}{
+				{"docker_container_network_receive_bytes_total", "Bytes received by the container", iface.RxBytes},
+				{"docker_container_network_receive_packets_total", "Packets received by the container", iface.RxPackets},
+				{"docker_container_network_receive_errors_total", "Errors receiving packets in the container", iface.RxErrors},
+				{"docker_container_network_receive_dropped_total", "Packets dropped on receipt in the container", iface.RxDropped},
+				{"docker_container_network_transmit_bytes_total", "Bytes transmitted by the container", iface.TxBytes},
+				{"docker_container_network_transmit_packets_total", "Packets transmitted by the container", iface.TxPackets},
+				{"docker_container_network_transmit_errors_total", "Errors transmitting packets in the container", iface.TxErrors},
+				{"docker_container_network_transmit_dropped_total", "Packets dropped on transmission in the container", iface.TxDropped},
+			} {
+				add(n.name, n.help, metrics.CounterType, labels(c, "interface", iface.Name), float64(n.value))
+			}
+		}
+	}
+
+	result := make([]*metrics.Family, 0, len(families))
+	for _, f := range families {
+		result = append(result, f)
+	}
+	return result
+}
+
+func blkioDevice(major, minor uint64) string {
+	return fmt.Sprintf("%d:%d", major, minor)
+}
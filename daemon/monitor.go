@@ -3,6 +3,7 @@ package daemon
 import (
 	"io"
 	"os/exec"
+	"strconv"
 	"sync"
 	"time"
 
@@ -151,6 +152,10 @@ func (m *containerMonitor) Start() error {
 		// here container.Lock is already lost
 		afterRun = true
 
+		m.container.Lock()
+		m.container.stopHealthMonitor()
+		m.container.Unlock()
+
 		m.resetMonitor(err == nil && exitStatus.ExitCode == 0)
 
 		if m.shouldRestart(exitStatus.ExitCode) {
@@ -158,7 +163,7 @@ func (m *containerMonitor) Start() error {
 			if exitStatus.OOMKilled {
 				m.container.LogEvent("oom")
 			}
-			m.container.LogEvent("die")
+			m.container.logEventWithAttributes("die", map[string]string{"exitCode": strconv.Itoa(exitStatus.ExitCode)})
 			m.resetContainer(true)
 
 			// sleep with a small time increment between each restart to help avoid issues cased by quickly
@@ -175,7 +180,7 @@ func (m *containerMonitor) Start() error {
 		if exitStatus.OOMKilled {
 			m.container.LogEvent("oom")
 		}
-		m.container.LogEvent("die")
+		m.container.logEventWithAttributes("die", map[string]string{"exitCode": strconv.Itoa(exitStatus.ExitCode)})
 		m.resetContainer(true)
 		return err
 	}
@@ -212,6 +217,14 @@ func (m *containerMonitor) waitForNextRestart() {
 	}
 }
 
+// setRestartPolicy changes the restart policy applied the next time the
+// container's process exits
+func (m *containerMonitor) setRestartPolicy(policy runconfig.RestartPolicy) {
+	m.mux.Lock()
+	m.restartPolicy = policy
+	m.mux.Unlock()
+}
+
 // shouldRestart checks the restart policy and applies the rules to determine if
 // the container's process should be restarted
 func (m *containerMonitor) shouldRestart(exitCode int) bool {
@@ -253,6 +266,7 @@ func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid
 	}
 
 	m.container.setRunning(pid)
+	m.container.initHealthMonitor()
 
 	// signal that the process has started
 	// close channel only if not closed
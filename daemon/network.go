@@ -0,0 +1,431 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/libnetwork"
+	"github.com/docker/libnetwork/netlabel"
+	"github.com/docker/libnetwork/netutils"
+	"github.com/docker/libnetwork/options"
+)
+
+const (
+	defaultNetworkDriver = "bridge"
+	defaultBridgeName    = "docker0"
+)
+
+var (
+	validNetworkNamePattern = regexp.MustCompile(`^` + validContainerNameChars + `+$`)
+
+	// ErrNetworkDisabled is returned when a network is managed while the
+	// networking of the daemon is disabled.
+	ErrNetworkDisabled = errors.New("Networking is disabled in the daemon")
+)
+
+// networkConfig is the configuration of a network created by the user. It is
+// saved on disk so that the network is created again when the daemon starts.
+type networkConfig struct {
+	Name    string
+	Driver  string
+	Bridge  string
+	Subnet  string
+	Gateway string
+}
+
+// networkStore keeps the configurations of the networks created by the user
+// in a file.
+type networkStore struct {
+	path    string
+	configs map[string]*networkConfig
+	sync.Mutex
+}
+
+func newNetworkStore(path string) (*networkStore, error) {
+	store := &networkStore{path: path, configs: make(map[string]*networkConfig)}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return store, nil
+		}
+		return nil, err
+	}
+
+	var configs []*networkConfig
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("Error reading the networks from %s: %v", path, err)
+	}
+	for _, config := range configs {
+		store.configs[config.Name] = config
+	}
+	return store, nil
+}
+
+func (store *networkStore) save() error {
+	configs := make([]*networkConfig, 0, len(store.configs))
+	for _, config := range store.configs {
+		configs = append(configs, config)
+	}
+
+	data, err := json.Marshal(configs)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(store.path, data, 0600)
+}
+
+func (store *networkStore) add(config *networkConfig) error {
+	store.Lock()
+	defer store.Unlock()
+
+	store.configs[config.Name] = config
+	if err := store.save(); err != nil {
+		delete(store.configs, config.Name)
+		return err
+	}
+	return nil
+}
+
+func (store *networkStore) remove(name string) error {
+	store.Lock()
+	defer store.Unlock()
+
+	config, ok := store.configs[name]
+	if !ok {
+		return nil
+	}
+	delete(store.configs, name)
+	if err := store.save(); err != nil {
+		store.configs[name] = config
+		return err
+	}
+	return nil
+}
+
+func (store *networkStore) get(name string) *networkConfig {
+	store.Lock()
+	defer store.Unlock()
+
+	return store.configs[name]
+}
+
+func (store *networkStore) list() []*networkConfig {
+	store.Lock()
+	defer store.Unlock()
+
+	configs := make([]*networkConfig, 0, len(store.configs))
+	for _, config := range store.configs {
+		configs = append(configs, config)
+	}
+	return configs
+}
+
+// restoreNetworks creates the networks created by the user before the daemon
+// was restarted.
+func (daemon *Daemon) restoreNetworks() {
+	for _, config := range daemon.networks.list() {
+		if _, err := daemon.createNetwork(config); err != nil {
+			logrus.Errorf("Error restoring network %s: %v", config.Name, err)
+		}
+	}
+}
+
+// NetworkCreate creates a network with the given name and driver. The subnet
+// and the gateway of the network can be chosen with the bridge driver, or are
+// picked by the daemon if empty.
+func (daemon *Daemon) NetworkCreate(name, driver, subnet, gateway string) (*types.NetworkResource, error) {
+	if daemon.netController == nil {
+		return nil, ErrNetworkDisabled
+	}
+	if !validNetworkNamePattern.MatchString(name) {
+		return nil, fmt.Errorf("Invalid network name (%s), only %s are allowed", name, validContainerNameChars)
+	}
+	if !runconfig.NetworkMode(name).IsUserDefined() {
+		return nil, fmt.Errorf("Conflict. %s is a pre-defined network and cannot be created", name)
+	}
+	if _, err := daemon.netController.NetworkByName(name); err == nil {
+		return nil, fmt.Errorf("Conflict. A network named %s already exists", name)
+	}
+
+	if driver == "" {
+		driver = defaultNetworkDriver
+	}
+	config := &networkConfig{Name: name, Driver: driver}
+	if driver == defaultNetworkDriver {
+		config.Bridge = "br-" + stringid.GenerateRandomID()[:12]
+		config.Subnet = subnet
+		config.Gateway = gateway
+	} else if subnet != "" || gateway != "" {
+		return nil, fmt.Errorf("The subnet and the gateway can only be set with the %s driver", defaultNetworkDriver)
+	}
+
+	n, err := daemon.createNetwork(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := daemon.networks.add(config); err != nil {
+		if err := n.Delete(); err != nil {
+			logrus.Errorf("Error deleting network %s: %v", name, err)
+		}
+		return nil, err
+	}
+
+	daemon.logNetworkEvent(n, "create", nil)
+	return daemon.networkToAPIType(n), nil
+}
+
+func (daemon *Daemon) createNetwork(config *networkConfig) (libnetwork.Network, error) {
+	if config.Driver != defaultNetworkDriver {
+		return daemon.netController.NewNetwork(config.Driver, config.Name)
+	}
+
+	netOption := options.Generic{
+		"BridgeName":            config.Bridge,
+		"AllowNonDefaultBridge": true,
+		"Mtu":                   daemon.config.Mtu,
+		"EnableIPTables":        daemon.config.Bridge.EnableIPTables,
+		"EnableIPMasquerade":    daemon.config.Bridge.EnableIPMasq,
+		"EnableICC":             daemon.config.Bridge.InterContainerCommunication,
+		"EnableUserlandProxy":   daemon.config.Bridge.EnableUserlandProxy,
+	}
+
+	if config.Subnet != "" {
+		ip, subnet, err := net.ParseCIDR(config.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid subnet %s: %v", config.Subnet, err)
+		}
+		if ip.To4() == nil {
+			return nil, fmt.Errorf("Invalid subnet %s: only IPv4 subnets are supported", config.Subnet)
+		}
+
+		// The gateway is the address of the bridge, the first address of the
+		// subnet by default.
+		gateway := make(net.IP, len(subnet.IP))
+		copy(gateway, subnet.IP)
+		gateway[len(gateway)-1]++
+		if config.Gateway != "" {
+			if gateway = net.ParseIP(config.Gateway); gateway == nil {
+				return nil, fmt.Errorf("Invalid gateway %s", config.Gateway)
+			}
+		}
+		if !subnet.Contains(gateway) {
+			return nil, fmt.Errorf("Invalid gateway %s: it is not in the subnet %s", gateway, subnet)
+		}
+
+		netOption["AddressIPv4"] = &net.IPNet{IP: gateway.To4(), Mask: subnet.Mask}
+	} else if config.Gateway != "" {
+		return nil, fmt.Errorf("Invalid gateway %s: a gateway can only be set with a subnet", config.Gateway)
+	}
+
+	if daemon.config.Bridge.DefaultIP != nil {
+		netOption["DefaultBindingIP"] = daemon.config.Bridge.DefaultIP
+	}
+
+	return daemon.netController.NewNetwork(config.Driver, config.Name,
+		libnetwork.NetworkOptionGeneric(options.Generic{
+			netlabel.GenericData: netOption,
+		}))
+}
+
+// Networks returns all the networks of the daemon, sorted by name.
+func (daemon *Daemon) Networks() ([]*types.NetworkResource, error) {
+	if daemon.netController == nil {
+		return nil, ErrNetworkDisabled
+	}
+
+	list := []*types.NetworkResource{}
+	for _, n := range daemon.netController.Networks() {
+		list = append(list, daemon.networkToAPIType(n))
+	}
+	sort.Sort(networksByName(list))
+	return list, nil
+}
+
+// NetworkInspect returns the network with the given name or ID.
+func (daemon *Daemon) NetworkInspect(name string) (*types.NetworkResource, error) {
+	n, err := daemon.findNetwork(name)
+	if err != nil {
+		return nil, err
+	}
+	return daemon.networkToAPIType(n), nil
+}
+
+// NetworkRm removes the network with the given name or ID. It fails if
+// containers are connected to the network.
+func (daemon *Daemon) NetworkRm(name string) error {
+	n, err := daemon.findNetwork(name)
+	if err != nil {
+		return err
+	}
+	if !runconfig.NetworkMode(n.Name()).IsUserDefined() {
+		return fmt.Errorf("Conflict. %s is a pre-defined network and cannot be removed", n.Name())
+	}
+
+	var containers []string
+	for _, c := range daemon.List() {
+		if c.isConnectedTo(n.Name()) {
+			containers = append(containers, c.Name[1:])
+		}
+	}
+	if len(containers) > 0 {
+		return fmt.Errorf("Conflict. The network %s is in use by containers %s", name, strings.Join(containers, ", "))
+	}
+
+	if err := n.Delete(); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "destroy", nil)
+	return daemon.networks.remove(n.Name())
+}
+
+// NetworkConnect connects the container to the network, with the given
+// aliases. A running container joins it right away, a stopped container when
+// it is started.
+func (daemon *Daemon) NetworkConnect(name, containerName string, aliases []string) error {
+	n, err := daemon.findNetwork(name)
+	if err != nil {
+		return err
+	}
+	container, err := daemon.Get(containerName)
+	if err != nil {
+		return err
+	}
+	if err := container.ConnectToNetwork(n, aliases); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "connect", map[string]string{"container": container.ID})
+	return nil
+}
+
+// NetworkDisconnect disconnects the container from the network, which it
+// leaves right away if it is running.
+func (daemon *Daemon) NetworkDisconnect(name, containerName string) error {
+	n, err := daemon.findNetwork(name)
+	if err != nil {
+		return err
+	}
+	container, err := daemon.Get(containerName)
+	if err != nil {
+		return err
+	}
+	if err := container.DisconnectFromNetwork(n); err != nil {
+		return err
+	}
+	daemon.logNetworkEvent(n, "disconnect", map[string]string{"container": container.ID})
+	return nil
+}
+
+// logNetworkEvent logs an event about the network, with its name and its
+// driver as attributes, in addition to the given ones.
+func (daemon *Daemon) logNetworkEvent(n libnetwork.Network, action string, extra map[string]string) {
+	attributes := map[string]string{"name": n.Name(), "type": n.Type()}
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	daemon.EventsService.Log(types.NetworkEventType, action, types.EventActor{
+		ID:         n.ID(),
+		Attributes: attributes,
+	})
+}
+
+// findNetwork looks for a network by its name, its full ID or a unique
+// prefix of its ID.
+func (daemon *Daemon) findNetwork(name string) (libnetwork.Network, error) {
+	if daemon.netController == nil {
+		return nil, ErrNetworkDisabled
+	}
+	if n, err := daemon.netController.NetworkByName(name); err == nil {
+		return n, nil
+	}
+	if n, err := daemon.netController.NetworkByID(name); err == nil {
+		return n, nil
+	}
+
+	var found libnetwork.Network
+	for _, n := range daemon.netController.Networks() {
+		if name != "" && strings.HasPrefix(n.ID(), name) {
+			if found != nil {
+				return nil, fmt.Errorf("Network name %s is ambiguous", name)
+			}
+			found = n
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("No such network: %s", name)
+	}
+	return found, nil
+}
+
+// bridgeName returns the name of the bridge of the network, or an empty
+// string if it is not a bridge network.
+func (daemon *Daemon) bridgeName(n libnetwork.Network) string {
+	if n.Type() != defaultNetworkDriver {
+		return ""
+	}
+	if config := daemon.networks.get(n.Name()); config != nil {
+		return config.Bridge
+	}
+	if daemon.config.Bridge.Iface != "" {
+		return daemon.config.Bridge.Iface
+	}
+	return defaultBridgeName
+}
+
+func (daemon *Daemon) networkToAPIType(n libnetwork.Network) *types.NetworkResource {
+	resource := &types.NetworkResource{
+		Name:       n.Name(),
+		Id:         n.ID(),
+		Driver:     n.Type(),
+		Containers: make(map[string]types.EndpointResource),
+	}
+
+	if bridge := daemon.bridgeName(n); bridge != "" {
+		if addr, _, err := netutils.GetIfaceAddr(bridge); err == nil {
+			if ipNet, ok := addr.(*net.IPNet); ok {
+				subnet := &net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}
+				resource.Subnet = subnet.String()
+				resource.Gateway = ipNet.IP.String()
+			}
+		}
+	}
+
+	for _, c := range daemon.List() {
+		settings, ok := c.endpointSettings(n.Name())
+		if !ok || settings.EndpointID == "" {
+			continue
+		}
+		endpoint := types.EndpointResource{
+			Name:       c.Name[1:],
+			EndpointID: settings.EndpointID,
+			MacAddress: settings.MacAddress,
+		}
+		if settings.IPAddress != "" {
+			endpoint.IPv4Address = fmt.Sprintf("%s/%d", settings.IPAddress, settings.IPPrefixLen)
+		}
+		if settings.GlobalIPv6Address != "" {
+			endpoint.IPv6Address = fmt.Sprintf("%s/%d", settings.GlobalIPv6Address, settings.GlobalIPv6PrefixLen)
+		}
+		resource.Containers[c.ID] = endpoint
+	}
+
+	return resource
+}
+
+type networksByName []*types.NetworkResource
+
+func (r networksByName) Len() int           { return len(r) }
+func (r networksByName) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r networksByName) Less(i, j int) bool { return r[i].Name < r[j].Name }
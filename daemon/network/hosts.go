@@ -0,0 +1,74 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/docker/libnetwork/etchosts"
+)
+
+// AddHosts appends the records to the existing hosts file at path.
+func AddHosts(path string, recs []etchosts.Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	content := bytes.NewBuffer(nil)
+	for _, r := range recs {
+		if _, err := r.WriteTo(content); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(content.Bytes())
+	return err
+}
+
+// DeleteHosts removes the records from the hosts file at path.
+func DeleteHosts(path string, recs []etchosts.Record) error {
+	if len(recs) == 0 {
+		return nil
+	}
+
+	old, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	s := bufio.NewScanner(bytes.NewReader(old))
+	eol := []byte{'\n'}
+loop:
+	for s.Scan() {
+		b := s.Bytes()
+		if len(b) == 0 {
+			continue
+		}
+
+		if b[0] == '#' {
+			buf.Write(b)
+			buf.Write(eol)
+			continue
+		}
+		for _, r := range recs {
+			if string(b) == r.IP+"\t"+r.Hosts {
+				continue loop
+			}
+		}
+		buf.Write(b)
+		buf.Write(eol)
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
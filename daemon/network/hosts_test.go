@@ -0,0 +1,55 @@
+package network
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/docker/libnetwork/etchosts"
+)
+
+func TestAddDeleteHosts(t *testing.T) {
+	file, err := ioutil.TempFile("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if err := etchosts.Build(file.Name(), "", "", "", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	records := []etchosts.Record{
+		{Hosts: "testhostname1", IP: "1.1.1.1"},
+		{Hosts: "testhostname2", IP: "2.2.2.2"},
+	}
+	if err := AddHosts(file.Name(), records); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := "1.1.1.1\ttesthostname1\n2.2.2.2\ttesthostname2\n"; !bytes.Contains(content, []byte(expected)) {
+		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
+	}
+
+	if err := DeleteHosts(file.Name(), records[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err = ioutil.ReadFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if unexpected := "1.1.1.1\ttesthostname1\n"; bytes.Contains(content, []byte(unexpected)) {
+		t.Fatalf("Did not expect to find '%s' got '%s'", unexpected, content)
+	}
+	if expected := "2.2.2.2\ttesthostname2\n"; !bytes.Contains(content, []byte(expected)) {
+		t.Fatalf("Expected to find '%s' got '%s'", expected, content)
+	}
+}
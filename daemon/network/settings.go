@@ -21,9 +21,27 @@ type Settings struct {
 	LinkLocalIPv6PrefixLen int
 	MacAddress             string
 	NetworkID              string
+	Networks               map[string]*EndpointSettings // The networks of the container, by name
 	PortMapping            map[string]map[string]string // Deprecated
 	Ports                  nat.PortMap
 	SandboxKey             string
 	SecondaryIPAddresses   []Address
 	SecondaryIPv6Addresses []Address
 }
+
+// EndpointSettings are the settings of the endpoint of a container in one of
+// its networks. They are empty while the container is not running.
+type EndpointSettings struct {
+	EndpointID          string
+	Gateway             string
+	GlobalIPv6Address   string
+	GlobalIPv6PrefixLen int
+	IPAddress           string
+	IPPrefixLen         int
+	IPv6Gateway         string
+	MacAddress          string
+	NetworkID           string
+	// other names of the container in the network, set when it is
+	// connected to it
+	Aliases []string `json:",omitempty"`
+}
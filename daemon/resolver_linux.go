@@ -0,0 +1,164 @@
+// +build linux
+
+package daemon
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"runtime"
+	"strings"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/dnsserver"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/libnetwork/resolvconf"
+	"github.com/vishvananda/netns"
+)
+
+// resolverIP is the address of the embedded DNS server in the network
+// namespace of a container.
+const resolverIP = "127.0.0.11"
+
+// The DNS servers the embedded DNS servers forward to when neither the
+// container, the daemon nor the host have any.
+var defaultResolverUpstreams = []string{"8.8.8.8", "8.8.4.4"}
+
+// useResolver returns whether the container resolves names with an embedded
+// DNS server, rather than with the entries written in its /etc/hosts.
+func (container *Container) useResolver() bool {
+	mode := container.hostConfig.NetworkMode
+	return container.daemon.config.EmbeddedDNS && !container.Config.NetworkDisabled &&
+		mode.IsPrivate() && !mode.IsNone()
+}
+
+// startResolver starts the embedded DNS server of the container in its
+// network namespace.
+func (container *Container) startResolver() error {
+	udp, tcp, err := listenInNamespace(container.NetworkSettings.SandboxKey, net.JoinHostPort(resolverIP, "53"))
+	if err != nil {
+		return err
+	}
+
+	container.resolver = dnsserver.New(container.lookupName, container.resolverUpstreams(), dnsserver.DefaultUpstreamPort)
+	container.resolver.Serve(udp, tcp)
+	return nil
+}
+
+func (container *Container) stopResolver() {
+	if container.resolver == nil {
+		return
+	}
+	if err := container.resolver.Close(); err != nil {
+		logrus.Errorf("Error stopping the embedded DNS server of %s: %v", container.ID, err)
+	}
+	container.resolver = nil
+}
+
+// resolverUpstreams returns the DNS servers the embedded DNS server of the
+// container forwards the queries it cannot answer to.
+func (container *Container) resolverUpstreams() []string {
+	if len(container.hostConfig.Dns) > 0 {
+		return container.hostConfig.Dns
+	}
+	if len(container.daemon.config.Dns) > 0 {
+		return container.daemon.config.Dns
+	}
+
+	// the queries are forwarded from the namespace of the daemon, so the
+	// DNS servers of the host on its loopback interface can be used
+	resolvConf, err := resolvconf.Get()
+	if err != nil {
+		logrus.Errorf("Error reading the DNS servers of the host: %v", err)
+	}
+	if upstreams := resolvconf.GetNameservers(resolvConf); len(upstreams) > 0 {
+		return upstreams
+	}
+	return defaultResolverUpstreams
+}
+
+// lookupName returns the addresses of a linked container, by its alias, name
+// or hostname, or of a container sharing a network created by the user, by
+// its name or its aliases in that network.
+func (container *Container) lookupName(name string) []net.IP {
+	children, err := container.daemon.Children(container.Name)
+	if err != nil {
+		logrus.Errorf("Error looking up %s for %s: %v", name, container.Name, err)
+	}
+	for linkAlias, child := range children {
+		_, alias := path.Split(linkAlias)
+		if strings.EqualFold(name, alias) || strings.EqualFold(name, child.Name[1:]) || strings.EqualFold(name, child.Config.Hostname) {
+			return parseIPs(child.addresses())
+		}
+	}
+
+	// the aliases of a peer only resolve in the network it was connected
+	// to with them, to its address in that network
+	for _, n := range container.networkNames() {
+		if !runconfig.NetworkMode(n).IsUserDefined() {
+			continue
+		}
+		for peer, settings := range container.networkPeers(n) {
+			if strings.EqualFold(name, peer.Name[1:]) || hasAlias(settings.Aliases, name) {
+				return parseIPs(settings.IPAddress, settings.GlobalIPv6Address)
+			}
+		}
+	}
+
+	return nil
+}
+
+func hasAlias(aliases []string, name string) bool {
+	for _, alias := range aliases {
+		if strings.EqualFold(name, alias) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseIPs(addrs ...string) []net.IP {
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// listenInNamespace listens on the UDP and TCP address in the network
+// namespace at path. The sockets stay in the namespace once created.
+func listenInNamespace(path, addr string) (net.PacketConn, net.Listener, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	origns, err := netns.Get()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer origns.Close()
+
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed get network namespace %q: %v", path, err)
+	}
+	defer f.Close()
+
+	if err := netns.Set(netns.NsHandle(f.Fd())); err != nil {
+		return nil, nil, err
+	}
+	defer netns.Set(origns)
+
+	udp, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	tcp, err := net.Listen("tcp", addr)
+	if err != nil {
+		udp.Close()
+		return nil, nil, err
+	}
+	return udp, tcp, nil
+}
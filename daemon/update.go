@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/runconfig"
+)
+
+// ContainerUpdate updates the resources and the restart policy of a
+// container. The resources of a running container are changed right away.
+func (daemon *Daemon) ContainerUpdate(name string, updateConfig *runconfig.UpdateConfig) ([]string, error) {
+	container, err := daemon.Get(name)
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := container.Update(updateConfig)
+	if err != nil {
+		return warnings, fmt.Errorf("Cannot update container %s: %s", name, err)
+	}
+	container.LogEvent("update")
+
+	return warnings, nil
+}
+
+// Update applies the given update to the host config of the container, which
+// is saved to disk, and to the cgroups of the container if it is running.
+func (container *Container) Update(updateConfig *runconfig.UpdateConfig) ([]string, error) {
+	container.Lock()
+	defer container.Unlock()
+
+	hostConfig := *container.hostConfig
+	updateConfig.Apply(&hostConfig)
+	warnings, err := container.daemon.verifyHostConfig(&hostConfig)
+	if err != nil {
+		return warnings, err
+	}
+
+	if container.Running && container.command != nil {
+		resources := *container.command.Resources
+		resources.Memory = hostConfig.Memory
+		resources.MemorySwap = hostConfig.MemorySwap
+		resources.CpuShares = hostConfig.CpuShares
+		resources.CpuPeriod = hostConfig.CpuPeriod
+		resources.CpuQuota = hostConfig.CpuQuota
+		resources.CpusetCpus = hostConfig.CpusetCpus
+		resources.CpusetMems = hostConfig.CpusetMems
+		resources.BlkioWeight = hostConfig.BlkioWeight
+
+		previous := container.command.Resources
+		container.command.Resources = &resources
+		if err := container.daemon.execDriver.Update(container.command); err != nil {
+			container.command.Resources = previous
+			return warnings, err
+		}
+	}
+
+	if container.monitor != nil {
+		container.monitor.setRestartPolicy(hostConfig.RestartPolicy)
+	}
+	container.hostConfig = &hostConfig
+
+	return warnings, container.toDisk()
+}
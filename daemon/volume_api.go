@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/volumes"
+)
+
+var acceptedVolumeFilterTags = map[string]struct{}{
+	"dangling": {},
+	"name":     {},
+	"label":    {},
+}
+
+// VolumeCreate creates a volume with the given name and labels, with the
+// given volume driver or the local driver. The volume is given its ID as name
+// if name is empty.
+func (daemon *Daemon) VolumeCreate(name, driverName string, labels map[string]string) (*types.Volume, error) {
+	v, err := daemon.volumes.Create(name, driverName, labels)
+	if err != nil {
+		return nil, err
+	}
+	daemon.logVolumeEvent(v, "create")
+	return volumeToAPIType(v), nil
+}
+
+// Volumes returns the volumes matching the given filters, the bind mounts
+// excepted.
+func (daemon *Daemon) Volumes(filter string) ([]*types.Volume, error) {
+	volumeFilters, err := filters.FromParam(filter)
+	if err != nil {
+		return nil, err
+	}
+	for name := range volumeFilters {
+		if _, ok := acceptedVolumeFilterTags[name]; !ok {
+			return nil, fmt.Errorf("Invalid filter '%s'", name)
+		}
+	}
+
+	var filtDangling *bool
+	for _, value := range volumeFilters["dangling"] {
+		dangling := strings.ToLower(value) == "true" || value == "1"
+		filtDangling = &dangling
+	}
+
+	list := []*types.Volume{}
+	for _, v := range daemon.volumes.List() {
+		if filtDangling != nil && (len(v.Containers()) == 0) != *filtDangling {
+			continue
+		}
+		if !volumeFilters.Match("name", v.DisplayName()) {
+			continue
+		}
+		if !volumeFilters.MatchKVList("label", v.Labels) {
+			continue
+		}
+		list = append(list, volumeToAPIType(v))
+	}
+	return list, nil
+}
+
+// VolumeInspect returns the volume with the given name or ID.
+func (daemon *Daemon) VolumeInspect(name string) (*types.Volume, error) {
+	v, err := daemon.volumes.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	return volumeToAPIType(v), nil
+}
+
+// VolumeRm removes the volume with the given name or ID, and its data. It
+// fails if the volume is used by a container.
+func (daemon *Daemon) VolumeRm(name string) error {
+	v, err := daemon.volumes.Lookup(name)
+	if err != nil {
+		return err
+	}
+	if containers := v.Containers(); len(containers) > 0 {
+		return fmt.Errorf("Conflict. The volume %s is in use by containers %s", name, strings.Join(containers, ", "))
+	}
+	if err := daemon.volumes.Delete(v.Path); err != nil {
+		return err
+	}
+	daemon.logVolumeEvent(v, "destroy")
+	return nil
+}
+
+// logVolumeEvent logs an event about the volume, with its driver as
+// attribute.
+func (daemon *Daemon) logVolumeEvent(v *volumes.Volume, action string) {
+	daemon.EventsService.Log(types.VolumeEventType, action, types.EventActor{
+		ID:         v.DisplayName(),
+		Attributes: map[string]string{"driver": v.DriverName()},
+	})
+}
+
+func volumeToAPIType(v *volumes.Volume) *types.Volume {
+	return &types.Volume{
+		Name:       v.DisplayName(),
+		Driver:     v.DriverName(),
+		Mountpoint: v.Path,
+		Labels:     v.Labels,
+	}
+}
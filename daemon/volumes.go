@@ -13,11 +13,13 @@ import (
 	"github.com/docker/docker/pkg/chrootarchive"
 	"github.com/docker/docker/pkg/mount"
 	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/volumes"
 )
 
 type volumeMount struct {
 	containerPath string
 	hostPath      string
+	name          string // name of the named volume to mount, instead of hostPath
 	writable      bool
 	copyData      bool
 	from          string
@@ -100,7 +102,15 @@ func (container *Container) createVolumes() error {
 		}
 
 		// Create the actual volume
-		v, err := container.daemon.volumes.FindOrCreateVolume(mnt.hostPath, mnt.writable)
+		var v *volumes.Volume
+		switch volumeDriver := container.hostConfig.VolumeDriver; {
+		case mnt.name != "":
+			v, err = container.daemon.volumes.FindOrCreateNamedVolume(mnt.name, volumeDriver, mnt.writable)
+		case mnt.hostPath == "" && volumeDriver != "":
+			v, err = container.daemon.volumes.Create("", volumeDriver, nil)
+		default:
+			v, err = container.daemon.volumes.FindOrCreateVolume(mnt.hostPath, mnt.writable)
+		}
 		if err != nil {
 			return err
 		}
@@ -112,7 +122,8 @@ func (container *Container) createVolumes() error {
 			container.AppliedVolumesFrom[mnt.from] = struct{}{}
 		}
 
-		if mnt.writable && mnt.copyData {
+		// the volumes of the drivers are only available while mounted
+		if mnt.writable && mnt.copyData && v.Driver == "" {
 			// Copy whatever is in the container at the containerPath to the volume
 			copyExistingContents(containerMntPath, v.Path)
 		}
@@ -189,12 +200,22 @@ func parseBindMountSpec(spec string) (*volumeMount, error) {
 		return nil, fmt.Errorf("Invalid volume specification: %s", spec)
 	}
 
+	mnt.containerPath = filepath.Clean(mnt.containerPath)
+
 	if !filepath.IsAbs(mnt.hostPath) {
-		return nil, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute.", mnt.hostPath)
+		// a relative path is the name of a named volume, whose data is
+		// initialized with the content of the container like the volumes
+		// of the image
+		if !volumes.IsValidName(mnt.hostPath) {
+			return nil, fmt.Errorf("cannot bind mount volume: %s volume paths must be absolute.", mnt.hostPath)
+		}
+		mnt.name = mnt.hostPath
+		mnt.hostPath = ""
+		mnt.copyData = true
+		return mnt, nil
 	}
 
 	mnt.hostPath = filepath.Clean(mnt.hostPath)
-	mnt.containerPath = filepath.Clean(mnt.containerPath)
 	return mnt, nil
 }
 
@@ -279,12 +300,39 @@ func copyExistingContents(source, destination string) error {
 	return copyOwnership(source, destination)
 }
 
-func (container *Container) mountVolumes() error {
+// mountVolumes mounts the volumes of the container in its root filesystem.
+// If it fails, the volumes it mounted are unmounted, otherwise they are
+// unmounted by unmountVolumes.
+func (container *Container) mountVolumes() (err error) {
+	var (
+		mounted       []string
+		driverVolumes []*volumes.Volume
+	)
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, destPath := range mounted {
+			if err := mount.ForceUnmount(destPath); err != nil {
+				logrus.Errorf("error while unmounting volumes %s: %v", destPath, err)
+			}
+		}
+		for _, v := range driverVolumes {
+			if err := v.Unmount(); err != nil {
+				logrus.Errorf("error while unmounting volume %s: %v", v.DisplayName(), err)
+			}
+		}
+	}()
+
 	for dest, source := range container.Volumes {
 		v := container.daemon.volumes.Get(source)
 		if v == nil {
 			return fmt.Errorf("could not find volume for %s:%s, impossible to mount", source, dest)
 		}
+		if err := v.Mount(); err != nil {
+			return err
+		}
+		driverVolumes = append(driverVolumes, v)
 
 		destPath, err := container.GetResourcePath(dest)
 		if err != nil {
@@ -294,6 +342,7 @@ func (container *Container) mountVolumes() error {
 		if err := mount.Mount(source, destPath, "bind", "rbind,rw"); err != nil {
 			return fmt.Errorf("error while mounting volume %s: %v", source, err)
 		}
+		mounted = append(mounted, destPath)
 	}
 
 	for _, mnt := range container.specialMounts() {
@@ -304,12 +353,13 @@ func (container *Container) mountVolumes() error {
 		if err := mount.Mount(mnt.Source, destPath, "bind", "bind,rw"); err != nil {
 			return fmt.Errorf("error while mounting volume %s: %v", mnt.Source, err)
 		}
+		mounted = append(mounted, destPath)
 	}
 	return nil
 }
 
 func (container *Container) unmountVolumes() {
-	for dest := range container.Volumes {
+	for dest, source := range container.Volumes {
 		destPath, err := container.GetResourcePath(dest)
 		if err != nil {
 			logrus.Errorf("error while unmounting volumes %s: %v", destPath, err)
@@ -319,6 +369,11 @@ func (container *Container) unmountVolumes() {
 			logrus.Errorf("error while unmounting volumes %s: %v", destPath, err)
 			continue
 		}
+		if v := container.daemon.volumes.Get(source); v != nil {
+			if err := v.Unmount(); err != nil {
+				logrus.Errorf("error while unmounting volume %s: %v", v.DisplayName(), err)
+			}
+		}
 	}
 
 	for _, mnt := range container.specialMounts() {
@@ -332,3 +387,29 @@ func (container *Container) unmountVolumes() {
 		}
 	}
 }
+
+// mountDriverVolumes asks the volume drivers to mount the volumes of the
+// container before it starts. They are unmounted by unmountDriverVolumes when
+// it stops.
+func (container *Container) mountDriverVolumes() error {
+	for _, source := range container.Volumes {
+		v := container.daemon.volumes.Get(source)
+		if v == nil || v.Driver == "" {
+			continue
+		}
+		if err := v.Mount(); err != nil {
+			return err
+		}
+		container.driverVolumes = append(container.driverVolumes, v)
+	}
+	return nil
+}
+
+func (container *Container) unmountDriverVolumes() {
+	for _, v := range container.driverVolumes {
+		if err := v.Unmount(); err != nil {
+			logrus.Errorf("error while unmounting volume %s: %v", v.DisplayName(), err)
+		}
+	}
+	container.driverVolumes = nil
+}
@@ -107,16 +107,17 @@ func mainDaemon() {
 	}
 
 	serverConfig := &apiserver.ServerConfig{
-		Logging:     true,
-		EnableCors:  daemonCfg.EnableCors,
-		CorsHeaders: daemonCfg.CorsHeaders,
-		Version:     dockerversion.VERSION,
-		SocketGroup: daemonCfg.SocketGroup,
-		Tls:         *flTls,
-		TlsVerify:   *flTlsVerify,
-		TlsCa:       *flCa,
-		TlsCert:     *flCert,
-		TlsKey:      *flKey,
+		Logging:       true,
+		EnableCors:    daemonCfg.EnableCors,
+		CorsHeaders:   daemonCfg.CorsHeaders,
+		EnableMetrics: daemonCfg.EnableMetrics,
+		Version:       dockerversion.VERSION,
+		SocketGroup:   daemonCfg.SocketGroup,
+		Tls:           *flTls,
+		TlsVerify:     *flTlsVerify,
+		TlsCa:         *flCa,
+		TlsCert:       *flCert,
+		TlsKey:        *flKey,
 	}
 
 	api := apiserver.New(serverConfig)
@@ -31,7 +31,7 @@ var (
 		{"attach", "Attach to a running container"},
 		{"build", "Build an image from a Dockerfile"},
 		{"commit", "Create a new image from a container's changes"},
-		{"cp", "Copy files/folders from a container's filesystem to the host path"},
+		{"cp", "Copy files/folders between a container and the local filesystem"},
 		{"create", "Create a new container"},
 		{"diff", "Inspect changes on a container's filesystem"},
 		{"events", "Get real time events from the server"},
@@ -47,6 +47,7 @@ var (
 		{"login", "Register or log in to a Docker registry server"},
 		{"logout", "Log out from a Docker registry server"},
 		{"logs", "Fetch the logs of a container"},
+		{"network", "Manage Docker networks"},
 		{"port", "Lookup the public-facing port that is NAT-ed to PRIVATE_PORT"},
 		{"pause", "Pause all processes within a container"},
 		{"ps", "List containers"},
@@ -65,7 +66,9 @@ var (
 		{"tag", "Tag an image into a repository"},
 		{"top", "Lookup the running processes of a container"},
 		{"unpause", "Unpause a paused container"},
+		{"update", "Update the resources and the restart policy of containers"},
 		{"version", "Show the Docker version information"},
+		{"volume", "Manage Docker volumes"},
 		{"wait", "Block until a container stops, then print its exit code"},
 	}
 )
@@ -0,0 +1,264 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/pkg/progressreader"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/registry"
+)
+
+// DefaultMaxConcurrentDownloads is the default number of layers downloaded
+// at the same time by all the pulls.
+const DefaultMaxConcurrentDownloads = 3
+
+// downloadManager bounds the number of layers downloaded at the same time,
+// and keeps the downloaded blobs for the pulls sharing them. The downloads
+// themselves are deduplicated through the pulling pool of the store.
+type downloadManager struct {
+	slots chan struct{}
+
+	mu    sync.Mutex
+	blobs map[digest.Digest]*blobDownload
+}
+
+// blobDownload is a blob downloaded to a temporary file, shared by the
+// pulls of the layers with that blob. The file is removed once all of them
+// registered their layers.
+type blobDownload struct {
+	path     string
+	length   int64
+	verified bool
+
+	refs int
+}
+
+func newDownloadManager(max int) *downloadManager {
+	if max <= 0 {
+		max = DefaultMaxConcurrentDownloads
+	}
+	return &downloadManager{
+		slots: make(chan struct{}, max),
+		blobs: make(map[digest.Digest]*blobDownload),
+	}
+}
+
+// getBlob returns the downloaded blob with the given digest, or nil if it
+// is not kept. The blob is referenced until it is released by putBlob.
+func (m *downloadManager) getBlob(dgst digest.Digest) *blobDownload {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, exists := m.blobs[dgst]
+	if !exists {
+		return nil
+	}
+	b.refs++
+	return b
+}
+
+// addBlob keeps a downloaded blob for the other pulls of the same digest.
+// It is referenced until it is released by putBlob.
+func (m *downloadManager) addBlob(dgst digest.Digest, b *blobDownload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b.refs = 1
+	m.blobs[dgst] = b
+}
+
+// putBlob releases a reference to the download of a blob, and removes its
+// file when it is not referenced anymore.
+func (m *downloadManager) putBlob(dgst digest.Digest, b *blobDownload) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if b.refs--; b.refs > 0 {
+		return
+	}
+	if m.blobs[dgst] == b {
+		delete(m.blobs, dgst)
+	}
+	if b.path != "" {
+		os.Remove(b.path)
+	}
+}
+
+// tryAcquire takes a download slot if one is free, and returns whether it
+// did.
+func (m *downloadManager) tryAcquire() bool {
+	select {
+	case m.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// acquire waits for a free download slot and takes it.
+func (m *downloadManager) acquire() {
+	m.slots <- struct{}{}
+}
+
+// release frees a download slot.
+func (m *downloadManager) release() {
+	<-m.slots
+}
+
+// downloadBlob downloads the blob of a layer from a v2 registry to a
+// temporary file, once a download slot is free. If another pull is
+// downloading the same blob, it waits for that download to end and shares
+// its file. The file is kept until it is released by releaseBlob, once the
+// layer is registered.
+func (s *TagStore) downloadBlob(r *registry.Session, endpoints []*v2Endpoint, repoInfo *registry.RepositoryInfo, di *downloadInfo, sf *streamformatter.StreamFormatter, out io.Writer) error {
+	progressID := stringid.TruncateID(di.img.ID)
+	key := "blob:" + di.digest.String()
+
+	for {
+		if b := s.downloads.getBlob(di.digest); b != nil {
+			return s.shareBlob(di, b, sf, out)
+		}
+
+		// ensure no two downloads of the same blob happen at the same time
+		if c, err := s.poolAdd("pull", key); err != nil {
+			out.Write(sf.FormatProgress(progressID, "Layer already being pulled by another client. Waiting.", nil))
+			<-c
+			// download the blob again if that download failed, unless
+			// another pull does already
+			continue
+		}
+		// the blob may have been downloaded since it was looked up
+		if b := s.downloads.getBlob(di.digest); b != nil {
+			s.poolRemove("pull", key)
+			return s.shareBlob(di, b, sf, out)
+		}
+
+		b := &blobDownload{}
+		err := s.fetchBlobToFile(r, endpoints, repoInfo, di, b, sf, out)
+		if err == nil {
+			s.downloads.addBlob(di.digest, b)
+			di.download = b
+		}
+		s.poolRemove("pull", key)
+		return err
+	}
+}
+
+// shareBlob uses the blob downloaded by another pull for a layer.
+func (s *TagStore) shareBlob(di *downloadInfo, b *blobDownload, sf *streamformatter.StreamFormatter, out io.Writer) error {
+	f, err := os.Open(b.path)
+	if err != nil {
+		s.downloads.putBlob(di.digest, b)
+		return err
+	}
+	di.tmpFile, di.length, di.verified, di.downloaded, di.download = f, b.length, b.verified, true, b
+	out.Write(sf.FormatProgress(stringid.TruncateID(di.img.ID), "Download complete", nil))
+	return nil
+}
+
+// releaseBlob releases the blob downloaded for a layer, once the layer is
+// registered or the pull failed.
+func (s *TagStore) releaseBlob(di *downloadInfo) {
+	if di.download == nil {
+		return
+	}
+	di.tmpFile.Close()
+	s.downloads.putBlob(di.digest, di.download)
+	di.download = nil
+}
+
+// fetchBlobToFile downloads the blob of a layer to a temporary file for b.
+// The interrupted downloads are resumed where they stopped, and the
+// endpoints are tried in order until one of them serves the blob.
+func (s *TagStore) fetchBlobToFile(r *registry.Session, endpoints []*v2Endpoint, repoInfo *registry.RepositoryInfo, di *downloadInfo, b *blobDownload, sf *streamformatter.StreamFormatter, out io.Writer) error {
+	progressID := stringid.TruncateID(di.img.ID)
+
+	if !s.downloads.tryAcquire() {
+		out.Write(sf.FormatProgress(progressID, "Waiting", nil))
+		s.downloads.acquire()
+	}
+	defer s.downloads.release()
+
+	logrus.Debugf("pulling blob %q to V1 img %s", di.digest, di.img.ID)
+
+	tmpFile, err := ioutil.TempFile("", "GetV2ImageBlob")
+	if err != nil {
+		return err
+	}
+	for _, ep := range endpoints {
+		if err = s.fetchBlob(r, ep, repoInfo, di, tmpFile, sf, out); err == nil {
+			out.Write(sf.FormatProgress(progressID, "Download complete"+ep.mirrorSuffix(), nil))
+			break
+		}
+		logrus.Debugf("Error pulling blob %s from %s: %v", di.digest, ep, err)
+		if err = rewind(tmpFile); err != nil {
+			break
+		}
+	}
+	if err != nil {
+		tmpFile.Close()
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	logrus.Debugf("Downloaded %s to tempfile %s", di.img.ID, tmpFile.Name())
+	di.tmpFile = tmpFile
+	di.downloaded = true
+	b.path, b.length, b.verified = tmpFile.Name(), di.length, di.verified
+	return nil
+}
+
+// fetchBlob copies the blob of a layer to f, and verifies its digest.
+func (s *TagStore) fetchBlob(r *registry.Session, ep *v2Endpoint, repoInfo *registry.RepositoryInfo, di *downloadInfo, f *os.File, sf *streamformatter.StreamFormatter, out io.Writer) error {
+	progressID := stringid.TruncateID(di.img.ID)
+
+	rc, l, err := r.GetV2ImageBlobReader(ep.Endpoint, repoInfo.RemoteName, di.digest, ep.auth)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	verifier, err := digest.NewDigestVerifier(di.digest)
+	if err != nil {
+		return err
+	}
+
+	n, err := io.Copy(f, progressreader.New(progressreader.Config{
+		In:        ioutil.NopCloser(io.TeeReader(rc, verifier)),
+		Out:       out,
+		Formatter: sf,
+		Size:      int(l),
+		NewLines:  false,
+		ID:        progressID,
+		Action:    "Downloading",
+	}))
+	if err != nil {
+		return fmt.Errorf("unable to copy v2 image blob data: %s", err)
+	}
+	if n != l {
+		return fmt.Errorf("unable to copy v2 image blob data: got %d bytes of %d", n, l)
+	}
+	di.length = l
+
+	out.Write(sf.FormatProgress(progressID, "Verifying Checksum", nil))
+
+	if !verifier.Verified() {
+		logrus.Infof("Image verification failed: checksum mismatch for %q", di.digest.String())
+	} else {
+		di.verified = true
+	}
+	return nil
+}
+
+// rewind empties f for the blob to be downloaded again.
+func rewind(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, 0)
+	return err
+}
@@ -0,0 +1,21 @@
+package graph
+
+import "testing"
+
+func TestDownloadManager(t *testing.T) {
+	m := newDownloadManager(2)
+	if !m.tryAcquire() || !m.tryAcquire() {
+		t.Fatal("Expected 2 free download slots")
+	}
+	if m.tryAcquire() {
+		t.Fatal("Expected no more than 2 downloads at the same time")
+	}
+	m.release()
+	if !m.tryAcquire() {
+		t.Fatal("Expected a released slot to be free")
+	}
+
+	if m := newDownloadManager(0); cap(m.slots) != DefaultMaxConcurrentDownloads {
+		t.Fatalf("Expected %d slots by default, got %d", DefaultMaxConcurrentDownloads, cap(m.slots))
+	}
+}
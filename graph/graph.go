@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"bytes"
 	"compress/gzip"
 	"crypto/sha256"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -28,10 +30,22 @@ import (
 )
 
 // A Graph is a store for versioned filesystem images and the relationship between them.
+//
+// The images and their layers are content-addressable: the ID of an image is
+// the digest of its configuration, and its layer is shared with the images
+// having the same layer on top of the same parent layers. The images pulled
+// from a registry or loaded from an archive are also known by the IDs they
+// had there.
 type Graph struct {
 	Root    string
 	idIndex *truncindex.TruncIndex
 	driver  graphdriver.Driver
+	layers  *layerStore
+
+	mu sync.Mutex
+	// v1IDs maps the IDs the images had in registries and archives to
+	// their IDs
+	v1IDs map[string]string
 }
 
 // NewGraph instantiates a new graph at the given root path in the filesystem.
@@ -46,10 +60,17 @@ func NewGraph(root string, driver graphdriver.Driver) (*Graph, error) {
 		return nil, err
 	}
 
+	layers, err := newLayerStore(filepath.Join(abspath, "_layers"), driver)
+	if err != nil {
+		return nil, err
+	}
+
 	graph := &Graph{
 		Root:    abspath,
 		idIndex: truncindex.NewTruncIndex([]string{}),
 		driver:  driver,
+		layers:  layers,
+		v1IDs:   make(map[string]string),
 	}
 	if err := graph.restore(); err != nil {
 		return nil, err
@@ -62,15 +83,41 @@ func (graph *Graph) restore() error {
 	if err != nil {
 		return err
 	}
-	var ids = []string{}
+	var (
+		ids        = []string{}
+		unmigrated []string
+	)
 	for _, v := range dir {
 		id := v.Name()
-		if graph.driver.Exists(id) {
-			ids = append(ids, id)
+		if image.ValidateID(id) != nil {
+			continue
+		}
+		img, err := image.LoadImage(graph.ImageRoot(id))
+		if err != nil {
+			continue
+		}
+		if img.ChainID == "" {
+			if graph.driver.Exists(id) {
+				unmigrated = append(unmigrated, id)
+			}
+			continue
+		}
+		ids = append(ids, id)
+		graph.layers.ref(img.ChainID)
+		v1IDs, err := readV1IDs(graph.ImageRoot(id))
+		if err != nil {
+			return err
+		}
+		for _, v1ID := range v1IDs {
+			graph.v1IDs[v1ID] = id
 		}
 	}
 	graph.idIndex = truncindex.NewTruncIndex(ids)
-	logrus.Debugf("Restored %d elements", len(dir))
+	logrus.Debugf("Restored %d elements", len(ids))
+
+	if len(unmigrated) > 0 {
+		return graph.migrate(unmigrated)
+	}
 	return nil
 }
 
@@ -89,9 +136,24 @@ func (graph *Graph) Exists(id string) bool {
 	return true
 }
 
+// resolve returns the ID of the image with the given ID, ID prefix, or ID
+// in a registry or an archive.
+func (graph *Graph) resolve(name string) (string, error) {
+	id, err := graph.idIndex.Get(name)
+	if err != nil {
+		graph.mu.Lock()
+		defer graph.mu.Unlock()
+		if id, exists := graph.v1IDs[name]; exists {
+			return id, nil
+		}
+		return "", err
+	}
+	return id, nil
+}
+
 // Get returns the image with the given id, or an error if the image doesn't exist.
 func (graph *Graph) Get(name string) (*image.Image, error) {
-	id, err := graph.idIndex.Get(name)
+	id, err := graph.resolve(name)
 	if err != nil {
 		return nil, fmt.Errorf("could not find image: %v", err)
 	}
@@ -104,8 +166,18 @@ func (graph *Graph) Get(name string) (*image.Image, error) {
 	}
 	img.SetGraph(graph)
 
+	l := graph.layers.get(img.ChainID)
+	if l == nil {
+		return nil, fmt.Errorf("Layer %s of image %s does not exist", img.ChainID, id)
+	}
+	img.LayerID = l.CacheID
+
 	if img.Size < 0 {
-		size, err := graph.driver.DiffSize(img.ID, img.Parent)
+		parentLayerID, err := graph.layers.cacheID(l.Parent)
+		if err != nil {
+			return nil, err
+		}
+		size, err := graph.driver.DiffSize(img.LayerID, parentLayerID)
 		if err != nil {
 			return nil, fmt.Errorf("unable to calculate size of image id %q: %s", img.ID, err)
 		}
@@ -121,7 +193,6 @@ func (graph *Graph) Get(name string) (*image.Image, error) {
 // Create creates a new image and registers it in the graph.
 func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, containerImage, comment, author string, containerConfig, config *runconfig.Config) (*image.Image, error) {
 	img := &image.Image{
-		ID:            stringid.GenerateRandomID(),
 		Comment:       comment,
 		Created:       time.Now().UTC(),
 		DockerVersion: dockerversion.VERSION,
@@ -143,60 +214,232 @@ func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, contain
 	return img, nil
 }
 
-// Register imports a pre-existing image into the graph.
-func (graph *Graph) Register(img *image.Image, layerData archive.ArchiveReader) (err error) {
+// Register imports a pre-existing image into the graph. The image is given
+// its content-addressable ID, and the ID it had, if any, still refers to it.
+// Its parent can be given by any of the IDs of the parent image.
+func (graph *Graph) Register(img *image.Image, layerData archive.ArchiveReader) error {
+	if img.ID != "" {
+		if err := image.ValidateID(img.ID); err != nil {
+			return err
+		}
+	}
+	parent, err := graph.getParent(img)
+	if err != nil {
+		return err
+	}
+
+	f, err := graph.newTempFile()
+	if err != nil {
+		return fmt.Errorf("Mktemp failed: %s", err)
+	}
+	defer func() {
+		f.Close()
+		os.RemoveAll(filepath.Dir(f.Name()))
+	}()
+
+	// Buffer the uncompressed layer to compute its diff ID before it is
+	// applied. Its blob sum is only known once it is pulled or pushed.
+	var diffID digest.Digest
+	if layerData != nil {
+		arch, err := archive.DecompressStream(layerData)
+		if err != nil {
+			return err
+		}
+		diffID, err = bufferDiffToFile(f, arch)
+		arch.Close()
+		if err != nil {
+			return err
+		}
+	} else {
+		diffID, err = bufferDiffToFile(f, bytes.NewReader(emptyTar))
+		if err != nil {
+			return err
+		}
+	}
+
+	var parentChainID digest.Digest
+	if parent != nil {
+		parentChainID = parent.ChainID
+	}
+	l, err := graph.layers.register(parentChainID, diffID, f)
+	if err != nil {
+		return err
+	}
+	return graph.registerWithLayer(img, parent, l)
+}
+
+// RegisterBlob registers an image whose layer is stored already, as the
+// layer with the given blob sum on top of the layer of the parent of the
+// image. It returns false if the graph does not have such a layer.
+func (graph *Graph) RegisterBlob(img *image.Image, blobSum digest.Digest) (bool, error) {
+	diffID, exists := graph.layers.diffID(blobSum)
+	if !exists {
+		return false, nil
+	}
+	parent, err := graph.getParent(img)
+	if err != nil {
+		// the parent is not registered yet
+		return false, nil
+	}
+	var parentChainID digest.Digest
+	if parent != nil {
+		parentChainID = parent.ChainID
+	}
+	chainID := createChainID(parentChainID, diffID)
+	if graph.layers.get(chainID) == nil {
+		return false, nil
+	}
+	graph.layers.ref(chainID)
+	if err := graph.registerWithLayer(img, parent, graph.layers.get(chainID)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// registerWithLayer stores the image with its layer, which is referenced for
+// the image, and sets its ID.
+func (graph *Graph) registerWithLayer(img *image.Image, parent *image.Image, l *layer) (err error) {
 	defer func() {
-		// If any error occurs, remove the new dir from the driver.
-		// Don't check for errors since the dir might not have been created.
-		// FIXME: this leaves a possible race condition.
 		if err != nil {
-			graph.driver.Remove(img.ID)
+			graph.layers.release(l.ChainID)
 		}
 	}()
-	if err := image.ValidateID(img.ID); err != nil {
+
+	config := *img
+	config.ID = ""
+	config.Parent = ""
+	if parent != nil {
+		config.Parent = parent.ID
+	}
+	config.DiffID = l.DiffID
+	id, err := config.ComputeID()
+	if err != nil {
 		return err
 	}
-	// (This is a convenience to save time. Race conditions are taken care of by os.Rename)
-	if graph.Exists(img.ID) {
-		return fmt.Errorf("Image %s already exists", img.ID)
+	config.ID = id
+	config.Size = l.Size
+	config.ChainID = l.ChainID
+	config.LayerID = l.CacheID
+	config.SetGraph(graph)
+
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+
+	v1ID := img.ID
+	if v1ID == id {
+		v1ID = ""
+	}
+	if other, exists := graph.v1IDs[v1ID]; exists && other != id {
+		// the ID refers to another image already
+		logrus.Warnf("Ignoring the ID %s of image %s, which refers to image %s", v1ID, id, other)
+		v1ID = ""
+	}
+	if _, err := graph.idIndex.Get(id); err == nil {
+		// the image is registered already, with its own reference to the
+		// layer
+		graph.layers.release(l.ChainID)
+		if v1ID != "" {
+			if err := graph.addV1ID(id, v1ID); err != nil {
+				return err
+			}
+		}
+		*img = config
+		return nil
 	}
 
 	// Ensure that the image root does not exist on the filesystem
 	// when it is not registered in the graph.
-	// This is common when you switch from one graph driver to another
-	if err := os.RemoveAll(graph.ImageRoot(img.ID)); err != nil && !os.IsNotExist(err) {
+	if err := os.RemoveAll(graph.ImageRoot(id)); err != nil && !os.IsNotExist(err) {
 		return err
 	}
 
-	// If the driver has this ID but the graph doesn't, remove it from the driver to start fresh.
-	// (the graph is the source of truth).
-	// Ignore errors, since we don't know if the driver correctly returns ErrNotExist.
-	// (FIXME: make that mandatory for drivers).
-	graph.driver.Remove(img.ID)
-
 	tmp, err := graph.Mktemp("")
 	defer os.RemoveAll(tmp)
 	if err != nil {
 		return fmt.Errorf("Mktemp failed: %s", err)
 	}
+	if err := image.StoreImage(&config, tmp); err != nil {
+		return err
+	}
+	if v1ID != "" {
+		if err := writeV1IDs(tmp, []string{v1ID}); err != nil {
+			return err
+		}
+	}
+	// Commit
+	if err := os.Rename(tmp, graph.ImageRoot(id)); err != nil {
+		return err
+	}
+	graph.idIndex.Add(id)
+	if v1ID != "" {
+		graph.v1IDs[v1ID] = id
+	}
+	*img = config
+	return nil
+}
 
-	// Create root filesystem in the driver
-	if err := graph.driver.Create(img.ID, img.Parent); err != nil {
-		return fmt.Errorf("Driver %s failed to create image rootfs %s: %s", graph.driver, img.ID, err)
+// getParent returns the parent of the image, or nil if it has no parent.
+func (graph *Graph) getParent(img *image.Image) (*image.Image, error) {
+	if img.Parent == "" {
+		return nil, nil
 	}
-	// Apply the diff/layer
-	img.SetGraph(graph)
-	if err := image.StoreImage(img, layerData, tmp); err != nil {
+	parent, err := graph.Get(img.Parent)
+	if err != nil {
+		return nil, fmt.Errorf("Parent %s of image %s does not exist: %v", img.Parent, img.ID, err)
+	}
+	return parent, nil
+}
+
+// addV1ID makes an ID in a registry or an archive refer to the image, unless
+// it refers to another image already. The graph must be locked.
+func (graph *Graph) addV1ID(id, v1ID string) error {
+	if other, exists := graph.v1IDs[v1ID]; exists {
+		if other != id {
+			logrus.Warnf("Ignoring the ID %s of image %s, which refers to image %s", v1ID, id, other)
+		}
+		return nil
+	}
+	v1IDs, err := readV1IDs(graph.ImageRoot(id))
+	if err != nil {
 		return err
 	}
-	// Commit
-	if err := os.Rename(tmp, graph.ImageRoot(img.ID)); err != nil {
+	if err := writeV1IDs(graph.ImageRoot(id), append(v1IDs, v1ID)); err != nil {
 		return err
 	}
-	graph.idIndex.Add(img.ID)
+	graph.v1IDs[v1ID] = id
 	return nil
 }
 
+func readV1IDs(root string) ([]string, error) {
+	buf, err := ioutil.ReadFile(filepath.Join(root, "v1ids"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return strings.Fields(string(buf)), nil
+}
+
+func writeV1IDs(root string, v1IDs []string) error {
+	return ioutil.WriteFile(filepath.Join(root, "v1ids"), []byte(strings.Join(v1IDs, "\n")+"\n"), 0600)
+}
+
+// BlobSum returns the digest of the compressed archive of the layer of the
+// image in registries, if it is known.
+func (graph *Graph) BlobSum(img *image.Image) digest.Digest {
+	if l := graph.layers.get(img.ChainID); l != nil {
+		return l.BlobSum
+	}
+	return ""
+}
+
+// SetBlobSum records the digest of the compressed archive of the layer of
+// the image in registries, to reuse the layer when pulling or pushing it.
+func (graph *Graph) SetBlobSum(img *image.Image, blobSum digest.Digest) error {
+	return graph.layers.setBlobSum(img.ChainID, blobSum)
+}
+
 // TempLayerArchive creates a temporary archive of the given image's filesystem layer.
 //   The archive is stored on disk and will be automatically deleted as soon as has been read.
 //   If output is not nil, a human-readable progress bar will be written to it.
@@ -264,6 +507,22 @@ func bufferToFile(f *os.File, src io.Reader) (int64, digest.Digest, error) {
 	return n, digest.NewDigest("sha256", h), nil
 }
 
+// bufferDiffToFile copies the uncompressed archive src into f, and returns
+// its digest, which is the diff ID of the layer it holds.
+func bufferDiffToFile(f *os.File, src io.Reader) (digest.Digest, error) {
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), src); err != nil {
+		return "", err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return "", err
+	}
+	return digest.NewDigest("sha256", h), nil
+}
+
+// emptyTar is the archive of an empty layer: the end of archive blocks.
+var emptyTar = make([]byte, 1024)
+
 // setupInitLayer populates a directory with mountpoints suitable
 // for bind-mounting dockerinit into the container. The mountpoint is simply an
 // empty file at /.dockerinit
@@ -336,14 +595,28 @@ func isNotEmpty(err error) bool {
 	return strings.Contains(err.Error(), " not empty")
 }
 
-// Delete atomically removes an image from the graph.
+// Delete atomically removes an image from the graph, and its layer if no
+// other image uses it.
 func (graph *Graph) Delete(name string) error {
-	id, err := graph.idIndex.Get(name)
+	id, err := graph.resolve(name)
+	if err != nil {
+		return err
+	}
+	img, err := image.LoadImage(graph.ImageRoot(id))
 	if err != nil {
 		return err
 	}
+	v1IDs, err := readV1IDs(graph.ImageRoot(id))
+	if err != nil {
+		return err
+	}
+
+	graph.mu.Lock()
 	tmp, err := graph.Mktemp("")
 	graph.idIndex.Delete(id)
+	for _, v1ID := range v1IDs {
+		delete(graph.v1IDs, v1ID)
+	}
 	if err == nil {
 		if err := os.Rename(graph.ImageRoot(id), tmp); err != nil {
 			// On err make tmp point to old dir and cleanup unused tmp dir
@@ -354,8 +627,12 @@ func (graph *Graph) Delete(name string) error {
 		// On err make tmp point to old dir for cleanup
 		tmp = graph.ImageRoot(id)
 	}
-	// Remove rootfs data from the driver
-	graph.driver.Remove(id)
+	graph.mu.Unlock()
+
+	// Remove the layer if no other image uses it
+	if err := graph.layers.release(img.ChainID); err != nil {
+		return err
+	}
 	// Remove the trashed image directory
 	return os.RemoveAll(tmp)
 }
@@ -1,11 +1,18 @@
 package graph
 
 import (
+	"archive/tar"
+	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -42,7 +49,7 @@ func TestMount(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if _, err := driver.Get(image.ID, ""); err != nil {
+	if _, err := driver.Get(image.LayerID, ""); err != nil {
 		t.Fatal(err)
 	}
 
@@ -304,3 +311,202 @@ func nukeGraph(graph *Graph) {
 	graph.Driver().Cleanup()
 	os.RemoveAll(graph.Root)
 }
+
+func TestSquash(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	layer := func(parent string, files ...string) *image.Image {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		for _, name := range files {
+			hdr := &tar.Header{Name: name, Uid: os.Getuid(), Gid: os.Getgid(), Mode: 0644}
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tw.Close()
+		img := &image.Image{
+			ID:      stringid.GenerateRandomID(),
+			Parent:  parent,
+			Comment: "layer " + strings.Join(files, " "),
+			Created: time.Now(),
+		}
+		if err := graph.Register(img, buf); err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	base := layer("", "base")
+	child1 := layer(base.ID, "a", "b")
+	child2 := layer(child1.ID, "c", ".wh.a")
+
+	if _, err := graph.Squash(child2.ID, stringid.GenerateRandomID()); err == nil {
+		t.Fatal("squashing on top of an image which is not a parent should fail")
+	}
+
+	squashed, err := graph.Squash(child2.ID, base.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if squashed.Parent != base.ID {
+		t.Fatalf("expected the squashed image to be on top of %s, got %s", base.ID, squashed.Parent)
+	}
+	if len(squashed.Squashed) != 2 || squashed.Squashed[0].ID != child2.ID || squashed.Squashed[1].ID != child1.ID {
+		t.Fatalf("unexpected squashed images: %v", squashed.Squashed)
+	}
+
+	layerData, err := graph.driver.Diff(squashed.LayerID, base.LayerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer layerData.Close()
+	var names []string
+	tr := tar.NewReader(layerData)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, path.Clean(hdr.Name))
+	}
+	sort.Strings(names)
+	if expected := []string{"b", "c"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected the squashed layer to contain %v, got %v", expected, names)
+	}
+
+	// squashing everything
+	flat, err := graph.Squash(squashed.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if flat.Parent != "" || len(flat.Squashed) != 4 {
+		t.Fatalf("unexpected flattened image: parent %q, squashed %v", flat.Parent, flat.Squashed)
+	}
+}
+
+func TestConcurrentRegister(t *testing.T) {
+	graph, _ := tempGraph(t)
+	defer nukeGraph(graph)
+
+	const n = 8
+	var (
+		wg     sync.WaitGroup
+		images = make([]*image.Image, n)
+		errs   = make([]error, n)
+	)
+	for i := 0; i < n; i++ {
+		archive, err := fakeTar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		images[i] = &image.Image{Comment: fmt.Sprintf("image %d", i), Created: time.Unix(0, 0).UTC()}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = graph.Register(images[i], archive)
+		}(i)
+	}
+	wg.Wait()
+
+	for i, img := range images {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if img.LayerID != images[0].LayerID {
+			t.Fatalf("expected the images to share layer %s, got %s", images[0].LayerID, img.LayerID)
+		}
+	}
+	assertNImages(graph, t, n)
+}
+
+func TestSharedLayers(t *testing.T) {
+	graph, driver := tempGraph(t)
+	defer nukeGraph(graph)
+
+	register := func(id, parent, comment string) *image.Image {
+		archive, err := fakeTar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		img := &image.Image{ID: id, Parent: parent, Comment: comment, Created: time.Unix(0, 0).UTC()}
+		if err := graph.Register(img, archive); err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+
+	v1ID := stringid.GenerateRandomID()
+	a := register(v1ID, "", "a")
+	if a.ID == v1ID || a.DiffID == "" || a.ChainID != a.DiffID {
+		t.Fatalf("unexpected image: ID %s, diff ID %s, chain ID %s", a.ID, a.DiffID, a.ChainID)
+	}
+	if img, err := graph.Get(v1ID); err != nil || img.ID != a.ID {
+		t.Fatalf("expected %s to refer to %s, got %v, %v", v1ID, a.ID, img, err)
+	}
+	// the blob sum is only known once the layer is pulled or pushed
+	if sum := graph.BlobSum(a); sum != "" {
+		t.Fatalf("expected no blob sum for a registered layer, got %s", sum)
+	}
+
+	// the same image registered again is the same image
+	if again := register(stringid.GenerateRandomID(), "", "a"); again.ID != a.ID {
+		t.Fatalf("expected the same image %s, got %s", a.ID, again.ID)
+	}
+	assertNImages(graph, t, 1)
+
+	// an ID referring to an image keeps referring to it
+	other := register(v1ID, "", "other")
+	if img, err := graph.Get(v1ID); err != nil || img.ID != a.ID {
+		t.Fatalf("expected %s to keep referring to %s, got %v, %v", v1ID, a.ID, img, err)
+	}
+	if err := graph.Delete(other.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// another image with the same layer shares it
+	b := register("", "", "b")
+	if b.ID == a.ID || b.LayerID != a.LayerID {
+		t.Fatalf("expected %s and %s to share layer %s, got %s", a.ID, b.ID, a.LayerID, b.LayerID)
+	}
+	// the same layer on top of another parent is another layer
+	c := register("", v1ID, "c")
+	if c.Parent != a.ID || c.ChainID == a.ChainID || c.LayerID == a.LayerID {
+		t.Fatalf("unexpected child image: parent %s, chain ID %s, layer %s", c.Parent, c.ChainID, c.LayerID)
+	}
+
+	if err := graph.Delete(c.ID); err != nil {
+		t.Fatal(err)
+	}
+	if driver.Exists(c.LayerID) {
+		t.Fatalf("expected layer %s to be removed", c.LayerID)
+	}
+	if err := graph.Delete(a.ID); err != nil {
+		t.Fatal(err)
+	}
+	if graph.Exists(v1ID) {
+		t.Fatalf("expected %s not to refer to a deleted image", v1ID)
+	}
+	if !driver.Exists(b.LayerID) {
+		t.Fatalf("expected layer %s to be kept for %s", b.LayerID, b.ID)
+	}
+
+	// the layers and the aliases are restored
+	restored, err := NewGraph(graph.Root, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img, err := restored.Get(b.ID); err != nil || img.LayerID != b.LayerID {
+		t.Fatalf("expected %s to be restored with layer %s, got %v, %v", b.ID, b.LayerID, img, err)
+	}
+	if err := restored.Delete(b.ID); err != nil {
+		t.Fatal(err)
+	}
+	if driver.Exists(b.LayerID) {
+		t.Fatalf("expected layer %s to be removed", b.LayerID)
+	}
+}
@@ -36,6 +36,16 @@ func (s *TagStore) History(name string) ([]*types.ImageHistory, error) {
 			Size:      img.Size,
 			Comment:   img.Comment,
 		})
+		// the layers of the squashed images are in the layer of the image
+		for _, squashed := range img.Squashed {
+			history = append(history, &types.ImageHistory{
+				ID:        squashed.ID,
+				Created:   squashed.Created.Unix(),
+				CreatedBy: squashed.CreatedBy,
+				Tags:      lookupMap[squashed.ID],
+				Comment:   squashed.Comment,
+			})
+		}
 		return nil
 	})
 
@@ -0,0 +1,317 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/pkg/system"
+)
+
+// A layer is a filesystem change set stored by the storage driver. The
+// layers are content-addressable: a layer is identified by its chain ID,
+// which is computed from the diff IDs of the layer and of its parents, the
+// diff ID of a layer being the digest of its uncompressed archive.
+type layer struct {
+	ChainID digest.Digest `json:"chain_id"`
+	DiffID  digest.Digest `json:"diff_id"`
+	// Parent is the chain ID of the parent layer.
+	Parent digest.Digest `json:"parent,omitempty"`
+	// CacheID is the ID of the layer in the storage driver.
+	CacheID string `json:"cache_id"`
+	Size    int64  `json:"size"`
+	// BlobSum is the digest of the compressed archive of the layer, as
+	// pulled from or pushed to a registry.
+	BlobSum digest.Digest `json:"blob_sum,omitempty"`
+	// Duplicates are the IDs in the storage driver of the copies of the
+	// layer found when migrating the images stored before the layers were
+	// content-addressable. They are removed with the layer.
+	Duplicates []string `json:"duplicates,omitempty"`
+	// Migrated is set for the layers migrated from the storage driver. Their
+	// diff IDs are the digests of archives regenerated by the storage
+	// driver, not of the archives they were pulled or loaded from, so they
+	// are not given the blob sums of these archives.
+	Migrated bool `json:"migrated,omitempty"`
+}
+
+// createChainID returns the chain ID of a layer from the chain ID of its
+// parent and its diff ID.
+func createChainID(parent, diffID digest.Digest) digest.Digest {
+	if parent == "" {
+		return diffID
+	}
+	h := sha256.Sum256([]byte(string(parent) + " " + string(diffID)))
+	return digest.Digest("sha256:" + hex.EncodeToString(h[:]))
+}
+
+// layerStore stores the layers by chain ID, and counts the images using
+// them.
+type layerStore struct {
+	root   string
+	driver graphdriver.Driver
+
+	mu     sync.Mutex
+	layers map[digest.Digest]*layer
+	refs   map[digest.Digest]int
+	// blobSums maps the blob sums of the layers to their diff IDs
+	blobSums map[digest.Digest]digest.Digest
+	// registering has the chain IDs of the layers being registered, their
+	// channels being closed once they are
+	registering map[digest.Digest]chan struct{}
+}
+
+func newLayerStore(root string, driver graphdriver.Driver) (*layerStore, error) {
+	if err := system.MkdirAll(root, 0700); err != nil && !os.IsExist(err) {
+		return nil, err
+	}
+	ls := &layerStore{
+		root:        root,
+		driver:      driver,
+		layers:      make(map[digest.Digest]*layer),
+		refs:        make(map[digest.Digest]int),
+		blobSums:    make(map[digest.Digest]digest.Digest),
+		registering: make(map[digest.Digest]chan struct{}),
+	}
+
+	dir, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range dir {
+		f, err := os.Open(filepath.Join(root, v.Name(), "json"))
+		if err != nil {
+			logrus.Debugf("Skipping layer %s: %v", v.Name(), err)
+			continue
+		}
+		l := &layer{}
+		err = json.NewDecoder(f).Decode(l)
+		f.Close()
+		if err != nil {
+			logrus.Debugf("Skipping layer %s: %v", v.Name(), err)
+			continue
+		}
+		ls.layers[l.ChainID] = l
+		if l.BlobSum != "" {
+			ls.blobSums[l.BlobSum] = l.DiffID
+		}
+	}
+	return ls, nil
+}
+
+func (ls *layerStore) layerRoot(chainID digest.Digest) string {
+	return filepath.Join(ls.root, chainID.Hex())
+}
+
+func (ls *layerStore) save(l *layer) error {
+	root := ls.layerRoot(l.ChainID)
+	if err := system.MkdirAll(root, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+	buf, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(root, "json"), buf, 0600)
+}
+
+// get returns the layer with the given chain ID, or nil.
+func (ls *layerStore) get(chainID digest.Digest) *layer {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	return ls.layers[chainID]
+}
+
+// cacheID returns the ID in the storage driver of the layer with the given
+// chain ID, or an empty string for the empty chain ID of the parent of the
+// base layers.
+func (ls *layerStore) cacheID(chainID digest.Digest) (string, error) {
+	if chainID == "" {
+		return "", nil
+	}
+	l := ls.get(chainID)
+	if l == nil {
+		return "", fmt.Errorf("layer %s does not exist", chainID)
+	}
+	return l.CacheID, nil
+}
+
+// ref adds a reference to the layer with the given chain ID.
+func (ls *layerStore) ref(chainID digest.Digest) {
+	ls.mu.Lock()
+	ls.refs[chainID]++
+	ls.mu.Unlock()
+}
+
+// register returns a referenced layer with the given diff on top of the
+// parent layer. The diff is only applied when the store does not have the
+// layer yet, the registrations of the same layer waiting for the first one.
+func (ls *layerStore) register(parent, diffID digest.Digest, diff io.Reader) (*layer, error) {
+	chainID := createChainID(parent, diffID)
+
+	ls.mu.Lock()
+	for {
+		if l, exists := ls.layers[chainID]; exists {
+			ls.refs[chainID]++
+			ls.mu.Unlock()
+			return l, nil
+		}
+		registering, exists := ls.registering[chainID]
+		if !exists {
+			break
+		}
+		ls.mu.Unlock()
+		<-registering
+		ls.mu.Lock()
+	}
+
+	var parentCacheID string
+	if parent != "" {
+		p, exists := ls.layers[parent]
+		if !exists {
+			ls.mu.Unlock()
+			return nil, fmt.Errorf("layer %s does not exist", parent)
+		}
+		parentCacheID = p.CacheID
+	}
+	registering := make(chan struct{})
+	ls.registering[chainID] = registering
+	ls.mu.Unlock()
+
+	defer func() {
+		ls.mu.Lock()
+		delete(ls.registering, chainID)
+		ls.mu.Unlock()
+		close(registering)
+	}()
+
+	l := &layer{
+		ChainID: chainID,
+		DiffID:  diffID,
+		Parent:  parent,
+		CacheID: stringid.GenerateRandomID(),
+	}
+	if err := ls.driver.Create(l.CacheID, parentCacheID); err != nil {
+		return nil, fmt.Errorf("Driver %s failed to create image rootfs %s: %s", ls.driver, l.CacheID, err)
+	}
+	size, err := ls.driver.ApplyDiff(l.CacheID, parentCacheID, diff)
+	if err != nil {
+		ls.driver.Remove(l.CacheID)
+		return nil, err
+	}
+	l.Size = size
+	if err := ls.save(l); err != nil {
+		ls.driver.Remove(l.CacheID)
+		return nil, err
+	}
+
+	ls.mu.Lock()
+	ls.layers[chainID] = l
+	ls.refs[chainID]++
+	ls.mu.Unlock()
+	return l, nil
+}
+
+// adopt returns the layer with the given diff on top of the parent layer,
+// created from the layer cacheID already in the storage driver. If the store
+// has the layer already, cacheID is recorded as a duplicate of it. The layer
+// is not referenced.
+func (ls *layerStore) adopt(parent, diffID digest.Digest, cacheID string, size func() (int64, error)) (*layer, error) {
+	chainID := createChainID(parent, diffID)
+
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	if l, exists := ls.layers[chainID]; exists {
+		if l.CacheID == cacheID {
+			return l, nil
+		}
+		for _, id := range l.Duplicates {
+			if id == cacheID {
+				return l, nil
+			}
+		}
+		l.Duplicates = append(l.Duplicates, cacheID)
+		if err := ls.save(l); err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	n, err := size()
+	if err != nil {
+		return nil, err
+	}
+	l := &layer{
+		ChainID:  chainID,
+		DiffID:   diffID,
+		Parent:   parent,
+		CacheID:  cacheID,
+		Size:     n,
+		Migrated: true,
+	}
+	if err := ls.save(l); err != nil {
+		return nil, err
+	}
+	ls.layers[chainID] = l
+	return l, nil
+}
+
+// release removes a reference to the layer with the given chain ID, and
+// removes the layer when it is not referenced anymore.
+func (ls *layerStore) release(chainID digest.Digest) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, exists := ls.layers[chainID]
+	if !exists {
+		return nil
+	}
+	if ls.refs[chainID]--; ls.refs[chainID] > 0 {
+		return nil
+	}
+
+	delete(ls.layers, chainID)
+	delete(ls.refs, chainID)
+	for _, id := range append([]string{l.CacheID}, l.Duplicates...) {
+		if err := ls.driver.Remove(id); err != nil {
+			logrus.Errorf("Error removing layer %s from the storage driver: %v", id, err)
+		}
+	}
+	return os.RemoveAll(ls.layerRoot(chainID))
+}
+
+// setBlobSum records the blob sum of the layer with the given chain ID,
+// unless it is a migrated layer.
+func (ls *layerStore) setBlobSum(chainID, blobSum digest.Digest) error {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	l, exists := ls.layers[chainID]
+	if !exists {
+		return fmt.Errorf("layer %s does not exist", chainID)
+	}
+	if l.Migrated {
+		return nil
+	}
+	ls.blobSums[blobSum] = l.DiffID
+	if l.BlobSum == blobSum {
+		return nil
+	}
+	l.BlobSum = blobSum
+	return ls.save(l)
+}
+
+// diffID returns the diff ID of the layers with the given blob sum.
+func (ls *layerStore) diffID(blobSum digest.Digest) (digest.Digest, bool) {
+	ls.mu.Lock()
+	defer ls.mu.Unlock()
+	diffID, exists := ls.blobSums[blobSum]
+	return diffID, exists
+}
@@ -139,7 +139,7 @@ func TestManifestTarsumCache(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	if cs, err := img.GetCheckSum(store.graph.ImageRoot(testManifestImageID)); err != nil {
+	if cs, err := img.GetCheckSum(store.graph.ImageRoot(img.ID)); err != nil {
 		t.Fatal(err)
 	} else if cs != "" {
 		t.Fatalf("Non-empty checksum file after register")
@@ -151,7 +151,7 @@ func TestManifestTarsumCache(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	manifestChecksum, err := img.GetCheckSum(store.graph.ImageRoot(testManifestImageID))
+	manifestChecksum, err := img.GetCheckSum(store.graph.ImageRoot(img.ID))
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -0,0 +1,158 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/image"
+)
+
+// migrate moves the images stored with random IDs, before the images and
+// their layers were content-addressable, to the layer store, and gives them
+// their content-addressable IDs. Their old IDs still refer to them. The
+// layers already in the storage driver are kept, and the identical layers of
+// several images are shared.
+//
+// The diff IDs of the migrated layers are computed from the archives the
+// storage driver regenerates, which differ from the archives the layers were
+// pulled or loaded from. So the migrated layers are only shared between the
+// migrated images, and the images pulled or loaded again get new layers.
+//
+// An image is only removed from its old location once it is stored at the
+// new one, so an interrupted or failed migration resumes when the graph is
+// loaded again. The migration fails if an image cannot be migrated, rather
+// than losing it and its children.
+func (graph *Graph) migrate(ids []string) error {
+	logrus.Infof("Migrating %d images to content-addressable storage, this may take a while", len(ids))
+
+	old := make(map[string]*image.Image, len(ids))
+	for _, id := range ids {
+		img, err := image.LoadImage(graph.ImageRoot(id))
+		if err != nil {
+			return err
+		}
+		old[id] = img
+	}
+
+	// migrate the parents first
+	var (
+		ordered []*image.Image
+		visited = make(map[string]bool)
+		visit   func(img *image.Image)
+	)
+	visit = func(img *image.Image) {
+		if visited[img.ID] {
+			return
+		}
+		visited[img.ID] = true
+		if parent, exists := old[img.Parent]; exists {
+			visit(parent)
+		}
+		ordered = append(ordered, img)
+	}
+	for _, id := range ids {
+		visit(old[id])
+	}
+
+	for _, img := range ordered {
+		if err := graph.migrateImage(img); err != nil {
+			return fmt.Errorf("Failed to migrate image %s: %v", img.ID, err)
+		}
+	}
+	return nil
+}
+
+// migrateImage migrates an image whose parent is migrated already.
+func (graph *Graph) migrateImage(img *image.Image) error {
+	oldID := img.ID
+
+	var parent *image.Image
+	if img.Parent != "" {
+		p, err := graph.Get(img.Parent)
+		if err != nil {
+			return fmt.Errorf("parent %s is not migrated: %v", img.Parent, err)
+		}
+		parent = p
+	}
+
+	// The layer of the image is on top of the old layer of its parent in
+	// the storage driver.
+	diffID, err := graph.diffID(oldID, img.Parent)
+	if err != nil {
+		return err
+	}
+	var parentChainID digest.Digest
+	if parent != nil {
+		parentChainID = parent.ChainID
+	}
+	l, err := graph.layers.adopt(parentChainID, diffID, oldID, func() (int64, error) {
+		if img.Size >= 0 {
+			return img.Size, nil
+		}
+		return graph.driver.DiffSize(oldID, img.Parent)
+	})
+	if err != nil {
+		return err
+	}
+
+	config := *img
+	config.ID = ""
+	config.Parent = ""
+	if parent != nil {
+		config.Parent = parent.ID
+	}
+	config.DiffID = diffID
+	id, err := config.ComputeID()
+	if err != nil {
+		return err
+	}
+	config.ID = id
+	config.Size = l.Size
+	config.ChainID = l.ChainID
+
+	graph.mu.Lock()
+	defer graph.mu.Unlock()
+	if _, err := graph.idIndex.Get(id); err == nil {
+		if err := graph.addV1ID(id, oldID); err != nil {
+			return err
+		}
+	} else {
+		tmp, err := graph.Mktemp("")
+		defer os.RemoveAll(tmp)
+		if err != nil {
+			return fmt.Errorf("Mktemp failed: %s", err)
+		}
+		if err := image.StoreImage(&config, tmp); err != nil {
+			return err
+		}
+		if err := writeV1IDs(tmp, []string{oldID}); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, graph.ImageRoot(id)); err != nil {
+			return err
+		}
+		graph.idIndex.Add(id)
+		graph.v1IDs[oldID] = id
+		graph.layers.ref(l.ChainID)
+	}
+	return os.RemoveAll(graph.ImageRoot(oldID))
+}
+
+// diffID returns the digest of the archive of the changes of the layer id
+// on top of the layer parent in the storage driver.
+func (graph *Graph) diffID(id, parent string) (digest.Digest, error) {
+	arch, err := graph.driver.Diff(id, parent)
+	if err != nil {
+		return "", err
+	}
+	defer arch.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, arch); err != nil {
+		return "", err
+	}
+	return digest.NewDigest("sha256", h), nil
+}
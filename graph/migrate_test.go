@@ -0,0 +1,164 @@
+package graph
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringid"
+)
+
+// storeOldImage stores an image the way it was before the images were
+// content-addressable: under its ID, with its layer under the same ID in
+// the storage driver.
+func storeOldImage(t *testing.T, graph *Graph, img *image.Image) {
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graph.driver.Create(img.ID, img.Parent); err != nil {
+		t.Fatal(err)
+	}
+	size, err := graph.driver.ApplyDiff(img.ID, img.Parent, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the directories created by the driver are given the same times, as
+	// if the layers were applied from the same archive
+	dir, err := graph.driver.Get(img.ID, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, time.Unix(0, 0), time.Unix(0, 0))
+	}); err != nil {
+		t.Fatal(err)
+	}
+	graph.driver.Put(img.ID)
+	root := graph.ImageRoot(img.ID)
+	if err := os.MkdirAll(root, 0700); err != nil {
+		t.Fatal(err)
+	}
+	buf, err := json.Marshal(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(root, "json"), buf, 0600); err != nil {
+		t.Fatal(err)
+	}
+	img.Size = size
+	if err := img.SaveSize(root); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	graph, driver := tempGraph(t)
+	defer nukeGraph(graph)
+
+	var (
+		base  = &image.Image{ID: stringid.GenerateRandomID(), Comment: "base", Created: time.Now().UTC()}
+		child = &image.Image{ID: stringid.GenerateRandomID(), Parent: base.ID, Comment: "child", Created: time.Now().UTC()}
+		// the same layer as base
+		dup = &image.Image{ID: stringid.GenerateRandomID(), Comment: "dup", Created: time.Now().UTC()}
+	)
+	for _, img := range []*image.Image{base, child, dup} {
+		storeOldImage(t, graph, img)
+	}
+	blobSum := "sha256:" + stringid.GenerateRandomID()
+	if err := base.SaveCheckSum(graph.ImageRoot(base.ID), blobSum); err != nil {
+		t.Fatal(err)
+	}
+
+	migrated, err := NewGraph(graph.Root, driver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertNImages(migrated, t, 3)
+
+	get := func(oldID string) *image.Image {
+		img, err := migrated.Get(oldID)
+		if err != nil {
+			t.Fatalf("expected %s to be migrated: %v", oldID, err)
+		}
+		if img.ID == oldID {
+			t.Fatalf("expected %s to have a content-addressable ID", oldID)
+		}
+		if _, err := os.Stat(migrated.ImageRoot(oldID)); !os.IsNotExist(err) {
+			t.Fatalf("expected the old image %s to be removed: %v", oldID, err)
+		}
+		return img
+	}
+	newBase, newChild, newDup := get(base.ID), get(child.ID), get(dup.ID)
+	if newChild.Parent != newBase.ID {
+		t.Fatalf("expected the parent of %s to be %s, got %s", newChild.ID, newBase.ID, newChild.Parent)
+	}
+	if (newBase.LayerID != base.ID && newBase.LayerID != dup.ID) || newChild.LayerID != child.ID {
+		t.Fatalf("expected the layers to be kept, got %s and %s", newBase.LayerID, newChild.LayerID)
+	}
+	if newDup.LayerID != newBase.LayerID {
+		t.Fatalf("expected %s and %s to share a layer, got %s and %s", newBase.ID, newDup.ID, newBase.LayerID, newDup.LayerID)
+	}
+	// the migrated layers are not given the blob sums of the archives they
+	// were pulled from
+	if sum := migrated.BlobSum(newBase); sum != "" {
+		t.Fatalf("expected no blob sum for a migrated layer, got %s", sum)
+	}
+
+	// the old IDs still refer to the parents of new images
+	archive, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := &image.Image{Parent: base.ID, Comment: "new"}
+	if err := migrated.Register(img, archive); err != nil {
+		t.Fatal(err)
+	}
+	if img.Parent != newBase.ID {
+		t.Fatalf("expected the parent of %s to be %s, got %s", img.ID, newBase.ID, img.Parent)
+	}
+
+	// the duplicated layer is removed with the shared layer
+	for _, id := range []string{img.ID, newChild.ID, newBase.ID, newDup.ID} {
+		if err := migrated.Delete(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, id := range []string{base.ID, child.ID, dup.ID, img.LayerID} {
+		if driver.Exists(id) {
+			t.Fatalf("expected layer %s to be removed", id)
+		}
+	}
+}
+
+func TestMigrateFailure(t *testing.T) {
+	graph, driver := tempGraph(t)
+	defer nukeGraph(graph)
+
+	var (
+		base  = &image.Image{ID: stringid.GenerateRandomID(), Comment: "base", Created: time.Now().UTC()}
+		child = &image.Image{ID: stringid.GenerateRandomID(), Parent: base.ID, Comment: "child", Created: time.Now().UTC()}
+	)
+	for _, img := range []*image.Image{base, child} {
+		storeOldImage(t, graph, img)
+	}
+	// the parent of child cannot be migrated without its layer
+	if err := driver.Remove(base.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewGraph(graph.Root, driver); err == nil {
+		t.Fatal("expected the migration to fail")
+	}
+	// the image is kept to be migrated again
+	if _, err := os.Stat(graph.ImageRoot(child.ID)); err != nil {
+		t.Fatalf("expected the image %s to be kept: %v", child.ID, err)
+	}
+}
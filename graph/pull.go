@@ -3,7 +3,6 @@ package graph
 import (
 	"fmt"
 	"io"
-	"io/ioutil"
 	"net"
 	"net/url"
 	"os"
@@ -12,8 +11,10 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/metrics"
 	"github.com/docker/docker/pkg/progressreader"
 	"github.com/docker/docker/pkg/streamformatter"
 	"github.com/docker/docker/pkg/stringid"
@@ -22,6 +23,14 @@ import (
 	"github.com/docker/docker/utils"
 )
 
+// pullDuration is the duration of the successful pulls, by version of the
+// registry API.
+var pullDuration = metrics.NewHistogram("docker_image_pull_duration_seconds", "Duration of the image pulls", []float64{1, 5, 10, 30, 60, 120, 300, 600, 1800}, "api_version")
+
+func init() {
+	metrics.Register(pullDuration)
+}
+
 type ImagePullConfig struct {
 	MetaHeaders map[string][]string
 	AuthConfig  *cliconfig.AuthConfig
@@ -54,12 +63,17 @@ func (s *TagStore) Pull(image string, tag string, imagePullConfig *ImagePullConf
 		return err
 	}
 	defer s.poolRemove("pull", utils.ImageReference(repoInfo.LocalName, tag))
+	start := time.Now()
 
 	logrus.Debugf("pulling image from host %q with remote name %q", repoInfo.Index.Name, repoInfo.RemoteName)
 
 	endpoint, err := repoInfo.GetEndpoint(imagePullConfig.MetaHeaders)
 	if err != nil {
-		return err
+		// the registry may be unreachable while its mirrors are not
+		if endpoint = mirrorEndpoint(repoInfo, imagePullConfig.MetaHeaders); endpoint == nil {
+			return err
+		}
+		logrus.Debugf("Unable to reach %s, pulling from its mirror %s: %v", repoInfo.Index.Name, endpoint, err)
 	}
 	// TODO(tiborvass): reuse client from endpoint?
 	// Adds Docker-specific headers as well as user-specified headers (metaHeaders)
@@ -78,14 +92,15 @@ func (s *TagStore) Pull(image string, tag string, imagePullConfig *ImagePullConf
 		logName = utils.ImageReference(logName, tag)
 	}
 
-	if len(repoInfo.Index.Mirrors) == 0 && (repoInfo.Index.Official || endpoint.Version == registry.APIVersion2) {
+	if repoInfo.Index.Official || endpoint.Version == registry.APIVersion2 || len(repoInfo.Index.Mirrors) > 0 {
 		if repoInfo.Official {
 			s.trustService.UpdateBase()
 		}
 
 		logrus.Debugf("pulling v2 repository with local name %q", repoInfo.LocalName)
 		if err := s.pullV2Repository(r, imagePullConfig.OutStream, repoInfo, tag, sf); err == nil {
-			s.eventsService.Log("pull", logName, "")
+			pullDuration.Observe(time.Since(start).Seconds(), "v2")
+			s.eventsService.Log(types.ImageEventType, "pull", types.EventActor{ID: logName})
 			return nil
 		} else if err != registry.ErrDoesNotExist && err != ErrV2RegistryUnavailable {
 			logrus.Errorf("Error from V2 registry: %s", err)
@@ -99,11 +114,28 @@ func (s *TagStore) Pull(image string, tag string, imagePullConfig *ImagePullConf
 		return err
 	}
 
-	s.eventsService.Log("pull", logName, "")
+	pullDuration.Observe(time.Since(start).Seconds(), "v1")
+	s.eventsService.Log(types.ImageEventType, "pull", types.EventActor{ID: logName})
 
 	return nil
 }
 
+// mirrorEndpoint returns the endpoint of the first mirror of the registry of
+// the repository which responds, or nil.
+func mirrorEndpoint(repoInfo *registry.RepositoryInfo, metaHeaders map[string][]string) *registry.Endpoint {
+	for _, mirror := range repoInfo.Index.Mirrors {
+		u, err := url.Parse(mirror)
+		if err != nil {
+			continue
+		}
+		index := &registry.IndexInfo{Name: u.Host, Secure: u.Scheme == "https"}
+		if endpoint, err := registry.NewEndpoint(index, metaHeaders); err == nil {
+			return endpoint
+		}
+	}
+	return nil
+}
+
 func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, askedTag string, sf *streamformatter.StreamFormatter) error {
 	out.Write(sf.FormatStatus("", "Pulling repository %s", repoInfo.CanonicalName))
 
@@ -361,26 +393,82 @@ type downloadInfo struct {
 	tmpFile    *os.File
 	length     int64
 	downloaded bool
-	err        chan error
+	// verified is whether the layer matches its digest
+	verified bool
+	// blob is the download of the same blob for another layer of the
+	// image
+	blob *downloadInfo
+	// download is the shared download of the blob, released by releaseBlob
+	download *blobDownload
+	// done is closed once the blob is downloaded, or failed to be with err
+	done chan struct{}
+	err  error
 }
 
-func (s *TagStore) pullV2Repository(r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, tag string, sf *streamformatter.StreamFormatter) error {
+// v2Endpoint is a v2 registry or mirror to pull from, and the authorization
+// to pull from it.
+type v2Endpoint struct {
+	*registry.Endpoint
+	auth *registry.RequestAuthorization
+}
+
+// v2Endpoints returns the endpoints to pull from: the mirrors of the
+// registry in order, and then the registry itself. The mirrors which cannot
+// be authorized are skipped.
+func (s *TagStore) v2Endpoints(r *registry.Session, repoInfo *registry.RepositoryInfo) ([]*v2Endpoint, error) {
+	var endpoints []*v2Endpoint
+	for _, ep := range r.V2MirrorEndpoints(repoInfo.Index) {
+		auth, err := r.GetV2Authorization(ep, repoInfo.RemoteName, true)
+		if err != nil {
+			logrus.Warnf("Skipping mirror %s of %s: error getting authorization: %s", ep, repoInfo.Index.Name, err)
+			continue
+		}
+		endpoints = append(endpoints, &v2Endpoint{ep, auth})
+	}
+
 	endpoint, err := r.V2RegistryEndpoint(repoInfo.Index)
 	if err != nil {
-		if repoInfo.Index.Official {
-			logrus.Debugf("Unable to pull from V2 registry, falling back to v1: %s", err)
-			return ErrV2RegistryUnavailable
+		if len(endpoints) == 0 {
+			if repoInfo.Index.Official {
+				logrus.Debugf("Unable to pull from V2 registry, falling back to v1: %s", err)
+				return nil, ErrV2RegistryUnavailable
+			}
+			return nil, fmt.Errorf("error getting registry endpoint: %s", err)
 		}
-		return fmt.Errorf("error getting registry endpoint: %s", err)
+		logrus.Debugf("Unable to pull from V2 registry, using its mirrors only: %s", err)
+		return endpoints, nil
 	}
 	auth, err := r.GetV2Authorization(endpoint, repoInfo.RemoteName, true)
 	if err != nil {
-		return fmt.Errorf("error getting authorization: %s", err)
+		return nil, fmt.Errorf("error getting authorization: %s", err)
+	}
+	return append(endpoints, &v2Endpoint{endpoint, auth}), nil
+}
+
+// mirrorSuffix returns the suffix of the progress messages about the data
+// served by the endpoint, naming it if it is a mirror.
+func (ep *v2Endpoint) mirrorSuffix() string {
+	if ep.Mirror {
+		return fmt.Sprintf(", mirror: %s", ep.URL)
+	}
+	return ""
+}
+
+func (s *TagStore) pullV2Repository(r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, tag string, sf *streamformatter.StreamFormatter) error {
+	endpoints, err := s.v2Endpoints(r, repoInfo)
+	if err != nil {
+		return err
 	}
 	var layersDownloaded bool
 	if tag == "" {
 		logrus.Debugf("Pulling tag list from V2 registry for %s", repoInfo.CanonicalName)
-		tags, err := r.GetV2RemoteTags(endpoint, repoInfo.RemoteName, auth)
+		var tags []string
+		for _, ep := range endpoints {
+			if tags, err = r.GetV2RemoteTags(ep.Endpoint, repoInfo.RemoteName, ep.auth); err == nil {
+				break
+			}
+			logrus.Debugf("Error pulling tag list from %s: %v", ep, err)
+		}
 		if err != nil {
 			return err
 		}
@@ -388,14 +476,14 @@ func (s *TagStore) pullV2Repository(r *registry.Session, out io.Writer, repoInfo
 			return registry.ErrDoesNotExist
 		}
 		for _, t := range tags {
-			if downloaded, err := s.pullV2Tag(r, out, endpoint, repoInfo, t, sf, auth); err != nil {
+			if downloaded, err := s.pullV2Tag(r, out, endpoints, repoInfo, t, sf); err != nil {
 				return err
 			} else if downloaded {
 				layersDownloaded = true
 			}
 		}
 	} else {
-		if downloaded, err := s.pullV2Tag(r, out, endpoint, repoInfo, tag, sf, auth); err != nil {
+		if downloaded, err := s.pullV2Tag(r, out, endpoints, repoInfo, tag, sf); err != nil {
 			return err
 		} else if downloaded {
 			layersDownloaded = true
@@ -410,10 +498,21 @@ func (s *TagStore) pullV2Repository(r *registry.Session, out io.Writer, repoInfo
 	return nil
 }
 
-func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, endpoint *registry.Endpoint, repoInfo *registry.RepositoryInfo, tag string, sf *streamformatter.StreamFormatter, auth *registry.RequestAuthorization) (bool, error) {
+func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, endpoints []*v2Endpoint, repoInfo *registry.RepositoryInfo, tag string, sf *streamformatter.StreamFormatter) (bool, error) {
 	logrus.Debugf("Pulling tag from V2 registry: %q", tag)
 
-	manifestBytes, manifestDigest, err := r.GetV2ImageManifest(endpoint, repoInfo.RemoteName, tag, auth)
+	var (
+		manifestBytes  []byte
+		manifestDigest string
+		endpoint       *v2Endpoint
+		err            error
+	)
+	for _, endpoint = range endpoints {
+		if manifestBytes, manifestDigest, err = r.GetV2ImageManifest(endpoint.Endpoint, repoInfo.RemoteName, tag, endpoint.auth); err == nil {
+			break
+		}
+		logrus.Debugf("Error pulling manifest of %s from %s: %v", tag, endpoint, err)
+	}
 	if err != nil {
 		return false, err
 	}
@@ -432,9 +531,14 @@ func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, endpoint *regis
 	if verified {
 		logrus.Printf("Image manifest for %s has been verified", utils.ImageReference(repoInfo.CanonicalName, tag))
 	}
-	out.Write(sf.FormatStatus(tag, "Pulling from %s", repoInfo.CanonicalName))
+	out.Write(sf.FormatStatus(tag, "Pulling from %s%s", repoInfo.CanonicalName, endpoint.mirrorSuffix()))
 
-	downloads := make([]downloadInfo, len(manifest.FSLayers))
+	var (
+		downloads = make([]downloadInfo, len(manifest.FSLayers))
+		// the downloads of the blobs, by digest, as a manifest may hold
+		// the same blob several times
+		blobs = make(map[digest.Digest]*downloadInfo)
+	)
 
 	for i := len(manifest.FSLayers) - 1; i >= 0; i-- {
 		var (
@@ -447,6 +551,7 @@ func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, endpoint *regis
 			return false, fmt.Errorf("failed to parse json: %s", err)
 		}
 		downloads[i].img = img
+		downloads[i].imgJSON = imgJSON
 
 		// Check if exists
 		if s.graph.Exists(img.ID) {
@@ -460,109 +565,89 @@ func (s *TagStore) pullV2Tag(r *registry.Session, out io.Writer, endpoint *regis
 		}
 		downloads[i].digest = dgst
 
-		out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Pulling fs layer", nil))
-
-		downloadFunc := func(di *downloadInfo) error {
-			logrus.Debugf("pulling blob %q to V1 img %s", sumStr, img.ID)
-
-			if c, err := s.poolAdd("pull", "img:"+img.ID); err != nil {
-				if c != nil {
-					out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Layer already being pulled by another client. Waiting.", nil))
-					<-c
-					out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Download complete", nil))
-				} else {
-					logrus.Debugf("Image (id: %s) pull is already running, skipping: %v", img.ID, err)
-				}
-			} else {
-				defer s.poolRemove("pull", "img:"+img.ID)
-				tmpFile, err := ioutil.TempFile("", "GetV2ImageBlob")
-				if err != nil {
-					return err
-				}
-
-				r, l, err := r.GetV2ImageBlobReader(endpoint, repoInfo.RemoteName, di.digest, auth)
-				if err != nil {
-					return err
-				}
-				defer r.Close()
-
-				verifier, err := digest.NewDigestVerifier(di.digest)
-				if err != nil {
-					return err
-				}
-
-				if _, err := io.Copy(tmpFile, progressreader.New(progressreader.Config{
-					In:        ioutil.NopCloser(io.TeeReader(r, verifier)),
-					Out:       out,
-					Formatter: sf,
-					Size:      int(l),
-					NewLines:  false,
-					ID:        stringid.TruncateID(img.ID),
-					Action:    "Downloading",
-				})); err != nil {
-					return fmt.Errorf("unable to copy v2 image blob data: %s", err)
-				}
-
-				out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Verifying Checksum", nil))
-
-				if !verifier.Verified() {
-					logrus.Infof("Image verification failed: checksum mismatch for %q", di.digest.String())
-					verified = false
-				}
-
-				out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Download complete", nil))
-
-				logrus.Debugf("Downloaded %s to tempfile %s", img.ID, tmpFile.Name())
-				di.tmpFile = tmpFile
-				di.length = l
-				di.downloaded = true
-			}
-			di.imgJSON = imgJSON
+		// Reuse the layer if it was pulled or pushed already, by another
+		// image or from another repository
+		if exists, err := s.graph.RegisterBlob(img, dgst); err != nil {
+			return false, err
+		} else if exists {
+			logrus.Debugf("Layer already exists: %s", dgst)
+			continue
+		}
 
-			return nil
+		if first, exists := blobs[dgst]; exists {
+			downloads[i].blob = first
+			continue
 		}
+		blobs[dgst] = &downloads[i]
+
+		out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Pulling fs layer", nil))
 
-		downloads[i].err = make(chan error)
+		downloads[i].done = make(chan struct{})
 		go func(di *downloadInfo) {
-			di.err <- downloadFunc(di)
+			di.err = s.downloadBlob(r, endpoints, repoInfo, di, sf, out)
+			close(di.done)
 		}(&downloads[i])
 	}
 
+	// release the blobs once the layers are registered, or once the
+	// downloads still running end if the pull fails
+	defer func() {
+		for i := range downloads {
+			if d := &downloads[i]; d.done != nil {
+				<-d.done
+				s.releaseBlob(d)
+			}
+		}
+	}()
+
 	var tagUpdated bool
 	for i := len(downloads) - 1; i >= 0; i-- {
 		d := &downloads[i]
-		if d.err != nil {
-			if err := <-d.err; err != nil {
-				return false, err
+		if d.done != nil {
+			<-d.done
+			if d.err != nil {
+				return false, d.err
 			}
 		}
+		if d.blob != nil {
+			// the blob was downloaded for another layer of the image
+			d.tmpFile, d.length, d.verified, d.downloaded = d.blob.tmpFile, d.blob.length, d.blob.verified, true
+		}
 		if d.downloaded {
-			// if tmpFile is empty assume download and extracted elsewhere
-			defer os.Remove(d.tmpFile.Name())
-			defer d.tmpFile.Close()
-			d.tmpFile.Seek(0, 0)
-			if d.tmpFile != nil {
-				err = s.graph.Register(d.img,
-					progressreader.New(progressreader.Config{
-						In:        d.tmpFile,
-						Out:       out,
-						Formatter: sf,
-						Size:      int(d.length),
-						ID:        stringid.TruncateID(d.img.ID),
-						Action:    "Extracting",
-					}))
-				if err != nil {
+			if !d.verified {
+				// an image pulled by digest must match it
+				if utils.DigestReference(tag) {
+					return false, fmt.Errorf("filesystem layer verification failed for digest %s", d.digest)
+				}
+				verified = false
+			}
+			// the image is given its content-addressable ID when registered
+			progressID := stringid.TruncateID(d.img.ID)
+			if _, err := d.tmpFile.Seek(0, 0); err != nil {
+				return false, err
+			}
+			err = s.graph.Register(d.img,
+				progressreader.New(progressreader.Config{
+					In:        d.tmpFile,
+					Out:       out,
+					Formatter: sf,
+					Size:      int(d.length),
+					ID:        progressID,
+					Action:    "Extracting",
+				}))
+			if err != nil {
+				return false, err
+			}
+			if d.verified {
+				if err := s.graph.SetBlobSum(d.img, d.digest); err != nil {
 					return false, err
 				}
-
-				// FIXME: Pool release here for parallel tag pull (ensures any downloads block until fully extracted)
 			}
-			out.Write(sf.FormatProgress(stringid.TruncateID(d.img.ID), "Pull complete", nil))
+			out.Write(sf.FormatProgress(progressID, "Pull complete", nil))
 			tagUpdated = true
 		} else {
 			out.Write(sf.FormatProgress(stringid.TruncateID(d.img.ID), "Already exists", nil))
 		}
-
 	}
 
 	// Check for new tag if no layers downloaded
@@ -0,0 +1,398 @@
+package graph
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/cliconfig"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/streamformatter"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/libtrust"
+)
+
+// testRegistry is a v2 registry serving signed manifests and blobs.
+type testRegistry struct {
+	*httptest.Server
+	key libtrust.PrivateKey
+
+	mu        sync.Mutex
+	manifests map[string][]byte
+	blobs     map[digest.Digest][]byte
+	// blobPulls counts the requests of each blob
+	blobPulls map[digest.Digest]int
+	// blobHook, if set, is called before a blob is served
+	blobHook func(digest.Digest)
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRegistry{
+		key:       key,
+		manifests: make(map[string][]byte),
+		blobs:     make(map[digest.Digest][]byte),
+		blobPulls: make(map[digest.Digest]int),
+	}
+	r.Server = httptest.NewServer(http.HandlerFunc(r.serveHTTP))
+	return r
+}
+
+func (r *testRegistry) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+	if path == "" {
+		return
+	}
+	if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		r.mu.Lock()
+		manifest, exists := r.manifests[path[:i]+":"+path[i+len("/manifests/"):]]
+		r.mu.Unlock()
+		if !exists {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(manifest)
+		return
+	}
+	if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		dgst := digest.Digest(path[i+len("/blobs/"):])
+		r.mu.Lock()
+		blob, exists := r.blobs[dgst]
+		r.blobPulls[dgst]++
+		hook := r.blobHook
+		r.mu.Unlock()
+		if !exists {
+			http.NotFound(w, req)
+			return
+		}
+		if hook != nil {
+			hook(dgst)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(blob)))
+		w.Write(blob)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// endpoint returns the endpoint of the registry, as a mirror or not.
+func (r *testRegistry) endpoint(t *testing.T, mirror bool) *v2Endpoint {
+	u, err := url.Parse(r.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := &registry.Endpoint{URL: u, Version: registry.APIVersion2, Mirror: mirror}
+	return &v2Endpoint{ep, registry.NewRequestAuthorization(&cliconfig.AuthConfig{}, ep, "repository", "", []string{"pull"})}
+}
+
+// testLayer is a layer of an image pushed to a test registry.
+type testLayer struct {
+	id, parent string
+	// file is the name of the file in the layer
+	file string
+}
+
+// push stores an image made of the given layers, from the top one to the
+// base one, and returns the digests of their blobs.
+func (r *testRegistry) push(t *testing.T, name, tag string, layers []testLayer) []digest.Digest {
+	manifest := &registry.ManifestData{
+		Name:          name,
+		Tag:           tag,
+		SchemaVersion: 1,
+	}
+	var digests []digest.Digest
+	for _, l := range layers {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		content := []byte(l.file)
+		if err := tw.WriteHeader(&tar.Header{Name: l.file, Size: int64(len(content)), Uid: os.Getuid(), Gid: os.Getgid()}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write(content)
+		tw.Close()
+		dgst, err := digest.FromBytes(buf.Bytes())
+		if err != nil {
+			t.Fatal(err)
+		}
+		digests = append(digests, dgst)
+
+		imgJSON, err := json.Marshal(&image.Image{ID: l.id, Parent: l.parent})
+		if err != nil {
+			t.Fatal(err)
+		}
+		manifest.FSLayers = append(manifest.FSLayers, &registry.FSLayer{BlobSum: dgst.String()})
+		manifest.History = append(manifest.History, &registry.ManifestHistory{V1Compatibility: string(imgJSON)})
+
+		r.mu.Lock()
+		r.blobs[dgst] = buf.Bytes()
+		r.mu.Unlock()
+	}
+
+	mBytes, err := json.MarshalIndent(manifest, "", "   ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := libtrust.NewJSONSignature(mBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := js.Sign(r.key); err != nil {
+		t.Fatal(err)
+	}
+	signed, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.mu.Lock()
+	r.manifests[name+":"+tag] = signed
+	r.mu.Unlock()
+	return digests
+}
+
+func (r *testRegistry) pulls(dgst digest.Digest) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.blobPulls[dgst]
+}
+
+// mkTestPullTagStore returns a tag store in a temporary directory to be
+// removed, and a session to pull to it.
+func mkTestPullTagStore(t *testing.T) (*TagStore, *registry.Session, string) {
+	root, err := ioutil.TempDir("", "docker-pull-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := mkTestTagStore(root, t)
+	if s.trustService, err = trust.NewTrustStore(path.Join(root, "trust")); err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse("http://127.0.0.1/v1/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := registry.NewSession(&http.Client{}, &cliconfig.AuthConfig{}, &registry.Endpoint{URL: u, Version: registry.APIVersion1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, r, root
+}
+
+func testRepoInfo(name string) *registry.RepositoryInfo {
+	return &registry.RepositoryInfo{
+		Index:         &registry.IndexInfo{Name: "127.0.0.1"},
+		RemoteName:    name,
+		LocalName:     name,
+		CanonicalName: name,
+	}
+}
+
+func TestPullSharedLayer(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.Close()
+	s, r, root := mkTestPullTagStore(t)
+	defer os.RemoveAll(root)
+
+	// both images have the same base layer under different v1 IDs
+	base := reg.push(t, "foo", "latest", []testLayer{
+		{id: strings.Repeat("a", 63) + "2", parent: strings.Repeat("a", 63) + "1", file: "foo"},
+		{id: strings.Repeat("a", 63) + "1", file: "base"},
+	})[1]
+	reg.push(t, "bar", "latest", []testLayer{
+		{id: strings.Repeat("b", 63) + "2", parent: strings.Repeat("b", 63) + "1", file: "bar"},
+		{id: strings.Repeat("b", 63) + "1", file: "base"},
+	})
+
+	// hold the base layer until both pulls want it
+	requested := make(chan struct{}, 2)
+	gate := make(chan struct{})
+	reg.blobHook = func(dgst digest.Digest) {
+		if dgst == base {
+			requested <- struct{}{}
+			<-gate
+		}
+	}
+
+	endpoints := []*v2Endpoint{reg.endpoint(t, false)}
+	sf := streamformatter.NewJSONStreamFormatter()
+	errs := make(chan error, 2)
+	pull := func(name string, out io.Writer) {
+		_, err := s.pullV2Tag(r, out, endpoints, testRepoInfo(name), "latest", sf)
+		errs <- err
+	}
+
+	go pull("foo", ioutil.Discard)
+	<-requested
+	out := &syncBuffer{}
+	go pull("bar", out)
+
+	// wait for the second pull to wait for the download of the first one
+	for start := time.Now(); !strings.Contains(out.String(), "already being pulled"); time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 10*time.Second {
+			t.Fatal("Expected the second pull to wait for the download of the shared layer")
+		}
+	}
+	close(gate)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := reg.pulls(base); n != 1 {
+		t.Fatalf("Expected the shared layer to be pulled once, got %d", n)
+	}
+	if len(s.downloads.blobs) != 0 {
+		t.Fatalf("Expected no blob to be kept once pulled, got %v", s.downloads.blobs)
+	}
+	if _, exists := s.pullingPool["blob:"+base.String()]; exists {
+		t.Fatal("Expected the download of the shared layer to leave the pulling pool")
+	}
+	var chainIDs []digest.Digest
+	for _, id := range []string{strings.Repeat("a", 63) + "1", strings.Repeat("b", 63) + "1"} {
+		img, err := s.graph.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		chainIDs = append(chainIDs, img.ChainID)
+	}
+	if chainIDs[0] != chainIDs[1] {
+		t.Fatalf("Expected the base images to share their layer, got %v", chainIDs)
+	}
+	for _, name := range []string{"foo", "bar"} {
+		if img, err := s.GetImage(name, "latest"); err != nil || img == nil {
+			t.Fatalf("Expected %s to be pulled, got %v", name, err)
+		}
+	}
+}
+
+func TestPullFailureReleasesDownloads(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.Close()
+	s, r, root := mkTestPullTagStore(t)
+	defer os.RemoveAll(root)
+
+	digests := reg.push(t, "foo", "latest", []testLayer{
+		{id: strings.Repeat("a", 63) + "2", parent: strings.Repeat("a", 63) + "1", file: "foo"},
+		{id: strings.Repeat("a", 63) + "1", file: "base"},
+	})
+	// the base layer is missing while the top one is still downloading
+	delete(reg.blobs, digests[1])
+	reg.blobHook = func(dgst digest.Digest) {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	endpoints := []*v2Endpoint{reg.endpoint(t, false)}
+	if _, err := s.pullV2Tag(r, ioutil.Discard, endpoints, testRepoInfo("foo"), "latest", streamformatter.NewJSONStreamFormatter()); err == nil {
+		t.Fatal("Expected the pull of a missing layer to fail")
+	}
+	if reg.pulls(digests[0]) != 1 {
+		t.Fatal("Expected the top layer to be downloaded")
+	}
+	s.downloads.mu.Lock()
+	defer s.downloads.mu.Unlock()
+	if len(s.downloads.blobs) != 0 {
+		t.Fatalf("Expected the downloads to be released when the pull fails, got %v", s.downloads.blobs)
+	}
+}
+
+// syncBuffer is a buffer written by the concurrent downloads of a pull.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func (b *syncBuffer) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf.Reset()
+}
+
+func TestPullFromMirrors(t *testing.T) {
+	reg := newTestRegistry(t)
+	defer reg.Close()
+	mirror := newTestRegistry(t)
+	defer mirror.Close()
+	s, r, root := mkTestPullTagStore(t)
+	defer os.RemoveAll(root)
+
+	layers := []testLayer{
+		{id: strings.Repeat("a", 63) + "2", parent: strings.Repeat("a", 63) + "1", file: "foo"},
+		{id: strings.Repeat("a", 63) + "1", file: "base"},
+	}
+	digests := reg.push(t, "foo", "latest", layers)
+	reg.push(t, "bar", "latest", layers)
+	// the mirror lacks bar and the top layer of foo
+	mirror.push(t, "foo", "latest", layers)
+	delete(mirror.blobs, digests[0])
+
+	u, err := url.Parse(reg.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repoInfo := testRepoInfo("foo")
+	repoInfo.Index = &registry.IndexInfo{Name: u.Host, Mirrors: []string{mirror.URL}}
+	endpoints, err := s.v2Endpoints(r, repoInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endpoints) != 2 || !endpoints[0].Mirror || endpoints[1].Mirror || endpoints[1].URL.Host != u.Host {
+		t.Fatalf("Expected the mirror and then the registry, got %v", endpoints)
+	}
+
+	out := new(syncBuffer)
+	if _, err := s.pullV2Tag(r, out, endpoints, repoInfo, "latest", streamformatter.NewJSONStreamFormatter()); err != nil {
+		t.Fatal(err)
+	}
+	suffix := ", mirror: " + endpoints[0].URL.String()
+	for _, expected := range []string{
+		"Pulling from foo" + suffix,
+		`"status":"Download complete` + suffix + `","progressDetail":{},"id":"` + layers[1].id[:12],
+		`"status":"Download complete","progressDetail":{},"id":"` + layers[0].id[:12],
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Fatalf("Expected %q in the output, got %s", expected, out)
+		}
+	}
+	if mirror.pulls(digests[0]) != 1 || reg.pulls(digests[0]) != 1 || reg.pulls(digests[1]) != 0 {
+		t.Fatal("Expected the layers to be pulled from the mirror first, and then from the registry")
+	}
+
+	// the manifest missing in the mirror is pulled from the registry
+	out.Reset()
+	if _, err := s.pullV2Tag(r, out, endpoints, testRepoInfo("bar"), "latest", streamformatter.NewJSONStreamFormatter()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"status":"Pulling from bar"`) {
+		t.Fatalf("Expected bar to be pulled from the registry, got %s", out)
+	}
+}
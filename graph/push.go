@@ -12,6 +12,7 @@ import (
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/digest"
+	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/cliconfig"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/ioutils"
@@ -377,31 +378,27 @@ func (s *TagStore) pushV2Repository(r *registry.Session, localRepo Repository, o
 				return fmt.Errorf("cannot retrieve the path for %s: %s", layer.ID, err)
 			}
 
-			checksum, err := layer.GetCheckSum(s.graph.ImageRoot(layer.ID))
-			if err != nil {
-				return fmt.Errorf("error getting image checksum: %s", err)
-			}
+			// Layers shared with other images, or pulled from a registry,
+			// are known by their blob sum already
+			checksum := s.graph.BlobSum(layer)
 
 			var exists bool
-			if len(checksum) > 0 {
-				dgst, err := digest.ParseDigest(checksum)
-				if err != nil {
-					return fmt.Errorf("Invalid checksum %s: %s", checksum, err)
-				}
-
+			if checksum != "" {
 				// Call mount blob
-				exists, err = r.HeadV2ImageBlob(endpoint, repoInfo.RemoteName, dgst, auth)
+				exists, err = r.HeadV2ImageBlob(endpoint, repoInfo.RemoteName, checksum, auth)
 				if err != nil {
 					out.Write(sf.FormatProgress(stringid.TruncateID(layer.ID), "Image push failed", nil))
 					return err
 				}
 			}
 			if !exists {
-				if cs, err := s.pushV2Image(r, layer, endpoint, repoInfo.RemoteName, sf, out, auth); err != nil {
+				cs, err := s.pushV2Image(r, layer, endpoint, repoInfo.RemoteName, sf, out, auth)
+				if err != nil {
 					return err
-				} else if cs != checksum {
+				}
+				if cs != checksum {
 					// Cache new checksum
-					if err := layer.SaveCheckSum(s.graph.ImageRoot(layer.ID), cs); err != nil {
+					if err := s.graph.SetBlobSum(layer, cs); err != nil {
 						return err
 					}
 					checksum = cs
@@ -409,7 +406,7 @@ func (s *TagStore) pushV2Repository(r *registry.Session, localRepo Repository, o
 			} else {
 				out.Write(sf.FormatProgress(stringid.TruncateID(layer.ID), "Image already exists", nil))
 			}
-			m.FSLayers[i] = &registry.FSLayer{BlobSum: checksum}
+			m.FSLayers[i] = &registry.FSLayer{BlobSum: checksum.String()}
 			m.History[i] = &registry.ManifestHistory{V1Compatibility: string(jsonData)}
 		}
 
@@ -444,12 +441,13 @@ func (s *TagStore) pushV2Repository(r *registry.Session, localRepo Repository, o
 		}
 
 		out.Write(sf.FormatStatus("", "Digest: %s", digest))
+		out.Write(sf.FormatAux(types.PushResult{Tag: tag, Digest: digest.String()}))
 	}
 	return nil
 }
 
 // PushV2Image pushes the image content to the v2 registry, first buffering the contents to disk
-func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *registry.Endpoint, imageName string, sf *streamformatter.StreamFormatter, out io.Writer, auth *registry.RequestAuthorization) (string, error) {
+func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *registry.Endpoint, imageName string, sf *streamformatter.StreamFormatter, out io.Writer, auth *registry.RequestAuthorization) (digest.Digest, error) {
 	out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Buffering to Disk", nil))
 
 	image, err := s.graph.Get(img.ID)
@@ -472,6 +470,9 @@ func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *
 	}()
 
 	size, dgst, err := bufferToFile(tf, arch)
+	if err != nil {
+		return "", err
+	}
 
 	// Send the layer
 	logrus.Debugf("rendered layer for %s of [%d] size", img.ID, size)
@@ -490,7 +491,7 @@ func (s *TagStore) pushV2Image(r *registry.Session, img *image.Image, endpoint *
 		return "", err
 	}
 	out.Write(sf.FormatProgress(stringid.TruncateID(img.ID), "Image successfully pushed", nil))
-	return dgst.String(), nil
+	return dgst, nil
 }
 
 // FIXME: Allow to interrupt current push when new push of same image is done.
@@ -542,7 +543,7 @@ func (s *TagStore) Push(localName string, imagePushConfig *ImagePushConfig) erro
 	if repoInfo.Index.Official || endpoint.Version == registry.APIVersion2 {
 		err := s.pushV2Repository(r, localRepo, imagePushConfig.OutStream, repoInfo, imagePushConfig.Tag, sf)
 		if err == nil {
-			s.eventsService.Log("push", repoInfo.LocalName, "")
+			s.eventsService.Log(types.ImageEventType, "push", types.EventActor{ID: repoInfo.LocalName})
 			return nil
 		}
 
@@ -554,7 +555,7 @@ func (s *TagStore) Push(localName string, imagePushConfig *ImagePushConfig) erro
 	if err := s.pushRepository(r, imagePushConfig.OutStream, repoInfo, localRepo, imagePushConfig.Tag, sf); err != nil {
 		return err
 	}
-	s.eventsService.Log("push", repoInfo.LocalName, "")
+	s.eventsService.Log(types.ImageEventType, "push", types.EventActor{ID: repoInfo.LocalName})
 	return nil
 
 }
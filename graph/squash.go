@@ -0,0 +1,98 @@
+package graph
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/autogen/dockerversion"
+	"github.com/docker/docker/daemon/graphdriver"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/runconfig"
+)
+
+// errStopWalk stops walking the history of an image
+var errStopWalk = errors.New("stop walking the history")
+
+// Squash creates a new image with the filesystem and the configuration of the
+// image id, whose single layer holds all the changes made by the layers of
+// the image on top of parent. parent must be an ancestor of the image, or ""
+// to squash all its layers. The squashed images are kept in the metadata of
+// the new image, for its history.
+func (graph *Graph) Squash(id, parent string) (*image.Image, error) {
+	img, err := graph.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		squashed []*image.SquashedImage
+		found    = parent == ""
+	)
+	if err := img.WalkHistory(func(i *image.Image) error {
+		if i.ID == parent {
+			found = true
+			return errStopWalk
+		}
+		squashed = append(squashed, &image.SquashedImage{
+			ID:        i.ID,
+			Created:   i.Created,
+			CreatedBy: strings.Join(i.ContainerConfig.Cmd.Slice(), " "),
+			Comment:   i.Comment,
+		})
+		squashed = append(squashed, i.Squashed...)
+		return nil
+	}); err != nil && err != errStopWalk {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("Image %s is not a parent of image %s", parent, id)
+	}
+	if len(squashed) == 0 {
+		// nothing to squash
+		return img, nil
+	}
+
+	// The drivers which do not rely on the naive diff, like aufs, only
+	// return the changes of the layer itself, whatever the parent is.
+	driver := graph.driver
+	if parent != img.Parent {
+		driver = graphdriver.NaiveDiffDriver(graph.driver)
+	}
+	var parentLayerID string
+	if parent != "" {
+		parentImg, err := graph.Get(parent)
+		if err != nil {
+			return nil, err
+		}
+		parentLayerID = parentImg.LayerID
+	}
+	layerData, err := driver.Diff(img.LayerID, parentLayerID)
+	if err != nil {
+		return nil, err
+	}
+	defer layerData.Close()
+
+	containerConfig := img.ContainerConfig
+	containerConfig.Cmd = runconfig.NewCommand("/bin/sh", "-c", fmt.Sprintf("#(nop) squashed %d layers", len(squashed)))
+
+	squashedImg := &image.Image{
+		Parent:          parent,
+		Comment:         img.Comment,
+		Created:         time.Now().UTC(),
+		Container:       img.Container,
+		ContainerConfig: containerConfig,
+		DockerVersion:   dockerversion.VERSION,
+		Author:          img.Author,
+		Config:          img.Config,
+		Architecture:    runtime.GOARCH,
+		OS:              runtime.GOOS,
+		Squashed:        squashed,
+	}
+	if err := graph.Register(squashedImg, layerData); err != nil {
+		return nil, err
+	}
+	return squashedImg, nil
+}
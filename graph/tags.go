@@ -44,6 +44,7 @@ type TagStore struct {
 	registryService *registry.Service
 	eventsService   *events.Events
 	trustService    *trust.TrustStore
+	downloads       *downloadManager
 }
 
 type Repository map[string]string
@@ -72,6 +73,9 @@ type TagStoreConfig struct {
 	Registry *registry.Service
 	Events   *events.Events
 	Trust    *trust.TrustStore
+	// MaxConcurrentDownloads is the number of layers downloaded at the
+	// same time by all the pulls
+	MaxConcurrentDownloads int
 }
 
 func NewTagStore(path string, cfg *TagStoreConfig) (*TagStore, error) {
@@ -90,6 +94,7 @@ func NewTagStore(path string, cfg *TagStoreConfig) (*TagStore, error) {
 		registryService: cfg.Registry,
 		eventsService:   cfg.Events,
 		trustService:    cfg.Trust,
+		downloads:       newDownloadManager(cfg.MaxConcurrentDownloads),
 	}
 	// Load the json file if it exists, otherwise create it.
 	if err := store.reload(); os.IsNotExist(err) {
@@ -98,10 +103,32 @@ func NewTagStore(path string, cfg *TagStoreConfig) (*TagStore, error) {
 		}
 	} else if err != nil {
 		return nil, err
+	} else if err := store.resolveIDs(); err != nil {
+		return nil, err
 	}
 	return store, nil
 }
 
+// resolveIDs replaces the IDs of the tagged images by their
+// content-addressable IDs, for the images migrated from random IDs.
+func (store *TagStore) resolveIDs() error {
+	changed := false
+	for _, repo := range store.Repositories {
+		for ref, id := range repo {
+			img, err := store.graph.Get(id)
+			if err != nil || img.ID == id {
+				continue
+			}
+			repo[ref] = img.ID
+			changed = true
+		}
+	}
+	if changed {
+		return store.save()
+	}
+	return nil
+}
+
 func (store *TagStore) save() error {
 	// Store the json ball
 	jsonData, err := json.Marshal(store)
@@ -12,18 +12,17 @@ import (
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs" // import the vfs driver so it is used in the tests
 	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/stringid"
 	"github.com/docker/docker/utils"
 )
 
 const (
-	testOfficialImageName    = "myapp"
-	testOfficialImageID      = "1a2d3c4d4e5fa2d2a21acea242a5e2345d3aefc3e7dfa2a2a2a21a2a2ad2d234"
-	testOfficialImageIDShort = "1a2d3c4d4e5f"
-	testPrivateImageName     = "127.0.0.1:8000/privateapp"
-	testPrivateImageID       = "5bc255f8699e4ee89ac4469266c3d11515da88fdcbde45d7b069b636ff4efd81"
-	testPrivateImageIDShort  = "5bc255f8699e"
-	testPrivateImageDigest   = "sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb"
-	testPrivateImageTag      = "sometag"
+	testOfficialImageName  = "myapp"
+	testOfficialImageID    = "1a2d3c4d4e5fa2d2a21acea242a5e2345d3aefc3e7dfa2a2a2a21a2a2ad2d234"
+	testPrivateImageName   = "127.0.0.1:8000/privateapp"
+	testPrivateImageID     = "5bc255f8699e4ee89ac4469266c3d11515da88fdcbde45d7b069b636ff4efd81"
+	testPrivateImageDigest = "sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb"
+	testPrivateImageTag    = "sometag"
 )
 
 func fakeTar() (io.Reader, error) {
@@ -62,7 +61,7 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	}
 	tagCfg := &TagStoreConfig{
 		Graph:  graph,
-		Events: events.New(),
+		Events: events.New(events.DefaultLimit),
 	}
 	store, err := NewTagStore(path.Join(root, "tags"), tagCfg)
 	if err != nil {
@@ -72,7 +71,7 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	img := &image.Image{ID: testOfficialImageID}
+	img := &image.Image{ID: testOfficialImageID, Comment: "official"}
 	if err := graph.Register(img, officialArchive); err != nil {
 		t.Fatal(err)
 	}
@@ -83,7 +82,7 @@ func mkTestTagStore(root string, t *testing.T) *TagStore {
 	if err != nil {
 		t.Fatal(err)
 	}
-	img = &image.Image{ID: testPrivateImageID}
+	img = &image.Image{ID: testPrivateImageID, Comment: "private"}
 	if err := graph.Register(img, privateArchive); err != nil {
 		t.Fatal(err)
 	}
@@ -105,11 +104,26 @@ func TestLookupImage(t *testing.T) {
 	store := mkTestTagStore(tmp, t)
 	defer store.graph.driver.Cleanup()
 
+	// The images are registered with the IDs they have in a registry, and
+	// given their content-addressable IDs
+	official, err := store.graph.Get(testOfficialImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	private, err := store.graph.Get(testPrivateImageID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if official.ID == testOfficialImageID || private.ID == testPrivateImageID || official.ID == private.ID {
+		t.Fatalf("Expected distinct content-addressable IDs, got %s and %s", official.ID, private.ID)
+	}
+
 	officialLookups := []string{
 		testOfficialImageID,
-		testOfficialImageIDShort,
-		testOfficialImageName + ":" + testOfficialImageID,
-		testOfficialImageName + ":" + testOfficialImageIDShort,
+		official.ID,
+		stringid.TruncateID(official.ID),
+		testOfficialImageName + ":" + official.ID,
+		testOfficialImageName + ":" + stringid.TruncateID(official.ID),
 		testOfficialImageName,
 		testOfficialImageName + ":" + DEFAULTTAG,
 		"docker.io/" + testOfficialImageName,
@@ -126,9 +140,10 @@ func TestLookupImage(t *testing.T) {
 
 	privateLookups := []string{
 		testPrivateImageID,
-		testPrivateImageIDShort,
-		testPrivateImageName + ":" + testPrivateImageID,
-		testPrivateImageName + ":" + testPrivateImageIDShort,
+		private.ID,
+		stringid.TruncateID(private.ID),
+		testPrivateImageName + ":" + private.ID,
+		testPrivateImageName + ":" + stringid.TruncateID(private.ID),
 		testPrivateImageName,
 		testPrivateImageName + ":" + DEFAULTTAG,
 	}
@@ -147,8 +162,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testOfficialImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testOfficialImageID, img.ID)
+		} else if img.ID != official.ID {
+			t.Errorf("Expected ID '%s' found '%s'", official.ID, img.ID)
 		}
 	}
 
@@ -157,8 +172,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testPrivateImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testPrivateImageID, img.ID)
+		} else if img.ID != private.ID {
+			t.Errorf("Expected ID '%s' found '%s'", private.ID, img.ID)
 		}
 	}
 
@@ -175,8 +190,8 @@ func TestLookupImage(t *testing.T) {
 			t.Errorf("Error looking up %s: %s", name, err)
 		} else if img == nil {
 			t.Errorf("Expected 1 image, none found: %s", name)
-		} else if img.ID != testPrivateImageID {
-			t.Errorf("Expected ID '%s' found '%s'", testPrivateImageID, img.ID)
+		} else if img.ID != private.ID {
+			t.Errorf("Expected ID '%s' found '%s'", private.ID, img.ID)
 		}
 	}
 }
@@ -42,6 +42,20 @@ clone() {
 	echo done
 }
 
+# Applies the patches of hack/vendor-patches/<pkg> to the package cloned by
+# clone, in order. They carry the changes not released upstream yet, and are
+# removed when the package is bumped to a revision including them.
+apply_patches() {
+	pkg=$1
+	patch_dir=../hack/vendor-patches/$pkg
+
+	for p in $patch_dir/*.patch; do
+		[ -e "$p" ] || continue
+		echo "$pkg: apply $(basename "$p")"
+		patch --quiet --forward --no-backup-if-mismatch -p1 -d src/$pkg < "$p"
+	done
+}
+
 # the following lines are in sorted order, FYI
 clone git github.com/Sirupsen/logrus v0.7.3 # logrus is a common dependency among multiple deps
 clone git github.com/docker/libtrust 230dfd18c232
@@ -56,6 +70,7 @@ clone hg code.google.com/p/gosqlite 74691fb6f837
 
 #get libnetwork packages
 clone git github.com/docker/libnetwork b39597744b0978fe4aeb9f3a099ba42f7b6c4a1f
+apply_patches github.com/docker/libnetwork
 clone git github.com/vishvananda/netns 008d17ae001344769b031375bdb38a86219154c6
 clone git github.com/vishvananda/netlink 8eb64238879fed52fd51c5b30ad20b928fb4c36c
 
@@ -1,6 +1,8 @@
 package image
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,6 +12,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/docker/distribution/digest"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/runconfig"
 )
@@ -34,10 +37,31 @@ type Image struct {
 	Architecture    string            `json:"architecture,omitempty"`
 	OS              string            `json:"os,omitempty"`
 	Size            int64
+	// Squashed are the images whose layers were squashed into the layer of
+	// this image, most recent first, to keep the history of the image.
+	Squashed []*SquashedImage `json:"squashed,omitempty"`
+	// DiffID is the digest of the uncompressed archive of the layer of the
+	// image.
+	DiffID digest.Digest `json:"diff_id,omitempty"`
+
+	// ChainID identifies the layer of the image on top of the layers of its
+	// parents, and LayerID is the ID of this layer in the storage driver.
+	// They are set by the graph.
+	ChainID digest.Digest `json:"-"`
+	LayerID string        `json:"-"`
 
 	graph Graph
 }
 
+// SquashedImage describes an image whose layer was squashed into the layer of
+// another image.
+type SquashedImage struct {
+	ID        string    `json:"id"`
+	Created   time.Time `json:"created"`
+	CreatedBy string    `json:"created_by,omitempty"`
+	Comment   string    `json:"comment,omitempty"`
+}
+
 func LoadImage(root string) (*Image, error) {
 	// Open the JSON file to decode by streaming
 	jsonSource, err := os.Open(jsonPath(root))
@@ -75,24 +99,28 @@ func LoadImage(root string) (*Image, error) {
 		img.Size = int64(size)
 	}
 
+	// The images stored before the layers were content-addressable have no
+	// layer file.
+	if buf, err := ioutil.ReadFile(filepath.Join(root, "layer")); err == nil {
+		img.ChainID = digest.Digest(buf)
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
 	return img, nil
 }
 
-// StoreImage stores file system layer data for the given image to the
-// image's registered storage driver. Image metadata is stored in a file
-// at the specified root directory.
-func StoreImage(img *Image, layerData archive.ArchiveReader, root string) (err error) {
-	// Store the layer. If layerData is not nil, unpack it into the new layer
-	if layerData != nil {
-		if img.Size, err = img.graph.Driver().ApplyDiff(img.ID, img.Parent, layerData); err != nil {
-			return err
-		}
-	}
-
+// StoreImage stores the metadata of the image, and the chain ID of its
+// layer, in the specified root directory.
+func StoreImage(img *Image, root string) error {
 	if err := img.SaveSize(root); err != nil {
 		return err
 	}
 
+	if err := ioutil.WriteFile(filepath.Join(root, "layer"), []byte(img.ChainID), 0600); err != nil {
+		return fmt.Errorf("Error storing image layer in %s/layer: %s", root, err)
+	}
+
 	f, err := os.OpenFile(jsonPath(root), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
 	if err != nil {
 		return err
@@ -157,9 +185,16 @@ func (img *Image) TarLayer() (arch archive.Archive, err error) {
 		return nil, fmt.Errorf("Can't load storage driver for unregistered image %s", img.ID)
 	}
 
-	driver := img.graph.Driver()
+	var parentLayerID string
+	parent, err := img.GetParent()
+	if err != nil {
+		return nil, err
+	}
+	if parent != nil {
+		parentLayerID = parent.LayerID
+	}
 
-	return driver.Diff(img.ID, img.Parent)
+	return img.graph.Driver().Diff(img.LayerID, parentLayerID)
 }
 
 // Image includes convenience proxy functions to its graph
@@ -255,6 +290,21 @@ func (img *Image) CheckDepth() error {
 	return nil
 }
 
+// ComputeID returns the content-addressable ID of the image: the digest of
+// its configuration, which refers to its parent by ID and to its layer by
+// diff ID, without its own ID and size.
+func (img *Image) ComputeID() (string, error) {
+	config := *img
+	config.ID = ""
+	config.Size = 0
+	buf, err := json.Marshal(&config)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.Sum256(buf)
+	return hex.EncodeToString(h[:]), nil
+}
+
 // Build an Image object from raw json data
 func NewImgJSON(src []byte) (*Image, error) {
 	ret := &Image{}
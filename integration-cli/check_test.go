@@ -59,6 +59,30 @@ func (s *DockerRegistrySuite) TearDownTest(c *check.C) {
 	s.ds.TearDownTest(c)
 }
 
+func init() {
+	check.Suite(&DockerTrustSuite{
+		ds: &DockerSuite{},
+	})
+}
+
+type DockerTrustSuite struct {
+	ds  *DockerSuite
+	reg *testRegistryV2
+	ts  *testTrustServer
+}
+
+func (s *DockerTrustSuite) SetUpTest(c *check.C) {
+	s.reg = setupRegistry(c)
+	s.ts = newTestTrustServer(c)
+	s.ds.SetUpTest(c)
+}
+
+func (s *DockerTrustSuite) TearDownTest(c *check.C) {
+	s.reg.Close()
+	s.ts.Close()
+	s.ds.TearDownTest(c)
+}
+
 func init() {
 	check.Suite(&DockerDaemonSuite{
 		ds: &DockerSuite{},
@@ -3,8 +3,10 @@ package main
 import (
 	"archive/tar"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httputil"
 	"os"
@@ -676,6 +678,18 @@ func (s *DockerSuite) TestContainerApiCreate(c *check.C) {
 	}
 }
 
+
+func (s *DockerSuite) TestContainerApiCreateInvalidStopSignal(c *check.C) {
+	config := map[string]interface{}{
+		"Image":      "busybox",
+		"StopSignal": "SIGFOO",
+	}
+
+	status, body, err := sockRequest("POST", "/containers/create", config)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, http.StatusInternalServerError)
+	c.Assert(strings.Contains(string(body), "Invalid signal: SIGFOO"), check.Equals, true)
+}
 func (s *DockerSuite) TestContainerApiCreateWithHostName(c *check.C) {
 	hostName := "test-host"
 	config := map[string]interface{}{
@@ -1161,6 +1175,74 @@ func (s *DockerSuite) TestContainerApiCopyContainerNotFound(c *check.C) {
 	c.Assert(status, check.Equals, http.StatusNotFound)
 }
 
+func (s *DockerSuite) TestContainerApiArchive(c *check.C) {
+	name := "test-container-api-archive"
+	dockerCmd(c, "run", "--name", name, "busybox", "sh", "-c", "echo hello > /test.txt && ln -s /test.txt /link")
+
+	res, body, err := sockRequestRaw("HEAD", "/containers/"+name+"/archive?path=/link", nil, "")
+	c.Assert(err, check.IsNil)
+	body.Close()
+	c.Assert(res.StatusCode, check.Equals, http.StatusOK)
+	var stat types.ContainerPathStat
+	statJSON := base64.NewDecoder(base64.URLEncoding, strings.NewReader(res.Header.Get("X-Docker-Container-Path-Stat")))
+	c.Assert(json.NewDecoder(statJSON).Decode(&stat), check.IsNil)
+	c.Assert(stat.Name, check.Equals, "link")
+	c.Assert(stat.LinkTarget, check.Equals, "/test.txt")
+
+	status, body2, err := sockRequest("GET", "/containers/"+name+"/archive?path=/link", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, http.StatusOK)
+	h, err := tar.NewReader(bytes.NewReader(body2)).Next()
+	c.Assert(err, check.IsNil)
+	c.Assert(h.Name, check.Equals, "test.txt")
+
+	res, body, err = sockRequestRaw("HEAD", "/containers/"+name+"/archive?path=/notexist", nil, "")
+	c.Assert(err, check.IsNil)
+	body.Close()
+	c.Assert(res.StatusCode, check.Equals, http.StatusNotFound)
+}
+
+func (s *DockerSuite) TestContainerApiArchivePut(c *check.C) {
+	name := "test-container-api-archive-put"
+	dockerCmd(c, "create", "--name", name, "-v", "/data", "--read-only", "busybox")
+
+	archive := func(name string) io.Reader {
+		buf := new(bytes.Buffer)
+		tw := tar.NewWriter(buf)
+		c.Assert(tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 5}), check.IsNil)
+		_, err := tw.Write([]byte("hello"))
+		c.Assert(err, check.IsNil)
+		c.Assert(tw.Close(), check.IsNil)
+		return buf
+	}
+
+	res, body, err := sockRequestRaw("PUT", "/containers/"+name+"/archive?path=/data", archive("test.txt"), "application/x-tar")
+	c.Assert(err, check.IsNil)
+	body.Close()
+	c.Assert(res.StatusCode, check.Equals, http.StatusOK)
+
+	// the root filesystem of the container is read-only
+	res, body, err = sockRequestRaw("PUT", "/containers/"+name+"/archive?path=/tmp", archive("test.txt"), "application/x-tar")
+	c.Assert(err, check.IsNil)
+	body.Close()
+	c.Assert(res.StatusCode, check.Equals, http.StatusForbidden)
+
+	res, body, err = sockRequestRaw("PUT", "/containers/"+name+"/archive?path=/data/test.txt", archive("other.txt"), "application/x-tar")
+	c.Assert(err, check.IsNil)
+	body.Close()
+	c.Assert(res.StatusCode, check.Equals, http.StatusBadRequest)
+
+	status, body2, err := sockRequest("GET", "/containers/"+name+"/archive?path=/data/test.txt", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, http.StatusOK)
+	tr := tar.NewReader(bytes.NewReader(body2))
+	_, err = tr.Next()
+	c.Assert(err, check.IsNil)
+	content, err := ioutil.ReadAll(tr)
+	c.Assert(err, check.IsNil)
+	c.Assert(string(content), check.Equals, "hello")
+}
+
 func (s *DockerSuite) TestContainerApiDelete(c *check.C) {
 	runCmd := exec.Command(dockerBinary, "run", "-d", "busybox", "top")
 	out, _, err := runCommandWithOutput(runCmd)
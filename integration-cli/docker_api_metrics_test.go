@@ -0,0 +1,13 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestApiMetricsDisabled(c *check.C) {
+	status, _, err := sockRequest("GET", "/metrics", nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(status, check.Equals, http.StatusNotFound)
+}
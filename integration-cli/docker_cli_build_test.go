@@ -5426,3 +5426,327 @@ func (s *DockerSuite) TestBuildRUNErrMsg(c *check.C) {
 		c.Fatalf("RUN doesn't have the correct output:\nGot:%s\nExpected:%s", out, exp)
 	}
 }
+
+func buildImageWithBuildArgs(name, dockerfile string, useCache bool, buildArgs ...string) (string, string, error) {
+	args := []string{"build", "-t", name}
+	if !useCache {
+		args = append(args, "--no-cache")
+	}
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+	args = append(args, "-")
+	buildCmd := exec.Command(dockerBinary, args...)
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		return "", out, fmt.Errorf("failed to build the image: %s", out)
+	}
+	id, err := getIDByName(name)
+	return id, out, err
+}
+
+func (s *DockerSuite) TestBuildBuildTimeArg(c *check.C) {
+	name := "testbuildbuildtimearg"
+	dockerfile := `FROM busybox
+ARG FOO
+RUN [ "$FOO" = "bar" ]
+WORKDIR /$FOO
+ARG DEFAULT=value
+RUN [ "$DEFAULT" = "value" ]
+ENV DEFAULT=env
+RUN [ "$DEFAULT" = "env" ]`
+
+	if _, out, err := buildImageWithBuildArgs(name, dockerfile, true, "FOO=bar"); err != nil {
+		c.Fatal(err, out)
+	}
+
+	workdir, err := inspectField(name, "Config.WorkingDir")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if workdir != "/bar" {
+		c.Fatalf("expected the build arg to be expanded in WORKDIR, got %q", workdir)
+	}
+	env, err := inspectFieldJSON(name, "Config.Env")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if strings.Contains(env, "FOO") {
+		c.Fatalf("build arg should not be persisted in the image config: %s", env)
+	}
+}
+
+func (s *DockerSuite) TestBuildBuildTimeArgCache(c *check.C) {
+	name := "testbuildbuildtimeargcache"
+	dockerfile := `FROM busybox
+ARG FOO
+RUN echo $FOO`
+
+	id1, _, err := buildImageWithBuildArgs(name, dockerfile, true, "FOO=bar")
+	if err != nil {
+		c.Fatal(err)
+	}
+	id2, _, err := buildImageWithBuildArgs(name, dockerfile, true, "FOO=bar")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id1 != id2 {
+		c.Fatal("build with the same build arg should have used the cache")
+	}
+	id3, _, err := buildImageWithBuildArgs(name, dockerfile, true, "FOO=baz")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id1 == id3 {
+		c.Fatal("build with a different build arg should not have used the cache")
+	}
+}
+
+func (s *DockerSuite) TestBuildBuildTimeArgNotConsumed(c *check.C) {
+	name := "testbuildbuildtimeargnotconsumed"
+	_, out, err := buildImageWithBuildArgs(name, "FROM busybox\nRUN true", true, "FOO=bar")
+	if err == nil {
+		c.Fatal("build should have failed on the unused build arg")
+	}
+	if !strings.Contains(out, "One or more build-args [FOO] were not consumed") {
+		c.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func (s *DockerSuite) TestBuildStopSignal(c *check.C) {
+	name := "testbuildstopsignal"
+	_, err := buildImage(name,
+		`FROM busybox
+		 STOPSIGNAL SIGKILL`,
+		true)
+	if err != nil {
+		c.Fatal(err)
+	}
+	res, err := inspectFieldJSON(name, "Config.StopSignal")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if res != `"SIGKILL"` {
+		c.Fatalf("Signal %s, expected SIGKILL", res)
+	}
+
+	if _, err := buildImage(name+"invalid", "FROM busybox\nSTOPSIGNAL SIGFOO", true); err == nil {
+		c.Fatal("build should have failed on the invalid signal")
+	}
+}
+
+func (s *DockerSuite) TestBuildMultiStage(c *check.C) {
+	name := "testbuildmultistage"
+	dockerfile := `FROM busybox AS first
+COPY foo /foo
+RUN echo -n bar >> /foo
+
+FROM busybox
+ENV FOO=stage1
+RUN echo -n baz > /baz
+
+FROM first AS third
+RUN echo -n qux >> /foo
+
+FROM busybox
+COPY --from=third /foo /foo
+COPY --from=1 /baz /dir/
+`
+	ctx, err := fakeContext(dockerfile, map[string]string{"foo": "foo"})
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer ctx.Close()
+
+	id1, err := buildImageFromContext(name, ctx, true)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// only the last stage is tagged, and it inherits nothing from the
+	// other stages
+	env, err := inspectFieldJSON(name, "Config.Env")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if strings.Contains(env, "FOO") {
+		c.Fatalf("the last stage should not inherit the config of the previous ones: %s", env)
+	}
+
+	out, _ := dockerCmd(c, "run", "--rm", name, "cat", "/foo", "/dir/baz")
+	if out != "foobarquxbaz" {
+		c.Fatalf("expected the files to have been copied from the third and second stages, got %q", out)
+	}
+
+	// all the stages are cached
+	id2, err := buildImageFromContext(name, ctx, true)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id1 != id2 {
+		c.Fatal("the multi-stage build should have been fully cached")
+	}
+
+	// a change in the first stage is copied to the last one
+	if err := ctx.Add("foo", "changed"); err != nil {
+		c.Fatal(err)
+	}
+	id3, err := buildImageFromContext(name, ctx, true)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id1 == id3 {
+		c.Fatal("the change in the first stage should have busted the cache of the last one")
+	}
+}
+
+func (s *DockerSuite) TestBuildMultiStageUnknownStage(c *check.C) {
+	name := "testbuildmultistageunknownstage"
+	_, out, err := buildImageWithOut(name, `FROM busybox
+COPY --from=nosuchstage /bin/sh /sh`, true)
+	if err == nil {
+		c.Fatal("build should have failed on the unknown stage")
+	}
+	if !strings.Contains(out, `unknown build stage "nosuchstage"`) {
+		c.Fatalf("unexpected output: %s", out)
+	}
+}
+
+func (s *DockerSuite) TestBuildMultiStageSymlinkFromArg(c *check.C) {
+	name := "testbuildmultistagesymlinkfromarg"
+	_, err := buildImage(name, `FROM busybox AS src
+RUN echo -n foo > /target && ln -s /target /link
+
+FROM busybox
+ARG STAGE=src
+COPY --from=$STAGE /link /dir/
+RUN [ "$(cat /dir/link)" = "foo" ] && [ ! -e /dir/target ]`, true)
+	if err != nil {
+		c.Fatal(err)
+	}
+}
+
+func (s *DockerSuite) TestBuildStreamContext(c *check.C) {
+	name := "testbuildstreamcontext"
+	ctx, err := fakeContext(`FROM busybox
+COPY foo /foo
+COPY dir /dir
+RUN [ "$(cat /foo)" = "foo" ] && [ -f /dir/bar ] && [ ! -e /dir/bar.log ]`,
+		map[string]string{
+			"foo":           "foo",
+			"dir/bar":       "bar",
+			"dir/bar.log":   "log",
+			"unused":        "unused",
+			".dockerignore": "dir/*.log",
+		})
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer ctx.Close()
+
+	build := func() string {
+		buildCmd := exec.Command(dockerBinary, "build", "--stream", "-t", name, ".")
+		buildCmd.Dir = ctx.Dir
+		out, exitCode, err := runCommandWithOutput(buildCmd)
+		if err != nil || exitCode != 0 {
+			c.Fatalf("failed to build the image: %s, %v", out, err)
+		}
+		return out
+	}
+
+	out := build()
+	if strings.Contains(out, "Sending build context") {
+		c.Fatalf("the context should not have been uploaded: %s", out)
+	}
+	id1, err := getIDByName(name)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// changing a file which is not used keeps the cache
+	if err := ctx.Add("unused", "changed"); err != nil {
+		c.Fatal(err)
+	}
+	build()
+	id2, err := getIDByName(name)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id1 != id2 {
+		c.Fatal("the build should have used the cache")
+	}
+
+	// changing a file which is used busts the cache
+	if err := ctx.Add("dir/bar", "changed"); err != nil {
+		c.Fatal(err)
+	}
+	build()
+	id3, err := getIDByName(name)
+	if err != nil {
+		c.Fatal(err)
+	}
+	if id3 == id2 {
+		c.Fatal("the build should not have used the cache")
+	}
+}
+
+func (s *DockerSuite) TestBuildSquash(c *check.C) {
+	name := "testbuildsquash"
+	ctx, err := fakeContext(`FROM busybox
+RUN echo hello > /hello && dd if=/dev/zero of=/zeros bs=1M count=10
+RUN rm /zeros`, map[string]string{})
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer ctx.Close()
+
+	buildCmd := exec.Command(dockerBinary, "build", "--squash", "-t", name, ".")
+	buildCmd.Dir = ctx.Dir
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		c.Fatalf("failed to build the image: %s, %v", out, err)
+	}
+
+	// the intermediate images are kept as build cache
+	buildCmd = exec.Command(dockerBinary, "build", "--squash", "-t", name, ".")
+	buildCmd.Dir = ctx.Dir
+	out, exitCode, err = runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		c.Fatalf("failed to build the image again: %s, %v", out, err)
+	}
+	if strings.Count(out, "Using cache") != 2 {
+		c.Fatalf("expected the instructions to be cached, got %s", out)
+	}
+
+	// the squashed layer is on top of the base image
+	parent, err := inspectField(name, "Parent")
+	if err != nil {
+		c.Fatal(err)
+	}
+	busyboxID, err := getIDByName("busybox")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if parent != busyboxID {
+		c.Fatalf("expected the squashed image to be on top of busybox %s, got %s", busyboxID, parent)
+	}
+	size, err := inspectField(name, "Size")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if n, err := strconv.Atoi(size); err != nil || n > 1024*1024 {
+		c.Fatalf("the removed file should not be in the squashed layer, size %s", size)
+	}
+
+	out, _ = dockerCmd(c, "run", "--rm", name, "cat", "/hello")
+	if strings.TrimSpace(out) != "hello" {
+		c.Fatalf("expected the files of the build in the image, got %q", out)
+	}
+
+	// the history still lists the instructions
+	out, _ = dockerCmd(c, "history", "--no-trunc", name)
+	if !strings.Contains(out, "rm /zeros") || !strings.Contains(out, "dd if=/dev/zero") {
+		c.Fatalf("expected the squashed instructions in the history, got %s", out)
+	}
+}
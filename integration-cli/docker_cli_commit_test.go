@@ -291,3 +291,32 @@ func (s *DockerSuite) TestCommitMergeConfigRun(c *check.C) {
 	}
 
 }
+
+func (s *DockerSuite) TestCommitSquash(c *check.C) {
+	name := "commitsquash"
+	dockerCmd(c, "run", "--name", name, "busybox", "touch", "/foo")
+
+	imageID, _ := dockerCmd(c, "commit", "--squash", name, "squashed")
+	imageID = strings.TrimSpace(imageID)
+
+	// the image is a single layer
+	parent, err := inspectField(imageID, "Parent")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if parent != "" {
+		c.Fatalf("expected the squashed image to have no parent, got %s", parent)
+	}
+
+	dockerCmd(c, "run", "--rm", "squashed", "ls", "/foo")
+
+	// the layers of busybox are kept in the history
+	busyboxID, err := getIDByName("busybox")
+	if err != nil {
+		c.Fatal(err)
+	}
+	out, _ := dockerCmd(c, "history", "-q", "--no-trunc", "squashed")
+	if !strings.Contains(out, busyboxID) {
+		c.Fatalf("expected the busybox layers in the history, got %s", out)
+	}
+}
@@ -596,3 +596,85 @@ func (s *DockerSuite) TestCpNameHasColon(c *check.C) {
 		c.Fatalf("Wrong content in copied file %q, should be %q", content, "lololol\n")
 	}
 }
+
+func (s *DockerSuite) TestCpToContainer(c *check.C) {
+	out, _ := dockerCmd(c, "create", "busybox", "true")
+	cID := strings.TrimSpace(out)
+	defer dockerCmd(c, "rm", "-f", cID)
+
+	tmpdir, err := ioutil.TempDir("", "docker-integration")
+	if err != nil {
+		c.Fatal(err)
+	}
+	defer os.RemoveAll(tmpdir)
+	if err := os.MkdirAll(filepath.Join(tmpdir, "dir"), 0755); err != nil {
+		c.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(tmpdir, "dir", "file"), []byte(cpHostContents), 0644); err != nil {
+		c.Fatal(err)
+	}
+
+	// into an existing directory, keeping the name
+	dockerCmd(c, "cp", filepath.Join(tmpdir, "dir"), cID+":/tmp")
+	// to a new path, renaming the file
+	dockerCmd(c, "cp", filepath.Join(tmpdir, "dir", "file"), cID+":/renamed")
+
+	outDir := filepath.Join(tmpdir, "out")
+	if err := os.Mkdir(outDir, 0755); err != nil {
+		c.Fatal(err)
+	}
+	dockerCmd(c, "cp", cID+":/tmp/dir/file", outDir)
+	// to a local path which does not exist, renaming the file
+	dockerCmd(c, "cp", cID+":/renamed", filepath.Join(outDir, "copy"))
+
+	for _, name := range []string{"file", "copy"} {
+		content, err := ioutil.ReadFile(filepath.Join(outDir, name))
+		if err != nil {
+			c.Fatal(err)
+		}
+		if string(content) != cpHostContents {
+			c.Fatalf("Wrong content in copied file %q, should be %q", content, cpHostContents)
+		}
+	}
+
+	// a directory cannot be copied to a file
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "cp", filepath.Join(tmpdir, "dir"), cID+":/renamed")); err == nil {
+		c.Fatalf("expected an error copying a directory to a file, got %s", out)
+	}
+}
+
+func (s *DockerSuite) TestCpFromStdin(c *check.C) {
+	out, _ := dockerCmd(c, "create", "busybox", "true")
+	cID := strings.TrimSpace(out)
+	defer dockerCmd(c, "rm", "-f", cID)
+
+	out, _, err := runCommandPipelineWithOutput(
+		exec.Command(dockerBinary, "cp", cID+":/etc/passwd", "-"),
+		exec.Command(dockerBinary, "cp", "-", cID+":/tmp"))
+	if err != nil {
+		c.Fatalf("Failed to run commands: %s, %v", out, err)
+	}
+
+	out, _ = dockerCmd(c, "cp", cID+":/tmp/passwd", "-")
+	if !strings.Contains(out, "root:") {
+		c.Fatalf("expected the archive to be extracted in the container, got %s", out)
+	}
+}
+
+func (s *DockerSuite) TestCpToContainerReadOnly(c *check.C) {
+	out, _ := dockerCmd(c, "create", "--read-only", "-v", "/data", "busybox", "true")
+	cID := strings.TrimSpace(out)
+	defer dockerCmd(c, "rm", "-fv", cID)
+
+	tmpfile, err := ioutil.TempFile("", "docker-integration")
+	if err != nil {
+		c.Fatal(err)
+	}
+	tmpfile.Close()
+	defer os.Remove(tmpfile.Name())
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "cp", tmpfile.Name(), cID+":/tmp")); err == nil {
+		c.Fatalf("expected an error copying to a read-only filesystem, got %s", out)
+	}
+	dockerCmd(c, "cp", tmpfile.Name(), cID+":/data")
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -979,7 +980,7 @@ func (s *DockerDaemonSuite) TestDaemonLoggingDriverNoneLogsError(c *check.C) {
 	if err == nil {
 		c.Fatalf("Logs should fail with \"none\" driver")
 	}
-	if !strings.Contains(out, `"logs" command is supported only for "json-file" logging driver`) {
+	if !strings.Contains(out, `"logs" command is supported only for "json-file" and "journald" logging drivers`) {
 		c.Fatalf("There should be error about non-json-file driver, got: %s", out)
 	}
 }
@@ -1255,3 +1256,94 @@ func (s *DockerDaemonSuite) TestCleanupMountsAfterCrash(c *check.C) {
 	c.Assert(err, check.IsNil, check.Commentf("Output: %s", mountOut))
 	c.Assert(strings.Contains(string(mountOut), id), check.Equals, false, check.Commentf("Something mounted from older daemon start: %s", mountOut))
 }
+
+func (s *DockerDaemonSuite) TestDaemonEmbeddedDNSResolvesLinksAfterRestart(c *check.C) {
+	c.Assert(s.d.StartWithBusybox("--embedded-dns"), check.IsNil)
+
+	out, err := s.d.Cmd("run", "-d", "--name", "child", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	out, err = s.d.Cmd("run", "-d", "--name", "parent", "--link", "child:alias", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+
+	out, err = s.d.Cmd("exec", "parent", "cat", "/etc/resolv.conf")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	c.Assert(strings.Contains(out, "nameserver 127.0.0.11"), check.Equals, true, check.Commentf("Output: %s", out))
+
+	out, err = s.d.Cmd("exec", "parent", "cat", "/etc/hosts")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	c.Assert(strings.Contains(out, "alias"), check.Equals, false, check.Commentf("Output: %s", out))
+
+	// the alias resolves to the new address of the child once it is restarted
+	out, err = s.d.Cmd("run", "-d", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	out, err = s.d.Cmd("restart", "-t", "0", "child")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	ip, err := s.d.Cmd("inspect", "--format", "{{.NetworkSettings.IPAddress}}", "child")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", ip))
+
+	out, err = s.d.Cmd("exec", "parent", "nslookup", "alias")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	c.Assert(strings.Contains(out, strings.TrimSpace(ip)), check.Equals, true, check.Commentf("Output: %s", out))
+}
+
+func (s *DockerDaemonSuite) TestDaemonEmbeddedDNSResolvesNetworkAliases(c *check.C) {
+	c.Assert(s.d.StartWithBusybox("--embedded-dns"), check.IsNil)
+
+	for _, name := range []string{"front", "back"} {
+		out, err := s.d.Cmd("network", "create", name)
+		c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	}
+	out, err := s.d.Cmd("run", "-d", "--name", "db", "--net", "back", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	out, err = s.d.Cmd("network", "connect", "--alias", "database", "front", "db")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	out, err = s.d.Cmd("run", "-d", "--name", "web", "--net", "front", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	out, err = s.d.Cmd("run", "-d", "--name", "worker", "--net", "back", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+
+	// the alias resolves to the address of the container in the network it
+	// was connected to with it
+	ip, err := s.d.Cmd("inspect", "--format", "{{(index .NetworkSettings.Networks \"front\").IPAddress}}", "db")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", ip))
+	out, err = s.d.Cmd("exec", "web", "nslookup", "database")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	c.Assert(strings.Contains(out, strings.TrimSpace(ip)), check.Equals, true, check.Commentf("Output: %s", out))
+
+	// and only in that network
+	out, err = s.d.Cmd("exec", "worker", "nslookup", "database")
+	c.Assert(err, check.NotNil, check.Commentf("Output: %s", out))
+}
+
+func (s *DockerDaemonSuite) TestDaemonMetrics(c *check.C) {
+	c.Assert(s.d.StartWithBusybox("--metrics"), check.IsNil)
+
+	out, err := s.d.Cmd("run", "-d", "busybox", "top")
+	c.Assert(err, check.IsNil, check.Commentf("Output: %s", out))
+	id := strings.TrimSpace(out)
+	// let the stats collector collect the stats of the container
+	time.Sleep(2 * time.Second)
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Dial: func(string, string) (net.Conn, error) {
+				return net.Dial("unix", filepath.Join(s.d.folder, "docker.sock"))
+			},
+		},
+	}
+	resp, err := client.Get("http://docker/metrics")
+	c.Assert(err, check.IsNil)
+	defer resp.Body.Close()
+	c.Assert(resp.StatusCode, check.Equals, http.StatusOK)
+	body, err := ioutil.ReadAll(resp.Body)
+	c.Assert(err, check.IsNil)
+
+	for _, expected := range []string{
+		`docker_containers{state="running"} 1`,
+		fmt.Sprintf(`docker_container_memory_usage_bytes{id="%s",`, id),
+		`docker_api_request_duration_seconds_count{method="POST",route="/containers/create"} 1`,
+		"docker_events_subscribers ",
+	} {
+		c.Assert(strings.Contains(string(body), expected), check.Equals, true, check.Commentf("Expected %q in the metrics:\n%s", expected, body))
+	}
+}
@@ -100,7 +100,10 @@ func (s *DockerSuite) TestEventsContainerFailStartDie(c *check.C) {
 
 }
 
-func (s *DockerSuite) TestEventsLimit(c *check.C) {
+func (s *DockerDaemonSuite) TestEventsLimit(c *check.C) {
+	if err := s.d.StartWithBusybox("--events-buffer-size=64"); err != nil {
+		c.Fatal(err)
+	}
 
 	var waitGroup sync.WaitGroup
 	errChan := make(chan error, 17)
@@ -110,7 +113,9 @@ func (s *DockerSuite) TestEventsLimit(c *check.C) {
 		waitGroup.Add(1)
 		go func() {
 			defer waitGroup.Done()
-			errChan <- exec.Command(dockerBinary, args...).Run()
+			if out, err := s.d.Cmd(args[0], args[1:]...); err != nil {
+				errChan <- fmt.Errorf("%v: %s", err, out)
+			}
 		}()
 	}
 
@@ -118,13 +123,13 @@ func (s *DockerSuite) TestEventsLimit(c *check.C) {
 	close(errChan)
 
 	for err := range errChan {
-		if err != nil {
-			c.Fatalf("%q failed with error: %v", strings.Join(args, " "), err)
-		}
+		c.Fatalf("%q failed with error: %v", strings.Join(args, " "), err)
 	}
 
-	eventsCmd := exec.Command(dockerBinary, "events", "--since=0", fmt.Sprintf("--until=%d", daemonTime(c).Unix()))
-	out, _, _ := runCommandWithOutput(eventsCmd)
+	out, err := s.d.Cmd("events", "--since=0", fmt.Sprintf("--until=%d", time.Now().Unix()))
+	if err != nil {
+		c.Fatalf("Failed to get events: %v, %s", err, out)
+	}
 	events := strings.Split(out, "\n")
 	nEvents := len(events) - 1
 	if nEvents != 64 {
@@ -132,6 +137,41 @@ func (s *DockerSuite) TestEventsLimit(c *check.C) {
 	}
 }
 
+func (s *DockerSuite) TestEventsFilterTypeAndLabel(c *check.C) {
+	since := daemonTime(c).Unix()
+	runCmd := exec.Command(dockerBinary, "run", "--name", "labeled", "--label", "tier=front", "busybox", "sh", "-c", "exit 3")
+	if _, exitCode, _ := runCommandWithOutput(runCmd); exitCode != 3 {
+		c.Fatalf("Expected the container to exit with 3, got %d", exitCode)
+	}
+	dockerCmd(c, "run", "--name", "unlabeled", "busybox", "true")
+	dockerCmd(c, "volume", "create", "--name", "eventsvolume")
+	defer dockerCmd(c, "volume", "rm", "eventsvolume")
+	defer dockerCmd(c, "rm", "labeled", "unlabeled")
+
+	eventsOut := func(filters ...string) []string {
+		args := []string{"events", fmt.Sprintf("--since=%d", since), fmt.Sprintf("--until=%d", daemonTime(c).Unix())}
+		for _, f := range filters {
+			args = append(args, "--filter", f)
+		}
+		out, _ := dockerCmd(c, args...)
+		return strings.Split(strings.TrimSpace(out), "\n")
+	}
+
+	events := eventsOut("label=tier=front", "event=die")
+	if len(events) != 1 || !strings.HasSuffix(events[0], " die") {
+		c.Fatalf("Expected the die event of the labeled container, got %q", events)
+	}
+	// the other attributes are not labels
+	events = eventsOut("label=exitCode=3")
+	if len(events) != 1 || events[0] != "" {
+		c.Fatalf("Expected the exit code not to match the label filter, got %q", events)
+	}
+	events = eventsOut("type=volume", "volume=eventsvolume")
+	if len(events) != 1 || !strings.HasSuffix(events[0], "eventsvolume: create") {
+		c.Fatalf("Expected the create event of the volume, got %q", events)
+	}
+}
+
 func (s *DockerSuite) TestEventsContainerEvents(c *check.C) {
 	dockerCmd(c, "run", "--rm", "busybox", "true")
 	eventsCmd := exec.Command(dockerBinary, "events", "--since=0", fmt.Sprintf("--until=%d", daemonTime(c).Unix()))
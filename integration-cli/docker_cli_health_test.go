@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/runconfig"
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestHealth(c *check.C) {
+	testRequires(c, NativeExecDriver)
+
+	imageName := "testhealth"
+	_, err := buildImage(imageName,
+		`FROM busybox
+		RUN echo OK > /status
+		CMD ["/bin/sleep", "120"]
+		HEALTHCHECK --interval=1s --timeout=30s \
+		  CMD cat /status`,
+		true)
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	// No health status before starting
+	name := "test_health"
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "create", "--name", name, imageName))
+	if err != nil {
+		c.Fatal(out, err)
+	}
+	if out, err := inspectField(name, "State.Health"); err != nil || out != "<nil>" {
+		c.Fatalf("expected no health status before start, got %q: %v", out, err)
+	}
+
+	// Start
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "start", name)); err != nil {
+		c.Fatal(out, err)
+	}
+	if err := waitInspect(name, "{{.State.Health.Status}}", "healthy", 10); err != nil {
+		c.Fatal(err)
+	}
+
+	// Inspect the status
+	if out, err := inspectField(name, "State.Health.Status"); err != nil || out != "healthy" {
+		c.Fatalf("expected healthy status, got %q: %v", out, err)
+	}
+	out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "ps", "--filter", "name="+name))
+	if err != nil {
+		c.Fatal(out, err)
+	}
+	if !strings.Contains(out, "(healthy)") {
+		c.Fatalf("expected the health status in ps, got %s", out)
+	}
+
+	// Make it fail
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", name, "rm", "/status")); err != nil {
+		c.Fatal(out, err)
+	}
+	if err := waitInspect(name, "{{.State.Health.Status}}", "unhealthy", 10); err != nil {
+		c.Fatal(err)
+	}
+
+	// Inspect the log
+	out, err = inspectFieldJSON(name, "State.Health")
+	if err != nil {
+		c.Fatal(err)
+	}
+	var health struct {
+		Status        string
+		FailingStreak int
+		Log           []struct {
+			ExitCode int
+			Output   string
+		}
+	}
+	if err := json.Unmarshal([]byte(out), &health); err != nil {
+		c.Fatal(err)
+	}
+	if health.FailingStreak < 3 {
+		c.Fatalf("expected at least 3 failures, got %d", health.FailingStreak)
+	}
+	last := health.Log[len(health.Log)-1]
+	if last.ExitCode != 1 || !strings.Contains(last.Output, "/status") {
+		c.Fatalf("unexpected last probe result: %+v", last)
+	}
+
+	// Fix it
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", name, "touch", "/status")); err != nil {
+		c.Fatal(out, err)
+	}
+	if err := waitInspect(name, "{{.State.Health.Status}}", "healthy", 10); err != nil {
+		c.Fatal(err)
+	}
+
+	dockerCmd(c, "rm", "-f", name)
+
+	// Disable the check from the CLI
+	dockerCmd(c, "create", "--name=noh", "--health-cmd=none", imageName)
+	defer dockerCmd(c, "rm", "-f", "noh")
+	out, err = inspectFieldJSON("noh", "Config.Healthcheck")
+	if err != nil {
+		c.Fatal(err)
+	}
+	var config runconfig.HealthConfig
+	if err := json.Unmarshal([]byte(out), &config); err != nil {
+		c.Fatal(err)
+	}
+	if len(config.Test) != 1 || config.Test[0] != "NONE" {
+		c.Fatalf("expected the healthcheck to be disabled, got %v", config.Test)
+	}
+
+	// Set the check from the CLI
+	dockerCmd(c, "run", "-d", "--name=fatal_healthcheck",
+		"--health-interval=1s",
+		"--health-retries=3",
+		"--health-cmd=cat /status",
+		"busybox", "sleep", "120")
+	defer dockerCmd(c, "rm", "-f", "fatal_healthcheck")
+	if err := waitInspect("fatal_healthcheck", "{{.State.Health.Status}}", "unhealthy", 10); err != nil {
+		c.Fatal(err)
+	}
+}
@@ -11,12 +11,19 @@ import (
 
 func (s *DockerSuite) TestInspectImage(c *check.C) {
 	imageTest := "emptyfs"
-	imageTestID := "511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158"
+	// the ID of the image in the archive it was loaded from still refers
+	// to it, but the image has its content-addressable ID
+	imageTestV1ID := "511136ea3c5a64f264b78b5433614aec563103b4d4702f3ba7d4d2698e22c158"
 	id, err := inspectField(imageTest, "Id")
 	c.Assert(err, check.IsNil)
 
-	if id != imageTestID {
-		c.Fatalf("Expected id: %s for image: %s but received id: %s", imageTestID, imageTest, id)
+	if id == imageTestV1ID || len(id) != 64 {
+		c.Fatalf("Expected a content-addressable id for image: %s but received id: %s", imageTest, id)
+	}
+	v1ID, err := inspectField(imageTestV1ID, "Id")
+	c.Assert(err, check.IsNil)
+	if v1ID != id {
+		c.Fatalf("Expected id: %s for image: %s but received id: %s", id, imageTestV1ID, v1ID)
 	}
 
 }
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestNetworkCliCreate(c *check.C) {
+	dockerCmd(c, "network", "create", "--subnet", "172.28.0.0/16", "testnet")
+	defer dockerCmd(c, "network", "rm", "testnet")
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "testnet"))
+	if err == nil || !strings.Contains(out, "already exists") {
+		c.Fatalf("expected an error creating a network with the same name, got %s", out)
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "create", "bridge")); err == nil {
+		c.Fatalf("expected an error creating a pre-defined network, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "network", "inspect", "testnet")
+	var networks []*types.NetworkResource
+	if err := json.Unmarshal([]byte(out), &networks); err != nil {
+		c.Fatal(err)
+	}
+	if len(networks) != 1 || networks[0].Name != "testnet" || networks[0].Driver != "bridge" {
+		c.Fatalf("unexpected network %s", out)
+	}
+	if networks[0].Subnet != "172.28.0.0/16" || networks[0].Gateway != "172.28.0.1" {
+		c.Fatalf("expected the subnet of the network to be used, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "network", "ls")
+	if !strings.Contains(out, "testnet") || !strings.Contains(out, "bridge") {
+		c.Fatalf("expected the networks to be listed, got %s", out)
+	}
+}
+
+func (s *DockerSuite) TestNetworkCliRunResolvesNames(c *check.C) {
+	dockerCmd(c, "network", "create", "testnet")
+	defer dockerCmd(c, "network", "rm", "testnet")
+
+	dockerCmd(c, "run", "-d", "--net=testnet", "--name=first", "busybox", "top")
+	defer deleteAllContainers()
+	if err := waitRun("first"); err != nil {
+		c.Fatal(err)
+	}
+
+	out, _ := dockerCmd(c, "run", "--rm", "--net=testnet", "busybox", "ping", "-c", "1", "first")
+	if !strings.Contains(out, "1 packets received") {
+		c.Fatalf("expected first to be reachable by name, got %s", out)
+	}
+
+	// a network in use cannot be removed
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "network", "rm", "testnet")); err == nil {
+		c.Fatalf("expected an error removing a network in use, got %s", out)
+	}
+	dockerCmd(c, "rm", "-f", "first")
+}
+
+func (s *DockerSuite) TestNetworkCliConnectIsolation(c *check.C) {
+	dockerCmd(c, "network", "create", "testnet")
+	defer dockerCmd(c, "network", "rm", "testnet")
+
+	dockerCmd(c, "run", "-d", "--net=testnet", "--name=first", "busybox", "top")
+	dockerCmd(c, "run", "-d", "--name=second", "busybox", "top")
+	defer deleteAllContainers()
+	if err := waitRun("second"); err != nil {
+		c.Fatal(err)
+	}
+
+	// the containers of the default bridge cannot reach the network
+	ip, err := inspectField("first", "NetworkSettings.IPAddress")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "second", "ping", "-c", "1", "-W", "1", ip)); err == nil {
+		c.Fatalf("expected first to be isolated from the default bridge, got %s", out)
+	}
+
+	dockerCmd(c, "network", "connect", "testnet", "second")
+	out, _ := dockerCmd(c, "exec", "second", "ping", "-c", "1", "first")
+	if !strings.Contains(out, "1 packets received") {
+		c.Fatalf("expected first to be reachable once connected, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "network", "inspect", "testnet")
+	if !strings.Contains(out, `"Name": "second"`) {
+		c.Fatalf("expected second to be an endpoint of the network, got %s", out)
+	}
+
+	dockerCmd(c, "network", "disconnect", "testnet", "second")
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "exec", "second", "ping", "-c", "1", "-W", "1", ip)); err == nil {
+		c.Fatalf("expected first to be unreachable once disconnected, got %s", out)
+	}
+	dockerCmd(c, "rm", "-f", "first", "second")
+}
@@ -221,3 +221,24 @@ func (s *DockerSuite) TestPortExposeHostBinding(c *check.C) {
 		c.Error("Port is still bound after the Container is removed")
 	}
 }
+
+func (s *DockerSuite) TestPortListIPv6(c *check.C) {
+	out, _ := dockerCmd(c, "run", "-d", "-p", "[::1]:9876:80", "-p", "0.0.0.0:9877:81", "busybox", "top")
+	ID := strings.TrimSpace(out)
+	defer dockerCmd(c, "rm", "-f", ID)
+
+	out, _ = dockerCmd(c, "port", ID, "80")
+	if !assertPortList(c, out, []string{"[::1]:9876"}) {
+		c.Error("Port list is not correct")
+	}
+
+	out, _ = dockerCmd(c, "port", ID)
+	if !assertPortList(c, out, []string{"80/tcp -> [::1]:9876", "81/tcp -> 0.0.0.0:9877"}) {
+		c.Error("Port list is not correct")
+	}
+
+	// the IPv4 binding is not published on the IPv6 addresses
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--net=host", "busybox", "nc", "::1", "9877")); err == nil {
+		c.Fatalf("Port published on 0.0.0.0 is reachable on ::1: %s", out)
+	}
+}
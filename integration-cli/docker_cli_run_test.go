@@ -2194,6 +2194,39 @@ func (s *DockerSuite) TestRunSetMacAddress(c *check.C) {
 	}
 }
 
+func (s *DockerSuite) TestRunSetIPAddress(c *check.C) {
+	ip := "172.17.100.104"
+	dockerCmd(c, "run", "-d", "--name=static", "--ip="+ip, "busybox", "top")
+	defer deleteAllContainers()
+
+	for i := 0; i < 2; i++ {
+		actualIP, err := inspectField("static", "NetworkSettings.IPAddress")
+		c.Assert(err, check.IsNil)
+		if actualIP != ip {
+			c.Fatalf("Set IP address with --ip failed. The container has an incorrect IP address: %q, expected: %q", actualIP, ip)
+		}
+		// the container keeps its address when it is restarted
+		dockerCmd(c, "restart", "static")
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "create", "--ip="+ip, "busybox", "top"))
+	if err == nil || !strings.Contains(out, "already assigned to container static") {
+		c.Fatalf("Expected a conflict creating a container with an address in use, got %s", out)
+	}
+}
+
+func (s *DockerSuite) TestRunSetIPAddressOfRunningContainer(c *check.C) {
+	dockerCmd(c, "run", "-d", "--name=dynamic", "busybox", "top")
+	defer deleteAllContainers()
+
+	ip, err := inspectField("dynamic", "NetworkSettings.IPAddress")
+	c.Assert(err, check.IsNil)
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "create", "--ip="+ip, "busybox", "top"))
+	if err == nil || !strings.Contains(out, "already in use by container dynamic") {
+		c.Fatalf("Expected a conflict creating a container with the address of a running container, got %s", out)
+	}
+}
+
 func (s *DockerSuite) TestRunInspectMacAddress(c *check.C) {
 	mac := "12:34:56:78:9a:bc"
 	cmd := exec.Command(dockerBinary, "run", "-d", "--mac-address="+mac, "busybox", "top")
@@ -250,3 +250,26 @@ func (s *DockerSuite) TestRunAttachDetach(c *check.C) {
 		c.Fatal("timed out waiting for container to exit")
 	}
 }
+
+func (s *DockerSuite) TestRunStopSignal(c *check.C) {
+	out, _ := dockerCmd(c, "run", "-d", "--stop-signal=SIGUSR1", "busybox",
+		"sh", "-c", "trap 'exit 42' USR1; while true; do sleep 1; done")
+	id := strings.TrimSpace(out)
+	if err := waitRun(id); err != nil {
+		c.Fatal(err)
+	}
+
+	dockerCmd(c, "stop", "-t", "30", id)
+
+	exitCode, err := inspectField(id, "State.ExitCode")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if exitCode != "42" {
+		c.Fatalf("expected the container to exit on SIGUSR1 with code 42, got %s", exitCode)
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--stop-signal=SIGFOO", "busybox", "true")); err == nil {
+		c.Fatalf("run should have failed on the invalid signal: %s", out)
+	}
+}
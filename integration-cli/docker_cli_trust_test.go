@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/go-check/check"
+)
+
+// setupTrustedImage pushes the busybox image to the private registry with
+// content trust, and removes it locally.
+func (s *DockerTrustSuite) setupTrustedImage(c *check.C, name string) string {
+	repoName := fmt.Sprintf("%v/dockercli/%s:latest", privateRegistryURL, name)
+	dockerCmd(c, "tag", "busybox", repoName)
+
+	pushCmd := exec.Command(dockerBinary, "push", repoName)
+	s.ts.trustedCmd(pushCmd)
+	out, _, err := runCommandWithOutput(pushCmd)
+	if err != nil {
+		c.Fatalf("Error running trusted push: %s\n%s", err, out)
+	}
+	if !strings.Contains(out, "Signed "+repoName) {
+		c.Fatalf("Missing expected output on trusted push:\n%s", out)
+	}
+
+	dockerCmd(c, "rmi", repoName)
+	return repoName
+}
+
+func (s *DockerTrustSuite) TestTrustedPull(c *check.C) {
+	repoName := s.setupTrustedImage(c, "trusted-pull")
+
+	pullCmd := exec.Command(dockerBinary, "pull", repoName)
+	s.ts.trustedCmd(pullCmd)
+	out, _, err := runCommandWithOutput(pullCmd)
+	if err != nil {
+		c.Fatalf("Error running trusted pull: %s\n%s", err, out)
+	}
+	if !strings.Contains(out, "Tagging") {
+		c.Fatalf("Missing expected output on trusted pull:\n%s", out)
+	}
+	dockerCmd(c, "inspect", repoName)
+}
+
+func (s *DockerTrustSuite) TestTrustedPullRefusesUnsigned(c *check.C) {
+	repoName := fmt.Sprintf("%v/dockercli/untrusted-pull:latest", privateRegistryURL)
+	dockerCmd(c, "tag", "busybox", repoName)
+	dockerCmd(c, "push", repoName)
+	dockerCmd(c, "rmi", repoName)
+
+	pullCmd := exec.Command(dockerBinary, "pull", repoName)
+	s.ts.trustedCmd(pullCmd)
+	out, _, err := runCommandWithOutput(pullCmd)
+	if err == nil || !strings.Contains(out, "No trust data") {
+		c.Fatalf("Expected the pull of an unsigned image to fail:\n%s", out)
+	}
+
+	// the verification can be disabled
+	pullCmd = exec.Command(dockerBinary, "pull", "--disable-content-trust", repoName)
+	s.ts.trustedCmd(pullCmd)
+	if out, _, err := runCommandWithOutput(pullCmd); err != nil {
+		c.Fatalf("Error running untrusted pull: %s\n%s", err, out)
+	}
+}
+
+func (s *DockerTrustSuite) TestTrustedPullRefusesRollback(c *check.C) {
+	repoName := s.setupTrustedImage(c, "trusted-rollback")
+	old := s.ts.snapshot()
+
+	// sign the tag again, and pull it to see the new version
+	dockerCmd(c, "pull", repoName)
+	pushCmd := exec.Command(dockerBinary, "push", repoName)
+	s.ts.trustedCmd(pushCmd)
+	if out, _, err := runCommandWithOutput(pushCmd); err != nil {
+		c.Fatalf("Error running trusted push: %s\n%s", err, out)
+	}
+	pullCmd := exec.Command(dockerBinary, "pull", repoName)
+	s.ts.trustedCmd(pullCmd)
+	if out, _, err := runCommandWithOutput(pullCmd); err != nil {
+		c.Fatalf("Error running trusted pull: %s\n%s", err, out)
+	}
+
+	// the first version served again is refused
+	s.ts.restore(old)
+	pullCmd = exec.Command(dockerBinary, "pull", repoName)
+	s.ts.trustedCmd(pullCmd)
+	out, _, err := runCommandWithOutput(pullCmd)
+	if err == nil || !strings.Contains(out, "older than the data seen already") {
+		c.Fatalf("Expected the pull of older trust data to fail:\n%s", out)
+	}
+}
+
+func (s *DockerTrustSuite) TestTrustedRun(c *check.C) {
+	repoName := s.setupTrustedImage(c, "trusted-run")
+
+	runCmd := exec.Command(dockerBinary, "run", "--name", "trusted", repoName, "true")
+	s.ts.trustedCmd(runCmd)
+	out, _, err := runCommandWithOutput(runCmd)
+	if err != nil {
+		c.Fatalf("Error running trusted run: %s\n%s", err, out)
+	}
+
+	// the container is created from the signed digest
+	out, _ = dockerCmd(c, "inspect", "-f", "{{.Config.Image}}", "trusted")
+	if !strings.Contains(out, "@sha256:") {
+		c.Fatalf("Expected the container to use the image by digest, got %s", out)
+	}
+	dockerCmd(c, "inspect", repoName)
+}
+
+func (s *DockerTrustSuite) TestTrustedBuild(c *check.C) {
+	repoName := s.setupTrustedImage(c, "trusted-build")
+
+	buildCmd := exec.Command(dockerBinary, "build", "-t", "trusted-build", "-")
+	s.ts.trustedCmd(buildCmd)
+	buildCmd.Stdin = strings.NewReader(fmt.Sprintf("FROM %s\nRUN true\n", repoName))
+	out, _, err := runCommandWithOutput(buildCmd)
+	if err != nil {
+		c.Fatalf("Error running trusted build: %s\n%s", err, out)
+	}
+	if !strings.Contains(out, "resolved to the signed digest") {
+		c.Fatalf("Missing expected output on trusted build:\n%s", out)
+	}
+
+	// a base image without trust data is refused
+	buildCmd = exec.Command(dockerBinary, "build", "-t", "untrusted-build", "-")
+	s.ts.trustedCmd(buildCmd)
+	buildCmd.Stdin = strings.NewReader(fmt.Sprintf("FROM %v/dockercli/missing\nRUN true\n", privateRegistryURL))
+	out, _, err = runCommandWithOutput(buildCmd)
+	if err == nil || !strings.Contains(out, "No trust data") {
+		c.Fatalf("Expected the build from an unsigned image to fail:\n%s", out)
+	}
+}
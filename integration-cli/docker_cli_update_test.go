@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestUpdateRunningContainer(c *check.C) {
+	testRequires(c, NativeExecDriver)
+
+	out, _ := dockerCmd(c, "run", "-d", "--name", "update-running", "-m", "32m", "--cpu-shares", "512", "busybox", "top")
+	id := strings.TrimSpace(out)
+	c.Assert(waitRun(id), check.IsNil)
+	defer dockerCmd(c, "rm", "-f", id)
+
+	dockerCmd(c, "update", "-m", "64m", "--cpu-shares", "256", "update-running")
+
+	out, _ = dockerCmd(c, "exec", id, "cat", "/sys/fs/cgroup/memory/memory.limit_in_bytes")
+	if strings.TrimSpace(out) != "67108864" {
+		c.Fatalf("expected the memory limit of the running container to be updated, got %s", out)
+	}
+	out, _ = dockerCmd(c, "exec", id, "cat", "/sys/fs/cgroup/cpu/cpu.shares")
+	if strings.TrimSpace(out) != "256" {
+		c.Fatalf("expected the cpu shares of the running container to be updated, got %s", out)
+	}
+
+	// the update is kept in the host config
+	memory, err := inspectField(id, "HostConfig.Memory")
+	c.Assert(err, check.IsNil)
+	c.Assert(memory, check.Equals, "67108864")
+	shares, err := inspectField(id, "HostConfig.CpuShares")
+	c.Assert(err, check.IsNil)
+	c.Assert(shares, check.Equals, "256")
+}
+
+func (s *DockerSuite) TestUpdateRestartPolicy(c *check.C) {
+	out, _ := dockerCmd(c, "create", "--name", "update-restart", "busybox", "true")
+	id := strings.TrimSpace(out)
+	defer dockerCmd(c, "rm", "-f", id)
+
+	dockerCmd(c, "update", "--restart", "on-failure:3", "update-restart")
+
+	name, err := inspectField(id, "HostConfig.RestartPolicy.Name")
+	c.Assert(err, check.IsNil)
+	c.Assert(name, check.Equals, "on-failure")
+	count, err := inspectField(id, "HostConfig.RestartPolicy.MaximumRetryCount")
+	c.Assert(err, check.IsNil)
+	c.Assert(count, check.Equals, "3")
+}
+
+func (s *DockerSuite) TestUpdateInvalid(c *check.C) {
+	dockerCmd(c, "create", "--name", "update-invalid", "busybox", "true")
+	defer dockerCmd(c, "rm", "-f", "update-invalid")
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "update", "-m", "1k", "update-invalid")); err == nil || !strings.Contains(out, "Minimum memory limit") {
+		c.Fatalf("expected an error updating the memory limit below the minimum, got %s", out)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "update", "--cpu-shares", "512", "notexist")); err == nil {
+		c.Fatalf("expected an error updating a missing container, got %s", out)
+	}
+}
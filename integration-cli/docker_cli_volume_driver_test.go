@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/go-check/check"
+)
+
+const volumePluginsDir = "/usr/share/docker/plugins"
+
+// fakeVolumePlugin serves a volume driver plugin on a unix socket in the
+// plugins directory, keeping the volumes in a temporary directory and the
+// calls it received.
+type fakeVolumePlugin struct {
+	sync.Mutex
+	name  string
+	root  string
+	l     net.Listener
+	calls []string
+}
+
+func newFakeVolumePlugin(c *check.C, name string) *fakeVolumePlugin {
+	root, err := ioutil.TempDir("", "docker-volume-plugin")
+	if err != nil {
+		c.Fatal(err)
+	}
+	if err := os.MkdirAll(volumePluginsDir, 0755); err != nil {
+		c.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(volumePluginsDir, name+".sock"))
+	if err != nil {
+		c.Fatal(err)
+	}
+
+	p := &fakeVolumePlugin{name: name, root: root, l: l}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"Implements": ["VolumeDriver"]}`)
+	})
+	mux.HandleFunc("/", p.handle)
+	go http.Serve(l, mux)
+	return p
+}
+
+func (p *fakeVolumePlugin) handle(w http.ResponseWriter, r *http.Request) {
+	var req struct{ Name string }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/VolumeDriver.")
+
+	p.Lock()
+	p.calls = append(p.calls, method)
+	p.Unlock()
+
+	var resp struct{ Mountpoint, Err string }
+	mountpoint := filepath.Join(p.root, req.Name)
+	switch method {
+	case "Create":
+		if err := os.MkdirAll(mountpoint, 0755); err != nil {
+			resp.Err = err.Error()
+		}
+	case "Remove":
+		if err := os.RemoveAll(mountpoint); err != nil {
+			resp.Err = err.Error()
+		}
+	case "Mount", "Path":
+		resp.Mountpoint = mountpoint
+	case "Unmount":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (p *fakeVolumePlugin) Calls() string {
+	p.Lock()
+	defer p.Unlock()
+	return strings.Join(p.calls, ",")
+}
+
+func (p *fakeVolumePlugin) Close() {
+	p.l.Close()
+	os.RemoveAll(p.root)
+	os.Remove(filepath.Join(volumePluginsDir, p.name+".sock"))
+}
+
+func (s *DockerSuite) TestVolumeDriverRunNamedVolume(c *check.C) {
+	testRequires(c, SameHostDaemon)
+	p := newFakeVolumePlugin(c, "test-volume-driver")
+	defer p.Close()
+
+	out, _ := dockerCmd(c, "run", "--rm", "--volume-driver", "test-volume-driver", "-v", "external:/data", "busybox", "sh", "-c", "echo hello > /data/file")
+	if data, err := ioutil.ReadFile(filepath.Join(p.root, "external", "file")); err != nil || string(data) != "hello\n" {
+		c.Fatalf("expected the data to be written in the volume of the plugin, got %q, %v: %s", data, err, out)
+	}
+	if calls := p.Calls(); calls != "Create,Path,Mount,Unmount" {
+		c.Fatalf("unexpected calls to the plugin: %s", calls)
+	}
+
+	out, _ = dockerCmd(c, "volume", "ls")
+	if !strings.Contains(out, "test-volume-driver") {
+		c.Fatalf("expected the volume of the plugin to be listed, got %s", out)
+	}
+
+	dockerCmd(c, "volume", "rm", "external")
+	if _, err := os.Stat(filepath.Join(p.root, "external")); !os.IsNotExist(err) {
+		c.Fatalf("expected the volume to be removed by the plugin, got %v", err)
+	}
+}
+
+func (s *DockerSuite) TestVolumeDriverCreateMissingPlugin(c *check.C) {
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "volume", "create", "--driver", "missing-volume-driver", "--name", "missing"))
+	if err == nil || !strings.Contains(out, "missing-volume-driver") {
+		c.Fatalf("expected an error creating a volume with a missing plugin, got %s", out)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"os/exec"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/go-check/check"
+)
+
+func (s *DockerSuite) TestVolumeCliCreate(c *check.C) {
+	out, _ := dockerCmd(c, "volume", "create", "--name", "test", "--label", "com.example.key=value")
+	if name := strings.TrimSpace(out); name != "test" {
+		c.Fatalf("expected the name of the volume to be printed, got %q", out)
+	}
+	defer dockerCmd(c, "volume", "rm", "test")
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "volume", "create", "--name", "test"))
+	if err == nil || !strings.Contains(out, "already exists") {
+		c.Fatalf("expected an error creating a volume with the same name, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "volume", "inspect", "test")
+	var volumes []*types.Volume
+	if err := json.Unmarshal([]byte(out), &volumes); err != nil {
+		c.Fatal(err)
+	}
+	if len(volumes) != 1 || volumes[0].Name != "test" || volumes[0].Labels["com.example.key"] != "value" {
+		c.Fatalf("unexpected volume %s", out)
+	}
+}
+
+func (s *DockerSuite) TestVolumeCliRunNamedVolume(c *check.C) {
+	dockerCmd(c, "run", "--name", "writer", "-v", "testdata:/foo", "busybox", "sh", "-c", "echo hello > /foo/bar")
+	dockerCmd(c, "rm", "-v", "writer")
+
+	// the named volume is kept when its container is removed
+	out, _ := dockerCmd(c, "run", "--rm", "-v", "testdata:/foo", "busybox", "cat", "/foo/bar")
+	if strings.TrimSpace(out) != "hello" {
+		c.Fatalf("expected the data of the volume to be kept, got %q", out)
+	}
+
+	dockerCmd(c, "volume", "rm", "testdata")
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "volume", "inspect", "testdata")); err == nil {
+		c.Fatalf("expected the volume to be removed, got %s", out)
+	}
+}
+
+func (s *DockerSuite) TestVolumeCliLsFilterDangling(c *check.C) {
+	dockerCmd(c, "volume", "create", "--name", "testnotinuse")
+	defer dockerCmd(c, "volume", "rm", "testnotinuse")
+	dockerCmd(c, "create", "--name", "user", "-v", "testisinuse:/foo", "busybox")
+	defer dockerCmd(c, "volume", "rm", "testisinuse")
+	defer dockerCmd(c, "rm", "user")
+
+	out, _ := dockerCmd(c, "volume", "ls", "-q")
+	if !strings.Contains(out, "testnotinuse\n") || !strings.Contains(out, "testisinuse\n") {
+		c.Fatalf("expected both volumes to be listed, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "volume", "ls", "-q", "-f", "dangling=true")
+	if !strings.Contains(out, "testnotinuse\n") || strings.Contains(out, "testisinuse\n") {
+		c.Fatalf("expected only the volume not in use to be listed, got %s", out)
+	}
+
+	out, _ = dockerCmd(c, "volume", "ls", "-q", "-f", "dangling=false")
+	if strings.Contains(out, "testnotinuse\n") || !strings.Contains(out, "testisinuse\n") {
+		c.Fatalf("expected only the volume in use to be listed, got %s", out)
+	}
+
+	// a volume in use cannot be removed
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "volume", "rm", "testisinuse")); err == nil {
+		c.Fatalf("expected an error removing a volume in use, got %s", out)
+	}
+}
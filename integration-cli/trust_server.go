@@ -0,0 +1,103 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/go-check/check"
+)
+
+// testTrustServer is a signing server keeping the signed targets in memory,
+// for the client to run with content trust offline. The client uses its
+// own home directory, keeping the keys of the repositories.
+type testTrustServer struct {
+	server *httptest.Server
+	home   string
+
+	mu      sync.Mutex
+	targets map[string][]byte
+}
+
+func newTestTrustServer(c *check.C) *testTrustServer {
+	home, err := ioutil.TempDir("", "trust-home")
+	if err != nil {
+		c.Fatal(err)
+	}
+	t := &testTrustServer{
+		home:    home,
+		targets: make(map[string][]byte),
+	}
+	t.server = httptest.NewServer(t)
+	return t
+}
+
+func (t *testTrustServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/trust/v1/") {
+		http.NotFound(w, r)
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	switch r.Method {
+	case "GET":
+		signed, exists := t.targets[r.URL.Path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(signed)
+	case "PUT":
+		signed, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		t.targets[r.URL.Path] = signed
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// snapshot returns a copy of the signed targets, by path.
+func (t *testTrustServer) snapshot() map[string][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	targets := make(map[string][]byte, len(t.targets))
+	for path, signed := range t.targets {
+		targets[path] = signed
+	}
+	return targets
+}
+
+// restore replaces the signed targets with a snapshot.
+func (t *testTrustServer) restore(targets map[string][]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.targets = targets
+}
+
+// trustedCmd makes cmd run the client with content trust.
+func (t *testTrustServer) trustedCmd(cmd *exec.Cmd) {
+	env := []string{
+		"DOCKER_CONTENT_TRUST=1",
+		"DOCKER_CONTENT_TRUST_SERVER=" + t.server.URL,
+		"HOME=" + t.home,
+	}
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "HOME=") {
+			env = append(env, e)
+		}
+	}
+	cmd.Env = env
+}
+
+func (t *testTrustServer) Close() {
+	t.server.Close()
+	os.RemoveAll(t.home)
+}
@@ -88,7 +88,7 @@ func validateProto(proto string) bool {
 }
 
 // We will receive port specs in the format of ip:public:private/proto and these need to be
-// parsed in the internal types
+// parsed in the internal types. An IPv6 ip is enclosed in brackets: [ip]:public:private/proto
 func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding, error) {
 	var (
 		exposedPorts = make(map[Port]struct{}, len(ports))
@@ -102,6 +102,21 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 			proto = rawPort[i+1:]
 			rawPort = rawPort[:i]
 		}
+
+		// An IPv6 address is enclosed in brackets, as in [::1]:8080:80
+		var bracketedIp string
+		if strings.HasPrefix(rawPort, "[") {
+			i := strings.Index(rawPort, "]:")
+			if i == -1 || strings.Count(rawPort[i+2:], ":") != 1 {
+				return nil, nil, fmt.Errorf("Invalid port specification: %s", rawPort)
+			}
+			bracketedIp = rawPort[1:i]
+			if bracketedIp == "" {
+				return nil, nil, fmt.Errorf("Invalid ip address: %s", rawPort)
+			}
+			rawPort = rawPort[i+1:]
+		}
+
 		if !strings.Contains(rawPort, ":") {
 			rawPort = fmt.Sprintf("::%s", rawPort)
 		} else if len(strings.Split(rawPort, ":")) == 2 {
@@ -118,6 +133,9 @@ func ParsePortSpecs(ports []string) (map[Port]struct{}, map[Port][]PortBinding,
 			rawIp         = parts["ip"]
 			hostPort      = parts["hostPort"]
 		)
+		if bracketedIp != "" {
+			rawIp = bracketedIp
+		}
 
 		if rawIp != "" && net.ParseIP(rawIp) == nil {
 			return nil, nil, fmt.Errorf("Invalid ip address: %s", rawIp)
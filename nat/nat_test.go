@@ -291,3 +291,31 @@ func TestParsePortSpecsWithRange(t *testing.T) {
 		t.Fatal("Received no error while trying to parse a hostname instead of ip")
 	}
 }
+
+func TestParsePortSpecsIPv6(t *testing.T) {
+	portMap, bindingMap, err := ParsePortSpecs([]string{"[::1]:8080:80/tcp", "[fd00::1]::53/udp", "[::]:1234-1235:1234-1235"})
+	if err != nil {
+		t.Fatalf("Error while processing ParsePortSpecs: %s", err)
+	}
+
+	expected := map[Port]PortBinding{
+		"80/tcp":   {HostIp: "::1", HostPort: "8080"},
+		"53/udp":   {HostIp: "fd00::1", HostPort: ""},
+		"1234/tcp": {HostIp: "::", HostPort: "1234"},
+		"1235/tcp": {HostIp: "::", HostPort: "1235"},
+	}
+	if len(portMap) != len(expected) {
+		t.Fatalf("Expected %d exposed ports, got %v", len(expected), portMap)
+	}
+	for port, binding := range expected {
+		if bindings := bindingMap[port]; len(bindings) != 1 || bindings[0] != binding {
+			t.Fatalf("Expected binding %v for %s, got %v", binding, port, bindings)
+		}
+	}
+
+	for _, spec := range []string{"[::1]", "[::1]:80", "[::1:8080:80", "[]:8080:80", "[::1]:8080:80:80", "[localhost]:8080:80", "::1:8080:80"} {
+		if _, _, err := ParsePortSpecs([]string{spec}); err == nil {
+			t.Fatalf("Received no error while trying to parse %s", spec)
+		}
+	}
+}
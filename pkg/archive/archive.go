@@ -34,6 +34,9 @@ type (
 		Compression     Compression
 		NoLchown        bool
 		Name            string
+		// When unpacking, an existing directory is not replaced with a
+		// non-directory, nor an existing non-directory with a directory.
+		NoOverwriteDirNonDir bool
 	}
 
 	// Archiver allows the reuse of most utility functions of this package
@@ -541,6 +544,12 @@ loop:
 			if fi.IsDir() && hdr.Name == "." {
 				continue
 			}
+			if options.NoOverwriteDirNonDir && fi.IsDir() != (hdr.Typeflag == tar.TypeDir) {
+				if fi.IsDir() {
+					return fmt.Errorf("cannot overwrite directory %q with non-directory %q", path, hdr.Name)
+				}
+				return fmt.Errorf("cannot overwrite non-directory %q with directory %q", path, hdr.Name)
+			}
 			if !(fi.IsDir() && hdr.Typeflag == tar.TypeDir) {
 				if err := os.RemoveAll(path); err != nil {
 					return err
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SplitPathDirEntry cleans the given path and splits it into its parent
+// directory and its base name.
+func SplitPathDirEntry(path string) (dir, base string) {
+	cleanedPath := filepath.Clean(path)
+	return filepath.Dir(cleanedPath), filepath.Base(cleanedPath)
+}
+
+// TarResource archives the file or directory at the given path, the entries
+// of the archive being named after the base name of the path.
+func TarResource(sourcePath string) (Archive, error) {
+	if _, err := os.Lstat(sourcePath); err != nil {
+		return nil, err
+	}
+	sourceDir, sourceBase := SplitPathDirEntry(sourcePath)
+	return TarWithOptions(sourceDir, &TarOptions{
+		Compression:  Uncompressed,
+		IncludeFiles: []string{sourceBase},
+	})
+}
+
+// RebaseArchiveEntries rewrites the entries of the given uncompressed tar
+// archive which are named oldBase or are below oldBase so that they are named
+// after newBase instead, e.g. to copy a resource to a path with another name.
+// The other entries are kept unchanged.
+func RebaseArchiveEntries(srcContent ArchiveReader, oldBase, newBase string) Archive {
+	rebased, w := io.Pipe()
+
+	go func() {
+		srcTar := tar.NewReader(srcContent)
+		rebasedTar := tar.NewWriter(w)
+
+		for {
+			hdr, err := srcTar.Next()
+			if err == io.EOF {
+				// Signals end of archive.
+				rebasedTar.Close()
+				w.Close()
+				return
+			}
+			if err != nil {
+				w.CloseWithError(err)
+				return
+			}
+
+			hdr.Name = rebaseName(hdr.Name, oldBase, newBase)
+			if hdr.Typeflag == tar.TypeLink {
+				// Hard links are relative to the root of the archive too.
+				hdr.Linkname = rebaseName(hdr.Linkname, oldBase, newBase)
+			}
+
+			if err = rebasedTar.WriteHeader(hdr); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+			if _, err = io.Copy(rebasedTar, srcTar); err != nil {
+				w.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	return rebased
+}
+
+// rebaseName replaces oldBase with newBase in name if name is oldBase or is
+// below oldBase.
+func rebaseName(name, oldBase, newBase string) string {
+	if name == oldBase {
+		return newBase
+	}
+	if strings.HasPrefix(name, oldBase+"/") {
+		return newBase + name[len(oldBase):]
+	}
+	return name
+}
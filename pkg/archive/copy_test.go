@@ -0,0 +1,111 @@
+package archive
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitPathDirEntry(t *testing.T) {
+	for _, tc := range []struct{ path, dir, base string }{
+		{"/foo/bar", "/foo", "bar"},
+		{"/foo/bar/", "/foo", "bar"},
+		{"foo/../bar", ".", "bar"},
+		{"/foo", "/", "foo"},
+	} {
+		dir, base := SplitPathDirEntry(tc.path)
+		if dir != tc.dir || base != tc.base {
+			t.Fatalf("SplitPathDirEntry(%q) = %q, %q; expected %q, %q", tc.path, dir, base, tc.dir, tc.base)
+		}
+	}
+}
+
+func TestTarResourceRebase(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "docker-archive-copy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(filepath.Join(src, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "sub", "file"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A sibling sharing the prefix of the resource must not be archived nor
+	// rebased.
+	if err := ioutil.WriteFile(filepath.Join(tmpDir, "srcfile"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	srcArchive, err := TarResource(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcArchive.Close()
+
+	dst := filepath.Join(tmpDir, "dst")
+	if err := os.MkdirAll(dst, 0755); err != nil {
+		t.Fatal(err)
+	}
+	rebased := RebaseArchiveEntries(srcArchive, "src", "renamed")
+	defer rebased.Close()
+	if err := Untar(rebased, dst, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(dst, "renamed", "sub", "file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "hello" {
+		t.Fatalf("unexpected content %q", content)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "src")); !os.IsNotExist(err) {
+		t.Fatalf("expected the resource to be renamed, got %v", err)
+	}
+	if _, err := os.Lstat(filepath.Join(dst, "srcfile")); !os.IsNotExist(err) {
+		t.Fatalf("expected only the resource to be archived, got %v", err)
+	}
+}
+
+func TestTarResourceMissing(t *testing.T) {
+	if _, err := TarResource("/does/not/exist"); !os.IsNotExist(err) {
+		t.Fatalf("expected a not exist error, got %v", err)
+	}
+}
+
+func TestUntarNoOverwriteDirNonDir(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "docker-archive-copy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	src := filepath.Join(tmpDir, "src")
+	if err := os.MkdirAll(src, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(src, "foo"), []byte("file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(tmpDir, "dst")
+	if err := os.MkdirAll(filepath.Join(dst, "foo"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	srcArchive, err := TarResource(filepath.Join(src, "foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srcArchive.Close()
+	if err := Untar(srcArchive, dst, &TarOptions{NoOverwriteDirNonDir: true}); err == nil {
+		t.Fatal("expected an error overwriting a directory with a file")
+	}
+	if fi, err := os.Lstat(filepath.Join(dst, "foo")); err != nil || !fi.IsDir() {
+		t.Fatalf("expected the directory to be kept, got %v", err)
+	}
+}
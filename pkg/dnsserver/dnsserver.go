@@ -0,0 +1,344 @@
+// Package dnsserver implements a minimal DNS server, which answers the A and
+// AAAA queries for the names it knows and forwards the other queries to
+// upstream servers.
+package dnsserver
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+const (
+	headerLen = 12
+
+	typeA    = 1
+	typeAAAA = 28
+	classIN  = 1
+
+	flagResponse           = 1 << 15
+	flagAuthoritative      = 1 << 10
+	flagRecursionDesired   = 1 << 8
+	flagRecursionAvailable = 1 << 7
+
+	rcodeServerFailure = 2
+
+	// The TTL of the answers, in seconds, short as the addresses of the
+	// containers change when they are restarted
+	answerTTL = 5
+	// The maximum size of a message over UDP, with EDNS0
+	maxUDPLen = 4096
+	// The time given to an upstream server to answer
+	upstreamTimeout = 4 * time.Second
+)
+
+// DefaultUpstreamPort is the port of the upstream servers, unless
+// configured otherwise.
+const DefaultUpstreamPort = 53
+
+var errMalformedMessage = errors.New("malformed DNS message")
+
+// LookupFunc returns the addresses of a name, or nil if the name is not known
+// to the server. The name is lower case, without a trailing dot.
+type LookupFunc func(name string) []net.IP
+
+// Server is a DNS server. It serves the queries received on a UDP connection
+// and a TCP listener until it is closed.
+type Server struct {
+	lookup    LookupFunc
+	upstreams []string
+
+	udp net.PacketConn
+	tcp net.Listener
+
+	closeOnce sync.Once
+}
+
+// New returns a server which answers the queries for the names known to
+// lookup, and forwards the others to the upstream servers, given as IP
+// addresses, on port.
+func New(lookup LookupFunc, upstreams []string, port int) *Server {
+	s := &Server{lookup: lookup}
+	for _, u := range upstreams {
+		s.upstreams = append(s.upstreams, net.JoinHostPort(u, strconv.Itoa(port)))
+	}
+	return s
+}
+
+// Serve starts serving the queries received on udp and tcp, in the
+// background.
+func (s *Server) Serve(udp net.PacketConn, tcp net.Listener) {
+	s.udp = udp
+	s.tcp = tcp
+	go s.serveUDP()
+	go s.serveTCP()
+}
+
+// Close stops the server, and closes its connection and listener.
+func (s *Server) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		if s.udp != nil {
+			err = s.udp.Close()
+		}
+		if s.tcp != nil {
+			if tcpErr := s.tcp.Close(); err == nil {
+				err = tcpErr
+			}
+		}
+	})
+	return err
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, maxUDPLen)
+	for {
+		n, addr, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if !isClosed(err) {
+				logrus.Errorf("Error reading DNS query: %v", err)
+			}
+			return
+		}
+
+		query := make([]byte, n)
+		copy(query, buf[:n])
+		go func() {
+			resp := s.handle("udp", query)
+			if resp == nil {
+				return
+			}
+			if _, err := s.udp.WriteTo(resp, addr); err != nil && !isClosed(err) {
+				logrus.Errorf("Error writing DNS response: %v", err)
+			}
+		}()
+	}
+}
+
+func (s *Server) serveTCP() {
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if !isClosed(err) {
+				logrus.Errorf("Error accepting DNS connection: %v", err)
+			}
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	for {
+		conn.SetDeadline(time.Now().Add(2 * upstreamTimeout))
+		query, err := readTCPMessage(conn)
+		if err != nil {
+			return
+		}
+		resp := s.handle("tcp", query)
+		if resp == nil {
+			return
+		}
+		if err := writeTCPMessage(conn, resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle returns the response to a query, or nil if the query is malformed.
+func (s *Server) handle(network string, query []byte) []byte {
+	q, err := parseQuery(query)
+	if err != nil {
+		return nil
+	}
+
+	if q.class == classIN && (q.qtype == typeA || q.qtype == typeAAAA) {
+		if ips := s.lookup(q.name); len(ips) > 0 {
+			return answer(query, q, ips)
+		}
+	}
+
+	resp, err := s.forward(network, query)
+	if err != nil {
+		logrus.Debugf("Error forwarding DNS query for %s: %v", q.name, err)
+		return failure(query, q)
+	}
+	return resp
+}
+
+// forward sends the query to the upstream servers in turn, and returns the
+// first response.
+func (s *Server) forward(network string, query []byte) ([]byte, error) {
+	err := errors.New("no upstream DNS server")
+	for _, upstream := range s.upstreams {
+		var resp []byte
+		if resp, err = exchange(network, upstream, query); err == nil {
+			return resp, nil
+		}
+	}
+	return nil, err
+}
+
+func exchange(network, addr string, query []byte) ([]byte, error) {
+	conn, err := net.DialTimeout(network, addr, upstreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(upstreamTimeout))
+
+	if network == "tcp" {
+		if err := writeTCPMessage(conn, query); err != nil {
+			return nil, err
+		}
+		return readTCPMessage(conn)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxUDPLen)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// ignore the responses to other queries
+		if n >= headerLen && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+func readTCPMessage(r io.Reader) ([]byte, error) {
+	var length uint16
+	if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, length)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeTCPMessage(w io.Writer, msg []byte) error {
+	buf := make([]byte, 2+len(msg))
+	binary.BigEndian.PutUint16(buf, uint16(len(msg)))
+	copy(buf[2:], msg)
+	_, err := w.Write(buf)
+	return err
+}
+
+type question struct {
+	name  string
+	qtype uint16
+	class uint16
+	// The offset of the end of the question in the query
+	end int
+}
+
+// parseQuery parses a standard query with one question.
+func parseQuery(msg []byte) (*question, error) {
+	if len(msg) < headerLen {
+		return nil, errMalformedMessage
+	}
+	flags := binary.BigEndian.Uint16(msg[2:])
+	if flags&flagResponse != 0 {
+		return nil, errMalformedMessage
+	}
+	if binary.BigEndian.Uint16(msg[4:]) != 1 {
+		return nil, errMalformedMessage
+	}
+
+	var (
+		labels []string
+		off    = headerLen
+	)
+	for {
+		if off >= len(msg) {
+			return nil, errMalformedMessage
+		}
+		length := int(msg[off])
+		off++
+		if length == 0 {
+			break
+		}
+		// queries do not use compression
+		if length > 63 || off+length > len(msg) {
+			return nil, errMalformedMessage
+		}
+		labels = append(labels, string(msg[off:off+length]))
+		off += length
+	}
+	if off+4 > len(msg) {
+		return nil, errMalformedMessage
+	}
+
+	return &question{
+		name:  strings.ToLower(strings.Join(labels, ".")),
+		qtype: binary.BigEndian.Uint16(msg[off:]),
+		class: binary.BigEndian.Uint16(msg[off+2:]),
+		end:   off + 4,
+	}, nil
+}
+
+// responseHeader returns the header of the response to the query, with the
+// question of the query.
+func responseHeader(query []byte, q *question, flags uint16, answers int) []byte {
+	resp := make([]byte, q.end, q.end+answers*28)
+	copy(resp, query[:q.end])
+	flags |= flagResponse | flagRecursionAvailable
+	flags |= binary.BigEndian.Uint16(query[2:]) & flagRecursionDesired
+	binary.BigEndian.PutUint16(resp[2:], flags)
+	binary.BigEndian.PutUint16(resp[6:], uint16(answers))
+	binary.BigEndian.PutUint16(resp[8:], 0)
+	binary.BigEndian.PutUint16(resp[10:], 0)
+	return resp
+}
+
+// answer returns the response to the query with the addresses of the type
+// asked. A response without answers tells that the name has no address of
+// that type.
+func answer(query []byte, q *question, ips []net.IP) []byte {
+	var rdatas [][]byte
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			if q.qtype == typeA {
+				rdatas = append(rdatas, ip4)
+			}
+		} else if ip6 := ip.To16(); ip6 != nil && q.qtype == typeAAAA {
+			rdatas = append(rdatas, ip6)
+		}
+	}
+
+	resp := responseHeader(query, q, flagAuthoritative, len(rdatas))
+	for _, rdata := range rdatas {
+		rr := make([]byte, 12, 12+len(rdata))
+		// the name is a pointer to the name of the question
+		binary.BigEndian.PutUint16(rr, 0xc000|headerLen)
+		binary.BigEndian.PutUint16(rr[2:], q.qtype)
+		binary.BigEndian.PutUint16(rr[4:], classIN)
+		binary.BigEndian.PutUint32(rr[6:], answerTTL)
+		binary.BigEndian.PutUint16(rr[10:], uint16(len(rdata)))
+		resp = append(resp, append(rr, rdata...)...)
+	}
+	return resp
+}
+
+// failure returns the response to a query which could not be answered.
+func failure(query []byte, q *question) []byte {
+	return responseHeader(query, q, rcodeServerFailure, 0)
+}
+
+func isClosed(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
@@ -0,0 +1,140 @@
+package dnsserver
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func buildQuery(id uint16, name string, qtype uint16) []byte {
+	msg := make([]byte, headerLen)
+	binary.BigEndian.PutUint16(msg, id)
+	binary.BigEndian.PutUint16(msg[2:], flagRecursionDesired)
+	binary.BigEndian.PutUint16(msg[4:], 1)
+	for _, label := range strings.Split(name, ".") {
+		msg = append(msg, byte(len(label)))
+		msg = append(msg, label...)
+	}
+	msg = append(msg, 0, byte(qtype>>8), byte(qtype), 0, classIN)
+	return msg
+}
+
+// answers returns the rcode and the rdata of the answers of a response to a
+// query built by buildQuery.
+func answers(t *testing.T, query, resp []byte) (uint16, [][]byte) {
+	if len(resp) < len(query) || resp[0] != query[0] || resp[1] != query[1] {
+		t.Fatalf("Unexpected response %v to query %v", resp, query)
+	}
+	flags := binary.BigEndian.Uint16(resp[2:])
+	if flags&flagResponse == 0 {
+		t.Fatalf("Expected a response, got flags %x", flags)
+	}
+
+	var rdatas [][]byte
+	off := len(query)
+	for i := 0; i < int(binary.BigEndian.Uint16(resp[6:])); i++ {
+		length := int(binary.BigEndian.Uint16(resp[off+10:]))
+		rdatas = append(rdatas, resp[off+12:off+12+length])
+		off += 12 + length
+	}
+	return flags & 0xf, rdatas
+}
+
+func startServer(t *testing.T, lookup LookupFunc, upstreams []string, port int) (*Server, string) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		udp.Close()
+		t.Fatal(err)
+	}
+	s := New(lookup, upstreams, port)
+	s.Serve(udp, tcp)
+	return s, udp.LocalAddr().String()
+}
+
+func TestServerAnswers(t *testing.T) {
+	lookup := func(name string) []net.IP {
+		if name == "db" {
+			return []net.IP{net.ParseIP("172.18.0.2"), net.ParseIP("fd00::2")}
+		}
+		return nil
+	}
+	s, addr := startServer(t, lookup, nil, DefaultUpstreamPort)
+	defer s.Close()
+
+	for _, network := range []string{"udp", "tcp"} {
+		query := buildQuery(42, "DB", typeA)
+		resp, err := exchange(network, addr, query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rcode, rdatas := answers(t, query, resp)
+		if rcode != 0 || len(rdatas) != 1 || !net.IP(rdatas[0]).Equal(net.ParseIP("172.18.0.2")) {
+			t.Fatalf("Unexpected answer over %s: rcode %d, %v", network, rcode, rdatas)
+		}
+	}
+
+	query := buildQuery(43, "db", typeAAAA)
+	resp, err := exchange("udp", addr, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, rdatas := answers(t, query, resp); len(rdatas) != 1 || !net.IP(rdatas[0]).Equal(net.ParseIP("fd00::2")) {
+		t.Fatalf("Unexpected AAAA answer %v", rdatas)
+	}
+}
+
+func TestServerForwards(t *testing.T) {
+	upstream, upstreamAddr := startServer(t, func(name string) []net.IP {
+		if name == "example.com" {
+			return []net.IP{net.ParseIP("192.0.2.1")}
+		}
+		return nil
+	}, nil, DefaultUpstreamPort)
+	defer upstream.Close()
+
+	host, port, _ := net.SplitHostPort(upstreamAddr)
+	upstreamPort, err := strconv.Atoi(port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, addr := startServer(t, func(string) []net.IP { return nil }, []string{host}, upstreamPort)
+	defer s.Close()
+
+	query := buildQuery(44, "example.com", typeA)
+	resp, err := exchange("udp", addr, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, rdatas := answers(t, query, resp); len(rdatas) != 1 || !net.IP(rdatas[0]).Equal(net.ParseIP("192.0.2.1")) {
+		t.Fatalf("Unexpected forwarded answer %v", rdatas)
+	}
+
+	// the upstream server does not know the name either
+	query = buildQuery(45, "unknown", typeA)
+	resp, err = exchange("tcp", addr, query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rcode, _ := answers(t, query, resp); rcode != rcodeServerFailure {
+		t.Fatalf("Expected a server failure, got rcode %d", rcode)
+	}
+
+	if New(nil, []string{host}, DefaultUpstreamPort).upstreams[0] != net.JoinHostPort(host, "53") {
+		t.Fatal("Expected the upstream servers to be on port 53 by default")
+	}
+}
+
+func TestParseQueryMalformed(t *testing.T) {
+	query := buildQuery(46, "db", typeA)
+	for _, msg := range [][]byte{query[:5], query[:len(query)-2], append([]byte{0, 0, 0x80}, query[3:]...)} {
+		if _, err := parseQuery(msg); err == nil {
+			t.Fatalf("Expected an error parsing %v", msg)
+		}
+	}
+}
@@ -81,3 +81,47 @@ func TestResumableRequestReaderWithInitialResponse(t *testing.T) {
 		t.Errorf("resstr != srvtxt")
 	}
 }
+
+func TestResumableRequestReaderResume(t *testing.T) {
+	srvtxt := "some response text data, interrupted halfway"
+	half := len(srvtxt) / 2
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rng := r.Header.Get("Range"); rng != "" {
+			if expected := fmt.Sprintf("bytes=%d-%d", half, len(srvtxt)); rng != expected {
+				t.Errorf("Expected range %q, got %q", expected, rng)
+			}
+			w.Header().Set("Content-Length", fmt.Sprint(len(srvtxt)-half))
+			w.WriteHeader(http.StatusPartialContent)
+			fmt.Fprint(w, srvtxt[half:])
+			return
+		}
+		// send the first half of the body and drop the connection
+		w.Header().Set("Content-Length", fmt.Sprint(len(srvtxt)))
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, srvtxt[:half])
+		w.(http.Flusher).Flush()
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.Close()
+	}))
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resreq := ResumableRequestReader(&http.Client{}, req, 5, int64(len(srvtxt)))
+	defer resreq.Close()
+
+	data, err := ioutil.ReadAll(resreq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != srvtxt {
+		t.Errorf("Expected %q, got %q", srvtxt, data)
+	}
+}
@@ -11,6 +11,9 @@ type JSONLog struct {
 	Log     string    `json:"log,omitempty"`
 	Stream  string    `json:"stream,omitempty"`
 	Created time.Time `json:"time"`
+	// Attrs are the extra attributes of the message, e.g. the container
+	// labels and environment variables selected with the log opts
+	Attrs map[string]string `json:"attrs,omitempty"`
 }
 
 func (jl *JSONLog) Format(format string) (string, error) {
@@ -28,6 +31,7 @@ func (jl *JSONLog) Reset() {
 	jl.Log = ""
 	jl.Stream = ""
 	jl.Created = time.Time{}
+	jl.Attrs = nil
 }
 
 func WriteLog(src io.Reader, dst io.Writer, format string, since time.Time) error {
@@ -1,6 +1,9 @@
 package jsonmessage
 
 import (
+	"bytes"
+	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -36,3 +39,23 @@ func TestProgress(t *testing.T) {
 		t.Fatalf("Expected %q, got %q", expected, jp4.String())
 	}
 }
+
+func TestDisplayJSONMessagesStreamAux(t *testing.T) {
+	in := strings.NewReader(`{"status":"Pushing"}{"aux":{"Tag":"latest"}}{"status":"Done"}`)
+	var (
+		out bytes.Buffer
+		aux []string
+	)
+	err := DisplayJSONMessagesStream(in, &out, 0, false, func(raw *json.RawMessage) {
+		aux = append(aux, string(*raw))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "Pushing\nDone\n" {
+		t.Fatalf("Expected the auxiliary data not to be displayed, got %q", out.String())
+	}
+	if len(aux) != 1 || aux[0] != `{"Tag":"latest"}` {
+		t.Fatalf("Expected the auxiliary data, got %v", aux)
+	}
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of a histogram of
+// durations in seconds, from 5 milliseconds to 10 seconds.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram counts the observed values in buckets, for each combination of
+// values of its labels.
+type Histogram struct {
+	name       string
+	help       string
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// NewHistogram returns a histogram with the given upper bounds of buckets
+// and names of labels.
+func NewHistogram(name, help string, buckets []float64, labelNames ...string) *Histogram {
+	b := make([]float64, len(buckets))
+	copy(b, buckets)
+	sort.Float64s(b)
+	return &Histogram{
+		name:       name,
+		help:       help,
+		buckets:    b,
+		labelNames: labelNames,
+		series:     make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds a value to the histogram, with the values of its labels in
+// the order of their names.
+func (h *Histogram) Observe(v float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s, exists := h.series[key]
+	if !exists {
+		s = &histogramSeries{
+			labelValues: labelValues,
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Collect returns the cumulative buckets, the sum and the count of the
+// observed values.
+func (h *Histogram) Collect() []*Family {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	f := &Family{Name: h.name, Help: h.help, Type: HistogramType}
+	for _, key := range keys {
+		s := h.series[key]
+		for i, upper := range h.buckets {
+			f.Samples = append(f.Samples, Sample{"_bucket", h.labels(s, "le", formatValue(upper)), float64(s.counts[i])})
+		}
+		f.Samples = append(f.Samples,
+			Sample{"_bucket", h.labels(s, "le", formatValue(math.Inf(1))), float64(s.count)},
+			Sample{"_sum", h.labels(s), s.sum},
+			Sample{"_count", h.labels(s), float64(s.count)},
+		)
+	}
+	return []*Family{f}
+}
+
+// labels returns the labels of the series, and the extra label given as a
+// name and a value.
+func (h *Histogram) labels(s *histogramSeries, extra ...string) Labels {
+	labels := make(Labels, len(h.labelNames)+1)
+	for i, name := range h.labelNames {
+		if i < len(s.labelValues) {
+			labels[name] = s.labelValues[i]
+		}
+	}
+	if len(extra) == 2 {
+		labels[extra[0]] = extra[1]
+	}
+	return labels
+}
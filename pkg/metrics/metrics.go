@@ -0,0 +1,170 @@
+// Package metrics collects metrics and writes them in the text exposition
+// format of Prometheus.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4"
+
+// Type is the type of a metric.
+type Type string
+
+// The types of the metrics
+const (
+	CounterType   Type = "counter"
+	GaugeType     Type = "gauge"
+	HistogramType Type = "histogram"
+)
+
+// Labels are the names and values of the labels of a sample.
+type Labels map[string]string
+
+// Sample is a value of a metric. The suffix is appended to the name of the
+// metric, as the _bucket, _sum and _count suffixes of the histograms.
+type Sample struct {
+	Suffix string
+	Labels Labels
+	Value  float64
+}
+
+// Family is a metric and its samples.
+type Family struct {
+	Name    string
+	Help    string
+	Type    Type
+	Samples []Sample
+}
+
+// Collector returns the current values of metrics.
+type Collector interface {
+	Collect() []*Family
+}
+
+// CollectorFunc is a function used as a Collector.
+type CollectorFunc func() []*Family
+
+// Collect calls f.
+func (f CollectorFunc) Collect() []*Family {
+	return f()
+}
+
+// Registry holds the collectors of the metrics to write.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// DefaultRegistry is the registry of the metrics of the process.
+var DefaultRegistry = NewRegistry()
+
+// Register adds a collector to the default registry.
+func Register(c Collector) {
+	DefaultRegistry.Register(c)
+}
+
+// Register adds a collector to the registry.
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	r.collectors = append(r.collectors, c)
+	r.mu.Unlock()
+}
+
+// Write writes the metrics of all the collectors, sorted by name, in the text
+// exposition format.
+func (r *Registry) Write(w io.Writer) error {
+	r.mu.Lock()
+	collectors := make([]Collector, len(r.collectors))
+	copy(collectors, r.collectors)
+	r.mu.Unlock()
+
+	var families []*Family
+	for _, c := range collectors {
+		families = append(families, c.Collect()...)
+	}
+	sort.Sort(byName(families))
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.Name, escapeHelp(f.Help))
+		fmt.Fprintf(bw, "# TYPE %s %s\n", f.Name, f.Type)
+		for _, s := range f.Samples {
+			bw.WriteString(f.Name + s.Suffix)
+			writeLabels(bw, s.Labels)
+			bw.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	return bw.Flush()
+}
+
+type byName []*Family
+
+func (f byName) Len() int           { return len(f) }
+func (f byName) Less(i, j int) bool { return f[i].Name < f[j].Name }
+func (f byName) Swap(i, j int)      { f[i], f[j] = f[j], f[i] }
+
+// writeLabels writes the labels sorted by name, with the le label of the
+// histogram buckets last.
+func writeLabels(w *bufio.Writer, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "le" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, ok := labels["le"]; ok {
+		names = append(names, "le")
+	}
+
+	w.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		w.WriteString(name + `="` + escapeLabelValue(labels[name]) + `"`)
+	}
+	w.WriteByte('}')
+}
+
+var (
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueReplacer.Replace(s)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
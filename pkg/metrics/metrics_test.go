@@ -0,0 +1,71 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	r.Register(CollectorFunc(func() []*Family {
+		return []*Family{{
+			Name: "test_containers",
+			Help: "Number of containers\nby state",
+			Type: GaugeType,
+			Samples: []Sample{
+				{Labels: Labels{"state": "running", "host": `a"b\c`}, Value: 2},
+				{Labels: Labels{"state": "stopped"}, Value: math.Inf(1)},
+			},
+		}}
+	}))
+	r.Register(CollectorFunc(func() []*Family {
+		return []*Family{{Name: "test_a_total", Help: "A counter", Type: CounterType, Samples: []Sample{{Value: 1.5}}}}
+	}))
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_a_total A counter
+# TYPE test_a_total counter
+test_a_total 1.5
+# HELP test_containers Number of containers\nby state
+# TYPE test_containers gauge
+test_containers{host="a\"b\\c",state="running"} 2
+test_containers{state="stopped"} +Inf
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram("test_duration_seconds", "Durations", []float64{1, 0.1}, "route")
+	h.Observe(0.05, "/b")
+	h.Observe(0.5, "/a")
+	h.Observe(2, "/a")
+
+	r := NewRegistry()
+	r.Register(h)
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP test_duration_seconds Durations
+# TYPE test_duration_seconds histogram
+test_duration_seconds_bucket{route="/a",le="0.1"} 0
+test_duration_seconds_bucket{route="/a",le="1"} 1
+test_duration_seconds_bucket{route="/a",le="+Inf"} 2
+test_duration_seconds_sum{route="/a"} 2.5
+test_duration_seconds_count{route="/a"} 2
+test_duration_seconds_bucket{route="/b",le="0.1"} 1
+test_duration_seconds_bucket{route="/b",le="1"} 1
+test_duration_seconds_bucket{route="/b",le="+Inf"} 1
+test_duration_seconds_sum{route="/b"} 0.05
+test_duration_seconds_count{route="/b"} 1
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
@@ -44,31 +44,41 @@ func (c *Client) Call(serviceMethod string, args interface{}, ret interface{}) e
 	req.URL.Scheme = "http"
 	req.URL.Host = c.addr
 
+	resp, err := c.callWithRetry(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		remoteErr, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil
+		}
+		return fmt.Errorf("Plugin Error: %s", remoteErr)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(&ret)
+}
+
+// callWithRetry sends the request to the plugin, retrying with a backoff
+// while the plugin cannot be reached.
+func (c *Client) callWithRetry(req *http.Request) (*http.Response, error) {
 	var retries int
 	start := time.Now()
 
 	for {
 		resp, err := c.http.Do(req)
-		if err != nil {
-			timeOff := backoff(retries)
-			if timeOff+time.Since(start) > defaultTimeOut {
-				return err
-			}
-			retries++
-			logrus.Warn("Unable to connect to plugin: %s, retrying in %ds\n", c.addr, timeOff)
-			time.Sleep(timeOff)
-			continue
+		if err == nil {
+			return resp, nil
 		}
-
-		if resp.StatusCode != http.StatusOK {
-			remoteErr, err := ioutil.ReadAll(resp.Body)
-			if err != nil {
-				return nil
-			}
-			return fmt.Errorf("Plugin Error: %s", remoteErr)
+		timeOff := backoff(retries)
+		if timeOff+time.Since(start) > defaultTimeOut {
+			return nil, err
 		}
-
-		return json.NewDecoder(resp.Body).Decode(&ret)
+		retries++
+		logrus.Warn("Unable to connect to plugin: %s, retrying in %ds\n", c.addr, timeOff)
+		time.Sleep(timeOff)
 	}
 }
 
@@ -214,3 +214,11 @@ func TestUDPWriteError(t *testing.T) {
 		t.Fatal(fmt.Errorf("Expected [%v] but got [%v]", testBuf, recvBuf))
 	}
 }
+
+func TestListenNetwork(t *testing.T) {
+	for ip, network := range map[string]string{"": "tcp", "0.0.0.0": "tcp4", "127.0.0.1": "tcp4", "::": "tcp6", "::1": "tcp6"} {
+		if n := listenNetwork("tcp", net.ParseIP(ip)); n != network {
+			t.Fatalf("Expected %q to be listened on with %s, got %s", ip, network, n)
+		}
+	}
+}
@@ -17,6 +17,20 @@ type Proxy interface {
 	BackendAddr() net.Addr
 }
 
+// listenNetwork returns the network to listen on ip with: an IPv4 address,
+// including 0.0.0.0, is only listened on with IPv4, an IPv6 address, including
+// ::, with IPv6. Without an address, all the addresses of both families are
+// listened on.
+func listenNetwork(proto string, ip net.IP) string {
+	if ip == nil {
+		return proto
+	}
+	if ip.To4() != nil {
+		return proto + "4"
+	}
+	return proto + "6"
+}
+
 func NewProxy(frontendAddr, backendAddr net.Addr) (Proxy, error) {
 	switch frontendAddr.(type) {
 	case *net.UDPAddr:
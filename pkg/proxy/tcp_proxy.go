@@ -15,7 +15,7 @@ type TCPProxy struct {
 }
 
 func NewTCPProxy(frontendAddr, backendAddr *net.TCPAddr) (*TCPProxy, error) {
-	listener, err := net.ListenTCP("tcp", frontendAddr)
+	listener, err := net.ListenTCP(listenNetwork("tcp", frontendAddr.IP), frontendAddr)
 	if err != nil {
 		return nil, err
 	}
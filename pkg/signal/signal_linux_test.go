@@ -0,0 +1,31 @@
+package signal
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	valid := map[string]syscall.Signal{
+		"9":       syscall.SIGKILL,
+		"KILL":    syscall.SIGKILL,
+		"SIGKILL": syscall.SIGKILL,
+		"sigquit": syscall.SIGQUIT,
+		"TERM":    syscall.SIGTERM,
+	}
+	for raw, expected := range valid {
+		sig, err := ParseSignal(raw)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", raw, err)
+		}
+		if sig != expected {
+			t.Fatalf("%q: expected %v, got %v", raw, expected, sig)
+		}
+	}
+
+	for _, raw := range []string{"", "0", "SIG", "SIGFOO", "foo"} {
+		if _, err := ParseSignal(raw); err == nil {
+			t.Fatalf("%q: expected an error", raw)
+		}
+	}
+}
@@ -86,6 +86,24 @@ func (sf *StreamFormatter) FormatProgress(id, action string, progress *jsonmessa
 	return []byte(action + " " + progress.String() + endl)
 }
 
+// FormatAux formats structured data about the operation for the client. It
+// is only sent in the JSON streams.
+func (sf *StreamFormatter) FormatAux(aux interface{}) []byte {
+	if !sf.json {
+		return nil
+	}
+	auxJSON, err := json.Marshal(aux)
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	raw := json.RawMessage(auxJSON)
+	b, err := json.Marshal(&jsonmessage.JSONMessage{Aux: &raw})
+	if err != nil {
+		return sf.FormatError(err)
+	}
+	return append(b, streamNewlineBytes...)
+}
+
 type StdoutFormater struct {
 	io.Writer
 	*StreamFormatter
@@ -91,3 +91,14 @@ func TestJSONFormatProgress(t *testing.T) {
 		t.Fatal("Original progress not equals progress from FormatProgress")
 	}
 }
+
+func TestFormatAux(t *testing.T) {
+	aux := map[string]string{"Tag": "latest"}
+	if res := NewStreamFormatter().FormatAux(aux); len(res) != 0 {
+		t.Fatalf("Expected no auxiliary data in a plain stream, got %q", res)
+	}
+	res := NewJSONStreamFormatter().FormatAux(aux)
+	if string(res) != `{"aux":{"Tag":"latest"}}`+"\r\n" {
+		t.Fatalf("%q", res)
+	}
+}
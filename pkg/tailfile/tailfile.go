@@ -3,6 +3,7 @@ package tailfile
 import (
 	"bytes"
 	"errors"
+	"io"
 	"os"
 )
 
@@ -11,8 +12,8 @@ const blockSize = 1024
 var eol = []byte("\n")
 var ErrNonPositiveLinesNumber = errors.New("Lines number must be positive")
 
-//TailFile returns last n lines of file f
-func TailFile(f *os.File, n int) ([][]byte, error) {
+//TailFile returns last n lines of reader f (could be a file).
+func TailFile(f io.ReadSeeker, n int) ([][]byte, error) {
 	if n <= 0 {
 		return nil, ErrNonPositiveLinesNumber
 	}
@@ -20,6 +21,9 @@ func TailFile(f *os.File, n int) ([][]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	if size == 0 {
+		return nil, nil
+	}
 	block := -1
 	var data []byte
 	var cnt int
@@ -192,6 +192,13 @@ func (ts *tarSum) Read(buf []byte) (int, error) {
 			if _, err := ts.h.Write(buf2[:n]); err != nil {
 				return 0, err
 			}
+			// the end of the current file can come with io.EOF, it has
+			// to be written before the header of the next one
+			if n > 0 {
+				if _, err := ts.tarW.Write(buf2[:n]); err != nil {
+					return 0, err
+				}
+			}
 			if !ts.first {
 				ts.sums = append(ts.sums, fileInfoSum{name: ts.currentFile, sum: hex.EncodeToString(ts.h.Sum(nil)), pos: ts.fileCounter})
 				ts.fileCounter++
@@ -224,9 +231,6 @@ func (ts *tarSum) Read(buf []byte) (int, error) {
 			if err := ts.tarW.WriteHeader(currentHeader); err != nil {
 				return 0, err
 			}
-			if _, err := ts.tarW.Write(buf2[:n]); err != nil {
-				return 0, err
-			}
 			ts.tarW.Flush()
 			if _, err := io.Copy(ts.writer, ts.bufTar); err != nil {
 				return 0, err
@@ -48,7 +48,7 @@ func IndexServerName() string {
 // the current process.
 func (options *Options) InstallFlags() {
 	options.Mirrors = opts.NewListOpts(ValidateMirror)
-	flag.Var(&options.Mirrors, []string{"-registry-mirror"}, "Preferred Docker registry mirror")
+	flag.Var(&options.Mirrors, []string{"-registry-mirror"}, "Preferred registry mirror, as [REGISTRY=]URL, for the Docker registry by default")
 	options.InsecureRegistries = opts.NewListOpts(ValidateIndexName)
 	flag.Var(&options.InsecureRegistries, []string{"-insecure-registry"}, "Enable insecure registry communication")
 }
@@ -74,6 +74,10 @@ func (ipnet *netIPNet) UnmarshalJSON(b []byte) (err error) {
 type ServiceConfig struct {
 	InsecureRegistryCIDRs []*netIPNet           `json:"InsecureRegistryCIDRs"`
 	IndexConfigs          map[string]*IndexInfo `json:"IndexConfigs"`
+
+	// mirrors are the mirrors of the registries which are not configured
+	// otherwise, by index name
+	mirrors map[string][]string
 }
 
 // NewServiceConfig returns a new instance of ServiceConfig
@@ -95,6 +99,7 @@ func NewServiceConfig(options *Options) *ServiceConfig {
 	config := &ServiceConfig{
 		InsecureRegistryCIDRs: make([]*netIPNet, 0),
 		IndexConfigs:          make(map[string]*IndexInfo, 0),
+		mirrors:               make(map[string][]string),
 	}
 	// Split --insecure-registry into CIDR and registry-specific settings.
 	for _, r := range options.InsecureRegistries.GetAll() {
@@ -114,10 +119,24 @@ func NewServiceConfig(options *Options) *ServiceConfig {
 		}
 	}
 
+	// Split --registry-mirror into the mirrors of the public registry and
+	// of the other registries.
+	publicMirrors := make([]string, 0)
+	for _, m := range options.Mirrors.GetAll() {
+		indexName, mirror := splitMirror(m)
+		if indexName == "" || indexName == IndexServerName() {
+			publicMirrors = append(publicMirrors, mirror)
+		} else if index, ok := config.IndexConfigs[indexName]; ok {
+			index.Mirrors = append(index.Mirrors, mirror)
+		} else {
+			config.mirrors[indexName] = append(config.mirrors[indexName], mirror)
+		}
+	}
+
 	// Configure public registry.
 	config.IndexConfigs[IndexServerName()] = &IndexInfo{
 		Name:     IndexServerName(),
-		Mirrors:  options.Mirrors.GetAll(),
+		Mirrors:  publicMirrors,
 		Secure:   true,
 		Official: true,
 	}
@@ -125,6 +144,15 @@ func NewServiceConfig(options *Options) *ServiceConfig {
 	return config
 }
 
+// splitMirror splits a validated mirror into the name of the index it
+// mirrors, or "" for the public registry, and its address.
+func splitMirror(val string) (string, string) {
+	if i := strings.Index(val, "="); i >= 0 {
+		return val[:i], val[i+1:]
+	}
+	return "", val
+}
+
 // isSecureIndex returns false if the provided indexName is part of the list of insecure registries
 // Insecure registries accept HTTP and/or accept HTTPS with certificates from unknown CAs.
 //
@@ -174,8 +202,17 @@ func (config *ServiceConfig) isSecureIndex(indexName string) bool {
 	return true
 }
 
-// ValidateMirror validates an HTTP(S) registry mirror
+// ValidateMirror validates an HTTP(S) registry mirror, given as
+// [REGISTRY=]URL to mirror another registry than the public registry.
 func ValidateMirror(val string) (string, error) {
+	indexName, val := splitMirror(val)
+	if indexName != "" {
+		var err error
+		if indexName, err = ValidateIndexName(indexName); err != nil {
+			return "", err
+		}
+	}
+
 	uri, err := url.Parse(val)
 	if err != nil {
 		return "", fmt.Errorf("%s is not a valid URI", val)
@@ -189,7 +226,11 @@ func ValidateMirror(val string) (string, error) {
 		return "", fmt.Errorf("Unsupported path/query/fragment at end of the URI")
 	}
 
-	return fmt.Sprintf("%s://%s/v1/", uri.Scheme, uri.Host), nil
+	mirror := fmt.Sprintf("%s://%s/v1/", uri.Scheme, uri.Host)
+	if indexName != "" {
+		return indexName + "=" + mirror, nil
+	}
+	return mirror, nil
 }
 
 // ValidateIndexName validates an index name.
@@ -284,6 +325,9 @@ func (config *ServiceConfig) NewIndexInfo(indexName string) (*IndexInfo, error)
 		Mirrors:  make([]string, 0),
 		Official: false,
 	}
+	if mirrors, ok := config.mirrors[indexName]; ok {
+		index.Mirrors = mirrors
+	}
 	index.Secure = config.isSecureIndex(indexName)
 	return index, nil
 }
@@ -16,6 +16,8 @@ func TestValidateMirror(t *testing.T) {
 		"https://127.0.0.1",
 		"http://127.0.0.1:5000",
 		"https://127.0.0.1:5000",
+		"example.com=https://mirror-1.com",
+		"example.com:5000=http://127.0.0.1:5000",
 	}
 
 	invalid := []string{
@@ -33,6 +35,9 @@ func TestValidateMirror(t *testing.T) {
 		"https://mirror-1.com/v1/",
 		"https://mirror-1.com/v1/#",
 		"https://mirror-1.com?q",
+		"-example.com=https://mirror-1.com",
+		"example.com=https://mirror-1.com/v1/",
+		"example.com=",
 	}
 
 	for _, address := range valid {
@@ -116,6 +116,8 @@ type Endpoint struct {
 	IsSecure       bool
 	AuthChallenges []*AuthorizationChallenge
 	URLBuilder     *v2.URLBuilder
+	// Mirror is whether the endpoint is a mirror of the registry
+	Mirror bool
 }
 
 // Get the formated URL for the root of this registry Endpoint
@@ -679,6 +679,36 @@ func TestNewIndexInfo(t *testing.T) {
 		},
 	}
 	testIndexInfo(config, expectedIndexInfos)
+
+	// mirrors of other registries than the public registry
+	config = makeServiceConfig([]string{"http://mirror1.local", "example.com=http://mirror2.local", "other.com=http://mirror3.local", "other.com=http://mirror4.local"}, []string{"example.com"})
+	expectedIndexInfos = map[string]*IndexInfo{
+		IndexServerName(): {
+			Name:     IndexServerName(),
+			Official: true,
+			Secure:   true,
+			Mirrors:  []string{"http://mirror1.local"},
+		},
+		"example.com": {
+			Name:     "example.com",
+			Official: false,
+			Secure:   false,
+			Mirrors:  []string{"http://mirror2.local"},
+		},
+		"other.com": {
+			Name:     "other.com",
+			Official: false,
+			Secure:   true,
+			Mirrors:  []string{"http://mirror3.local", "http://mirror4.local"},
+		},
+		"example.com:5000": {
+			Name:     "example.com:5000",
+			Official: false,
+			Secure:   true,
+			Mirrors:  noMirrors,
+		},
+	}
+	testIndexInfo(config, expectedIndexInfos)
 }
 
 func TestPushRegistryTag(t *testing.T) {
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/distribution/digest"
@@ -24,8 +25,9 @@ func getV2Builder(e *Endpoint) *v2.URLBuilder {
 	return e.URLBuilder
 }
 
+// V2RegistryEndpoint returns the endpoint of the v2 registry of the index.
+// The mirrors of the index are not used, see V2MirrorEndpoints.
 func (r *Session) V2RegistryEndpoint(index *IndexInfo) (ep *Endpoint, err error) {
-	// TODO check if should use Mirror
 	if index.Official {
 		ep, err = newEndpoint(REGISTRYSERVER, true, nil)
 		if err != nil {
@@ -48,6 +50,29 @@ func (r *Session) V2RegistryEndpoint(index *IndexInfo) (ep *Endpoint, err error)
 	return
 }
 
+// V2MirrorEndpoints returns the endpoints of the mirrors of the index which
+// are v2 registries, in the order they were configured. The mirrors which
+// do not respond are skipped. The mirrors are only used to pull, before the
+// registry itself.
+func (r *Session) V2MirrorEndpoints(index *IndexInfo) []*Endpoint {
+	var endpoints []*Endpoint
+	for _, mirror := range index.Mirrors {
+		address := strings.TrimSuffix(mirror, "/v1/") + "/v2/"
+		ep, err := newEndpoint(address, strings.HasPrefix(address, "https://"), nil)
+		if err == nil {
+			err = validateEndpoint(ep)
+		}
+		if err != nil {
+			logrus.Debugf("Skipping mirror %s of %s: %v", mirror, index.Name, err)
+			continue
+		}
+		ep.Mirror = true
+		ep.URLBuilder = v2.NewURLBuilder(ep.URL)
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints
+}
+
 // GetV2Authorization gets the authorization needed to the given image
 // If readonly access is requested, then the authorization may
 // only be used for Get operations.
@@ -203,10 +228,12 @@ func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName string, dgst dige
 	lenStr := res.Header.Get("Content-Length")
 	l, err := strconv.ParseInt(lenStr, 10, 64)
 	if err != nil {
+		res.Body.Close()
 		return nil, 0, err
 	}
 
-	return res.Body, l, err
+	// resume the download with range requests when it is interrupted
+	return httputils.ResumableRequestReaderWithInitialResponse(r.client, req, 5, l, res), l, nil
 }
 
 // Push the image to the server for storage.
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/nat"
 )
@@ -128,6 +129,25 @@ type Config struct {
 	MacAddress      string
 	OnBuild         []string
 	Labels          map[string]string
+	Healthcheck     *HealthConfig // Healthcheck describes how to check the container is healthy
+	StopSignal      string        // Signal to stop the container, SIGTERM if empty
+}
+
+// HealthConfig holds the configuration of the HEALTHCHECK probe run
+// periodically in a container.
+type HealthConfig struct {
+	// Test is the probe to run:
+	// {} : inherit the healthcheck of the image
+	// {"NONE"} : disable the healthcheck
+	// {"CMD", args...} : exec the arguments directly
+	// {"CMD-SHELL", command} : run the command with the shell of the system
+	Test []string `json:",omitempty"`
+
+	// Zero means to inherit, or to use the default if there is nothing to
+	// inherit.
+	Interval time.Duration `json:",omitempty"` // Interval is the time to wait between probes
+	Timeout  time.Duration `json:",omitempty"` // Timeout is the time to wait before considering a probe hung
+	Retries  int           `json:",omitempty"` // Retries is the number of consecutive failures needed to consider the container unhealthy
 }
 
 type ContainerConfigWrapper struct {
@@ -6,6 +6,7 @@ import (
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/nat"
 )
@@ -257,6 +258,74 @@ func TestMerge(t *testing.T) {
 	}
 }
 
+func TestParseRunHealthcheck(t *testing.T) {
+	if config, _ := mustParse(t, ""); config.Healthcheck != nil {
+		t.Fatalf("Expected no healthcheck, received: %v", config.Healthcheck)
+	}
+	config, _ := mustParse(t, "--health-cmd=true --health-interval=5s --health-retries=2")
+	hc := config.Healthcheck
+	if hc == nil || len(hc.Test) != 2 || hc.Test[0] != "CMD-SHELL" || hc.Test[1] != "true" ||
+		hc.Interval != 5*time.Second || hc.Timeout != 0 || hc.Retries != 2 {
+		t.Fatalf("Error parsing healthcheck, received: %v", hc)
+	}
+	if config, _ := mustParse(t, "--health-cmd=none"); len(config.Healthcheck.Test) != 1 || config.Healthcheck.Test[0] != "NONE" {
+		t.Fatalf("Expected the healthcheck to be disabled, received: %v", config.Healthcheck)
+	}
+	if _, _, err := parse(t, "--health-retries=-1"); err == nil {
+		t.Fatal("Expected an error with negative --health-retries")
+	}
+}
+
+func TestMergeHealthcheck(t *testing.T) {
+	configImage := &Config{
+		Healthcheck: &HealthConfig{
+			Test:     []string{"CMD", "check"},
+			Interval: time.Minute,
+			Retries:  5,
+		},
+	}
+	configUser := &Config{
+		Healthcheck: &HealthConfig{
+			Interval: time.Second,
+		},
+	}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	hc := configUser.Healthcheck
+	if len(hc.Test) != 2 || hc.Test[1] != "check" || hc.Interval != time.Second || hc.Retries != 5 {
+		t.Fatalf("Unexpected merged healthcheck: %v", hc)
+	}
+
+	configUser = &Config{}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	if configUser.Healthcheck != configImage.Healthcheck {
+		t.Fatalf("Expected the healthcheck of the image to be inherited, found %v", configUser.Healthcheck)
+	}
+}
+
+func TestParseRunStopSignal(t *testing.T) {
+	if config, _ := mustParse(t, ""); config.StopSignal != "" {
+		t.Fatalf("Expected no stop signal, received: %q", config.StopSignal)
+	}
+	if config, _ := mustParse(t, "--stop-signal=SIGQUIT"); config.StopSignal != "SIGQUIT" {
+		t.Fatalf("Expected stop signal SIGQUIT, received: %q", config.StopSignal)
+	}
+	if _, _, err := parse(t, "--stop-signal=SIGFOO"); err == nil {
+		t.Fatal("Expected an error with an invalid --stop-signal")
+	}
+
+	configUser := &Config{}
+	if err := Merge(configUser, &Config{StopSignal: "SIGINT"}); err != nil {
+		t.Fatal(err)
+	}
+	if configUser.StopSignal != "SIGINT" {
+		t.Fatalf("Expected the stop signal of the image to be inherited, found %q", configUser.StopSignal)
+	}
+}
+
 func TestDecodeContainerConfig(t *testing.T) {
 	fixtures := []struct {
 		file       string
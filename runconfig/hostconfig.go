@@ -38,6 +38,12 @@ func (n NetworkMode) IsNone() bool {
 	return n == "none"
 }
 
+// IsUserDefined indicates whether the container uses a network created by
+// the user, which is named by the network mode.
+func (n NetworkMode) IsUserDefined() bool {
+	return n != "" && !n.IsBridge() && !n.IsHost() && !n.IsNone() && !n.IsContainer()
+}
+
 type IpcMode string
 
 // IsPrivate indicates whether container use it's private ipc stack
@@ -216,8 +222,11 @@ type HostConfig struct {
 	DnsSearch       []string
 	ExtraHosts      []string
 	VolumesFrom     []string
+	VolumeDriver    string // Name of the volume driver plugin creating the volumes of the container
 	Devices         []DeviceMapping
 	NetworkMode     NetworkMode
+	IPv4Address     string // Static IPv4 address of the container on its network
+	IPv6Address     string // Static IPv6 address of the container on its network
 	IpcMode         IpcMode
 	PidMode         PidMode
 	UTSMode         UTSMode
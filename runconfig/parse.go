@@ -2,13 +2,16 @@ package runconfig
 
 import (
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/docker/docker/nat"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/ulimit"
 	"github.com/docker/docker/pkg/units"
 )
@@ -20,6 +23,7 @@ var (
 	ErrConflictHostNetworkAndLinks      = fmt.Errorf("Conflicting options: --net=host can't be used with links. This would result in undefined behavior")
 	ErrConflictContainerNetworkAndMac   = fmt.Errorf("Conflicting options: --mac-address and the network mode (--net)")
 	ErrConflictNetworkHosts             = fmt.Errorf("Conflicting options: --add-host and the network mode (--net)")
+	ErrConflictNetworkAndIP             = fmt.Errorf("Conflicting options: --ip/--ip6 and the network mode (--net)")
 )
 
 func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSet, error) {
@@ -72,11 +76,19 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		flBlkioWeight     = cmd.Int64([]string{"-blkio-weight"}, 0, "Block IO (relative weight), between 10 and 1000")
 		flNetMode         = cmd.String([]string{"-net"}, "bridge", "Set the Network mode for the container")
 		flMacAddress      = cmd.String([]string{"-mac-address"}, "", "Container MAC address (e.g. 92:d0:c6:0a:29:33)")
+		flIPv4Address     = cmd.String([]string{"-ip"}, "", "Container IPv4 address (e.g. 172.30.100.104)")
+		flIPv6Address     = cmd.String([]string{"-ip6"}, "", "Container IPv6 address (e.g. 2001:db8::33)")
 		flIpcMode         = cmd.String([]string{"-ipc"}, "", "IPC namespace to use")
 		flRestartPolicy   = cmd.String([]string{"-restart"}, "no", "Restart policy to apply when a container exits")
 		flReadonlyRootfs  = cmd.Bool([]string{"-read-only"}, false, "Mount the container's root filesystem as read only")
 		flLoggingDriver   = cmd.String([]string{"-log-driver"}, "", "Logging driver for container")
 		flCgroupParent    = cmd.String([]string{"-cgroup-parent"}, "", "Optional parent cgroup for the container")
+		flHealthCmd       = cmd.String([]string{"-health-cmd"}, "", "Command to run to check health")
+		flHealthInterval  = cmd.Duration([]string{"-health-interval"}, 0, "Time between running the check")
+		flHealthTimeout   = cmd.Duration([]string{"-health-timeout"}, 0, "Maximum time to allow one check to run")
+		flHealthRetries   = cmd.Int([]string{"-health-retries"}, 0, "Consecutive failures needed to report unhealthy")
+		flStopSignal      = cmd.String([]string{"-stop-signal"}, "", "Signal to stop a container, SIGTERM by default")
+		flVolumeDriver    = cmd.String([]string{"-volume-driver"}, "", "Optional volume driver for the container")
 	)
 
 	cmd.Var(&flAttach, []string{"a", "-attach"}, "Attach to STDIN, STDOUT or STDERR")
@@ -148,6 +160,22 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		}
 	}
 
+	if (netMode.IsContainer() || netMode.IsHost() || netMode.IsNone()) && (*flIPv4Address != "" || *flIPv6Address != "") {
+		return nil, nil, cmd, ErrConflictNetworkAndIP
+	}
+
+	// Validate the input ip addresses
+	if *flIPv4Address != "" {
+		if ip := net.ParseIP(*flIPv4Address); ip == nil || ip.To4() == nil {
+			return nil, nil, cmd, fmt.Errorf("%s is not a valid IPv4 address", *flIPv4Address)
+		}
+	}
+	if *flIPv6Address != "" {
+		if ip := net.ParseIP(*flIPv6Address); ip == nil || ip.To4() != nil {
+			return nil, nil, cmd, fmt.Errorf("%s is not a valid IPv6 address", *flIPv6Address)
+		}
+	}
+
 	// If neither -d or -a are set, attach to everything by default
 	if flAttach.Len() == 0 {
 		attachStdout = true
@@ -297,6 +325,17 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		return nil, nil, cmd, err
 	}
 
+	healthConfig, err := parseHealthConfig(*flHealthCmd, *flHealthInterval, *flHealthTimeout, *flHealthRetries)
+	if err != nil {
+		return nil, nil, cmd, err
+	}
+
+	if *flStopSignal != "" {
+		if _, err := signal.ParseSignal(*flStopSignal); err != nil {
+			return nil, nil, cmd, err
+		}
+	}
+
 	config := &Config{
 		Hostname:        hostname,
 		Domainname:      domainname,
@@ -317,6 +356,8 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		Entrypoint:      entrypoint,
 		WorkingDir:      *flWorkingDir,
 		Labels:          convertKVStringsToMap(labels),
+		Healthcheck:     healthConfig,
+		StopSignal:      *flStopSignal,
 	}
 
 	hostConfig := &HostConfig{
@@ -340,7 +381,10 @@ func Parse(cmd *flag.FlagSet, args []string) (*Config, *HostConfig, *flag.FlagSe
 		DnsSearch:       flDnsSearch.GetAll(),
 		ExtraHosts:      flExtraHosts.GetAll(),
 		VolumesFrom:     flVolumesFrom.GetAll(),
+		VolumeDriver:    *flVolumeDriver,
 		NetworkMode:     netMode,
+		IPv4Address:     *flIPv4Address,
+		IPv6Address:     *flIPv6Address,
 		IpcMode:         ipcMode,
 		PidMode:         pidMode,
 		UTSMode:         utsMode,
@@ -479,7 +523,10 @@ func parseNetMode(netMode string) (NetworkMode, error) {
 			return "", fmt.Errorf("invalid container format container:<name|id>")
 		}
 	default:
-		return "", fmt.Errorf("invalid --net: %s", netMode)
+		// The name of a network created by the user
+		if len(parts) > 1 || mode == "" {
+			return "", fmt.Errorf("invalid --net: %s", netMode)
+		}
 	}
 	return NetworkMode(netMode), nil
 }
@@ -513,3 +560,35 @@ func ParseDevice(device string) (DeviceMapping, error) {
 	}
 	return deviceMapping, nil
 }
+
+// parseHealthConfig returns the healthcheck configuration set with the
+// --health-* flags, or nil if none was set so that the one of the image is
+// used.
+func parseHealthConfig(command string, interval, timeout time.Duration, retries int) (*HealthConfig, error) {
+	if interval < 0 {
+		return nil, fmt.Errorf("--health-interval cannot be negative")
+	}
+	if timeout < 0 {
+		return nil, fmt.Errorf("--health-timeout cannot be negative")
+	}
+	if retries < 0 {
+		return nil, fmt.Errorf("--health-retries cannot be negative")
+	}
+	if command == "" && interval == 0 && timeout == 0 && retries == 0 {
+		return nil, nil
+	}
+
+	healthConfig := &HealthConfig{
+		Interval: interval,
+		Timeout:  timeout,
+		Retries:  retries,
+	}
+	if command != "" {
+		if strings.ToUpper(command) == "NONE" {
+			healthConfig.Test = []string{"NONE"}
+		} else {
+			healthConfig.Test = []string{"CMD-SHELL", command}
+		}
+	}
+	return healthConfig, nil
+}
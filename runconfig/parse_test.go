@@ -63,3 +63,41 @@ func TestConflictContainerNetworkAndLinks(t *testing.T) {
 		t.Fatalf("Expected error ErrConflictContainerNetworkAndLinks, got: %s", err)
 	}
 }
+
+func TestParseNetMode(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--net=mynet", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if !hostConfig.NetworkMode.IsUserDefined() || hostConfig.NetworkMode != "mynet" {
+		t.Fatalf("Expected the network created by the user mynet, got %s", hostConfig.NetworkMode)
+	}
+
+	for _, mode := range []string{"foo:bar", "container:", ":"} {
+		if _, _, _, err := parseRun([]string{"--net=" + mode, "img", "cmd"}); err == nil {
+			t.Fatalf("Expected an error with --net=%s", mode)
+		}
+	}
+}
+
+func TestParseIPAddresses(t *testing.T) {
+	_, hostConfig, _, err := parseRun([]string{"--ip=172.30.100.104", "--ip6=2001:db8::33", "img", "cmd"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %s", err)
+	}
+	if hostConfig.IPv4Address != "172.30.100.104" || hostConfig.IPv6Address != "2001:db8::33" {
+		t.Fatalf("Expected the addresses to be set, got %s and %s", hostConfig.IPv4Address, hostConfig.IPv6Address)
+	}
+
+	for _, args := range [][]string{{"--ip=2001:db8::33"}, {"--ip=172.30.300.104"}, {"--ip6=172.30.100.104"}} {
+		if _, _, _, err := parseRun(append(args, "img", "cmd")); err == nil {
+			t.Fatalf("Expected an error with %s", args)
+		}
+	}
+
+	for _, mode := range []string{"host", "none", "container:other"} {
+		if _, _, _, err := parseRun([]string{"--net=" + mode, "--ip=172.30.100.104", "img", "cmd"}); err != ErrConflictNetworkAndIP {
+			t.Fatalf("Expected error ErrConflictNetworkAndIP with --net=%s, got: %v", mode, err)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package runconfig
+
+import (
+	"fmt"
+
+	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/units"
+)
+
+// UpdateConfig holds the resources and the restart policy of a container which
+// can be changed after it is created. The fields left to their zero value are
+// not changed.
+type UpdateConfig struct {
+	Memory        int64 // Memory limit (in bytes)
+	MemorySwap    int64 // Total memory usage (memory + swap); set `-1` to disable swap
+	CpuShares     int64 // CPU shares (relative weight vs. other containers)
+	CpuPeriod     int64
+	CpuQuota      int64
+	CpusetCpus    string // CpusetCpus 0-2, 0,1
+	CpusetMems    string // CpusetMems 0-2, 0,1
+	BlkioWeight   int64  // Block IO weight (relative weight vs. other containers)
+	RestartPolicy RestartPolicy
+}
+
+// IsEmpty returns whether the update does not change anything.
+func (c *UpdateConfig) IsEmpty() bool {
+	return *c == UpdateConfig{}
+}
+
+// Apply sets the values of the update in the given host config.
+func (c *UpdateConfig) Apply(hostConfig *HostConfig) {
+	if c.Memory != 0 {
+		hostConfig.Memory = c.Memory
+	}
+	if c.MemorySwap != 0 {
+		hostConfig.MemorySwap = c.MemorySwap
+	}
+	if c.CpuShares != 0 {
+		hostConfig.CpuShares = c.CpuShares
+	}
+	if c.CpuPeriod != 0 {
+		hostConfig.CpuPeriod = c.CpuPeriod
+	}
+	if c.CpuQuota != 0 {
+		hostConfig.CpuQuota = c.CpuQuota
+	}
+	if c.CpusetCpus != "" {
+		hostConfig.CpusetCpus = c.CpusetCpus
+	}
+	if c.CpusetMems != "" {
+		hostConfig.CpusetMems = c.CpusetMems
+	}
+	if c.BlkioWeight != 0 {
+		hostConfig.BlkioWeight = c.BlkioWeight
+	}
+	if c.RestartPolicy.Name != "" {
+		hostConfig.RestartPolicy = c.RestartPolicy
+	}
+}
+
+// ParseUpdate parses the flags of docker update, and returns the update and
+// the names of the containers to update.
+func ParseUpdate(cmd *flag.FlagSet, args []string) (*UpdateConfig, []string, error) {
+	var (
+		flMemoryString  = cmd.String([]string{"m", "-memory"}, "", "Memory limit")
+		flMemorySwap    = cmd.String([]string{"-memory-swap"}, "", "Total memory (memory + swap), '-1' to disable swap")
+		flCpuShares     = cmd.Int64([]string{"c", "-cpu-shares"}, 0, "CPU shares (relative weight)")
+		flCpuPeriod     = cmd.Int64([]string{"-cpu-period"}, 0, "Limit CPU CFS (Completely Fair Scheduler) period")
+		flCpuQuota      = cmd.Int64([]string{"-cpu-quota"}, 0, "Limit the CPU CFS quota")
+		flCpusetCpus    = cmd.String([]string{"-cpuset-cpus"}, "", "CPUs in which to allow execution (0-3, 0,1)")
+		flCpusetMems    = cmd.String([]string{"-cpuset-mems"}, "", "MEMs in which to allow execution (0-3, 0,1)")
+		flBlkioWeight   = cmd.Int64([]string{"-blkio-weight"}, 0, "Block IO (relative weight), between 10 and 1000")
+		flRestartPolicy = cmd.String([]string{"-restart"}, "", "Restart policy to apply when a container exits")
+	)
+	cmd.Require(flag.Min, 1)
+	if err := cmd.ParseFlags(args, true); err != nil {
+		return nil, nil, err
+	}
+
+	var flMemory int64
+	if *flMemoryString != "" {
+		parsedMemory, err := units.RAMInBytes(*flMemoryString)
+		if err != nil {
+			return nil, nil, err
+		}
+		flMemory = parsedMemory
+	}
+
+	var memorySwap int64
+	if *flMemorySwap != "" {
+		if *flMemorySwap == "-1" {
+			memorySwap = -1
+		} else {
+			parsedMemorySwap, err := units.RAMInBytes(*flMemorySwap)
+			if err != nil {
+				return nil, nil, err
+			}
+			memorySwap = parsedMemorySwap
+		}
+	}
+
+	restartPolicy, err := ParseRestartPolicy(*flRestartPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updateConfig := &UpdateConfig{
+		Memory:        flMemory,
+		MemorySwap:    memorySwap,
+		CpuShares:     *flCpuShares,
+		CpuPeriod:     *flCpuPeriod,
+		CpuQuota:      *flCpuQuota,
+		CpusetCpus:    *flCpusetCpus,
+		CpusetMems:    *flCpusetMems,
+		BlkioWeight:   *flBlkioWeight,
+		RestartPolicy: restartPolicy,
+	}
+	if updateConfig.IsEmpty() {
+		return nil, nil, fmt.Errorf("You must provide one or more flags when using this command.")
+	}
+	return updateConfig, cmd.Args(), nil
+}
@@ -0,0 +1,49 @@
+package runconfig
+
+import (
+	"io/ioutil"
+	"testing"
+
+	flag "github.com/docker/docker/pkg/mflag"
+)
+
+func parseUpdate(args []string) (*UpdateConfig, []string, error) {
+	cmd := flag.NewFlagSet("update", flag.ContinueOnError)
+	cmd.SetOutput(ioutil.Discard)
+	cmd.Usage = nil
+	return ParseUpdate(cmd, args)
+}
+
+func TestParseUpdate(t *testing.T) {
+	updateConfig, names, err := parseUpdate([]string{"-m", "512m", "--cpu-shares=512", "--restart=on-failure:3", "c1", "c2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 || names[0] != "c1" || names[1] != "c2" {
+		t.Fatalf("Unexpected containers %v", names)
+	}
+
+	hostConfig := &HostConfig{Memory: 1024, MemorySwap: -1, CpuShares: 1024, CpusetCpus: "0-1"}
+	updateConfig.Apply(hostConfig)
+	if hostConfig.Memory != 512*1024*1024 || hostConfig.CpuShares != 512 {
+		t.Fatalf("Expected the resources to be updated, got %+v", hostConfig)
+	}
+	if hostConfig.MemorySwap != -1 || hostConfig.CpusetCpus != "0-1" {
+		t.Fatalf("Expected the resources not given to be kept, got %+v", hostConfig)
+	}
+	if hostConfig.RestartPolicy.Name != "on-failure" || hostConfig.RestartPolicy.MaximumRetryCount != 3 {
+		t.Fatalf("Expected the restart policy to be updated, got %+v", hostConfig.RestartPolicy)
+	}
+}
+
+func TestParseUpdateInvalid(t *testing.T) {
+	if _, _, err := parseUpdate([]string{"c1"}); err == nil {
+		t.Fatal("Expected an error without flags")
+	}
+	if _, _, err := parseUpdate([]string{"--restart=sometimes", "c1"}); err == nil {
+		t.Fatal("Expected an error with an invalid restart policy")
+	}
+	if _, _, err := parseUpdate([]string{"-m", "lots", "c1"}); err == nil {
+		t.Fatal("Expected an error with an invalid memory limit")
+	}
+}
@@ -0,0 +1,73 @@
+package trust
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// ErrTargetNotFound is returned when the signing server has no signed
+// target for a tag.
+var ErrTargetNotFound = errors.New("no signed target")
+
+// Client talks to a signing server, which keeps the signed targets of the
+// repositories. The signed target of a tag is read with
+// GET /trust/v1/<name>/targets/<tag>, and written with a PUT of the signed
+// target to the same path. The paths are distinct from the registry APIs,
+// for a registry to serve them as well.
+type Client struct {
+	server string
+	client *http.Client
+}
+
+// NewClient returns a client of the signing server at the given URL, using
+// the transport to make its requests.
+func NewClient(server string, transport http.RoundTripper) *Client {
+	return &Client{
+		server: strings.TrimSuffix(server, "/"),
+		client: &http.Client{Transport: transport},
+	}
+}
+
+func (c *Client) targetURL(name, tag string) string {
+	return fmt.Sprintf("%s/trust/v1/%s/targets/%s", c.server, name, tag)
+}
+
+// GetTarget returns the signed target of the tag of the repository, or
+// ErrTargetNotFound.
+func (c *Client) GetTarget(name, tag string) ([]byte, error) {
+	res, err := c.client.Get(c.targetURL(name, tag))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrTargetNotFound
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error getting the signed target of %s:%s: server returned %d", name, tag, res.StatusCode)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// PutTarget sends the signed target of the tag of the repository to the
+// server.
+func (c *Client) PutTarget(name, tag string, signed []byte) error {
+	req, err := http.NewRequest("PUT", c.targetURL(name, tag), bytes.NewReader(signed))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("error putting the signed target of %s:%s: server returned %d", name, tag, res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/docker/distribution/digest"
+	"github.com/docker/libtrust"
+)
+
+// DefaultTargetExpiry is how long the signature of a target is valid.
+const DefaultTargetExpiry = 90 * 24 * time.Hour
+
+// A Target maps a tag of a repository to the digest of the manifest of the
+// image it refers to. Content trust resolves the tags to the digests of the
+// targets signed with the key of the repository.
+type Target struct {
+	// Name is the canonical name of the repository.
+	Name   string        `json:"name"`
+	Tag    string        `json:"tag"`
+	Digest digest.Digest `json:"digest"`
+	// Version is increased each time the tag is signed, for the clients to
+	// refuse the targets older than the ones they have seen.
+	Version int64 `json:"version"`
+	// Expires is when the signature of the target expires.
+	Expires time.Time `json:"expires"`
+}
+
+// SignTarget returns the target signed with the key, as a JSON web signature
+// in the pretty format of the signed manifests.
+func SignTarget(target *Target, key libtrust.PrivateKey) ([]byte, error) {
+	b, err := json.MarshalIndent(target, "", "   ")
+	if err != nil {
+		return nil, err
+	}
+	js, err := libtrust.NewJSONSignature(b)
+	if err != nil {
+		return nil, err
+	}
+	if err := js.Sign(key); err != nil {
+		return nil, err
+	}
+	return js.PrettySignature("signatures")
+}
+
+// VerifyTarget verifies the signatures of a signed target, and returns the
+// target and the keys which signed it. Whether the keys are trusted for the
+// repository is left to the caller.
+func VerifyTarget(signed []byte) (*Target, []libtrust.PublicKey, error) {
+	sig, err := libtrust.ParsePrettySignature(signed, "signatures")
+	if err != nil {
+		return nil, nil, fmt.Errorf("error parsing signed target: %s", err)
+	}
+	keys, err := sig.Verify()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error verifying signed target: %s", err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error retrieving signed target: %s", err)
+	}
+
+	var target Target
+	if err := json.Unmarshal(payload, &target); err != nil {
+		return nil, nil, fmt.Errorf("error unmarshalling signed target: %s", err)
+	}
+	if err := target.Digest.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid digest in signed target: %s", err)
+	}
+	if target.Version <= 0 {
+		return nil, nil, fmt.Errorf("invalid version in signed target: %d", target.Version)
+	}
+	return &target, keys, nil
+}
+
+// CheckTarget returns an error if the target expired, or if it would roll
+// the tag back from the previous target seen for it, if any: an older
+// version, or the same version signed for another digest.
+func CheckTarget(target, previous *Target) error {
+	if target.Expires.Before(time.Now()) {
+		return fmt.Errorf("trust data of %s:%s expired on %s", target.Name, target.Tag, target.Expires.Format(time.RFC3339))
+	}
+	if previous == nil {
+		return nil
+	}
+	if target.Version < previous.Version {
+		return fmt.Errorf("trust data of %s:%s is older than the data seen already: version %d, expected at least %d", target.Name, target.Tag, target.Version, previous.Version)
+	}
+	if target.Version == previous.Version && target.Digest != previous.Digest {
+		return fmt.Errorf("version %d of the trust data of %s:%s is signed for several digests", target.Version, target.Name, target.Tag)
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package trust
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/libtrust"
+)
+
+const testDigest = "sha256:2cf86b47e4e6c1b93d0d7e2ea9a3b0d4e8a7c2a3c9c4c0f2e7f0e8e3f3c1d2b4"
+
+func TestSignTarget(t *testing.T) {
+	key, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := &Target{Name: "example.com/foo/bar", Tag: "latest", Digest: testDigest, Version: 1, Expires: time.Now().Add(time.Hour).UTC().Truncate(time.Second)}
+	signed, err := SignTarget(target, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verified, keys, err := VerifyTarget(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *verified != *target {
+		t.Fatalf("Expected %+v, got %+v", target, verified)
+	}
+	if len(keys) != 1 || keys[0].KeyID() != key.KeyID() {
+		t.Fatalf("Expected the signing key %s, got %v", key.KeyID(), keys)
+	}
+
+	tampered := bytes.Replace(signed, []byte(`"latest"`), []byte(`"stable"`), 1)
+	if _, _, err := VerifyTarget(tampered); err == nil {
+		t.Fatal("Expected a tampered target to fail verification")
+	}
+
+	unversioned, err := SignTarget(&Target{Name: "example.com/foo/bar", Tag: "latest", Digest: testDigest}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := VerifyTarget(unversioned); err == nil {
+		t.Fatal("Expected a target without version to fail verification")
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	const otherDigest = "sha256:" + "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	expires := time.Now().Add(time.Hour)
+	target := &Target{Name: "example.com/foo/bar", Tag: "latest", Digest: testDigest, Version: 2, Expires: expires}
+
+	for _, previous := range []*Target{
+		nil,
+		{Digest: otherDigest, Version: 1},
+		{Digest: testDigest, Version: 2},
+	} {
+		if err := CheckTarget(target, previous); err != nil {
+			t.Fatalf("Expected the target to be accepted after %+v, got %v", previous, err)
+		}
+	}
+	for _, previous := range []*Target{
+		{Digest: testDigest, Version: 3},
+		{Digest: otherDigest, Version: 2},
+	} {
+		if err := CheckTarget(target, previous); err == nil {
+			t.Fatalf("Expected the target to be refused after %+v", previous)
+		}
+	}
+
+	expired := *target
+	expired.Expires = time.Now().Add(-time.Hour)
+	if err := CheckTarget(&expired, nil); err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("Expected an expired target to be refused, got %v", err)
+	}
+}
+
+// testServer is a signing server keeping the signed targets in memory.
+type testServer struct {
+	sync.Mutex
+	targets map[string][]byte
+}
+
+func (s *testServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/trust/v1/")
+	switch r.Method {
+	case "GET":
+		signed, exists := s.targets[path]
+		if !exists {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(signed)
+	case "PUT":
+		signed, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.targets[path] = signed
+		w.WriteHeader(http.StatusCreated)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestClient(t *testing.T) {
+	ts := &testServer{targets: make(map[string][]byte)}
+	server := httptest.NewServer(ts)
+	defer server.Close()
+
+	c := NewClient(server.URL+"/", nil)
+	if _, err := c.GetTarget("example.com/foo/bar", "latest"); err != ErrTargetNotFound {
+		t.Fatalf("Expected ErrTargetNotFound, got %v", err)
+	}
+	if err := c.PutTarget("example.com/foo/bar", "latest", []byte("signed")); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := ts.targets["example.com/foo/bar/targets/latest"]; !exists {
+		t.Fatalf("Target not stored at the expected path: %v", ts.targets)
+	}
+	signed, err := c.GetTarget("example.com/foo/bar", "latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(signed) != "signed" {
+		t.Fatalf("Expected the stored target, got %q", signed)
+	}
+}
@@ -0,0 +1,97 @@
+// Package drivers implements the volume driver plugins, the out-of-process
+// drivers the volumes can be created with instead of the local driver.
+package drivers
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// DefaultDriverName is the name of the driver of the volumes stored on the
+// host by the daemon itself.
+const DefaultDriverName = "local"
+
+// Driver is a volume driver. The volumes are identified by their name.
+type Driver interface {
+	// Name returns the name of the driver.
+	Name() string
+	// Create creates the volume with the given name.
+	Create(name string) error
+	// Remove removes the volume with the given name, and its data.
+	Remove(name string) error
+	// Mount makes the volume available on the host, and returns its
+	// mountpoint. It is called each time a container using the volume starts.
+	Mount(name string) (string, error)
+	// Unmount tells the driver that a container using the volume stopped.
+	Unmount(name string) error
+	// Path returns the mountpoint of the volume on the host.
+	Path(name string) (string, error)
+}
+
+var drivers = &driverExtpoint{extensions: make(map[string]Driver)}
+
+type driverExtpoint struct {
+	extensions map[string]Driver
+	sync.Mutex
+}
+
+func init() {
+	plugins.Handle("VolumeDriver", func(name string, client *plugins.Client) {
+		Register(NewVolumeDriver(name, client), name)
+	})
+}
+
+// Register makes the driver available under the given name. It returns
+// false if a driver is already registered with that name.
+func Register(driver Driver, name string) bool {
+	drivers.Lock()
+	defer drivers.Unlock()
+	if name == "" {
+		return false
+	}
+	if _, exists := drivers.extensions[name]; exists {
+		return false
+	}
+	drivers.extensions[name] = driver
+	return true
+}
+
+// Unregister removes the driver registered with the given name.
+func Unregister(name string) bool {
+	drivers.Lock()
+	defer drivers.Unlock()
+	if _, exists := drivers.extensions[name]; !exists {
+		return false
+	}
+	delete(drivers.extensions, name)
+	return true
+}
+
+// Lookup returns the driver with the given name. The drivers which are not
+// registered yet are looked up in the plugins.
+func Lookup(name string) (Driver, error) {
+	drivers.Lock()
+	ext, ok := drivers.extensions[name]
+	drivers.Unlock()
+	if ok {
+		return ext, nil
+	}
+
+	pl, err := plugins.Get(name, "VolumeDriver")
+	if err != nil {
+		return nil, fmt.Errorf("Error looking up volume plugin %s: %v", name, err)
+	}
+
+	// the driver is registered by the handler when the plugin is activated,
+	// unless it was already activated before for another interface
+	drivers.Lock()
+	defer drivers.Unlock()
+	if ext, ok := drivers.extensions[name]; ok {
+		return ext, nil
+	}
+	d := NewVolumeDriver(name, pl.Client)
+	drivers.extensions[name] = d
+	return d, nil
+}
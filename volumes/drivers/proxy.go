@@ -0,0 +1,70 @@
+package drivers
+
+import (
+	"errors"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// The requests and responses of the volume driver plugin protocol. Each
+// method of the driver is a POST to /VolumeDriver.<Method> with a JSON
+// volumeDriverRequest, answered with a JSON volumeDriverResponse whose Err is
+// set if the method failed.
+type volumeDriverRequest struct {
+	Name string
+}
+
+type volumeDriverResponse struct {
+	Mountpoint string `json:",omitempty"`
+	Err        string `json:",omitempty"`
+}
+
+// volumeDriverProxy is a Driver forwarding the calls to a plugin.
+type volumeDriverProxy struct {
+	name   string
+	client *plugins.Client
+}
+
+// NewVolumeDriver returns the driver of the plugin with the given name,
+// reached with client.
+func NewVolumeDriver(name string, client *plugins.Client) Driver {
+	return &volumeDriverProxy{name: name, client: client}
+}
+
+func (p *volumeDriverProxy) Name() string {
+	return p.name
+}
+
+func (p *volumeDriverProxy) call(method, name string) (string, error) {
+	var ret volumeDriverResponse
+	if err := p.client.Call("VolumeDriver."+method, volumeDriverRequest{Name: name}, &ret); err != nil {
+		return "", err
+	}
+	if ret.Err != "" {
+		return "", errors.New(ret.Err)
+	}
+	return ret.Mountpoint, nil
+}
+
+func (p *volumeDriverProxy) Create(name string) error {
+	_, err := p.call("Create", name)
+	return err
+}
+
+func (p *volumeDriverProxy) Remove(name string) error {
+	_, err := p.call("Remove", name)
+	return err
+}
+
+func (p *volumeDriverProxy) Mount(name string) (string, error) {
+	return p.call("Mount", name)
+}
+
+func (p *volumeDriverProxy) Unmount(name string) error {
+	_, err := p.call("Unmount", name)
+	return err
+}
+
+func (p *volumeDriverProxy) Path(name string) (string, error) {
+	return p.call("Path", name)
+}
@@ -0,0 +1,156 @@
+package drivers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/docker/docker/pkg/plugins"
+)
+
+// fakePlugin is a volume driver plugin keeping its volumes in a directory,
+// served on a unix socket.
+type fakePlugin struct {
+	sync.Mutex
+	root    string
+	volumes map[string]int // the number of mounts of each volume
+}
+
+func (p *fakePlugin) handle(w http.ResponseWriter, r *http.Request) {
+	var req volumeDriverRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	var resp volumeDriverResponse
+	mountpoint := filepath.Join(p.root, req.Name)
+	_, exists := p.volumes[req.Name]
+	switch method := strings.TrimPrefix(r.URL.Path, "/VolumeDriver."); {
+	case method == "Create":
+		if err := os.MkdirAll(mountpoint, 0755); err != nil {
+			resp.Err = err.Error()
+		}
+		p.volumes[req.Name] = 0
+	case !exists:
+		resp.Err = fmt.Sprintf("no such volume %s", req.Name)
+	case method == "Remove":
+		if p.volumes[req.Name] > 0 {
+			resp.Err = fmt.Sprintf("volume %s is mounted", req.Name)
+			break
+		}
+		delete(p.volumes, req.Name)
+		os.RemoveAll(mountpoint)
+	case method == "Mount":
+		p.volumes[req.Name]++
+		resp.Mountpoint = mountpoint
+	case method == "Unmount":
+		p.volumes[req.Name]--
+	case method == "Path":
+		resp.Mountpoint = mountpoint
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1+json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestVolumeDriverProxy(t *testing.T) {
+	tmp, err := ioutil.TempDir("", "docker-volume-plugin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+
+	l, err := net.Listen("unix", filepath.Join(tmp, "fake.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	plugin := &fakePlugin{root: filepath.Join(tmp, "volumes"), volumes: make(map[string]int)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", plugin.handle)
+	go http.Serve(l, mux)
+
+	d := NewVolumeDriver("fake", plugins.NewClient("unix://"+filepath.Join(tmp, "fake.sock")))
+	if d.Name() != "fake" {
+		t.Fatalf("expected the name of the driver to be fake, got %s", d.Name())
+	}
+
+	if err := d.Create("foo"); err != nil {
+		t.Fatal(err)
+	}
+	expected := filepath.Join(tmp, "volumes", "foo")
+	if fi, err := os.Stat(expected); err != nil || !fi.IsDir() {
+		t.Fatalf("expected the volume to be created in %s: %v", expected, err)
+	}
+
+	if p, err := d.Path("foo"); err != nil || p != expected {
+		t.Fatalf("expected the path %s, got %s, %v", expected, p, err)
+	}
+	if p, err := d.Mount("foo"); err != nil || p != expected {
+		t.Fatalf("expected the mountpoint %s, got %s, %v", expected, p, err)
+	}
+
+	// the errors of the plugin are returned
+	if err := d.Remove("foo"); err == nil || err.Error() != "volume foo is mounted" {
+		t.Fatalf("expected an error removing a mounted volume, got %v", err)
+	}
+	if _, err := d.Mount("bar"); err == nil || err.Error() != "no such volume bar" {
+		t.Fatalf("expected an error mounting a missing volume, got %v", err)
+	}
+
+	if err := d.Unmount("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove("foo"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(expected); !os.IsNotExist(err) {
+		t.Fatalf("expected the volume to be removed, got %v", err)
+	}
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Name() string                 { return "fake" }
+func (fakeDriver) Create(string) error          { return nil }
+func (fakeDriver) Remove(string) error          { return nil }
+func (fakeDriver) Mount(string) (string, error) { return "", nil }
+func (fakeDriver) Unmount(string) error         { return nil }
+func (fakeDriver) Path(string) (string, error)  { return "", nil }
+
+func TestRegister(t *testing.T) {
+	if !Register(fakeDriver{}, "fake") {
+		t.Fatal("expected the driver to be registered")
+	}
+	defer Unregister("fake")
+	if Register(fakeDriver{}, "fake") {
+		t.Fatal("expected the driver not to be registered twice")
+	}
+
+	d, err := Lookup("fake")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := d.(fakeDriver); !ok {
+		t.Fatalf("expected the registered driver, got %v", d)
+	}
+
+	if !Unregister("fake") {
+		t.Fatal("expected the driver to be unregistered")
+	}
+	if _, err := Lookup("fake"); err == nil {
+		t.Fatal("expected an error looking up an unregistered driver")
+	}
+}
@@ -5,13 +5,24 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"sync"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/docker/docker/daemon/graphdriver"
 	"github.com/docker/docker/pkg/stringid"
+	"github.com/docker/docker/volumes/drivers"
 )
 
+// validName is the format of the names of the volumes
+var validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// IsValidName returns whether name can be the name of a volume.
+func IsValidName(name string) bool {
+	return validName.MatchString(name)
+}
+
 type Repository struct {
 	configPath string
 	driver     graphdriver.Driver
@@ -40,6 +51,10 @@ func NewRepository(configPath string, driver graphdriver.Driver) (*Repository, e
 }
 
 func (r *Repository) newVolume(path string, writable bool) (*Volume, error) {
+	return r.newNamedVolume(path, "", "", nil, writable)
+}
+
+func (r *Repository) newNamedVolume(path, name, driverName string, labels map[string]string, writable bool) (*Volume, error) {
 	var (
 		isBindMount bool
 		err         error
@@ -48,8 +63,21 @@ func (r *Repository) newVolume(path string, writable bool) (*Volume, error) {
 	if path != "" {
 		isBindMount = true
 	}
+	if driverName == drivers.DefaultDriverName {
+		driverName = ""
+	}
 
-	if path == "" {
+	if path == "" && driverName != "" {
+		// the volumes of the drivers are known by the drivers by their
+		// display name, the volumes without name staying anonymous
+		driverVolumeName := name
+		if driverVolumeName == "" {
+			driverVolumeName = id
+		}
+		if path, err = r.createDriverVolume(driverVolumeName, driverName); err != nil {
+			return nil, err
+		}
+	} else if path == "" {
 		path, err = r.createNewVolumePath(id)
 		if err != nil {
 			return nil, err
@@ -65,6 +93,9 @@ func (r *Repository) newVolume(path string, writable bool) (*Volume, error) {
 
 	v := &Volume{
 		ID:          id,
+		Name:        name,
+		Labels:      labels,
+		Driver:      driverName,
 		Path:        path,
 		repository:  r,
 		Writable:    writable,
@@ -117,11 +148,16 @@ func (r *Repository) Get(path string) *Volume {
 }
 
 func (r *Repository) get(path string) *Volume {
-	path, err := filepath.EvalSymlinks(path)
+	evalPath, err := filepath.EvalSymlinks(path)
 	if err != nil {
+		// the mountpoints of the volumes of the drivers may only exist
+		// while they are mounted
+		if v := r.volumes[filepath.Clean(path)]; v != nil && v.Driver != "" {
+			return v
+		}
 		return nil
 	}
-	return r.volumes[filepath.Clean(path)]
+	return r.volumes[filepath.Clean(evalPath)]
 }
 
 func (r *Repository) add(volume *Volume) {
@@ -134,12 +170,11 @@ func (r *Repository) add(volume *Volume) {
 func (r *Repository) Delete(path string) error {
 	r.lock.Lock()
 	defer r.lock.Unlock()
-	path, err := filepath.EvalSymlinks(path)
-	if err != nil {
-		return err
-	}
-	volume := r.get(filepath.Clean(path))
+	volume := r.get(path)
 	if volume == nil {
+		if _, err := filepath.EvalSymlinks(path); err != nil {
+			return err
+		}
 		return fmt.Errorf("Volume %s does not exist", path)
 	}
 
@@ -148,11 +183,21 @@ func (r *Repository) Delete(path string) error {
 		return fmt.Errorf("Volume %s is being used and cannot be removed: used by containers %s", volume.Path, containers)
 	}
 
+	if volume.Driver != "" {
+		d, err := drivers.Lookup(volume.Driver)
+		if err != nil {
+			return err
+		}
+		if err := d.Remove(volume.DisplayName()); err != nil {
+			return fmt.Errorf("Error removing volume %s with driver %s: %v", volume.DisplayName(), volume.Driver, err)
+		}
+	}
+
 	if err := os.RemoveAll(volume.configPath); err != nil {
 		return err
 	}
 
-	if !volume.IsBindMount {
+	if !volume.IsBindMount && volume.Driver == "" {
 		if err := r.driver.Remove(volume.ID); err != nil {
 			if !os.IsNotExist(err) {
 				return err
@@ -177,6 +222,26 @@ func (r *Repository) createNewVolumePath(id string) (string, error) {
 	return path, nil
 }
 
+// createDriverVolume creates the volume with the given name with the volume
+// driver, and returns its mountpoint.
+func (r *Repository) createDriverVolume(name, driverName string) (string, error) {
+	d, err := drivers.Lookup(driverName)
+	if err != nil {
+		return "", err
+	}
+	if err := d.Create(name); err != nil {
+		return "", fmt.Errorf("Error creating volume %s with driver %s: %v", name, driverName, err)
+	}
+	path, err := d.Path(name)
+	if err != nil {
+		return "", fmt.Errorf("Error getting the path of volume %s with driver %s: %v", name, driverName, err)
+	}
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("Volume driver %s returned an invalid path for volume %s: %q", driverName, name, path)
+	}
+	return path, nil
+}
+
 func (r *Repository) FindOrCreateVolume(path string, writable bool) (*Volume, error) {
 	r.lock.Lock()
 	defer r.lock.Unlock()
@@ -191,3 +256,94 @@ func (r *Repository) FindOrCreateVolume(path string, writable bool) (*Volume, er
 
 	return r.newVolume(path, writable)
 }
+
+// Create creates a volume with the given name and labels, with the given
+// volume driver, or the local driver if driverName is empty. A volume without
+// name is known by its ID. It fails if a volume with the same name already
+// exists.
+func (r *Repository) Create(name, driverName string, labels map[string]string) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if name != "" {
+		if !IsValidName(name) {
+			return nil, fmt.Errorf("Invalid volume name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+		}
+		if r.getByName(name) != nil {
+			return nil, fmt.Errorf("Conflict. A volume named %s already exists", name)
+		}
+	}
+	return r.newNamedVolume("", name, driverName, labels, true)
+}
+
+// FindOrCreateNamedVolume returns the volume with the given name or ID,
+// creating a volume with the given name and volume driver if there is none.
+func (r *Repository) FindOrCreateNamedVolume(name, driverName string, writable bool) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if v := r.getByNameOrID(name); v != nil {
+		return v, nil
+	}
+	if !IsValidName(name) {
+		return nil, fmt.Errorf("Invalid volume name %q, only [a-zA-Z0-9][a-zA-Z0-9_.-] are allowed", name)
+	}
+	return r.newNamedVolume("", name, driverName, nil, writable)
+}
+
+// Lookup returns the volume with the given name or ID. The bind mounts are
+// not looked up.
+func (r *Repository) Lookup(nameOrID string) (*Volume, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if v := r.getByNameOrID(nameOrID); v != nil {
+		return v, nil
+	}
+	return nil, fmt.Errorf("No such volume: %s", nameOrID)
+}
+
+// getByNameOrID returns the volume with the given name, or else the volume
+// with the given ID.
+func (r *Repository) getByNameOrID(nameOrID string) *Volume {
+	if v := r.getByName(nameOrID); v != nil {
+		return v
+	}
+	for _, v := range r.volumes {
+		if !v.IsBindMount && v.ID == nameOrID {
+			return v
+		}
+	}
+	return nil
+}
+
+func (r *Repository) getByName(name string) *Volume {
+	for _, v := range r.volumes {
+		if !v.IsBindMount && v.Name != "" && v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// List returns the volumes of the repository, sorted by name, without the
+// bind mounts.
+func (r *Repository) List() []*Volume {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var volumes []*Volume
+	for _, v := range r.volumes {
+		if !v.IsBindMount {
+			volumes = append(volumes, v)
+		}
+	}
+	sort.Sort(byName(volumes))
+	return volumes
+}
+
+type byName []*Volume
+
+func (s byName) Len() int           { return len(s) }
+func (s byName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byName) Less(i, j int) bool { return s[i].DisplayName() < s[j].DisplayName() }
@@ -4,10 +4,13 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/docker/docker/daemon/graphdriver"
 	_ "github.com/docker/docker/daemon/graphdriver/vfs"
+	"github.com/docker/docker/volumes/drivers"
 )
 
 func TestRepositoryFindOrCreate(t *testing.T) {
@@ -152,6 +155,204 @@ func TestRepositoryDelete(t *testing.T) {
 
 }
 
+func TestRepositoryNamedVolumes(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := repo.Create("foo", "", map[string]string{"com.example.key": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Name != "foo" || v.Labels["com.example.key"] != "value" {
+		t.Fatalf("unexpected volume %v", v)
+	}
+
+	if _, err := repo.Create("foo", "", nil); err == nil {
+		t.Fatal("expected an error creating a volume with the same name")
+	}
+	if _, err := repo.Create("/foo", "", nil); err == nil {
+		t.Fatal("expected an error creating a volume with an invalid name")
+	}
+
+	v2, err := repo.FindOrCreateNamedVolume("foo", "", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2 != v {
+		t.Fatal("expected the existing volume to be returned")
+	}
+
+	if v2, err = repo.Lookup("foo"); err != nil || v2 != v {
+		t.Fatalf("expected to find the volume by name, got %v, %v", v2, err)
+	}
+	if v2, err = repo.Lookup(v.ID); err != nil || v2 != v {
+		t.Fatalf("expected to find the volume by ID, got %v, %v", v2, err)
+	}
+	if _, err := repo.Lookup("bar"); err == nil {
+		t.Fatal("expected an error looking up a missing volume")
+	}
+
+	anonymous, err := repo.Create("", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anonymous.Name != "" || anonymous.DisplayName() != anonymous.ID {
+		t.Fatalf("expected the volume without name to be known by its ID, got %v", anonymous)
+	}
+	// the volumes are found by ID too
+	if v2, err = repo.FindOrCreateNamedVolume(anonymous.ID, "", true); err != nil || v2 != anonymous {
+		t.Fatalf("expected to find the volume by ID, got %v, %v", v2, err)
+	}
+	if _, err := repo.Create("x", "", nil); err != nil {
+		t.Fatalf("expected a one character name to be valid: %v", err)
+	}
+	if _, err := repo.FindOrCreateVolume(filepath.Join(root, "bind"), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FindOrCreateNamedVolume("bar", "local", true); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, v := range repo.List() {
+		names = append(names, v.DisplayName())
+	}
+	expected := []string{anonymous.ID, "bar", "foo", "x"}
+	sort.Strings(expected)
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+
+	// the names are restored with the volumes
+	repo, err = newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2, err = repo.Lookup("foo"); err != nil || v2.ID != v.ID {
+		t.Fatalf("expected to find the restored volume, got %v, %v", v2, err)
+	}
+}
+
+// fakeDriver is a volume driver keeping its volumes in a directory.
+type fakeDriver struct {
+	root    string
+	mounted map[string]int
+}
+
+func (d *fakeDriver) Name() string { return "fake" }
+
+func (d *fakeDriver) Create(name string) error {
+	d.mounted[name] = 0
+	return os.MkdirAll(filepath.Join(d.root, name), 0755)
+}
+
+func (d *fakeDriver) Remove(name string) error {
+	delete(d.mounted, name)
+	return os.RemoveAll(filepath.Join(d.root, name))
+}
+
+func (d *fakeDriver) Mount(name string) (string, error) {
+	d.mounted[name]++
+	return filepath.Join(d.root, name), nil
+}
+
+func (d *fakeDriver) Unmount(name string) error {
+	d.mounted[name]--
+	return nil
+}
+
+func (d *fakeDriver) Path(name string) (string, error) {
+	return filepath.Join(d.root, name), nil
+}
+
+func TestRepositoryDriverVolumes(t *testing.T) {
+	root, err := ioutil.TempDir(os.TempDir(), "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	repo, err := newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := &fakeDriver{root: filepath.Join(root, "fake"), mounted: make(map[string]int)}
+	drivers.Register(d, "fake")
+	defer drivers.Unregister("fake")
+
+	if _, err := repo.Create("foo", "missing", nil); err == nil {
+		t.Fatal("expected an error creating a volume with a missing driver")
+	}
+
+	v, err := repo.FindOrCreateNamedVolume("foo", "fake", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.DriverName() != "fake" || v.Path != filepath.Join(d.root, "foo") {
+		t.Fatalf("unexpected volume %v", v)
+	}
+	if _, exists := d.mounted["foo"]; !exists {
+		t.Fatal("expected the volume to be created by the driver")
+	}
+
+	if err := v.Mount(); err != nil {
+		t.Fatal(err)
+	}
+	if d.mounted["foo"] != 1 {
+		t.Fatal("expected the volume to be mounted by the driver")
+	}
+	if err := v.Unmount(); err != nil {
+		t.Fatal(err)
+	}
+	if d.mounted["foo"] != 0 {
+		t.Fatal("expected the volume to be unmounted by the driver")
+	}
+
+	// the volumes are found by path even if their mountpoint is missing
+	os.RemoveAll(v.Path)
+	if v2 := repo.Get(v.Path); v2 != v {
+		t.Fatalf("expected to find the volume by path, got %v", v2)
+	}
+
+	// the driver is restored with the volume
+	repo, err = newRepo(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err = repo.Lookup("foo"); err != nil || v.Driver != "fake" {
+		t.Fatalf("expected to find the restored volume, got %v, %v", v, err)
+	}
+
+	if err := repo.Delete(v.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := d.mounted["foo"]; exists {
+		t.Fatal("expected the volume to be removed by the driver")
+	}
+
+	// the volumes without name stay anonymous, the driver knowing them by ID
+	anonymous, err := repo.Create("", "fake", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if anonymous.Name != "" || anonymous.Path != filepath.Join(d.root, anonymous.ID) {
+		t.Fatalf("unexpected volume %v", anonymous)
+	}
+	if err := repo.Delete(anonymous.Path); err != nil {
+		t.Fatal(err)
+	}
+	if _, exists := d.mounted[anonymous.ID]; exists {
+		t.Fatal("expected the volume to be removed by the driver")
+	}
+}
+
 func newRepo(root string) (*Repository, error) {
 	configPath := filepath.Join(root, "repo-config")
 	graphDir := filepath.Join(root, "repo-graph")
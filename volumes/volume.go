@@ -2,15 +2,20 @@ package volumes
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
 
 	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/volumes/drivers"
 )
 
 type Volume struct {
 	ID          string
+	Name        string // set for the volumes created with a name, which are kept when their containers are removed
+	Labels      map[string]string
+	Driver      string // the volume driver plugin managing the volume, empty for the local volumes
 	Path        string
 	IsBindMount bool
 	Writable    bool
@@ -20,6 +25,56 @@ type Volume struct {
 	lock        sync.Mutex
 }
 
+// DisplayName returns the name of the volume, or its ID if it has no name.
+func (v *Volume) DisplayName() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return v.ID
+}
+
+// DriverName returns the name of the driver of the volume.
+func (v *Volume) DriverName() string {
+	if v.Driver != "" {
+		return v.Driver
+	}
+	return drivers.DefaultDriverName
+}
+
+// Mount makes the volume available at its path on the host, before a
+// container using it starts. It is a no-op for the local volumes.
+func (v *Volume) Mount() error {
+	if v.Driver == "" {
+		return nil
+	}
+	d, err := drivers.Lookup(v.Driver)
+	if err != nil {
+		return err
+	}
+	mountpoint, err := d.Mount(v.DisplayName())
+	if err != nil {
+		return fmt.Errorf("Error mounting volume %s with driver %s: %v", v.DisplayName(), v.Driver, err)
+	}
+	if filepath.Clean(mountpoint) != v.Path {
+		d.Unmount(v.DisplayName())
+		return fmt.Errorf("Volume driver %s mounted volume %s at %s instead of %s", v.Driver, v.DisplayName(), mountpoint, v.Path)
+	}
+	return nil
+}
+
+// Unmount tells the driver of the volume that a container using it stopped.
+// It is a no-op for the local volumes.
+func (v *Volume) Unmount() error {
+	if v.Driver == "" {
+		return nil
+	}
+	d, err := drivers.Lookup(v.Driver)
+	if err != nil {
+		return err
+	}
+	return d.Unmount(v.DisplayName())
+}
+
 func (v *Volume) IsDir() (bool, error) {
 	stat, err := os.Stat(v.Path)
 	if err != nil {
@@ -57,12 +112,15 @@ func (v *Volume) initialize() error {
 	v.lock.Lock()
 	defer v.lock.Unlock()
 
-	if _, err := os.Stat(v.Path); err != nil {
-		if !os.IsNotExist(err) {
-			return err
-		}
-		if err := os.MkdirAll(v.Path, 0755); err != nil {
-			return err
+	// the path of the volumes of the drivers is managed by the driver
+	if v.Driver == "" {
+		if _, err := os.Stat(v.Path); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			if err := os.MkdirAll(v.Path, 0755); err != nil {
+				return err
+			}
 		}
 	}
 